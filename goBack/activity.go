@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ActivityItem is one entry of the "Recent activity" feed
+type ActivityItem struct {
+	Action    string    `json:"action"`
+	EmpID     int       `json:"emp_id"`
+	Actor     string    `json:"actor"`
+	Timestamp time.Time `json:"timestamp"`
+	Summary   string    `json:"summary"`
+}
+
+// activitySummary renders an audit entry as a human readable line
+func activitySummary(e AuditEntry) string {
+	// details come back from Mongo as an ordered bson.D
+	d := bson.M{}
+	if doc, ok := e.Details.(bson.D); ok {
+		for _, el := range doc {
+			d[el.Key] = el.Value
+		}
+	}
+	switch e.Action {
+	case "create":
+		return fmt.Sprintf("%s created employee %d (%v)", e.Actor, e.EmpID, d["emp_name"])
+	case "update":
+		return fmt.Sprintf("%s updated employee %d", e.Actor, e.EmpID)
+	case "delete":
+		return fmt.Sprintf("%s deleted employee %d", e.Actor, e.EmpID)
+	case "deactivate":
+		return fmt.Sprintf("%s deactivated employee %d", e.Actor, e.EmpID)
+	case "purge":
+		return fmt.Sprintf("%s permanently deleted employee %d", e.Actor, e.EmpID)
+	case "terminate":
+		return fmt.Sprintf("%s terminated employee %d (%v)", e.Actor, e.EmpID, d["reason"])
+	case "tags":
+		if d["removed"] != nil {
+			return fmt.Sprintf("%s removed tag %v from employee %d", e.Actor, d["removed"], e.EmpID)
+		}
+		return fmt.Sprintf("%s tagged employee %d with %v", e.Actor, e.EmpID, d["added"])
+	case "photo":
+		return fmt.Sprintf("%s uploaded a photo of employee %d", e.Actor, e.EmpID)
+	case "photo_removed":
+		return fmt.Sprintf("%s removed the photo of employee %d", e.Actor, e.EmpID)
+	case "transfer":
+		return fmt.Sprintf("%s moved employee %d from %v to %v", e.Actor, e.EmpID, d["from_department"], d["to_department"])
+	case "merge":
+		return fmt.Sprintf("%s merged employee %v into %d", e.Actor, d["duplicate_id"], e.EmpID)
+	case "change_requested":
+		return fmt.Sprintf("%s requested approval to %v employee %d", e.Actor, d["kind"], e.EmpID)
+	case "change_approved", "change_rejected":
+		verb := "approved"
+		if e.Action == "change_rejected" {
+			verb = "rejected"
+		}
+		return fmt.Sprintf("%s %s %v's %v of employee %d", e.Actor, verb, d["requested_by"], d["kind"], e.EmpID)
+	}
+	return fmt.Sprintf("%s: %s on employee %d", e.Actor, e.Action, e.EmpID)
+}
+
+// activityHandler handles GET /api/activity?emp_id=&actor=&page=&limit=, newest first
+func activityHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	filter := bson.M{}
+	if v := q.Get("emp_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			httpError(w, "invalid emp_id", http.StatusBadRequest)
+			return
+		}
+		filter["emp_id"] = id
+	}
+	if v := q.Get("actor"); v != "" {
+		filter["actor"] = v
+	}
+	page, limit := 1, 20
+	if v := q.Get("page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			httpError(w, "invalid page", http.StatusBadRequest)
+			return
+		}
+		page = n
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 100 {
+			httpError(w, "limit must be between 1 and 100", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	ctx := r.Context()
+
+	total, err := coll(ctx, "AuditLog").CountDocuments(ctx, filter)
+	if err != nil {
+		storeError(w, "count activity", err)
+		return
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "timestamp", Value: -1}}).
+		SetSkip(int64((page - 1) * limit)).
+		SetLimit(int64(limit))
+	cur, err := coll(ctx, "AuditLog").Find(ctx, filter, opts)
+	if err != nil {
+		storeError(w, "find activity", err)
+		return
+	}
+	defer cur.Close(ctx)
+	var entries []AuditEntry
+	if err := cur.All(ctx, &entries); err != nil {
+		storeError(w, "cursor all", err)
+		return
+	}
+
+	items := make([]ActivityItem, 0, len(entries))
+	for _, e := range entries {
+		items = append(items, ActivityItem{
+			Action:    e.Action,
+			EmpID:     e.EmpID,
+			Actor:     e.Actor,
+			Timestamp: e.Timestamp,
+			Summary:   activitySummary(e),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(bson.M{"items": items, "page": page, "limit": limit, "total": total})
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/karthikeyan-meenachisundaram/goBack/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// API keys let scripts call the API without a user's login: X-API-Key stands in for the
+// bearer token and acts with the role of its scope. A key reads
+// "gbk_<org id>_<64 hex characters>", so it names the organization whose ApiKeys
+// collection has it; only its SHA-256 is stored (the key is random, so a slow password
+// hash would add nothing) and the key itself is only shown when it is issued.
+
+const (
+	apiKeyHeader = "X-API-Key"
+	apiKeyPrefix = "gbk_"
+	// apiKeyCacheTTL is how long a verified key is used without asking the database
+	// again; a key revoked on another instance keeps working here for up to that long
+	apiKeyCacheTTL      = time.Minute
+	maxAPIKeyNameLength = 100
+)
+
+// apiKeyScopes maps the scopes of a key to the role it acts with
+var apiKeyScopes = map[string]string{
+	"read-only":  "viewer",
+	"read-write": "editor",
+}
+
+// APIKey is an issued key in the ApiKeys collection
+type APIKey struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name       string             `bson:"name" json:"name"`
+	Scope      string             `bson:"scope" json:"scope"`
+	Prefix     string             `bson:"prefix" json:"prefix"` // the start of the key, to recognise it by
+	Hash       string             `bson:"key_hash" json:"-"`
+	Active     bool               `bson:"active" json:"active"`
+	CreatedBy  string             `bson:"created_by" json:"created_by"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+	ExpiresAt  *time.Time         `bson:"expires_at,omitempty" json:"expires_at,omitempty"`
+	LastUsedAt *time.Time         `bson:"last_used_at,omitempty" json:"last_used_at,omitempty"` // updated at most once per apiKeyCacheTTL and instance
+	RevokedBy  string             `bson:"revoked_by,omitempty" json:"revoked_by,omitempty"`
+	RevokedAt  *time.Time         `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+	Key        string             `bson:"-" json:"key,omitempty"` // only in the response to the issue
+}
+
+// apiKeyStore keeps the issued keys of the request's organization
+type apiKeyStore interface {
+	// List returns the active keys, oldest first, and the revoked ones too when revoked
+	List(ctx context.Context, revoked bool) ([]APIKey, error)
+	// Get returns the key with id; models.ErrNotFound when there is none
+	Get(ctx context.Context, id primitive.ObjectID) (APIKey, error)
+	// Active returns the active key whose hash is hash; models.ErrNotFound when there is none
+	Active(ctx context.Context, hash string) (APIKey, error)
+	// Issue stores k and sets its ID; errAPIKeyNameTaken when an active key has its name
+	Issue(ctx context.Context, k *APIKey) error
+	// Used records that the key with id was used at t
+	Used(ctx context.Context, id primitive.ObjectID, t time.Time) error
+	// Revoke deactivates the key with id, as revoked by actor at t
+	Revoke(ctx context.Context, id primitive.ObjectID, actor string, t time.Time) error
+}
+
+// apiKeys are the ApiKeys collection; serveMemory swaps in memoryAPIKeys
+var apiKeys apiKeyStore = mongoAPIKeys{}
+
+// apiKeyCache holds the claims of recently verified keys by hash
+var apiKeyCache = struct {
+	sync.Mutex
+	keys map[string]cachedAPIKey
+}{keys: map[string]cachedAPIKey{}}
+
+type cachedAPIKey struct {
+	claims  *tokenClaims
+	expires time.Time
+}
+
+var (
+	// errInvalidAPIKey is any key that is malformed, unknown, revoked or expired
+	errInvalidAPIKey = errors.New("invalid API key")
+	// errAPIKeyNameTaken is an issue under the name of an active key
+	errAPIKeyNameTaken = errors.New("an active API key has this name")
+)
+
+// newAPIKey returns a fresh key for organization org
+func newAPIKey(org string) string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return apiKeyPrefix + org + "_" + hex.EncodeToString(b)
+}
+
+// hashAPIKey is the stored form of key
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// apiKeyOrg is the organization key names, or false when it is not shaped like a key
+func apiKeyOrg(key string) (string, bool) {
+	rest, ok := strings.CutPrefix(key, apiKeyPrefix)
+	if !ok {
+		return "", false
+	}
+	org, secret, ok := strings.Cut(rest, "_")
+	if !ok || !orgIDPattern.MatchString(org) || len(secret) != 64 {
+		return "", false
+	}
+	return org, true
+}
+
+// cachedAPIKeyClaims are the claims of key if it was verified lately
+func cachedAPIKeyClaims(key string) (*tokenClaims, bool) {
+	hash := hashAPIKey(key)
+	apiKeyCache.Lock()
+	defer apiKeyCache.Unlock()
+	c, ok := apiKeyCache.keys[hash]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(c.expires) {
+		delete(apiKeyCache.keys, hash)
+		return nil, false
+	}
+	return c.claims, true
+}
+
+// apiKeyClaims verifies key and returns claims standing in for an access token's: the
+// key's organization, the role of its scope and "apikey/<name>" as subject (user names
+// cannot contain a slash, so the audit log tells keys and users apart). It returns
+// errInvalidAPIKey for keys that don't grant access and store errors as they are.
+func apiKeyClaims(ctx context.Context, key string) (*tokenClaims, error) {
+	if claims, ok := cachedAPIKeyClaims(key); ok {
+		return claims, nil
+	}
+	org, ok := apiKeyOrg(key)
+	if !ok {
+		return nil, errInvalidAPIKey
+	}
+	if ok, err := orgExists(ctx, org); err != nil || !ok {
+		if err == nil {
+			err = errInvalidAPIKey
+		}
+		return nil, err
+	}
+	ctx = withOrg(ctx, org)
+	hash := hashAPIKey(key)
+	k, err := apiKeys.Active(ctx, hash)
+	if err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			return nil, errInvalidAPIKey
+		}
+		return nil, err
+	}
+	now := time.Now()
+	if k.ExpiresAt != nil && !now.Before(*k.ExpiresAt) {
+		return nil, errInvalidAPIKey
+	}
+	if err := apiKeys.Used(ctx, k.ID, now.UTC()); err != nil {
+		logFor(ctx).Warn("api keys: record use", "key", k.Prefix, "err", err)
+	}
+
+	claims := &tokenClaims{
+		Type: "access",
+		Role: apiKeyScopes[k.Scope],
+		Org:  org,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:  "apikey/" + k.Name,
+			IssuedAt: jwt.NewNumericDate(k.CreatedAt),
+		},
+	}
+	expires := now.Add(apiKeyCacheTTL)
+	if k.ExpiresAt != nil && k.ExpiresAt.Before(expires) {
+		expires = *k.ExpiresAt
+	}
+	apiKeyCache.Lock()
+	apiKeyCache.keys[hash] = cachedAPIKey{claims: claims, expires: expires}
+	apiKeyCache.Unlock()
+	return claims, nil
+}
+
+// forgetAPIKey drops a revoked key from this instance's cache
+func forgetAPIKey(hash string) {
+	apiKeyCache.Lock()
+	delete(apiKeyCache.keys, hash)
+	apiKeyCache.Unlock()
+}
+
+// ---------------- Handlers ----------------
+
+// apiKeysHandler handles GET (list) and POST (issue {name, scope, expires_at}) on
+// /api/admin/api-keys (admin). The key is only in the response to the POST.
+func apiKeysHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	ctx := r.Context()
+
+	switch r.Method {
+	case http.MethodGet:
+		list, err := apiKeys.List(ctx, r.URL.Query().Get("revoked") == "true")
+		if err != nil {
+			storeError(w, "find api keys", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(list)
+	case http.MethodPost:
+		var input struct {
+			Name      string     `json:"name"`
+			Scope     string     `json:"scope"`
+			ExpiresAt *time.Time `json:"expires_at"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			httpError(w, "invalid input: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		errs := map[string]string{}
+		input.Name = strings.TrimSpace(input.Name)
+		if input.Name == "" || len(input.Name) > maxAPIKeyNameLength {
+			errs["name"] = "is required and at most 100 characters"
+		}
+		if input.Scope == "" {
+			input.Scope = "read-only"
+		}
+		if _, ok := apiKeyScopes[input.Scope]; !ok {
+			errs["scope"] = "must be read-only or read-write"
+		}
+		if input.ExpiresAt != nil && !input.ExpiresAt.After(time.Now()) {
+			errs["expires_at"] = "must be in the future"
+		}
+		if len(errs) > 0 {
+			writeError(w, http.StatusUnprocessableEntity, "validation failed", errs)
+			return
+		}
+
+		key := newAPIKey(orgOf(ctx))
+		k := APIKey{
+			Name:      input.Name,
+			Scope:     input.Scope,
+			Prefix:    key[:len(key)-56],
+			Hash:      hashAPIKey(key),
+			Active:    true,
+			CreatedBy: actorFromRequest(r),
+			CreatedAt: time.Now().UTC(),
+		}
+		if input.ExpiresAt != nil {
+			t := input.ExpiresAt.UTC()
+			k.ExpiresAt = &t
+		}
+		if err := apiKeys.Issue(ctx, &k); err != nil {
+			if errors.Is(err, errAPIKeyNameTaken) {
+				httpError(w, "an active API key is already named "+input.Name, http.StatusConflict)
+				return
+			}
+			storeError(w, "insert api key", err)
+			return
+		}
+		k.Key = key
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(k)
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// apiKeyByIDHandler handles GET and DELETE (revoke) on /api/admin/api-keys/{id} (admin).
+// A revoked key stays listed with ?revoked=true, for the record.
+func apiKeyByIDHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	id, err := primitive.ObjectIDFromHex(strings.TrimPrefix(r.URL.Path, "/api/admin/api-keys/"))
+	if err != nil {
+		httpError(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	ctx := r.Context()
+
+	k, err := apiKeys.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			httpError(w, "API key not found", http.StatusNotFound)
+			return
+		}
+		storeError(w, "find api key", err)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(k)
+	case http.MethodDelete:
+		if !k.Active {
+			httpError(w, "API key is already revoked", http.StatusConflict)
+			return
+		}
+		if err := apiKeys.Revoke(ctx, id, actorFromRequest(r), time.Now().UTC()); err != nil {
+			storeError(w, "revoke api key", err)
+			return
+		}
+		forgetAPIKey(k.Hash)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(bson.M{"message": "API key revoked"})
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// ---------------- Stores ----------------
+
+// mongoAPIKeys keeps the keys in the ApiKeys collection
+type mongoAPIKeys struct{}
+
+func (mongoAPIKeys) List(ctx context.Context, revoked bool) ([]APIKey, error) {
+	filter := bson.M{}
+	if !revoked {
+		filter["active"] = true
+	}
+	cur, err := coll(ctx, "ApiKeys").Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	list := []APIKey{}
+	err = cur.All(ctx, &list)
+	return list, err
+}
+
+func (mongoAPIKeys) Get(ctx context.Context, id primitive.ObjectID) (APIKey, error) {
+	return findAPIKey(ctx, bson.M{"_id": id})
+}
+
+func (mongoAPIKeys) Active(ctx context.Context, hash string) (APIKey, error) {
+	return findAPIKey(ctx, bson.M{"key_hash": hash, "active": true})
+}
+
+func (mongoAPIKeys) Issue(ctx context.Context, k *APIKey) error {
+	res, err := coll(ctx, "ApiKeys").InsertOne(ctx, k)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return errAPIKeyNameTaken
+		}
+		return err
+	}
+	k.ID, _ = res.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (mongoAPIKeys) Used(ctx context.Context, id primitive.ObjectID, t time.Time) error {
+	_, err := coll(ctx, "ApiKeys").UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"last_used_at": t}})
+	return err
+}
+
+func (mongoAPIKeys) Revoke(ctx context.Context, id primitive.ObjectID, actor string, t time.Time) error {
+	_, err := coll(ctx, "ApiKeys").UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{
+		"active":     false,
+		"revoked_by": actor,
+		"revoked_at": t,
+	}})
+	return err
+}
+
+// findAPIKey is the key matching filter; models.ErrNotFound when there is none
+func findAPIKey(ctx context.Context, filter bson.M) (APIKey, error) {
+	var k APIKey
+	err := coll(ctx, "ApiKeys").FindOne(ctx, filter).Decode(&k)
+	if err == mongo.ErrNoDocuments {
+		err = models.ErrNotFound
+	}
+	return k, err
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// issueTestKey stores an active key of org with scope and returns it
+func issueTestKey(t *testing.T, s *testStores, org, name, scope string, expires *time.Time) string {
+	t.Helper()
+	key := newAPIKey(org)
+	k := APIKey{Name: name, Scope: scope, Prefix: key[:len(key)-56], Hash: hashAPIKey(key), Active: true,
+		CreatedBy: "admin", CreatedAt: time.Now().UTC(), ExpiresAt: expires}
+	if err := s.apiKeys.Issue(t.Context(), &k); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { forgetAPIKey(k.Hash) })
+	return key
+}
+
+func TestAPIKeyClaims(t *testing.T) {
+	s := useBootstrapAccount(t)
+	useOrg(t, "acme")
+	past := time.Now().Add(-time.Minute)
+
+	readOnly := issueTestKey(t, s, defaultOrg, "ci", "read-only", nil)
+	readWrite := issueTestKey(t, s, defaultOrg, "sync", "read-write", nil)
+	expired := issueTestKey(t, s, defaultOrg, "old", "read-only", &past)
+	acme := issueTestKey(t, s, "acme", "acme-ci", "read-only", nil)
+	revoked := issueTestKey(t, s, defaultOrg, "gone", "read-only", nil)
+	list, _ := s.apiKeys.List(t.Context(), false)
+	if err := s.apiKeys.Revoke(t.Context(), list[len(list)-1].ID, "admin", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	// a stray organization can't be put into someone else's key
+	relabeled := strings.Replace(readOnly, apiKeyPrefix+defaultOrg+"_", apiKeyPrefix+"acme_", 1)
+
+	tests := []struct {
+		name, key, org string // org is the X-Org-ID header
+		method         string
+		status         int
+	}{
+		{"read-only", readOnly, "", http.MethodGet, http.StatusOK},
+		{"read-only POST", readOnly, "", http.MethodPost, http.StatusForbidden},
+		{"read-write POST", readWrite, "", http.MethodPost, http.StatusOK},
+		{"read-write DELETE", readWrite, "", http.MethodDelete, http.StatusForbidden},
+		{"own organization", readOnly, defaultOrg, http.MethodGet, http.StatusOK},
+		{"other organization", acme, defaultOrg, http.MethodGet, http.StatusForbidden},
+		{"other organization's key", readOnly, "acme", http.MethodGet, http.StatusForbidden},
+		{"relabeled", relabeled, "", http.MethodGet, http.StatusUnauthorized},
+		{"revoked", revoked, "", http.MethodGet, http.StatusUnauthorized},
+		{"expired", expired, "", http.MethodGet, http.StatusUnauthorized},
+		{"no prefix", strings.TrimPrefix(readOnly, apiKeyPrefix), "", http.MethodGet, http.StatusUnauthorized},
+		{"short", readOnly[:len(readOnly)-1], "", http.MethodGet, http.StatusUnauthorized},
+		{"no organization", apiKeyPrefix + strings.Repeat("a", 64), "", http.MethodGet, http.StatusUnauthorized},
+		{"bad organization", apiKeyPrefix + "Acme!_" + strings.Repeat("a", 64), "", http.MethodGet, http.StatusUnauthorized},
+		{"unknown organization", newAPIKey("globex"), "", http.MethodGet, http.StatusUnauthorized},
+		{"unknown key", newAPIKey(defaultOrg), "", http.MethodGet, http.StatusUnauthorized},
+	}
+	var org string
+	h := authenticate(tenant(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { org = orgOf(r.Context()) })))
+	for _, tt := range tests {
+		org = ""
+		r := request(tt.method, "/api/employees/1", "", "", "")
+		r.Header.Set(apiKeyHeader, tt.key)
+		if tt.org != "" {
+			r.Header.Set(orgHeader, tt.org)
+		}
+		w := record(h.ServeHTTP, r)
+		if w.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d (%s)", tt.name, w.Code, tt.status, w.Body.String())
+		}
+		if tt.status == http.StatusOK && org != defaultOrg {
+			t.Errorf("%s: acted for organization %q", tt.name, org)
+		}
+	}
+
+	claims, err := apiKeyClaims(t.Context(), acme)
+	if err != nil || claims.Org != "acme" || claims.Subject != "apikey/acme-ci" || claims.Role != "viewer" {
+		t.Errorf("acme key: claims = %+v, err = %v", claims, err)
+	}
+}
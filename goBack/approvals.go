@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/karthikeyan-meenachisundaram/goBack/models"
+	"github.com/karthikeyan-meenachisundaram/goBack/service"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// approvalMode reports whether non-admin edits/deletes need an approver (auth.approval_mode)
+func approvalMode() bool {
+	return cfg.Auth.ApprovalMode
+}
+
+// submittedForApproval reports whether, in approval mode, a non-admin's method on path is
+// submitted as a change request rather than refused for want of the admin role: deletes
+// of employees, their tags and photos, and merges
+func submittedForApproval(method, path string) bool {
+	if !approvalMode() {
+		return false
+	}
+	if employeeMerge(path) {
+		return true
+	}
+	rest, ok := strings.CutPrefix(path, "/api/employees/")
+	id, sub, _ := strings.Cut(rest, "/")
+	action, _, _ := strings.Cut(sub, "/")
+	_, err := strconv.Atoi(id)
+	return ok && method == http.MethodDelete && err == nil && (action == "" || action == "tags" || action == "photo")
+}
+
+// submitChangeRequest stores cr, a change of one of the kinds applyChangeRequest makes,
+// for review and answers 202 Accepted. It reports whether cr was stored.
+func submitChangeRequest(ctx context.Context, w http.ResponseWriter, r *http.Request, cr models.ChangeRequest) bool {
+	if _, err := employees.Version(ctx, cr.EmpID); err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			httpError(w, "employee not found", http.StatusNotFound)
+			return false
+		}
+		storeError(w, "find employee", err)
+		return false
+	}
+
+	cr.Status = "pending"
+	cr.RequestedBy = actorFromRequest(r)
+	cr.RequestedAt = time.Now().UTC()
+	if err := changeRequests.Submit(ctx, &cr); err != nil {
+		storeError(w, "insert change request", err)
+		return false
+	}
+	_ = notifyAdmins(ctx, "approval_requested",
+		fmt.Sprintf("%s submitted a %s of employee %d for approval", cr.RequestedBy, cr.Kind, cr.EmpID),
+		"/api/approvals/"+cr.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(bson.M{"message": "Change submitted for approval", "approval_id": cr.ID})
+	return true
+}
+
+// approvalsHandler handles GET /api/approvals?status=pending.
+// Admins see every request, everyone else only their own.
+func approvalsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	requestedBy := ""
+	if !isAdmin(r) {
+		requestedBy = actorFromRequest(r)
+	}
+
+	list, err := changeRequests.List(r.Context(), r.URL.Query().Get("status"), requestedBy)
+	if err != nil {
+		storeError(w, "find change requests", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(list)
+}
+
+// approvalByIDHandler handles GET /api/approvals/{id} and POST /api/approvals/{id}/approve|reject (admin)
+func approvalByIDHandler(w http.ResponseWriter, r *http.Request) {
+	id, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/approvals/"), "/")
+	ctx := r.Context()
+
+	if action == "" {
+		if r.Method != http.MethodGet {
+			httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		cr, err := changeRequests.Get(ctx, id)
+		if err != nil {
+			if errors.Is(err, models.ErrNotFound) {
+				httpError(w, "change request not found", http.StatusNotFound)
+				return
+			}
+			storeError(w, "find change request", err)
+			return
+		}
+		if !isAdmin(r) && cr.RequestedBy != actorFromRequest(r) {
+			httpError(w, "change request not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(cr)
+		return
+	}
+
+	if action != "approve" && action != "reject" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	var input struct {
+		Comment string `json:"comment"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			httpError(w, "invalid input: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	approver := actorFromRequest(r)
+	cr, err := changeRequests.Decide(ctx, id, action == "approve", approver, input.Comment, applyChangeRequest)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNotFound):
+			httpError(w, "change request not found", http.StatusNotFound)
+		case errors.Is(err, models.ErrChangeNotPending),
+			// the employee changed since the request was submitted
+			errors.Is(err, models.ErrAlreadyTerminated),
+			errors.Is(err, models.ErrTerminated),
+			errors.Is(err, service.ErrSameDepartment),
+			errors.Is(err, errNoPhoto):
+			httpError(w, err.Error(), http.StatusConflict)
+		default:
+			storeError(w, action, err)
+		}
+		return
+	}
+	if cr.Status == "rejected" {
+		discardChangeRequest(ctx, cr)
+	}
+
+	_ = notify(ctx, cr.RequestedBy, "approval_"+cr.Status,
+		fmt.Sprintf("Your %s of employee %d was %s by %s", cr.Kind, cr.EmpID, cr.Status, approver),
+		"/api/approvals/"+cr.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(cr)
+}
+
+// applyChangeRequest makes the change an approved request asked for
+func applyChangeRequest(ctx context.Context, cr models.ChangeRequest) error {
+	actor := cr.RequestedBy
+	switch {
+	case cr.Kind == "update":
+		defs, err := loadCustomFields(ctx)
+		if err != nil {
+			return err
+		}
+		if cr.Payload != nil {
+			return applyEmployeeUpdate(ctx, cr.EmpID, *cr.Payload, defs, actor)
+		}
+		return nil
+	case cr.Kind == "delete":
+		n, err := employees.SoftDelete(ctx, cr.EmpID, actor)
+		if err == nil && n == 0 {
+			err = models.ErrNotFound
+		}
+		return err
+	case cr.Kind == "terminate" && cr.Termination != nil:
+		t := cr.Termination
+		return employees.Terminate(ctx, cr.EmpID, models.Termination{EndDate: t.EndDate, Reason: t.Reason, Note: t.Note}, actor)
+	case cr.Kind == "transfer" && cr.Transfer != nil:
+		t, err := newTransfer(ctx, cr.EmpID, *cr.Transfer, actor)
+		if err != nil {
+			return err
+		}
+		return transfers.Transfer(ctx, t)
+	case cr.Kind == "tags" && cr.Tags != nil:
+		_, err := changeTags(ctx, cr.EmpID, *cr.Tags, actor)
+		return err
+	case cr.Kind == "photo" && cr.Photo != nil:
+		p := cr.Photo
+		return setPhoto(ctx, cr.EmpID, PhotoInfo{Key: p.Key, ContentType: p.ContentType, ETag: p.ETag, Size: p.Size, UpdatedAt: time.Now().UTC()}, actor)
+	case cr.Kind == "photo":
+		return removePhoto(ctx, cr.EmpID, actor)
+	case cr.Kind == "merge" && cr.Merge != nil:
+		_, err := mergePair(ctx, cr.EmpID, cr.Merge.DuplicateID, actor)
+		return err
+	default:
+		return fmt.Errorf("unknown change kind %q", cr.Kind)
+	}
+}
+
+// discardChangeRequest drops what a rejected request kept for its change: the photo an
+// upload stored, unless the employee has that one already
+func discardChangeRequest(ctx context.Context, cr models.ChangeRequest) {
+	if cr.Kind != "photo" || cr.Photo == nil {
+		return
+	}
+	if info, err := findPhoto(ctx, cr.EmpID); err == nil && info != nil && info.Key == cr.Photo.Key {
+		return
+	}
+	if err := photos.Delete(ctx, cr.Photo.Key); err != nil {
+		logFor(ctx).Warn("delete rejected photo", "key", cr.Photo.Key, "err", err)
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/karthikeyan-meenachisundaram/goBack/models"
+	"github.com/karthikeyan-meenachisundaram/goBack/service"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestApprovalsHandler(t *testing.T) {
+	s := useTestStores(t)
+	s.changeRequests.list = []models.ChangeRequest{
+		{ID: "1", Kind: "delete", EmpID: 1, Status: "pending", RequestedBy: "ravi"},
+		{ID: "2", Kind: "delete", EmpID: 2, Status: "approved", RequestedBy: "meena"},
+	}
+
+	// everyone but an admin sees only their own requests
+	w := call(t, approvalsHandler, http.MethodGet, "/api/approvals", "", "ravi", "editor")
+	expectStatus(t, w, http.StatusOK)
+	if got := w.Body.String(); !strings.Contains(got, `"ravi"`) || strings.Contains(got, `"meena"`) {
+		t.Errorf("ravi's list = %s", got)
+	}
+	w = asAdmin(t, approvalsHandler, http.MethodGet, "/api/approvals?status=approved", "")
+	expectStatus(t, w, http.StatusOK)
+	if got := w.Body.String(); !strings.Contains(got, `"meena"`) || strings.Contains(got, `"ravi"`) {
+		t.Errorf("approved list = %s", got)
+	}
+
+	other := "/api/approvals/" + s.changeRequests.list[1].ID
+	w = call(t, approvalByIDHandler, http.MethodGet, other, "", "ravi", "editor")
+	expectStatus(t, w, http.StatusNotFound)
+}
+
+func TestDecideChangeRequest(t *testing.T) {
+	s := useTestStores(t)
+	decided := models.ChangeRequest{ID: "1", Kind: "delete", EmpID: 1, Status: "approved", RequestedBy: "ravi"}
+	s.changeRequests.list = []models.ChangeRequest{decided}
+	path := "/api/approvals/" + decided.ID
+
+	w := asAdmin(t, approvalByIDHandler, http.MethodPost, path+"/approve", "")
+	expectStatus(t, w, http.StatusConflict)
+	w = call(t, approvalByIDHandler, http.MethodPost, path+"/reject", "", "ravi", "editor")
+	expectStatus(t, w, http.StatusForbidden)
+	w = asAdmin(t, approvalByIDHandler, http.MethodPost, "/api/approvals/2/approve", "")
+	expectStatus(t, w, http.StatusNotFound)
+	w = asAdmin(t, approvalByIDHandler, http.MethodPost, "/api/approvals/nope/approve", "")
+	expectStatus(t, w, http.StatusNotFound)
+}
+
+func TestApplyChangeRequest(t *testing.T) {
+	s := useTestStores(t)
+	s.addEmployees(t, models.NewEmployee{EmpID: 1, EmpName: "Asha", Department: "Engg"})
+	name := "Asha Rao"
+
+	if err := applyChangeRequest(t.Context(), models.ChangeRequest{Kind: "update", EmpID: 1, Payload: &models.EmployeePayload{EmpName: &name}}); err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := s.employees.Get(t.Context(), 1, nil)
+	if got := bson.Raw(raw).Lookup("emp_name").StringValue(); got != name {
+		t.Errorf("emp_name = %q, want %q", got, name)
+	}
+	if err := applyChangeRequest(t.Context(), models.ChangeRequest{Kind: "delete", EmpID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.employees.Version(t.Context(), 1); err != models.ErrNotFound {
+		t.Errorf("employee still there after delete: %v", err)
+	}
+	if err := applyChangeRequest(t.Context(), models.ChangeRequest{Kind: "rename", EmpID: 1}); err == nil {
+		t.Error("unknown kind applied")
+	}
+}
+
+func TestSubmitChangesForApproval(t *testing.T) {
+	s := useTestStores(t)
+	s.addEmployees(t,
+		models.NewEmployee{EmpID: 1, EmpName: "Asha", Department: "Engg"},
+		models.NewEmployee{EmpID: 2, EmpName: "Asha R", Department: "Engg"},
+	)
+	cfg.Auth.ApprovalMode = true
+
+	tests := []struct {
+		method, target, body string
+		kind                 string
+	}{
+		{http.MethodPost, "/api/employees/1/terminate", `{"end_date":"2024-06-30","reason":"resignation"}`, "terminate"},
+		{http.MethodPost, "/api/employees/1/transfer", `{"to_department":"Ops"}`, "transfer"},
+		{http.MethodPost, "/api/employees/1/tags", `{"tags":["On-Call"]}`, "tags"},
+		{http.MethodDelete, "/api/employees/1/tags/on-call", "", "tags"},
+		{http.MethodDelete, "/api/employees/1/photo", "", "photo"},
+		{http.MethodPost, "/api/employees/1/merge/2", "", "merge"},
+		{http.MethodDelete, "/api/employees/1", "", "delete"},
+	}
+	for i, tt := range tests {
+		if need := requiredRole(tt.method, tt.target); need != "editor" {
+			t.Errorf("%s %s needs %s", tt.method, tt.target, need)
+		}
+		w := call(t, empByIDHandler, tt.method, tt.target, tt.body, "ravi", "editor")
+		expectStatus(t, w, http.StatusAccepted)
+		if cr := s.changeRequests.list[i]; cr.Kind != tt.kind || cr.EmpID != 1 || cr.RequestedBy != "ravi" {
+			t.Errorf("%s %s submitted %+v", tt.method, tt.target, cr)
+		}
+	}
+	list := s.changeRequests.list
+	if c := list[1].Transfer; c == nil || c.ToDepartment != "Ops" {
+		t.Errorf("transfer = %+v", c)
+	}
+	if c := list[2].Tags; c == nil || len(c.Add) != 1 || c.Add[0] != "on-call" {
+		t.Errorf("tags = %+v", c)
+	}
+	if c := list[5].Merge; c == nil || c.DuplicateID != 2 {
+		t.Errorf("merge = %+v", c)
+	}
+	if raw, _ := s.employees.Get(t.Context(), 1, nil); bson.Raw(raw).Lookup("department").StringValue() != "Engg" {
+		t.Error("a submitted change was made")
+	}
+
+	// without approval mode deletes and merges stay admin only
+	cfg.Auth.ApprovalMode = false
+	if need := requiredRole(http.MethodDelete, "/api/employees/1"); need != "admin" {
+		t.Errorf("DELETE /api/employees/1 needs %s", need)
+	}
+}
+
+func TestApprovalNotifications(t *testing.T) {
+	s := useTestStores(t)
+	s.addEmployees(t, models.NewEmployee{EmpID: 1, EmpName: "Asha", Department: "Engg"})
+	s.accounts["anu"] = User{Username: "anu", Role: "admin"}
+	s.accounts["zoe"] = User{Username: "zoe", Role: "admin"}
+	s.accounts["ravi"] = User{Username: "ravi", Role: "editor"}
+	cfg.Auth.ApprovalMode = true
+
+	w := call(t, empByIDHandler, http.MethodDelete, "/api/employees/1", "", "ravi", "editor")
+	expectStatus(t, w, http.StatusAccepted)
+	w = asAdmin(t, approvalByIDHandler, http.MethodPost, "/api/approvals/1/approve", "")
+	expectStatus(t, w, http.StatusOK)
+
+	var got []string
+	for _, n := range s.notifications.list {
+		got = append(got, n.User+" "+n.Kind+" "+n.Link)
+	}
+	want := []string{
+		"anu approval_requested /api/approvals/1",
+		"zoe approval_requested /api/approvals/1",
+		"ravi approval_approved /api/approvals/1",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("notifications = %q, want %q", got, want)
+	}
+}
+
+func TestApplyApprovedChanges(t *testing.T) {
+	s := useTestStores(t)
+	s.addEmployees(t,
+		models.NewEmployee{EmpID: 1, EmpName: "Asha", Department: "Engg"},
+		models.NewEmployee{EmpID: 2, EmpName: "Asha R", Department: "Engg"},
+	)
+	ctx := t.Context()
+
+	transfer := models.ChangeRequest{Kind: "transfer", EmpID: 1, RequestedBy: "ravi",
+		Transfer: &models.TransferChange{ToDepartment: "Ops", EffectiveDate: time.Now()}}
+	if err := applyChangeRequest(ctx, transfer); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.transfers.recorded) != 1 || s.transfers.recorded[0].FromDepartment != "Engg" {
+		t.Errorf("transfers = %+v", s.transfers.recorded)
+	}
+	// the employee moved there since
+	if err := applyChangeRequest(ctx, transfer); !errors.Is(err, service.ErrSameDepartment) {
+		t.Errorf("second transfer: %v", err)
+	}
+
+	merge := models.ChangeRequest{Kind: "merge", EmpID: 1, RequestedBy: "ravi", Merge: &models.MergeChange{DuplicateID: 2}}
+	if err := applyChangeRequest(ctx, merge); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.merges.merged) != 1 || s.merges.merged[0] != [2]int{1, 2} {
+		t.Errorf("merged = %v", s.merges.merged)
+	}
+
+	terminate := models.ChangeRequest{Kind: "terminate", EmpID: 1, RequestedBy: "ravi",
+		Termination: &models.TerminationChange{EndDate: time.Now(), Reason: "resignation"}}
+	if err := applyChangeRequest(ctx, terminate); err != nil {
+		t.Fatal(err)
+	}
+	if raw, _ := s.employees.Get(ctx, 1, nil); bson.Raw(raw).Lookup("status").StringValue() != "terminated" {
+		t.Errorf("employee = %s", bson.Raw(raw))
+	}
+
+	// deciding answers 409 when the change no longer applies
+	s.changeRequests.list = []models.ChangeRequest{terminate}
+	s.changeRequests.list[0].ID, s.changeRequests.list[0].Status = "1", "pending"
+	w := asAdmin(t, approvalByIDHandler, http.MethodPost, "/api/approvals/1/approve", "")
+	expectStatus(t, w, http.StatusConflict)
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AuditEntry is one record in the AuditLog collection
+type AuditEntry struct {
+	Action    string                 `bson:"action" json:"action"`
+	EmpID     int                    `bson:"emp_id" json:"emp_id"`
+	Actor     string                 `bson:"actor" json:"actor"`
+	Timestamp time.Time              `bson:"timestamp" json:"timestamp"`
+	Details   interface{}            `bson:"details,omitempty" json:"details,omitempty"`
+	Changes   map[string]FieldChange `bson:"changes,omitempty" json:"changes,omitempty"`
+}
+
+// FieldChange is the before and after value of one employee field; nil on one side
+// means the field was added or removed (all fields on a create or delete)
+type FieldChange struct {
+	Before interface{} `bson:"before" json:"before"`
+	After  interface{} `bson:"after" json:"after"`
+}
+
+// recordAudit appends an entry to the AuditLog collection
+func recordAudit(ctx context.Context, action string, empId int, actor string, details interface{}) error {
+	return recordAuditDiff(ctx, action, empId, actor, details, nil, nil)
+}
+
+// recordAuditDiff appends an entry carrying the field changes between two snapshots; when
+// any field changed, the employee's new state is kept as a revision too
+func recordAuditDiff(ctx context.Context, action string, empId int, actor string, details interface{}, before, after bson.M) error {
+	now := time.Now().UTC()
+	changes := diffSnapshots(before, after)
+	_, err := coll(ctx, "AuditLog").InsertOne(ctx, AuditEntry{
+		Action:    action,
+		EmpID:     empId,
+		Actor:     actor,
+		Timestamp: now,
+		Details:   details,
+		Changes:   changes,
+	})
+	if err != nil || changes == nil {
+		return err
+	}
+	return recordRevision(ctx, action, empId, actor, now, after, changes)
+}
+
+// auditChange runs change and audits it with the employee's before/after diff
+func auditChange(ctx context.Context, action string, empId int, actor string, details interface{}, change func() error) error {
+	before := employeeSnapshot(ctx, empId)
+	if err := change(); err != nil {
+		return err
+	}
+	return recordAuditDiff(ctx, action, empId, actor, details, before, employeeSnapshot(ctx, empId))
+}
+
+// employeeSnapshot is the audited state of a live employee as flat field -> value (nested
+// custom fields and termination as "custom_fields.x"); nil when there is none
+func employeeSnapshot(ctx context.Context, empId int) bson.M {
+	raw, err := employees.Get(ctx, empId, nil)
+	if err != nil {
+		return nil
+	}
+	var doc bson.M
+	if err := raw.Decode(&doc); err != nil {
+		return nil
+	}
+	return snapshotFields(doc)
+}
+
+// snapshotFields flattens an employee row (as employees.Get returns it) into a snapshot
+func snapshotFields(doc bson.M) bson.M {
+	snap := bson.M{}
+	for k, v := range doc {
+		switch k {
+		case "emp_id", "language", "version", "updated_at": // the key, the first of languages and bookkeeping
+			continue
+		case "custom_fields", "termination":
+			for sub, sv := range subFields(v) {
+				snap[k+"."+sub] = sv
+			}
+			continue
+		}
+		snap[k] = v
+	}
+	return snap
+}
+
+// subFields reads an embedded document decoded as either bson.D or bson.M
+func subFields(v interface{}) bson.M {
+	switch d := v.(type) {
+	case bson.M:
+		return d
+	case bson.D:
+		m := bson.M{}
+		for _, e := range d {
+			m[e.Key] = e.Value
+		}
+		return m
+	}
+	return nil
+}
+
+// diffSnapshots lists the fields whose value differs between before and after
+func diffSnapshots(before, after bson.M) map[string]FieldChange {
+	changes := map[string]FieldChange{}
+	for k, b := range before {
+		if a, ok := after[k]; !ok || !reflect.DeepEqual(a, b) {
+			changes[k] = FieldChange{Before: b, After: after[k]}
+		}
+	}
+	for k, a := range after {
+		if _, ok := before[k]; !ok {
+			changes[k] = FieldChange{After: a}
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	return changes
+}
+
+// plainValue turns the bson.D documents Mongo decodes into interface{} fields into
+// maps, so they encode as JSON objects instead of key/value pair lists
+func plainValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case bson.D:
+		m := bson.M{}
+		for _, e := range t {
+			m[e.Key] = plainValue(e.Value)
+		}
+		return m
+	case bson.M:
+		for k, e := range t {
+			t[k] = plainValue(e)
+		}
+		return t
+	case bson.A:
+		for i, e := range t {
+			t[i] = plainValue(e)
+		}
+		return t
+	}
+	return v
+}
+
+// parseAuditTime reads a from/to bound: RFC 3339, or a date (YYYY-MM-DD) meaning the
+// start of that day, or the end of it for an upper bound
+func parseAuditTime(v string, upper bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", v)
+	if err != nil {
+		return t, err
+	}
+	if upper {
+		t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+	return t, nil
+}
+
+// ---------------- Handlers ----------------
+
+// auditHandler handles GET /api/audit?emp_id=&actor=&action=&from=&to=&page=&limit=
+// (admin): raw audit entries with their field changes, newest first
+func auditHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	q := r.URL.Query()
+	filter := bson.M{}
+	if v := q.Get("emp_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			httpError(w, "invalid emp_id", http.StatusBadRequest)
+			return
+		}
+		filter["emp_id"] = id
+	}
+	if v := q.Get("actor"); v != "" {
+		filter["actor"] = v
+	}
+	if v := q.Get("action"); v != "" {
+		filter["action"] = bson.M{"$in": strings.Split(v, ",")}
+	}
+	between := bson.M{}
+	for _, b := range []struct {
+		param, op string
+		upper     bool
+	}{{"from", "$gte", false}, {"to", "$lte", true}} {
+		v := q.Get(b.param)
+		if v == "" {
+			continue
+		}
+		t, err := parseAuditTime(v, b.upper)
+		if err != nil {
+			httpError(w, "invalid "+b.param+", expected YYYY-MM-DD or RFC 3339", http.StatusBadRequest)
+			return
+		}
+		between[b.op] = t
+	}
+	if len(between) > 0 {
+		filter["timestamp"] = between
+	}
+	page, limit, err := parsePage(q)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	total, err := coll(ctx, "AuditLog").CountDocuments(ctx, filter)
+	if err != nil {
+		storeError(w, "count audit", err)
+		return
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "timestamp", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(int64((page - 1) * limit)).
+		SetLimit(int64(limit))
+	cur, err := coll(ctx, "AuditLog").Find(ctx, filter, opts)
+	if err != nil {
+		storeError(w, "find audit", err)
+		return
+	}
+	defer cur.Close(ctx)
+	items := []AuditEntry{}
+	if err := cur.All(ctx, &items); err != nil {
+		storeError(w, "cursor all", err)
+		return
+	}
+	for i := range items {
+		items[i].Details = plainValue(items[i].Details)
+		for f, c := range items[i].Changes {
+			items[i].Changes[f] = FieldChange{Before: plainValue(c.Before), After: plainValue(c.After)}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(bson.M{"items": items, "page": page, "limit": limit, "total": total})
+}
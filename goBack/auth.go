@@ -0,0 +1,410 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/karthikeyan-meenachisundaram/goBack/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 7 * 24 * time.Hour
+)
+
+// roleRank orders the roles: viewers can read, editors can also create and edit,
+// admins can also delete and manage users
+var roleRank = map[string]int{
+	"viewer": 1,
+	"editor": 2,
+	"admin":  3,
+}
+
+// User is an account that can log in to the API
+type User struct {
+	Username     string    `bson:"username" json:"username"`
+	PasswordHash string    `bson:"password_hash" json:"-"`
+	Role         string    `bson:"role" json:"role"`
+	CreatedAt    time.Time `bson:"created_at" json:"created_at"`
+}
+
+// tokenClaims are the claims of both access and refresh tokens; Type tells them apart
+type tokenClaims struct {
+	Type string `json:"typ"`
+	Role string `json:"role,omitempty"`
+	Org  string `json:"org,omitempty"` // see orgOfClaims
+	jwt.RegisteredClaims
+}
+
+// accountStore finds the accounts logins and refreshes check
+type accountStore interface {
+	// Find returns the account called username; models.ErrNotFound when there is none
+	Find(ctx context.Context, username string) (User, error)
+	// Admins returns the names of the admin accounts, in name order
+	Admins(ctx context.Context) ([]string, error)
+}
+
+// accounts are the Users collection of the request's organization; serveMemory swaps in
+// memoryAccounts
+var accounts accountStore = mongoAccounts{}
+
+// mongoAccounts finds accounts in the Users collection
+type mongoAccounts struct{}
+
+func (mongoAccounts) Find(ctx context.Context, username string) (User, error) {
+	var u User
+	err := coll(ctx, "Users").FindOne(ctx, bson.M{"username": username}).Decode(&u)
+	if err == mongo.ErrNoDocuments {
+		err = models.ErrNotFound
+	}
+	return u, err
+}
+
+func (mongoAccounts) Admins(ctx context.Context) ([]string, error) {
+	cur, err := coll(ctx, "Users").Find(ctx, bson.M{"role": "admin"}, options.Find().
+		SetProjection(bson.M{"username": 1}).SetSort(bson.D{{Key: "username", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	var list []User
+	if err := cur.All(ctx, &list); err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(list))
+	for _, u := range list {
+		names = append(names, u.Username)
+	}
+	return names, nil
+}
+
+// ctxKey keys request-scoped values
+type ctxKey int
+
+const (
+	userKey ctxKey = iota
+	requestIDKey
+	apiVersionKey
+	requestKey // the *http.Request, for code that only gets a context (GraphQL resolvers)
+)
+
+// jwtSecret signs and verifies tokens (auth.jwt_secret; a random one when unset)
+var jwtSecret []byte
+
+// initAuth loads the signing secret and, when the Users collection is empty, creates the
+// first account from auth.bootstrap_user / auth.bootstrap_password
+func initAuth(ctx context.Context) {
+	initJWTSecret()
+
+	users := coll(ctx, "Users")
+	name, password := cfg.Auth.BootstrapUser, cfg.Auth.BootstrapPassword
+	if name == "" || password == "" {
+		return
+	}
+	if n, err := users.CountDocuments(ctx, bson.M{}); err != nil || n > 0 {
+		return
+	}
+	if _, err := createUser(ctx, name, password, "admin"); err != nil {
+		slog.Error("initAuth: create bootstrap user", "err", err)
+		return
+	}
+	slog.Info("created bootstrap user", "username", name)
+}
+
+// initJWTSecret sets the token signing secret from auth.jwt_secret, or a random one
+func initJWTSecret() {
+	if s := cfg.Auth.JWTSecret; s != "" {
+		jwtSecret = []byte(s)
+		return
+	}
+	jwtSecret = make([]byte, minJWTSecretBytes)
+	_, _ = rand.Read(jwtSecret)
+	slog.Warn("auth.jwt_secret is not set; using a random secret (tokens won't survive a restart)")
+}
+
+// createUser stores a new account with a bcrypt-hashed password
+func createUser(ctx context.Context, username, password, role string) (User, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return User{}, err
+	}
+	u := User{Username: username, PasswordHash: string(hash), Role: role, CreatedAt: time.Now().UTC()}
+	_, err = coll(ctx, "Users").InsertOne(ctx, u)
+	return u, err
+}
+
+// issueToken signs a token of the given type for u, a user of organization org
+func issueToken(u User, org, typ string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := tokenClaims{
+		Type: typ,
+		Role: u.Role,
+		Org:  org,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   u.Username,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtSecret)
+}
+
+// parseToken verifies a token's signature, expiry and type and returns its claims
+func parseToken(token, typ string) (*tokenClaims, error) {
+	var claims tokenClaims
+	_, err := jwt.ParseWithClaims(token, &claims, func(*jwt.Token) (interface{}, error) {
+		return jwtSecret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return nil, err
+	}
+	if claims.Type != typ {
+		return nil, fmt.Errorf("not a %s token", typ)
+	}
+	return &claims, nil
+}
+
+// writeTokens answers with a fresh access/refresh token pair for u, a user of the
+// organization ctx acts for
+func writeTokens(ctx context.Context, w http.ResponseWriter, u User) {
+	org := orgOf(ctx)
+	access, err := issueToken(u, org, "access", accessTokenTTL)
+	if err != nil {
+		httpError(w, "sign token: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	refresh, err := issueToken(u, org, "refresh", refreshTokenTTL)
+	if err != nil {
+		httpError(w, "sign token: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(bson.M{
+		"access_token":  access,
+		"refresh_token": refresh,
+		"token_type":    "Bearer",
+		"expires_in":    int(accessTokenTTL.Seconds()),
+		"role":          u.Role,
+		"org":           org,
+	})
+}
+
+// writeUnauthorized answers 401 with a JSON body
+func writeUnauthorized(w http.ResponseWriter, msg string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+	httpError(w, msg, http.StatusUnauthorized)
+}
+
+// writeForbidden answers 403 with a JSON body
+func writeForbidden(w http.ResponseWriter, msg string) {
+	httpError(w, msg, http.StatusForbidden)
+}
+
+// bearerToken returns the token from "Authorization: Bearer ..." or, for EventSource
+// clients that cannot set headers, from ?access_token=
+func bearerToken(r *http.Request) string {
+	if h := r.Header.Get("Authorization"); h != "" {
+		if t, ok := strings.CutPrefix(h, "Bearer "); ok {
+			return strings.TrimSpace(t)
+		}
+		return ""
+	}
+	return r.URL.Query().Get("access_token")
+}
+
+// requiredRole is the least role allowed to call method on path. By default reads need
+// viewer, creates and edits need editor and deletes (merges among them) need admin; the
+// caller's own preferences, notifications and saved searches are open to every role. In
+// approval mode editors submit the deletes of submittedForApproval for approval.
+func requiredRole(method, path string) string {
+	switch {
+	case submittedForApproval(method, path): // an admin approves them
+		return "editor"
+	case strings.HasPrefix(path, "/api/admin/users"),
+		employeeMerge(path): // a merge deletes the duplicate
+		return "admin"
+	case path == "/api/graphql": // mutations check their own role
+		return "viewer"
+	case strings.HasPrefix(path, "/api/me/"),
+		strings.HasPrefix(path, "/api/notifications"),
+		strings.HasPrefix(path, "/api/saved-searches"):
+		return "viewer"
+	}
+	switch method {
+	case http.MethodGet, http.MethodHead:
+		return "viewer"
+	case http.MethodDelete:
+		return "admin"
+	default:
+		return "editor"
+	}
+}
+
+// employeeMerge reports whether path is /api/employees/merge or
+// /api/employees/{id}/merge/{otherId}
+func employeeMerge(path string) bool {
+	rest, ok := strings.CutPrefix(path, "/api/employees/")
+	_, sub, _ := strings.Cut(rest, "/")
+	action, _, _ := strings.Cut(sub, "/")
+	return ok && (rest == "merge" || action == "merge")
+}
+
+// publicAPI are the /api routes that need no token: login/refresh and the API docs
+func publicAPI(path string) bool {
+	return strings.HasPrefix(path, "/api/auth/") || path == "/api/docs" || path == "/api/openapi.json"
+}
+
+// authenticate requires a valid access token (or, without one, an X-API-Key) on /api
+// routes except the publicAPI ones and checks its role against requiredRole. The SPA and
+// its static assets stay public.
+func authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/") || publicAPI(r.URL.Path) || r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+		var claims *tokenClaims
+		token := bearerToken(r)
+		switch key := r.Header.Get(apiKeyHeader); {
+		case token == "" && key != "":
+			var err error
+			if claims, err = apiKeyClaims(r.Context(), key); err != nil {
+				if err == errInvalidAPIKey {
+					writeUnauthorized(w, err.Error())
+					return
+				}
+				storeError(w, "find api key", err)
+				return
+			}
+		case token == "":
+			writeUnauthorized(w, "missing bearer token")
+			return
+		default:
+			var err error
+			if claims, err = parseToken(token, "access"); err != nil {
+				writeUnauthorized(w, "invalid token: "+err.Error())
+				return
+			}
+		}
+		if need := requiredRole(r.Method, r.URL.Path); roleRank[claims.Role] < roleRank[need] {
+			writeForbidden(w, need+" role required")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userKey, claims)))
+	})
+}
+
+// actorFromRequest identifies who is making the request (the token subject)
+func actorFromRequest(r *http.Request) string {
+	if c, ok := r.Context().Value(userKey).(*tokenClaims); ok && c.Subject != "" {
+		return c.Subject
+	}
+	return "anonymous"
+}
+
+// isAdmin reports whether the request's token carries the admin role
+func isAdmin(r *http.Request) bool {
+	c, ok := r.Context().Value(userKey).(*tokenClaims)
+	return ok && c.Role == "admin"
+}
+
+// requireAdmin writes 403 and returns false unless the request is from an admin
+func requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if !isAdmin(r) {
+		httpError(w, "admin access required", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// ---------------- Handlers ----------------
+
+// loginHandler handles POST /api/auth/login {username, password, org}; without org the
+// user is one of the X-Org-ID organization (the default one without the header)
+func loginHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var input struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Org      string `json:"org"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		httpError(w, "invalid input: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx := r.Context()
+	if input.Org != "" {
+		ok, err := orgExists(ctx, input.Org)
+		if err != nil {
+			storeError(w, "find organization", err)
+			return
+		}
+		if !ok {
+			writeUnauthorized(w, "invalid username or password")
+			return
+		}
+		ctx = withOrg(ctx, input.Org)
+	}
+
+	u, err := accounts.Find(ctx, input.Username)
+	if err != nil && !errors.Is(err, models.ErrNotFound) {
+		storeError(w, "find user", err)
+		return
+	}
+	// unknown users and wrong passwords get the same answer
+	if err != nil || bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(input.Password)) != nil {
+		writeUnauthorized(w, "invalid username or password")
+		return
+	}
+	writeTokens(ctx, w, u)
+}
+
+// refreshHandler handles POST /api/auth/refresh {refresh_token}
+func refreshHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var input struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		httpError(w, "invalid input: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	claims, err := parseToken(input.RefreshToken, "refresh")
+	if err != nil {
+		writeUnauthorized(w, "invalid refresh token: "+err.Error())
+		return
+	}
+	// the token's organization, whatever X-Org-ID says
+	ctx := withOrg(r.Context(), orgOfClaims(claims))
+
+	// the account may have been removed or its role changed since the token was issued
+	u, err := accounts.Find(ctx, claims.Subject)
+	if err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			writeUnauthorized(w, "user no longer exists")
+			return
+		}
+		storeError(w, "find user", err)
+		return
+	}
+	writeTokens(ctx, w, u)
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// forgedToken is a token of type typ for user, signed with method and key instead of
+// issueToken's HS256 and jwtSecret
+func forgedToken(t *testing.T, user, typ string, method jwt.SigningMethod, key interface{}) string {
+	t.Helper()
+	now := time.Now()
+	token, err := jwt.NewWithClaims(method, tokenClaims{
+		Type: typ,
+		Role: "admin",
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   user,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		},
+	}).SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// testTokens are the tokens of admin that TestParseToken and TestRefreshHandler try
+func testTokens(t *testing.T) map[string]string {
+	t.Helper()
+	issue := func(typ string, ttl time.Duration) string {
+		token, err := issueToken(User{Username: "admin", Role: "admin"}, defaultOrg, typ, ttl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	return map[string]string{
+		"access":        issue("access", time.Hour),
+		"refresh":       issue("refresh", time.Hour),
+		"expired":       issue("refresh", -time.Minute),
+		"bad signature": forgedToken(t, "admin", "refresh", jwt.SigningMethodHS256, []byte(strings.Repeat("x", minJWTSecretBytes))),
+		"alg none":      forgedToken(t, "admin", "refresh", jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType),
+		"HS512":         forgedToken(t, "admin", "refresh", jwt.SigningMethodHS512, jwtSecret),
+		"garbage":       "not.a.token",
+	}
+}
+
+func TestParseToken(t *testing.T) {
+	useBootstrapAccount(t)
+	tokens := testTokens(t)
+
+	tests := []struct {
+		token, typ string
+		ok         bool
+	}{
+		{"access", "access", true},
+		{"refresh", "refresh", true},
+		{"refresh", "access", false}, // the wrong type
+		{"access", "refresh", false},
+		{"expired", "refresh", false},
+		{"bad signature", "refresh", false},
+		{"alg none", "refresh", false},
+		{"HS512", "refresh", false},
+		{"garbage", "refresh", false},
+	}
+	for _, tt := range tests {
+		claims, err := parseToken(tokens[tt.token], tt.typ)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s token as %s: err = %v", tt.token, tt.typ, err)
+			continue
+		}
+		if tt.ok && (claims.Subject != "admin" || claims.Role != "admin" || orgOfClaims(claims) != defaultOrg) {
+			t.Errorf("%s token as %s: claims = %+v", tt.token, tt.typ, claims)
+		}
+	}
+}
+
+func TestLoginHandler(t *testing.T) {
+	s := useBootstrapAccount(t)
+	account, err := memoryAccount("admin", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.accounts[account.Username] = account
+
+	tests := []struct {
+		name, method, body string
+		status             int
+	}{
+		{"right password", http.MethodPost, `{"username":"admin","password":"secret"}`, http.StatusOK},
+		{"default org", http.MethodPost, `{"username":"admin","password":"secret","org":"` + defaultOrg + `"}`, http.StatusOK},
+		{"wrong password", http.MethodPost, `{"username":"admin","password":"wrong"}`, http.StatusUnauthorized},
+		{"unknown user", http.MethodPost, `{"username":"nobody","password":"secret"}`, http.StatusUnauthorized},
+		{"unknown org", http.MethodPost, `{"username":"admin","password":"secret","org":"acme"}`, http.StatusUnauthorized},
+		{"no password", http.MethodPost, `{"username":"admin"}`, http.StatusUnauthorized},
+		{"bad JSON", http.MethodPost, `{"username":`, http.StatusBadRequest},
+		{"GET", http.MethodGet, "", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		w := record(loginHandler, request(tt.method, "/api/auth/login", tt.body, "", ""))
+		if w.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d (%s)", tt.name, w.Code, tt.status, w.Body.String())
+			continue
+		}
+		if tt.status != http.StatusOK {
+			continue
+		}
+		body := decode(t, w)
+		access, _ := body["access_token"].(string)
+		refresh, _ := body["refresh_token"].(string)
+		if _, err := parseToken(access, "access"); err != nil {
+			t.Errorf("%s: access token: %v", tt.name, err)
+		}
+		if _, err := parseToken(refresh, "refresh"); err != nil {
+			t.Errorf("%s: refresh token: %v", tt.name, err)
+		}
+	}
+}
+
+func TestRefreshHandler(t *testing.T) {
+	s := useBootstrapAccount(t)
+	account, err := memoryAccount("admin", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.accounts[account.Username] = account
+	tokens := testTokens(t)
+	tokens["removed user"] = forgedToken(t, "ravi", "refresh", jwt.SigningMethodHS256, jwtSecret)
+
+	tests := []struct {
+		token  string
+		status int
+	}{
+		{"refresh", http.StatusOK},
+		{"access", http.StatusUnauthorized}, // the wrong type
+		{"expired", http.StatusUnauthorized},
+		{"bad signature", http.StatusUnauthorized},
+		{"alg none", http.StatusUnauthorized},
+		{"HS512", http.StatusUnauthorized},
+		{"garbage", http.StatusUnauthorized},
+		{"removed user", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		w := record(refreshHandler, request(http.MethodPost, "/api/auth/refresh", `{"refresh_token":"`+tokens[tt.token]+`"}`, "", ""))
+		if w.Code != tt.status {
+			t.Errorf("%s token: status = %d, want %d (%s)", tt.token, w.Code, tt.status, w.Body.String())
+			continue
+		}
+		if tt.status == http.StatusOK {
+			if access, _ := decode(t, w)["access_token"].(string); access == "" {
+				t.Errorf("%s token: no access token in %s", tt.token, w.Body.String())
+			}
+		}
+	}
+}
+
+func TestAuthenticateRoles(t *testing.T) {
+	useBootstrapAccount(t)
+	h := authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	serve := func(method, target, role string) int {
+		r := request(method, target, "", "", "")
+		if role != "" {
+			token, err := issueToken(User{Username: "ann", Role: role}, defaultOrg, "access", time.Hour)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		return record(h.ServeHTTP, r).Code
+	}
+
+	tests := []struct {
+		method, path string
+		need         string // the least role; "" for routes without a token
+		approval     string // the least role in approval mode, if different
+	}{
+		{http.MethodGet, "/api/employees", "viewer", ""},
+		{http.MethodHead, "/api/employees", "viewer", ""},
+		{http.MethodPost, "/api/employees", "editor", ""},
+		{http.MethodGet, "/api/employees/1", "viewer", ""},
+		{http.MethodPut, "/api/employees/1", "editor", ""},
+		{http.MethodPatch, "/api/employees/1", "editor", ""},
+		{http.MethodDelete, "/api/employees/1", "admin", "editor"},
+		{http.MethodDelete, "/api/employees/1/tags/on-call", "admin", "editor"},
+		{http.MethodDelete, "/api/employees/1/photo", "admin", "editor"},
+		{http.MethodPost, "/api/employees/1/merge/2", "admin", "editor"},
+		{http.MethodPost, "/api/employees/merge", "admin", "editor"},
+		{http.MethodGet, "/api/admin/users", "admin", ""},
+		{http.MethodPost, "/api/admin/users", "admin", ""},
+		{http.MethodPost, "/api/graphql", "viewer", ""},
+		{http.MethodPut, "/api/me/preferences", "viewer", ""},
+		{http.MethodPost, "/api/notifications/read-all", "viewer", ""},
+		{http.MethodDelete, "/api/saved-searches/1", "viewer", ""},
+		{http.MethodPost, "/api/auth/login", "", ""},
+		{http.MethodGet, "/api/openapi.json", "", ""},
+		{http.MethodGet, "/index.html", "", ""},
+	}
+	roles := []string{"intern", "viewer", "editor", "admin"} // unknown roles rank below viewer
+	for _, approval := range []bool{false, true} {
+		cfg.Auth.ApprovalMode = approval
+		for _, tt := range tests {
+			need := tt.need
+			if approval && tt.approval != "" {
+				need = tt.approval
+			}
+			if need == "" {
+				if got := serve(tt.method, tt.path, ""); got != http.StatusOK {
+					t.Errorf("approval %v, %s %s without a token: %d", approval, tt.method, tt.path, got)
+				}
+				continue
+			}
+			if got := serve(tt.method, tt.path, ""); got != http.StatusUnauthorized {
+				t.Errorf("approval %v, %s %s without a token: %d", approval, tt.method, tt.path, got)
+			}
+			for _, role := range roles {
+				want := http.StatusForbidden
+				if roleRank[role] >= roleRank[need] {
+					want = http.StatusOK
+				}
+				if got := serve(tt.method, tt.path, role); got != want {
+					t.Errorf("approval %v, %s %s as %s: %d, want %d", approval, tt.method, tt.path, role, got, want)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	backupFormat    = "goback-backup"
+	backupVersion   = 1
+	maxRestoreBytes = 100 << 20
+)
+
+// backupCollections are the collections a backup holds: employees, their department
+// assignments and languages, and the departments themselves. Notes, transfers, photos,
+// users and the audit log are not part of it.
+var backupCollections = []string{"Employee", "Department", "Developers", "Departments"}
+
+// backupKeys is the field every document of a collection must have to be restored
+var backupKeys = map[string]string{
+	"Employee":    "emp_id",
+	"Department":  "emp_id",
+	"Developers":  "emp_id",
+	"Departments": "dept_id",
+}
+
+// BackupFile is the dump written by /api/admin/backup and read by /api/admin/restore.
+// Documents are canonical Extended JSON, so dates, ObjectIDs and number types survive.
+type BackupFile struct {
+	Format      string                       `json:"format"`
+	Version     int                          `json:"version"`
+	CreatedAt   time.Time                    `json:"created_at"`
+	Collections map[string][]json.RawMessage `json:"collections"`
+}
+
+// RestoreCount is what a restore does to one collection
+type RestoreCount struct {
+	Restored int   `json:"restored"` // documents in the backup
+	Replaced int64 `json:"replaced"` // documents there were before
+}
+
+// RestoreReport summarizes a restore
+type RestoreReport struct {
+	DryRun          bool                    `json:"dry_run"`
+	BackupCreatedAt time.Time               `json:"backup_created_at"`
+	Collections     map[string]RestoreCount `json:"collections"`
+}
+
+// writeBackup streams the backup collections as a BackupFile, one document at a time
+func writeBackup(ctx context.Context, w io.Writer) error {
+	if _, err := fmt.Fprintf(w, `{"format":%q,"version":%d,"created_at":%q,"collections":{`,
+		backupFormat, backupVersion, time.Now().UTC().Format(time.RFC3339)); err != nil {
+		return err
+	}
+	for i, name := range backupCollections {
+		if i > 0 {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "\n%q:[", name); err != nil {
+			return err
+		}
+		cur, err := coll(ctx, name).Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+		if err != nil {
+			return fmt.Errorf("find %s: %w", name, err)
+		}
+		for n := 0; cur.Next(ctx); n++ {
+			doc, err := bson.MarshalExtJSON(cur.Current, true, false)
+			if err != nil {
+				cur.Close(ctx)
+				return fmt.Errorf("encode %s: %w", name, err)
+			}
+			sep := ",\n"
+			if n == 0 {
+				sep = "\n"
+			}
+			if _, err := io.WriteString(w, sep+string(doc)); err != nil {
+				cur.Close(ctx)
+				return err
+			}
+		}
+		err = cur.Err()
+		cur.Close(ctx)
+		if err != nil {
+			return fmt.Errorf("read %s: %w", name, err)
+		}
+		if _, err := io.WriteString(w, "]"); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "}}\n")
+	return err
+}
+
+// parseBackup checks a backup and decodes its documents; the error map names each
+// problem by collection and position
+func parseBackup(b BackupFile) (map[string][]interface{}, map[string]string) {
+	errs := map[string]string{}
+	if b.Format != backupFormat {
+		errs["format"] = fmt.Sprintf("must be %q", backupFormat)
+	}
+	if b.Version != backupVersion {
+		errs["version"] = fmt.Sprintf("unsupported version %d", b.Version)
+	}
+	for name := range b.Collections {
+		if _, ok := backupKeys[name]; !ok {
+			errs["collections."+name] = "is not a collection a backup holds"
+		}
+	}
+	docs := map[string][]interface{}{}
+	for _, name := range backupCollections {
+		raw, ok := b.Collections[name]
+		if !ok {
+			// a restore replaces every collection, so a missing one would empty it
+			errs["collections."+name] = "is missing"
+			continue
+		}
+		key := backupKeys[name]
+		seen := map[string]bool{}
+		list := make([]interface{}, 0, len(raw))
+		for i, r := range raw {
+			field := fmt.Sprintf("collections.%s[%d]", name, i)
+			var doc bson.D
+			if err := bson.UnmarshalExtJSON(r, true, &doc); err != nil {
+				errs[field] = err.Error()
+				continue
+			}
+			id, ok := docValue(doc, key)
+			if !ok {
+				errs[field] = key + " is required"
+				continue
+			}
+			// employees and departments are unique by their id; the other two hold one
+			// or more rows per employee
+			if name == "Employee" || name == "Departments" {
+				k := fmt.Sprint(id)
+				if seen[k] {
+					errs[field] = fmt.Sprintf("duplicate %s %v", key, id)
+					continue
+				}
+				seen[k] = true
+			}
+			list = append(list, doc)
+		}
+		docs[name] = list
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return docs, nil
+}
+
+// docValue looks a top-level field up in a decoded document
+func docValue(doc bson.D, key string) (interface{}, bool) {
+	for _, e := range doc {
+		if e.Key == key {
+			return e.Value, true
+		}
+	}
+	return nil, false
+}
+
+// restoreBackup replaces the backup collections with docs in one transaction, then moves
+// the id counters past the restored ids so ids handed out since the backup aren't reused
+func restoreBackup(ctx context.Context, docs map[string][]interface{}) error {
+	err := withTransaction(ctx, func(sc mongo.SessionContext) error {
+		for _, name := range backupCollections {
+			if _, err := coll(sc, name).DeleteMany(sc, bson.M{}); err != nil {
+				return fmt.Errorf("clear %s: %w", name, err)
+			}
+			if len(docs[name]) == 0 {
+				continue
+			}
+			if _, err := coll(sc, name).InsertMany(sc, docs[name]); err != nil {
+				return fmt.Errorf("insert %s: %w", name, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	last, err := employees.LastID(ctx)
+	if err != nil {
+		return err
+	}
+	if err := employees.ReserveID(ctx, last); err != nil {
+		return err
+	}
+	var dept struct {
+		DeptID int `bson:"dept_id"`
+	}
+	err = coll(ctx, "Departments").FindOne(ctx, bson.M{}, options.FindOne().SetSort(bson.D{{Key: "dept_id", Value: -1}})).Decode(&dept)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = coll(ctx, "Counters").UpdateOne(ctx, bson.M{"_id": "dept_id"}, bson.M{"$max": bson.M{"seq": dept.DeptID}}, options.Update().SetUpsert(true))
+	return err
+}
+
+// ---------------- Handlers ----------------
+
+// backupHandler handles POST /api/admin/backup (admin): a download of the employee data
+func backupHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	ctx := r.Context()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "goback-backup-"+time.Now().UTC().Format("2006-01-02")+".json"))
+	if err := writeBackup(ctx, w); err != nil {
+		// headers are gone by now; the truncated download is all the client sees
+		logFor(r.Context()).Error("backup aborted", "err", err)
+		return
+	}
+	logFor(r.Context()).Info("backup written", "actor", actorFromRequest(r))
+}
+
+// restoreHandler handles POST /api/admin/restore (admin): the body is a backup, which
+// replaces the employee data. A dry run only checks it and reports what would change.
+func restoreHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxRestoreBytes)
+	var b BackupFile
+	if err := json.NewDecoder(r.Body).Decode(&b); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			httpError(w, fmt.Sprintf("backup is larger than %d MB", maxRestoreBytes>>20), http.StatusRequestEntityTooLarge)
+			return
+		}
+		httpError(w, "invalid backup: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	docs, errs := parseBackup(b)
+	if len(errs) > 0 {
+		writeError(w, http.StatusUnprocessableEntity, "invalid backup", errs)
+		return
+	}
+
+	ctx := r.Context()
+
+	report := RestoreReport{
+		DryRun:          dryRun(w, r),
+		BackupCreatedAt: b.CreatedAt,
+		Collections:     map[string]RestoreCount{},
+	}
+	for _, name := range backupCollections {
+		n, err := coll(ctx, name).CountDocuments(ctx, bson.M{})
+		if err != nil {
+			storeError(w, "count "+name, err)
+			return
+		}
+		report.Collections[name] = RestoreCount{Restored: len(docs[name]), Replaced: n}
+	}
+	if !report.DryRun {
+		if err := restoreBackup(ctx, docs); err != nil {
+			storeError(w, "restore", err)
+			return
+		}
+		logFor(r.Context()).Info("backup restored", "actor", actorFromRequest(r), "backup_created_at", b.CreatedAt)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/karthikeyan-meenachisundaram/goBack/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// maxBatchSize caps the items of one batch request
+const maxBatchSize = 500
+
+// BatchItemResult is the outcome of one item of a batch request
+type BatchItemResult struct {
+	Index  int               `json:"index"` // position in the request array
+	Status string            `json:"status"`
+	EmpID  int               `json:"emp_id,omitempty"`
+	Errors map[string]string `json:"errors,omitempty"`
+	// Changes are the fields the item would be created with, in dry runs
+	Changes map[string]FieldChange `json:"changes,omitempty"`
+}
+
+// batchHandler handles POST and DELETE on /api/employees/batch
+func batchHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		batchCreate(w, r)
+	case http.MethodDelete:
+		batchDelete(w, r)
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// batchCreate handles POST /api/employees/batch with an array of employee payloads.
+// Every item is validated like a single create; if any fails nothing is written and
+// the 422 response reports each item. Otherwise all are inserted in one transaction, or
+// for a dry run reported as valid with their fields.
+func batchCreate(w http.ResponseWriter, r *http.Request) {
+	dry := dryRun(w, r)
+	var input []models.EmployeePayload
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		httpError(w, "invalid input: expected an array of employees: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(input) == 0 || len(input) > maxBatchSize {
+		httpError(w, fmt.Sprintf("batch must hold between 1 and %d employees", maxBatchSize), http.StatusUnprocessableEntity)
+		return
+	}
+
+	ctx := r.Context()
+
+	defs, err := loadCustomFields(ctx)
+	if err != nil {
+		storeError(w, "find custom fields", err)
+		return
+	}
+	ref, err := loadReferenceData(ctx)
+	if err != nil {
+		storeError(w, "reference data", err)
+		return
+	}
+	results := make([]BatchItemResult, len(input))
+	list := make([]models.NewEmployee, 0, len(input))
+	invalid := false
+	for i := range input {
+		p := &input[i]
+		results[i] = BatchItemResult{Index: i, Status: "valid"}
+		errs := validatePayload(p, ref, true)
+		if p.EmpId != 0 {
+			errs = mergeFieldErrors(errs, "", map[string]string{"emp_id": "is assigned by the server in batch creates"})
+		}
+		// managers must exist already; employees of the batch have no ids yet
+		if m := managerID(p.ManagerID); m != 0 && errs["manager_id"] == "" {
+			msg, err := checkManager(ctx, 0, m)
+			if err != nil {
+				storeError(w, "check manager", err)
+				return
+			}
+			if msg != "" {
+				errs = mergeFieldErrors(errs, "", map[string]string{"manager_id": msg})
+			}
+		}
+		customFields, _, cfErrs := validateCustomFields(defs, p.CustomFields, true)
+		if errs = mergeFieldErrors(errs, "custom_fields.", cfErrs); len(errs) > 0 {
+			results[i].Status, results[i].Errors = "error", errs
+			invalid = true
+			continue
+		}
+		list = append(list, models.NewEmployee{
+			EmpName:      *p.EmpName,
+			Department:   *p.Department,
+			Languages:    p.Languages,
+			ManagerID:    managerID(p.ManagerID),
+			CustomFields: customFields,
+		})
+	}
+	if invalid {
+		writeError(w, http.StatusUnprocessableEntity, "validation failed, nothing was created", results)
+		return
+	}
+	if dry {
+		for i := range list {
+			results[i].Changes = previewChanges(nil, newEmployeeSnapshot(list[i]))
+		}
+		writeDryRun(w, "Employees would be created", bson.M{"created": 0, "results": results})
+		return
+	}
+
+	first, err := employees.NextIDs(ctx, len(list))
+	if err != nil {
+		storeError(w, "allocate ids", err)
+		return
+	}
+	for i := range list {
+		list[i].EmpID = first + i
+	}
+	actor := actorFromRequest(r)
+	err = withTransaction(ctx, func(sc mongo.SessionContext) error {
+		return employees.CreateMany(sc, list, actor, "batch")
+	})
+	if err != nil {
+		storeError(w, "batch create", err)
+		return
+	}
+	for i := range results {
+		results[i].Status, results[i].EmpID = "created", list[i].EmpID
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(bson.M{"message": "Employees created successfully", "created": len(list), "results": results})
+}
+
+// batchDelete handles DELETE /api/employees/batch {"emp_ids": [...]}: soft-deletes each
+// employee and reports it as deleted, not_found or error
+func batchDelete(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		EmpIDs []int `json:"emp_ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		httpError(w, "invalid input: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(input.EmpIDs) == 0 || len(input.EmpIDs) > maxBatchSize {
+		httpError(w, fmt.Sprintf("emp_ids must hold between 1 and %d ids", maxBatchSize), http.StatusUnprocessableEntity)
+		return
+	}
+
+	ctx := r.Context()
+
+	actor := actorFromRequest(r)
+	results := make([]BatchItemResult, len(input.EmpIDs))
+	deleted := 0
+	for i, id := range input.EmpIDs {
+		results[i] = BatchItemResult{Index: i, EmpID: id}
+		n, err := employees.SoftDelete(ctx, id, actor)
+		switch {
+		case err != nil:
+			results[i].Status, results[i].Errors = "error", map[string]string{"emp_id": err.Error()}
+		case n == 0:
+			results[i].Status = "not_found"
+		default:
+			results[i].Status = "deleted"
+			deleted++
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(bson.M{"message": "Batch delete finished", "deleted": deleted, "results": results})
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/karthikeyan-meenachisundaram/goBack/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// loadCustomFields returns all field definitions keyed by name
+func loadCustomFields(ctx context.Context) (map[string]models.CustomField, error) {
+	fields, err := definitions.CustomFields(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defs := make(map[string]models.CustomField, len(fields))
+	for _, f := range fields {
+		defs[f.Name] = f
+	}
+	return defs, nil
+}
+
+// validateCustomFields checks submitted values against the definitions.
+// On create, required fields must be present; on update a null value clears the field.
+// It returns the values to $set, the names to $unset, and per-field errors.
+func validateCustomFields(defs map[string]models.CustomField, values map[string]interface{}, creating bool) (bson.M, []string, map[string]string) {
+	set := bson.M{}
+	unset := []string{}
+	errs := map[string]string{}
+	for name, v := range values {
+		f, ok := defs[name]
+		if !ok {
+			errs[name] = "unknown custom field"
+			continue
+		}
+		if v == nil {
+			if f.Required {
+				errs[name] = "is required"
+			} else if !creating {
+				unset = append(unset, name)
+			}
+			continue
+		}
+		clean, err := f.Coerce(v)
+		if err != nil {
+			errs[name] = err.Error()
+			continue
+		}
+		set[name] = clean
+	}
+	if creating {
+		for name, f := range defs {
+			if _, ok := values[name]; f.Required && !ok {
+				errs[name] = "is required"
+			}
+		}
+	}
+	return set, unset, errs
+}
+
+// customFieldFilters reads ?cf.<name>=value query params into the values, typed like
+// the fields, that the listed employees' custom fields must equal
+func customFieldFilters(defs map[string]models.CustomField, q url.Values, admin bool) (map[string]interface{}, error) {
+	match := map[string]interface{}{}
+	for key, vals := range q {
+		name, ok := strings.CutPrefix(key, "cf.")
+		if !ok {
+			continue
+		}
+		f, ok := defs[name]
+		if !ok || (f.Visibility == "admin" && !admin) {
+			return nil, fmt.Errorf("unknown custom field %q", name)
+		}
+		raw := vals[0]
+		var v interface{} = raw
+		switch f.Type {
+		case "number":
+			n, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				return nil, fmt.Errorf("custom field %q: must be a number", name)
+			}
+			v = n
+		case "boolean":
+			b, err := strconv.ParseBool(raw)
+			if err != nil {
+				return nil, fmt.Errorf("custom field %q: must be a boolean", name)
+			}
+			v = b
+		}
+		match[name] = v
+	}
+	return match, nil
+}
+
+// hiddenCustomFields lists the custom_fields paths the caller may not see
+func hiddenCustomFields(defs map[string]models.CustomField, admin bool) []string {
+	hidden := []string{}
+	if admin {
+		return hidden
+	}
+	for name, f := range defs {
+		if f.Visibility == "admin" {
+			hidden = append(hidden, "custom_fields."+name)
+		}
+	}
+	return hidden
+}
+
+// ---------------- Handlers ----------------
+
+// customFieldsHandler handles GET (list) and POST (define) on /api/admin/custom-fields
+func customFieldsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	switch r.Method {
+	case http.MethodGet:
+		// readable by everyone so forms can be rendered; admin-only fields are hidden from others
+		defs, err := loadCustomFields(ctx)
+		if err != nil {
+			storeError(w, "find custom fields", err)
+			return
+		}
+		admin := isAdmin(r)
+		list := []models.CustomField{}
+		for _, f := range defs {
+			if f.Visibility == "public" || admin {
+				list = append(list, f)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(list)
+	case http.MethodPost:
+		if !requireAdmin(w, r) {
+			return
+		}
+		var f models.CustomField
+		if err := json.NewDecoder(r.Body).Decode(&f); err != nil {
+			httpError(w, "invalid input: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := f.Check(); err != nil {
+			httpError(w, "invalid custom field: "+err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		f.CreatedAt = time.Now().UTC()
+		res, err := coll(ctx, "CustomFields").UpdateOne(ctx, bson.M{"name": f.Name}, bson.M{"$setOnInsert": f}, options.Update().SetUpsert(true))
+		if err != nil {
+			storeError(w, "insert custom field", err)
+			return
+		}
+		if res.UpsertedCount == 0 {
+			httpError(w, "custom field already exists: "+f.Name, http.StatusConflict)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(f)
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// customFieldByNameHandler handles PUT and DELETE on /api/admin/custom-fields/{name}
+func customFieldByNameHandler(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/api/admin/custom-fields/")
+	if name == "" {
+		httpError(w, "name required in path", http.StatusBadRequest)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	ctx := r.Context()
+
+	switch r.Method {
+	case http.MethodPut:
+		var f models.CustomField
+		if err := json.NewDecoder(r.Body).Decode(&f); err != nil {
+			httpError(w, "invalid input: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.Name = name
+		if err := f.Check(); err != nil {
+			httpError(w, "invalid custom field: "+err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		var old models.CustomField
+		if err := coll(ctx, "CustomFields").FindOne(ctx, bson.M{"name": name}).Decode(&old); err != nil {
+			if err == mongo.ErrNoDocuments {
+				httpError(w, "custom field not found", http.StatusNotFound)
+				return
+			}
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if old.Type != f.Type {
+			httpError(w, "type of an existing custom field cannot change", http.StatusConflict)
+			return
+		}
+		f.CreatedAt = old.CreatedAt
+		if _, err := coll(ctx, "CustomFields").ReplaceOne(ctx, bson.M{"name": name}, f); err != nil {
+			storeError(w, "update custom field", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(f)
+	case http.MethodDelete:
+		res, err := coll(ctx, "CustomFields").DeleteOne(ctx, bson.M{"name": name})
+		if err != nil {
+			storeError(w, "delete custom field", err)
+			return
+		}
+		if res.DeletedCount == 0 {
+			httpError(w, "custom field not found", http.StatusNotFound)
+			return
+		}
+		// stored values are dropped along with the definition
+		if _, err := coll(ctx, "Employee").UpdateMany(ctx, bson.M{}, bson.M{"$unset": bson.M{"custom_fields." + name: ""}}); err != nil {
+			storeError(w, "unset custom field values", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(bson.M{"message": "Custom field deleted successfully"})
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/karthikeyan-meenachisundaram/goBack/models"
+	"github.com/karthikeyan-meenachisundaram/goBack/service"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// initDepartments migrates membership documents that still carry a department_name
+// instead of a dept_id
+func initDepartments(ctx context.Context) {
+	migrateCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Minute)
+	defer cancel()
+	if err := migrateDepartments(migrateCtx); err != nil {
+		slog.Error("initDepartments: migrate", "err", err)
+	}
+}
+
+// migrateDepartments creates a department for every distinct legacy department_name and
+// points its members at it by dept_id. It only touches unmigrated documents, so it is
+// safe to run on every start.
+func migrateDepartments(ctx context.Context) error {
+	legacy := bson.M{"dept_id": bson.M{"$exists": false}}
+	names, err := coll(ctx, "Department").Distinct(ctx, "department_name", legacy)
+	if err != nil {
+		return err
+	}
+	for _, n := range names {
+		name, _ := n.(string)
+		id, err := mongoStores.DepartmentID(ctx, name)
+		if err != nil {
+			return err
+		}
+		res, err := coll(ctx, "Department").UpdateMany(ctx,
+			bson.M{"department_name": name, "dept_id": bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{"dept_id": id}, "$unset": bson.M{"department_name": ""}})
+		if err != nil {
+			return err
+		}
+		slog.Info("migrated department", "name", name, "dept_id", id, "employees", res.ModifiedCount)
+	}
+	return nil
+}
+
+// findDepartment looks a department up by dept_id or, for older clients, by name
+func findDepartment(ctx context.Context, key string) (models.Department, error) {
+	if id, err := strconv.Atoi(key); err == nil {
+		return departments.Get(ctx, id)
+	}
+	return departments.ByName(ctx, key)
+}
+
+// checkDepartmentName trims and validates a department name like the employee payload does
+func checkDepartmentName(name *string) string {
+	return departmentList.checkName(name)
+}
+
+// ---------------- Handlers ----------------
+
+// departmentsHandler handles GET (list) and POST (create) on /api/departments
+func departmentsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	switch r.Method {
+	case http.MethodGet:
+		list, err := departments.List(ctx)
+		if err != nil {
+			storeError(w, "find departments", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(list)
+	case http.MethodPost:
+		var input struct {
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			httpError(w, "invalid input: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if msg := checkDepartmentName(&input.Name); msg != "" {
+			httpError(w, msg, http.StatusUnprocessableEntity)
+			return
+		}
+		d, err := departments.Create(ctx, input.Name)
+		if err != nil {
+			if errors.Is(err, models.ErrDuplicate) {
+				httpError(w, "department already exists: "+input.Name, http.StatusConflict)
+				return
+			}
+			storeError(w, "insert department", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(d)
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// departmentByIDHandler handles /api/departments/{id}[/{action}]; {id} is the dept_id, or
+// the department name as older clients send it
+func departmentByIDHandler(w http.ResponseWriter, r *http.Request) {
+	// path: /api/departments/{id}[/{action}]
+	rest := strings.TrimPrefix(r.URL.EscapedPath(), "/api/departments/")
+	escaped, action, _ := strings.Cut(rest, "/")
+	key, err := url.PathUnescape(escaped)
+	if err != nil || key == "" {
+		httpError(w, "invalid department id", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	dept, err := findDepartment(ctx, key)
+	if err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			httpError(w, "department not found", http.StatusNotFound)
+			return
+		}
+		storeError(w, "find department", err)
+		return
+	}
+
+	switch action {
+	case "":
+		department(w, r, dept)
+	case "reassign":
+		reassignDepartment(w, r, dept)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// department handles GET, PUT (rename) and DELETE on /api/departments/{id}
+func department(w http.ResponseWriter, r *http.Request, dept models.Department) {
+	ctx := r.Context()
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(dept)
+	case http.MethodPut:
+		var input struct {
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			httpError(w, "invalid input: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if msg := checkDepartmentName(&input.Name); msg != "" {
+			httpError(w, msg, http.StatusUnprocessableEntity)
+			return
+		}
+		if err := departments.Rename(ctx, dept.DeptID, input.Name); err != nil {
+			if errors.Is(err, models.ErrDuplicate) {
+				httpError(w, "department already exists: "+input.Name, http.StatusConflict)
+				return
+			}
+			storeError(w, "rename department", err)
+			return
+		}
+		dept.Name = input.Name
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(dept)
+	case http.MethodDelete:
+		// members in the trash count too, they would come back without a department
+		n, err := departments.Members(ctx, dept.DeptID)
+		if err != nil {
+			storeError(w, "count members", err)
+			return
+		}
+		if n > 0 {
+			writeError(w, http.StatusConflict, "department still has employees; reassign them first", bson.M{"employees": n})
+			return
+		}
+		if err := departments.Delete(ctx, dept.DeptID); err != nil {
+			storeError(w, "delete department", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(bson.M{"message": "Department deleted successfully"})
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// reassignDepartment handles POST /api/departments/{id}/reassign.
+// Moves all (or the selected emp_ids) employees of a department to another one
+// in a single transaction, recording a transfer for each moved employee.
+func reassignDepartment(w http.ResponseWriter, r *http.Request, dept models.Department) {
+	from := dept.Name
+	if r.Method != http.MethodPost {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var input struct {
+		ToDepartment  string `json:"to_department"`
+		EmpIDs        []int  `json:"emp_ids"` // optional, defaults to everyone in the department
+		EffectiveDate string `json:"effective_date"`
+		Reason        string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		httpError(w, "invalid input: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if input.ToDepartment == "" {
+		httpError(w, "to_department is required", http.StatusUnprocessableEntity)
+		return
+	}
+	if input.ToDepartment == from {
+		httpError(w, "to_department must differ from the source department", http.StatusUnprocessableEntity)
+		return
+	}
+	effective, err := service.EffectiveDate(input.EffectiveDate)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	ctx := r.Context()
+
+	res, err := departments.Reassign(ctx, dept, input.EmpIDs, models.Transfer{
+		FromDepartment: from,
+		ToDepartment:   input.ToDepartment,
+		EffectiveDate:  effective,
+		Reason:         input.Reason,
+		RecordedAt:     time.Now().UTC(),
+		RecordedBy:     actorFromRequest(r),
+	})
+	if err != nil {
+		storeError(w, "reassign", err)
+		return
+	}
+
+	// selected ids that were not in the source department
+	notFound := []int{}
+	if len(input.EmpIDs) > 0 {
+		seen := map[int]bool{}
+		for _, id := range append(res.Moved, res.Skipped...) {
+			seen[id] = true
+		}
+		for _, id := range input.EmpIDs {
+			if !seen[id] {
+				notFound = append(notFound, id)
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(bson.M{
+		"message":         "Department reassigned successfully",
+		"from_department": from,
+		"to_department":   input.ToDepartment,
+		"moved_count":     len(res.Moved),
+		"skipped_count":   len(res.Skipped),
+		"not_found_count": len(notFound),
+		"moved":           res.Moved,
+		"skipped":         res.Skipped,
+		"not_found":       notFound,
+	})
+}
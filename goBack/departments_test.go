@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/karthikeyan-meenachisundaram/goBack/models"
+)
+
+func TestDepartments(t *testing.T) {
+	s := useTestStores(t)
+
+	w := asAdmin(t, departmentsHandler, http.MethodPost, "/api/departments", `{"name":"  Engg "}`)
+	expectStatus(t, w, http.StatusCreated)
+	if d := decode(t, w); d["name"] != "Engg" || d["dept_id"] != 1.0 {
+		t.Errorf("department = %v", d)
+	}
+	w = asAdmin(t, departmentsHandler, http.MethodPost, "/api/departments", `{"name":"engg"}`)
+	expectStatus(t, w, http.StatusConflict)
+	w = asAdmin(t, departmentsHandler, http.MethodPost, "/api/departments", `{"name":""}`)
+	expectStatus(t, w, http.StatusUnprocessableEntity)
+
+	// by dept_id or, as older clients send it, by name
+	w = asAdmin(t, departmentByIDHandler, http.MethodGet, "/api/departments/1", "")
+	expectStatus(t, w, http.StatusOK)
+	w = asAdmin(t, departmentByIDHandler, http.MethodGet, "/api/departments/Engg", "")
+	expectStatus(t, w, http.StatusOK)
+	w = asAdmin(t, departmentByIDHandler, http.MethodGet, "/api/departments/Sales", "")
+	expectStatus(t, w, http.StatusNotFound)
+
+	w = asAdmin(t, departmentByIDHandler, http.MethodPut, "/api/departments/1", `{"name":"Engineering"}`)
+	expectStatus(t, w, http.StatusOK)
+	if s.departments.list[0].Name != "Engineering" {
+		t.Errorf("name = %q after rename", s.departments.list[0].Name)
+	}
+}
+
+func TestDeleteDepartment(t *testing.T) {
+	s := useTestStores(t)
+	s.departments.list = []models.Department{{DeptID: 1, Name: "Engg"}, {DeptID: 2, Name: "Ops"}}
+	s.departments.members = map[int]int64{1: 3}
+
+	w := asAdmin(t, departmentByIDHandler, http.MethodDelete, "/api/departments/1", "")
+	expectStatus(t, w, http.StatusConflict)
+	w = asAdmin(t, departmentByIDHandler, http.MethodDelete, "/api/departments/2", "")
+	expectStatus(t, w, http.StatusOK)
+	if len(s.departments.list) != 1 {
+		t.Errorf("departments = %+v", s.departments.list)
+	}
+}
+
+func TestReassignDepartment(t *testing.T) {
+	s := useTestStores(t)
+	s.departments.list = []models.Department{{DeptID: 1, Name: "Engg"}, {DeptID: 2, Name: "Ops"}}
+	s.addEmployees(t,
+		models.NewEmployee{EmpID: 1, EmpName: "Asha", Department: "Engg"},
+		models.NewEmployee{EmpID: 2, EmpName: "Ravi", Department: "Engg"},
+		models.NewEmployee{EmpID: 3, EmpName: "Meena", Department: "Ops"},
+	)
+	if err := s.employees.Terminate(t.Context(), 2, models.Termination{Reason: "layoff"}, "admin"); err != nil {
+		t.Fatal(err)
+	}
+
+	w := asAdmin(t, departmentByIDHandler, http.MethodPost, "/api/departments/1/reassign", `{"to_department":"Ops","emp_ids":[1,2,3]}`)
+	expectStatus(t, w, http.StatusOK)
+	res := s.departments.reassigned
+	if !slices.Equal(res.Moved, []int{1}) || !slices.Equal(res.Skipped, []int{2}) {
+		t.Errorf("reassigned = %+v", res)
+	}
+	if out := decode(t, w); out["not_found_count"] != 1.0 {
+		t.Errorf("response = %v", out)
+	}
+
+	w = asAdmin(t, departmentByIDHandler, http.MethodPost, "/api/departments/1/reassign", `{"to_department":"Engg"}`)
+	expectStatus(t, w, http.StatusUnprocessableEntity)
+}
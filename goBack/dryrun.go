@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/karthikeyan-meenachisundaram/goBack/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// A dry run (?dry_run=true, or the header Prefer: handling=validate) of a create, update,
+// batch create, import or restore validates the request in full, with the same database
+// lookups, but writes nothing and answers 200 with what the request would change. The
+// changes read like an audit entry's, so a preview can be compared with the result.
+
+// preferValidate reports whether r carries the preference handling=validate
+func preferValidate(r *http.Request) bool {
+	for _, h := range r.Header.Values("Prefer") {
+		for _, pref := range strings.Split(h, ",") {
+			pref, _, _ = strings.Cut(pref, ";") // parameters of the preference
+			name, value, _ := strings.Cut(pref, "=")
+			if strings.EqualFold(strings.TrimSpace(name), "handling") &&
+				strings.EqualFold(strings.Trim(strings.TrimSpace(value), `"`), "validate") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isDryRun reports whether r asks for a dry run
+func isDryRun(r *http.Request) bool {
+	return r.URL.Query().Get("dry_run") == "true" || preferValidate(r)
+}
+
+// dryRun is isDryRun for the handler answering r, which confirms the preference with
+// Preference-Applied when it came as a header
+func dryRun(w http.ResponseWriter, r *http.Request) bool {
+	if preferValidate(r) {
+		w.Header().Set("Preference-Applied", "handling=validate")
+	}
+	return isDryRun(r)
+}
+
+// normalizedFields round-trips fields through BSON so their values compare equal with
+// the ones of a snapshot read back from the database
+func normalizedFields(fields bson.M) bson.M {
+	b, err := bson.Marshal(fields)
+	if err != nil {
+		return fields
+	}
+	var out bson.M
+	if err := bson.Unmarshal(b, &out); err != nil {
+		return fields
+	}
+	return out
+}
+
+// newEmployeeSnapshot is the snapshot e would have once created
+func newEmployeeSnapshot(e models.NewEmployee) bson.M {
+	languages := e.Languages
+	if languages == nil {
+		languages = []string{}
+	}
+	doc := bson.M{
+		"emp_name":   e.EmpName,
+		"department": e.Department,
+		"languages":  languages,
+		"status":     "active",
+	}
+	if e.ManagerID != 0 {
+		doc["manager_id"] = e.ManagerID
+	}
+	if len(e.CustomFields) > 0 {
+		doc["custom_fields"] = e.CustomFields
+	}
+	return snapshotFields(normalizedFields(doc))
+}
+
+// changedSnapshot is before with c applied, as employees.Update would leave it
+func changedSnapshot(before bson.M, c models.EmployeeChange) bson.M {
+	set := bson.M{}
+	for name, v := range c.CustomFields {
+		set["custom_fields."+name] = v
+	}
+	if c.EmpName != nil {
+		set["emp_name"] = *c.EmpName
+	}
+	if c.Department != nil {
+		set["department"] = *c.Department
+	}
+	if c.Languages != nil {
+		set["languages"] = c.Languages
+	}
+	if c.ManagerID != nil && *c.ManagerID != 0 {
+		set["manager_id"] = *c.ManagerID
+	}
+
+	after := bson.M{}
+	for k, v := range before {
+		after[k] = v
+	}
+	for k, v := range normalizedFields(set) {
+		after[k] = v
+	}
+	for _, name := range c.ClearFields {
+		delete(after, "custom_fields."+name)
+	}
+	if c.ManagerID != nil && *c.ManagerID == 0 {
+		delete(after, "manager_id")
+	}
+	return after
+}
+
+// previewChanges is the audit diff of before and after, ready to encode as JSON
+func previewChanges(before, after bson.M) map[string]FieldChange {
+	changes := map[string]FieldChange{}
+	for f, c := range diffSnapshots(before, after) {
+		changes[f] = FieldChange{Before: plainValue(c.Before), After: plainValue(c.After)}
+	}
+	return changes
+}
+
+// writeDryRun answers a dry run with the message and details of what would happen
+func writeDryRun(w http.ResponseWriter, message string, details bson.M) {
+	body := bson.M{"dry_run": true, "message": message}
+	for k, v := range details {
+		body[k] = v
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/karthikeyan-meenachisundaram/goBack/repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// The distribution list in email.to hears about every employee created (onboarding) or
+// deleted (offboarding). Like the webhooks, the emails are queued from the employee
+// events and sent by the job workers, which retry a failed send.
+
+// emailEvents maps the employee events that send an email to their template
+var emailEvents = map[string]string{
+	"created": "onboarding",
+	"deleted": "offboarding",
+}
+
+// defaultEmailTemplates are the built-in templates; each defines "subject" and "body"
+var defaultEmailTemplates = map[string]string{
+	"onboarding": `{{define "subject"}}Welcome {{.EmpName}} (employee {{.EmpID}}){{end}}` +
+		`{{define "body"}}{{.EmpName}} (employee {{.EmpID}}) has joined{{with .Department}} the {{.}} department{{end}}.
+{{with .Languages}}
+Languages: {{join . ", "}}
+{{end}}
+Organization: {{.Org}}
+Added: {{.At.Format "2 January 2006 15:04 MST"}}
+{{end}}`,
+	"offboarding": `{{define "subject"}}{{.EmpName}} (employee {{.EmpID}}) has left{{end}}` +
+		`{{define "body"}}{{.EmpName}} (employee {{.EmpID}}){{with .Department}} of the {{.}} department{{end}} has been removed from the directory.
+Please revoke their access and collect their equipment.
+
+Organization: {{.Org}}
+Removed: {{.At.Format "2 January 2006 15:04 MST"}}
+{{end}}`,
+}
+
+// emailData is what the templates are executed with
+type emailData struct {
+	Event      string // created or deleted
+	Org        string
+	EmpID      int
+	EmpName    string
+	Department string
+	Languages  []string
+	At         time.Time
+}
+
+// emailTemplates are the parsed templates by name, set by startEmails
+var emailTemplates map[string]*template.Template
+
+// loadEmailTemplates parses the built-in templates and the ones in email.templates_dir
+// that replace them
+func loadEmailTemplates() (map[string]*template.Template, error) {
+	templates := map[string]*template.Template{}
+	for name, text := range defaultEmailTemplates {
+		source := name + " (built-in)"
+		if dir := cfg.Email.TemplatesDir; dir != "" {
+			path := filepath.Join(dir, name+".tmpl")
+			b, err := os.ReadFile(path)
+			switch {
+			case err == nil:
+				text, source = string(b), path
+			case !errors.Is(err, os.ErrNotExist):
+				return nil, err
+			}
+		}
+		t, err := template.New(name).Funcs(template.FuncMap{"join": strings.Join}).Parse(text)
+		if err != nil {
+			return nil, err
+		}
+		for _, part := range []string{"subject", "body"} {
+			if t.Lookup(part) == nil {
+				return nil, fmt.Errorf("%s does not define %q", source, part)
+			}
+		}
+		templates[name] = t
+	}
+	return templates, nil
+}
+
+// renderEmail executes the subject and body of template name with d
+func renderEmail(name string, d emailData) (subject, body string, err error) {
+	t := emailTemplates[name]
+	var b bytes.Buffer
+	if err := t.ExecuteTemplate(&b, "subject", d); err != nil {
+		return "", "", err
+	}
+	// a header is one line
+	subject = strings.Join(strings.Fields(b.String()), " ")
+	b.Reset()
+	if err := t.ExecuteTemplate(&b, "body", d); err != nil {
+		return "", "", err
+	}
+	return subject, b.String(), nil
+}
+
+// startEmails queues the onboarding and offboarding emails until ctx ends; the job
+// workers send them. As with startWebhooks, every instance sees every event and the
+// job key makes one email of it.
+func startEmails(ctx context.Context) {
+	if !cfg.Email.enabled() {
+		return
+	}
+	templates, err := loadEmailTemplates()
+	if err != nil {
+		fatal("email templates", "err", err)
+	}
+	emailTemplates = templates
+	events, _ := subscribeEvents(0)
+	go func() {
+		defer unsubscribeEvents(events)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case e := <-events:
+				if _, ok := emailEvents[e.Type]; ok {
+					go dispatchEmail(ctx, e)
+				}
+			}
+		}
+	}()
+}
+
+// dispatchEmail renders the email for e and queues it. The employee is looked up with
+// the deleted ones, so an offboarding email still has the name and department.
+func dispatchEmail(ctx context.Context, e EmployeeEvent) {
+	ctx = withOrg(ctx, e.Org)
+	lookupCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	var emp struct {
+		EmpName    string   `bson:"emp_name"`
+		Department string   `bson:"department"`
+		Languages  []string `bson:"languages"`
+		Version    int64    `bson:"version"`
+	}
+	var deleted struct {
+		DeletedAt time.Time `bson:"deleted_at"`
+	}
+	pipeline := append(repository.DetailsPipeline(bson.M{"emp_id": e.EmpID}),
+		bson.D{{Key: "$project", Value: bson.M{"emp_name": 1, "department": 1, "languages": 1, "version": 1}}})
+	cur, err := coll(lookupCtx, "Employee").Aggregate(lookupCtx, pipeline)
+	if err == nil {
+		defer cur.Close(lookupCtx)
+		if !cur.Next(lookupCtx) {
+			err = cur.Err()
+			if err == nil {
+				return // purged meanwhile; there is no one to write about
+			}
+		} else {
+			err = cur.Decode(&emp)
+		}
+	}
+	if err == nil && e.Type == "deleted" {
+		err = coll(lookupCtx, "Employee").FindOne(lookupCtx, bson.M{"emp_id": e.EmpID},
+			options.FindOne().SetProjection(bson.M{"deleted_at": 1})).Decode(&deleted)
+	}
+	if err != nil {
+		slog.Error("email: find employee", "emp_id", e.EmpID, "err", err)
+		return
+	}
+
+	eventID := fmt.Sprintf("emp-%d-created-v%d", e.EmpID, emp.Version)
+	if e.Type == "deleted" {
+		eventID = fmt.Sprintf("emp-%d-deleted-%d", e.EmpID, deleted.DeletedAt.UnixMilli())
+	}
+	subject, body, err := renderEmail(emailEvents[e.Type], emailData{
+		Event:      e.Type,
+		Org:        e.Org,
+		EmpID:      e.EmpID,
+		EmpName:    emp.EmpName,
+		Department: emp.Department,
+		Languages:  emp.Languages,
+		At:         e.At,
+	})
+	if err != nil {
+		slog.Error("email: render", "template", emailEvents[e.Type], "emp_id", e.EmpID, "err", err)
+		return
+	}
+	payload := bson.M{"event_id": eventID, "to": cfg.Email.To, "subject": subject, "body": body}
+	if err := enqueueJob(ctx, "email", "email:"+eventID, payload, time.Now()); err != nil {
+		slog.Error("email: queue", "event_id", eventID, "err", err)
+	}
+}
+
+// runEmailJob sends one queued email. 5xx answers from the server are final; recipients
+// it refuses with one are left out rather than failing the send for everyone.
+func runEmailJob(ctx context.Context, j Job) (string, error) {
+	var p struct {
+		EventID string   `bson:"event_id"`
+		To      []string `bson:"to"`
+		Subject string   `bson:"subject"`
+		Body    string   `bson:"body"`
+	}
+	if b, err := bson.Marshal(j.Payload); err != nil || bson.Unmarshal(b, &p) != nil {
+		return "", permanent(fmt.Errorf("invalid email job payload"))
+	}
+	from, err := mail.ParseAddress(cfg.Email.From)
+	if err != nil {
+		return "", permanent(fmt.Errorf("email.from: %w", err))
+	}
+	var to []*mail.Address
+	for _, s := range p.To {
+		a, err := mail.ParseAddress(s)
+		if err != nil {
+			return "", permanent(fmt.Errorf("recipient %q: %w", s, err))
+		}
+		to = append(to, a)
+	}
+	msg, err := emailMessage(from, to, p.EventID, p.Subject, p.Body)
+	if err != nil {
+		return "", permanent(err)
+	}
+
+	refused, err := sendMail(ctx, from.Address, to, msg)
+	if err != nil {
+		return "", err
+	}
+	if len(refused) > 0 {
+		slog.Warn("email: recipients refused", "event_id", p.EventID, "recipients", refused)
+		return fmt.Sprintf("sent to %d of %d recipients; refused %s", len(to)-len(refused), len(to), strings.Join(refused, ", ")), nil
+	}
+	return fmt.Sprintf("sent to %d recipients", len(to)), nil
+}
+
+// emailMessage is the plain text message for the email with subject and body. The
+// Message-ID comes from the event, so a resend after a lost answer is recognisable.
+func emailMessage(from *mail.Address, to []*mail.Address, eventID, subject, body string) ([]byte, error) {
+	recipients := make([]string, len(to))
+	for i, a := range to {
+		recipients[i] = a.String()
+	}
+	domain := from.Address[strings.LastIndex(from.Address, "@")+1:]
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from.String())
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(recipients, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "Message-ID: <%s@%s>\r\n", eventID, domain)
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+	qp := quotedprintable.NewWriter(&b)
+	if _, err := qp.Write([]byte(strings.ReplaceAll(body, "\n", "\r\n"))); err != nil {
+		return nil, err
+	}
+	if err := qp.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// sendMail delivers msg through the SMTP server in cfg.Email and returns the recipients
+// it refused for good. The whole exchange is bounded by email.timeout.
+func sendMail(ctx context.Context, from string, to []*mail.Address, msg []byte) ([]string, error) {
+	e := cfg.Email
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(e.Timeout))
+	defer cancel()
+	addr := net.JoinHostPort(e.SMTPHost, strconv.Itoa(e.SMTPPort))
+	tlsConfig := &tls.Config{ServerName: e.SMTPHost}
+
+	var conn net.Conn
+	var err error
+	if e.TLS == "implicit" {
+		conn, err = (&tls.Dialer{Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	deadline, _ := ctx.Deadline()
+	_ = conn.SetDeadline(deadline)
+	c, err := smtp.NewClient(conn, e.SMTPHost)
+	if err != nil {
+		conn.Close()
+		return nil, smtpError(err)
+	}
+	defer c.Close()
+
+	if e.TLS == "starttls" {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return nil, permanent(fmt.Errorf("%s does not offer STARTTLS", addr))
+		}
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return nil, smtpError(err)
+		}
+	}
+	if e.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", e.Username, e.Password, e.SMTPHost)); err != nil {
+			return nil, smtpError(err)
+		}
+	}
+	if err := c.Mail(from); err != nil {
+		return nil, smtpError(err)
+	}
+	var refused []string
+	for _, a := range to {
+		if err := c.Rcpt(a.Address); err != nil {
+			if !isPermanentSMTP(err) {
+				return nil, err
+			}
+			refused = append(refused, a.Address)
+		}
+	}
+	if len(refused) == len(to) {
+		return nil, permanent(fmt.Errorf("every recipient was refused"))
+	}
+	w, err := c.Data()
+	if err != nil {
+		return nil, smtpError(err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, smtpError(err)
+	}
+	_ = c.Quit()
+	return refused, nil
+}
+
+// isPermanentSMTP reports whether err is a 5xx reply, which a retry gets again
+func isPermanentSMTP(err error) bool {
+	var te *textproto.Error
+	return errors.As(err, &te) && te.Code >= 500
+}
+
+// smtpError marks 5xx replies as permanent; the job queue retries the rest
+func smtpError(err error) error {
+	if isPermanentSMTP(err) {
+		return permanent(err)
+	}
+	return err
+}
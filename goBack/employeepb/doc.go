@@ -0,0 +1,5 @@
+// Package employeepb is the generated code for proto/employee.proto, the gRPC
+// EmployeeService. Other Go services import it for the client.
+package employeepb
+
+//go:generate protoc -I ../proto --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative employee.proto
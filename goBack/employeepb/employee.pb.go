@@ -0,0 +1,851 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.10
+// 	protoc        (unknown)
+// source: employee.proto
+
+package employeepb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Employee struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	EmpId      int32                  `protobuf:"varint,1,opt,name=emp_id,json=empId,proto3" json:"emp_id,omitempty"`
+	EmpName    string                 `protobuf:"bytes,2,opt,name=emp_name,json=empName,proto3" json:"emp_name,omitempty"`
+	Department string                 `protobuf:"bytes,3,opt,name=department,proto3" json:"department,omitempty"`
+	// the first one is the primary language
+	Languages []string `protobuf:"bytes,4,rep,name=languages,proto3" json:"languages,omitempty"`
+	// active, inactive or terminated
+	Status   string   `protobuf:"bytes,5,opt,name=status,proto3" json:"status,omitempty"`
+	Tags     []string `protobuf:"bytes,6,rep,name=tags,proto3" json:"tags,omitempty"`
+	PhotoUrl string   `protobuf:"bytes,7,opt,name=photo_url,json=photoUrl,proto3" json:"photo_url,omitempty"`
+	// changes on every update; pass it to Update to detect concurrent edits
+	Version       int32                  `protobuf:"varint,8,opt,name=version,proto3" json:"version,omitempty"`
+	UpdatedAt     *timestamppb.Timestamp `protobuf:"bytes,9,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Employee) Reset() {
+	*x = Employee{}
+	mi := &file_employee_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Employee) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Employee) ProtoMessage() {}
+
+func (x *Employee) ProtoReflect() protoreflect.Message {
+	mi := &file_employee_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Employee.ProtoReflect.Descriptor instead.
+func (*Employee) Descriptor() ([]byte, []int) {
+	return file_employee_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Employee) GetEmpId() int32 {
+	if x != nil {
+		return x.EmpId
+	}
+	return 0
+}
+
+func (x *Employee) GetEmpName() string {
+	if x != nil {
+		return x.EmpName
+	}
+	return ""
+}
+
+func (x *Employee) GetDepartment() string {
+	if x != nil {
+		return x.Department
+	}
+	return ""
+}
+
+func (x *Employee) GetLanguages() []string {
+	if x != nil {
+		return x.Languages
+	}
+	return nil
+}
+
+func (x *Employee) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Employee) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *Employee) GetPhotoUrl() string {
+	if x != nil {
+		return x.PhotoUrl
+	}
+	return ""
+}
+
+func (x *Employee) GetVersion() int32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *Employee) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+type EmployeeFilter struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	Status     string                 `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	Department string                 `protobuf:"bytes,2,opt,name=department,proto3" json:"department,omitempty"`
+	// matches any of the employee's languages
+	Language string `protobuf:"bytes,3,opt,name=language,proto3" json:"language,omitempty"`
+	// employees carrying all of them
+	Tags []string `protobuf:"bytes,4,rep,name=tags,proto3" json:"tags,omitempty"`
+	// comma-separated fields, "-" prefix for descending, e.g. "emp_name,-emp_id"
+	Sort          string `protobuf:"bytes,5,opt,name=sort,proto3" json:"sort,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EmployeeFilter) Reset() {
+	*x = EmployeeFilter{}
+	mi := &file_employee_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EmployeeFilter) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EmployeeFilter) ProtoMessage() {}
+
+func (x *EmployeeFilter) ProtoReflect() protoreflect.Message {
+	mi := &file_employee_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EmployeeFilter.ProtoReflect.Descriptor instead.
+func (*EmployeeFilter) Descriptor() ([]byte, []int) {
+	return file_employee_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *EmployeeFilter) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *EmployeeFilter) GetDepartment() string {
+	if x != nil {
+		return x.Department
+	}
+	return ""
+}
+
+func (x *EmployeeFilter) GetLanguage() string {
+	if x != nil {
+		return x.Language
+	}
+	return ""
+}
+
+func (x *EmployeeFilter) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *EmployeeFilter) GetSort() string {
+	if x != nil {
+		return x.Sort
+	}
+	return ""
+}
+
+type ListEmployeesRequest struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Filter *EmployeeFilter        `protobuf:"bytes,1,opt,name=filter,proto3" json:"filter,omitempty"`
+	// 1 when unset
+	Page int32 `protobuf:"varint,2,opt,name=page,proto3" json:"page,omitempty"`
+	// 20 when unset, at most 100
+	Limit         int32 `protobuf:"varint,3,opt,name=limit,proto3" json:"limit,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListEmployeesRequest) Reset() {
+	*x = ListEmployeesRequest{}
+	mi := &file_employee_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListEmployeesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListEmployeesRequest) ProtoMessage() {}
+
+func (x *ListEmployeesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_employee_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListEmployeesRequest.ProtoReflect.Descriptor instead.
+func (*ListEmployeesRequest) Descriptor() ([]byte, []int) {
+	return file_employee_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ListEmployeesRequest) GetFilter() *EmployeeFilter {
+	if x != nil {
+		return x.Filter
+	}
+	return nil
+}
+
+func (x *ListEmployeesRequest) GetPage() int32 {
+	if x != nil {
+		return x.Page
+	}
+	return 0
+}
+
+func (x *ListEmployeesRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type ListEmployeesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Items         []*Employee            `protobuf:"bytes,1,rep,name=items,proto3" json:"items,omitempty"`
+	Page          int32                  `protobuf:"varint,2,opt,name=page,proto3" json:"page,omitempty"`
+	Limit         int32                  `protobuf:"varint,3,opt,name=limit,proto3" json:"limit,omitempty"`
+	Total         int32                  `protobuf:"varint,4,opt,name=total,proto3" json:"total,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListEmployeesResponse) Reset() {
+	*x = ListEmployeesResponse{}
+	mi := &file_employee_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListEmployeesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListEmployeesResponse) ProtoMessage() {}
+
+func (x *ListEmployeesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_employee_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListEmployeesResponse.ProtoReflect.Descriptor instead.
+func (*ListEmployeesResponse) Descriptor() ([]byte, []int) {
+	return file_employee_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ListEmployeesResponse) GetItems() []*Employee {
+	if x != nil {
+		return x.Items
+	}
+	return nil
+}
+
+func (x *ListEmployeesResponse) GetPage() int32 {
+	if x != nil {
+		return x.Page
+	}
+	return 0
+}
+
+func (x *ListEmployeesResponse) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *ListEmployeesResponse) GetTotal() int32 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+type StreamEmployeesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Filter        *EmployeeFilter        `protobuf:"bytes,1,opt,name=filter,proto3" json:"filter,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamEmployeesRequest) Reset() {
+	*x = StreamEmployeesRequest{}
+	mi := &file_employee_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamEmployeesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamEmployeesRequest) ProtoMessage() {}
+
+func (x *StreamEmployeesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_employee_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamEmployeesRequest.ProtoReflect.Descriptor instead.
+func (*StreamEmployeesRequest) Descriptor() ([]byte, []int) {
+	return file_employee_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *StreamEmployeesRequest) GetFilter() *EmployeeFilter {
+	if x != nil {
+		return x.Filter
+	}
+	return nil
+}
+
+type GetEmployeeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	EmpId         int32                  `protobuf:"varint,1,opt,name=emp_id,json=empId,proto3" json:"emp_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetEmployeeRequest) Reset() {
+	*x = GetEmployeeRequest{}
+	mi := &file_employee_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetEmployeeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetEmployeeRequest) ProtoMessage() {}
+
+func (x *GetEmployeeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_employee_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetEmployeeRequest.ProtoReflect.Descriptor instead.
+func (*GetEmployeeRequest) Descriptor() ([]byte, []int) {
+	return file_employee_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *GetEmployeeRequest) GetEmpId() int32 {
+	if x != nil {
+		return x.EmpId
+	}
+	return 0
+}
+
+type CreateEmployeeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	EmpName       string                 `protobuf:"bytes,1,opt,name=emp_name,json=empName,proto3" json:"emp_name,omitempty"`
+	Department    string                 `protobuf:"bytes,2,opt,name=department,proto3" json:"department,omitempty"`
+	Languages     []string               `protobuf:"bytes,3,rep,name=languages,proto3" json:"languages,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateEmployeeRequest) Reset() {
+	*x = CreateEmployeeRequest{}
+	mi := &file_employee_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateEmployeeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateEmployeeRequest) ProtoMessage() {}
+
+func (x *CreateEmployeeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_employee_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateEmployeeRequest.ProtoReflect.Descriptor instead.
+func (*CreateEmployeeRequest) Descriptor() ([]byte, []int) {
+	return file_employee_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *CreateEmployeeRequest) GetEmpName() string {
+	if x != nil {
+		return x.EmpName
+	}
+	return ""
+}
+
+func (x *CreateEmployeeRequest) GetDepartment() string {
+	if x != nil {
+		return x.Department
+	}
+	return ""
+}
+
+func (x *CreateEmployeeRequest) GetLanguages() []string {
+	if x != nil {
+		return x.Languages
+	}
+	return nil
+}
+
+// Languages wraps a language list so Update can tell "unchanged" (unset) from "clear"
+// (set and empty)
+type Languages struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Values        []string               `protobuf:"bytes,1,rep,name=values,proto3" json:"values,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Languages) Reset() {
+	*x = Languages{}
+	mi := &file_employee_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Languages) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Languages) ProtoMessage() {}
+
+func (x *Languages) ProtoReflect() protoreflect.Message {
+	mi := &file_employee_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Languages.ProtoReflect.Descriptor instead.
+func (*Languages) Descriptor() ([]byte, []int) {
+	return file_employee_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Languages) GetValues() []string {
+	if x != nil {
+		return x.Values
+	}
+	return nil
+}
+
+type UpdateEmployeeRequest struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	EmpId      int32                  `protobuf:"varint,1,opt,name=emp_id,json=empId,proto3" json:"emp_id,omitempty"`
+	EmpName    *string                `protobuf:"bytes,2,opt,name=emp_name,json=empName,proto3,oneof" json:"emp_name,omitempty"`
+	Department *string                `protobuf:"bytes,3,opt,name=department,proto3,oneof" json:"department,omitempty"`
+	Languages  *Languages             `protobuf:"bytes,4,opt,name=languages,proto3" json:"languages,omitempty"`
+	// when set the update only applies to this version (FAILED_PRECONDITION otherwise)
+	Version       *int32 `protobuf:"varint,5,opt,name=version,proto3,oneof" json:"version,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateEmployeeRequest) Reset() {
+	*x = UpdateEmployeeRequest{}
+	mi := &file_employee_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateEmployeeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateEmployeeRequest) ProtoMessage() {}
+
+func (x *UpdateEmployeeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_employee_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateEmployeeRequest.ProtoReflect.Descriptor instead.
+func (*UpdateEmployeeRequest) Descriptor() ([]byte, []int) {
+	return file_employee_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *UpdateEmployeeRequest) GetEmpId() int32 {
+	if x != nil {
+		return x.EmpId
+	}
+	return 0
+}
+
+func (x *UpdateEmployeeRequest) GetEmpName() string {
+	if x != nil && x.EmpName != nil {
+		return *x.EmpName
+	}
+	return ""
+}
+
+func (x *UpdateEmployeeRequest) GetDepartment() string {
+	if x != nil && x.Department != nil {
+		return *x.Department
+	}
+	return ""
+}
+
+func (x *UpdateEmployeeRequest) GetLanguages() *Languages {
+	if x != nil {
+		return x.Languages
+	}
+	return nil
+}
+
+func (x *UpdateEmployeeRequest) GetVersion() int32 {
+	if x != nil && x.Version != nil {
+		return *x.Version
+	}
+	return 0
+}
+
+type DeleteEmployeeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	EmpId         int32                  `protobuf:"varint,1,opt,name=emp_id,json=empId,proto3" json:"emp_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteEmployeeRequest) Reset() {
+	*x = DeleteEmployeeRequest{}
+	mi := &file_employee_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteEmployeeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteEmployeeRequest) ProtoMessage() {}
+
+func (x *DeleteEmployeeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_employee_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteEmployeeRequest.ProtoReflect.Descriptor instead.
+func (*DeleteEmployeeRequest) Descriptor() ([]byte, []int) {
+	return file_employee_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *DeleteEmployeeRequest) GetEmpId() int32 {
+	if x != nil {
+		return x.EmpId
+	}
+	return 0
+}
+
+type DeleteEmployeeResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Deleted       bool                   `protobuf:"varint,1,opt,name=deleted,proto3" json:"deleted,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteEmployeeResponse) Reset() {
+	*x = DeleteEmployeeResponse{}
+	mi := &file_employee_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteEmployeeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteEmployeeResponse) ProtoMessage() {}
+
+func (x *DeleteEmployeeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_employee_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteEmployeeResponse.ProtoReflect.Descriptor instead.
+func (*DeleteEmployeeResponse) Descriptor() ([]byte, []int) {
+	return file_employee_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *DeleteEmployeeResponse) GetDeleted() bool {
+	if x != nil {
+		return x.Deleted
+	}
+	return false
+}
+
+var File_employee_proto protoreflect.FileDescriptor
+
+const file_employee_proto_rawDesc = "" +
+	"\n" +
+	"\x0eemployee.proto\x12\vemployee.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\x98\x02\n" +
+	"\bEmployee\x12\x15\n" +
+	"\x06emp_id\x18\x01 \x01(\x05R\x05empId\x12\x19\n" +
+	"\bemp_name\x18\x02 \x01(\tR\aempName\x12\x1e\n" +
+	"\n" +
+	"department\x18\x03 \x01(\tR\n" +
+	"department\x12\x1c\n" +
+	"\tlanguages\x18\x04 \x03(\tR\tlanguages\x12\x16\n" +
+	"\x06status\x18\x05 \x01(\tR\x06status\x12\x12\n" +
+	"\x04tags\x18\x06 \x03(\tR\x04tags\x12\x1b\n" +
+	"\tphoto_url\x18\a \x01(\tR\bphotoUrl\x12\x18\n" +
+	"\aversion\x18\b \x01(\x05R\aversion\x129\n" +
+	"\n" +
+	"updated_at\x18\t \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\"\x8c\x01\n" +
+	"\x0eEmployeeFilter\x12\x16\n" +
+	"\x06status\x18\x01 \x01(\tR\x06status\x12\x1e\n" +
+	"\n" +
+	"department\x18\x02 \x01(\tR\n" +
+	"department\x12\x1a\n" +
+	"\blanguage\x18\x03 \x01(\tR\blanguage\x12\x12\n" +
+	"\x04tags\x18\x04 \x03(\tR\x04tags\x12\x12\n" +
+	"\x04sort\x18\x05 \x01(\tR\x04sort\"u\n" +
+	"\x14ListEmployeesRequest\x123\n" +
+	"\x06filter\x18\x01 \x01(\v2\x1b.employee.v1.EmployeeFilterR\x06filter\x12\x12\n" +
+	"\x04page\x18\x02 \x01(\x05R\x04page\x12\x14\n" +
+	"\x05limit\x18\x03 \x01(\x05R\x05limit\"\x84\x01\n" +
+	"\x15ListEmployeesResponse\x12+\n" +
+	"\x05items\x18\x01 \x03(\v2\x15.employee.v1.EmployeeR\x05items\x12\x12\n" +
+	"\x04page\x18\x02 \x01(\x05R\x04page\x12\x14\n" +
+	"\x05limit\x18\x03 \x01(\x05R\x05limit\x12\x14\n" +
+	"\x05total\x18\x04 \x01(\x05R\x05total\"M\n" +
+	"\x16StreamEmployeesRequest\x123\n" +
+	"\x06filter\x18\x01 \x01(\v2\x1b.employee.v1.EmployeeFilterR\x06filter\"+\n" +
+	"\x12GetEmployeeRequest\x12\x15\n" +
+	"\x06emp_id\x18\x01 \x01(\x05R\x05empId\"p\n" +
+	"\x15CreateEmployeeRequest\x12\x19\n" +
+	"\bemp_name\x18\x01 \x01(\tR\aempName\x12\x1e\n" +
+	"\n" +
+	"department\x18\x02 \x01(\tR\n" +
+	"department\x12\x1c\n" +
+	"\tlanguages\x18\x03 \x03(\tR\tlanguages\"#\n" +
+	"\tLanguages\x12\x16\n" +
+	"\x06values\x18\x01 \x03(\tR\x06values\"\xf0\x01\n" +
+	"\x15UpdateEmployeeRequest\x12\x15\n" +
+	"\x06emp_id\x18\x01 \x01(\x05R\x05empId\x12\x1e\n" +
+	"\bemp_name\x18\x02 \x01(\tH\x00R\aempName\x88\x01\x01\x12#\n" +
+	"\n" +
+	"department\x18\x03 \x01(\tH\x01R\n" +
+	"department\x88\x01\x01\x124\n" +
+	"\tlanguages\x18\x04 \x01(\v2\x16.employee.v1.LanguagesR\tlanguages\x12\x1d\n" +
+	"\aversion\x18\x05 \x01(\x05H\x02R\aversion\x88\x01\x01B\v\n" +
+	"\t_emp_nameB\r\n" +
+	"\v_departmentB\n" +
+	"\n" +
+	"\b_version\".\n" +
+	"\x15DeleteEmployeeRequest\x12\x15\n" +
+	"\x06emp_id\x18\x01 \x01(\x05R\x05empId\"2\n" +
+	"\x16DeleteEmployeeResponse\x12\x18\n" +
+	"\adeleted\x18\x01 \x01(\bR\adeleted2\xc8\x03\n" +
+	"\x0fEmployeeService\x12M\n" +
+	"\x04List\x12!.employee.v1.ListEmployeesRequest\x1a\".employee.v1.ListEmployeesResponse\x12J\n" +
+	"\n" +
+	"StreamList\x12#.employee.v1.StreamEmployeesRequest\x1a\x15.employee.v1.Employee0\x01\x12=\n" +
+	"\x03Get\x12\x1f.employee.v1.GetEmployeeRequest\x1a\x15.employee.v1.Employee\x12C\n" +
+	"\x06Create\x12\".employee.v1.CreateEmployeeRequest\x1a\x15.employee.v1.Employee\x12C\n" +
+	"\x06Update\x12\".employee.v1.UpdateEmployeeRequest\x1a\x15.employee.v1.Employee\x12Q\n" +
+	"\x06Delete\x12\".employee.v1.DeleteEmployeeRequest\x1a#.employee.v1.DeleteEmployeeResponseBFZDgithub.com/karthikeyan-meenachisundaram/goBack/employeepb;employeepbb\x06proto3"
+
+var (
+	file_employee_proto_rawDescOnce sync.Once
+	file_employee_proto_rawDescData []byte
+)
+
+func file_employee_proto_rawDescGZIP() []byte {
+	file_employee_proto_rawDescOnce.Do(func() {
+		file_employee_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_employee_proto_rawDesc), len(file_employee_proto_rawDesc)))
+	})
+	return file_employee_proto_rawDescData
+}
+
+var file_employee_proto_msgTypes = make([]protoimpl.MessageInfo, 11)
+var file_employee_proto_goTypes = []any{
+	(*Employee)(nil),               // 0: employee.v1.Employee
+	(*EmployeeFilter)(nil),         // 1: employee.v1.EmployeeFilter
+	(*ListEmployeesRequest)(nil),   // 2: employee.v1.ListEmployeesRequest
+	(*ListEmployeesResponse)(nil),  // 3: employee.v1.ListEmployeesResponse
+	(*StreamEmployeesRequest)(nil), // 4: employee.v1.StreamEmployeesRequest
+	(*GetEmployeeRequest)(nil),     // 5: employee.v1.GetEmployeeRequest
+	(*CreateEmployeeRequest)(nil),  // 6: employee.v1.CreateEmployeeRequest
+	(*Languages)(nil),              // 7: employee.v1.Languages
+	(*UpdateEmployeeRequest)(nil),  // 8: employee.v1.UpdateEmployeeRequest
+	(*DeleteEmployeeRequest)(nil),  // 9: employee.v1.DeleteEmployeeRequest
+	(*DeleteEmployeeResponse)(nil), // 10: employee.v1.DeleteEmployeeResponse
+	(*timestamppb.Timestamp)(nil),  // 11: google.protobuf.Timestamp
+}
+var file_employee_proto_depIdxs = []int32{
+	11, // 0: employee.v1.Employee.updated_at:type_name -> google.protobuf.Timestamp
+	1,  // 1: employee.v1.ListEmployeesRequest.filter:type_name -> employee.v1.EmployeeFilter
+	0,  // 2: employee.v1.ListEmployeesResponse.items:type_name -> employee.v1.Employee
+	1,  // 3: employee.v1.StreamEmployeesRequest.filter:type_name -> employee.v1.EmployeeFilter
+	7,  // 4: employee.v1.UpdateEmployeeRequest.languages:type_name -> employee.v1.Languages
+	2,  // 5: employee.v1.EmployeeService.List:input_type -> employee.v1.ListEmployeesRequest
+	4,  // 6: employee.v1.EmployeeService.StreamList:input_type -> employee.v1.StreamEmployeesRequest
+	5,  // 7: employee.v1.EmployeeService.Get:input_type -> employee.v1.GetEmployeeRequest
+	6,  // 8: employee.v1.EmployeeService.Create:input_type -> employee.v1.CreateEmployeeRequest
+	8,  // 9: employee.v1.EmployeeService.Update:input_type -> employee.v1.UpdateEmployeeRequest
+	9,  // 10: employee.v1.EmployeeService.Delete:input_type -> employee.v1.DeleteEmployeeRequest
+	3,  // 11: employee.v1.EmployeeService.List:output_type -> employee.v1.ListEmployeesResponse
+	0,  // 12: employee.v1.EmployeeService.StreamList:output_type -> employee.v1.Employee
+	0,  // 13: employee.v1.EmployeeService.Get:output_type -> employee.v1.Employee
+	0,  // 14: employee.v1.EmployeeService.Create:output_type -> employee.v1.Employee
+	0,  // 15: employee.v1.EmployeeService.Update:output_type -> employee.v1.Employee
+	10, // 16: employee.v1.EmployeeService.Delete:output_type -> employee.v1.DeleteEmployeeResponse
+	11, // [11:17] is the sub-list for method output_type
+	5,  // [5:11] is the sub-list for method input_type
+	5,  // [5:5] is the sub-list for extension type_name
+	5,  // [5:5] is the sub-list for extension extendee
+	0,  // [0:5] is the sub-list for field type_name
+}
+
+func init() { file_employee_proto_init() }
+func file_employee_proto_init() {
+	if File_employee_proto != nil {
+		return
+	}
+	file_employee_proto_msgTypes[8].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_employee_proto_rawDesc), len(file_employee_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   11,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_employee_proto_goTypes,
+		DependencyIndexes: file_employee_proto_depIdxs,
+		MessageInfos:      file_employee_proto_msgTypes,
+	}.Build()
+	File_employee_proto = out.File
+	file_employee_proto_goTypes = nil
+	file_employee_proto_depIdxs = nil
+}
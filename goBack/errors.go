@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/karthikeyan-meenachisundaram/goBack/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// apiError is the body of every error response, wrapped as {"error": {...}}
+type apiError struct {
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// errorCodes are the envelope codes for the statuses the API answers with
+var errorCodes = map[int]string{
+	http.StatusBadRequest:            "bad_request",
+	http.StatusUnauthorized:          "unauthorized",
+	http.StatusForbidden:             "forbidden",
+	http.StatusNotFound:              "not_found",
+	http.StatusMethodNotAllowed:      "method_not_allowed",
+	http.StatusConflict:              "conflict",
+	http.StatusPreconditionFailed:    "precondition_failed",
+	http.StatusRequestEntityTooLarge: "too_large",
+	http.StatusUnsupportedMediaType:  "unsupported_media_type",
+	http.StatusUnprocessableEntity:   "validation_failed",
+	http.StatusTooManyRequests:       "rate_limited",
+	http.StatusInternalServerError:   "internal_error",
+	http.StatusBadGateway:            "bad_gateway",
+	http.StatusServiceUnavailable:    "unavailable",
+	http.StatusGatewayTimeout:        "timeout",
+}
+
+// writeError answers status with the JSON error envelope. details is optional
+// (e.g. per-field validation messages).
+func writeError(w http.ResponseWriter, status int, message string, details interface{}) {
+	code, ok := errorCodes[status]
+	if !ok {
+		code = "error"
+	}
+	noteError(w, message)
+	h := w.Header()
+	h.Del("Content-Disposition")
+	h.Set("Content-Type", "application/json")
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(bson.M{"error": apiError{Code: code, Message: message, Details: details}})
+}
+
+// httpError is the envelope counterpart of http.Error
+func httpError(w http.ResponseWriter, message string, status int) {
+	writeError(w, status, message, nil)
+}
+
+// storeError answers a failed store call: 404 when nothing matched, 409 on a version
+// conflict, a taken name or when the emp_ids ran out, 503 when no server was reachable
+// (see mongoUnavailable), 504 when the request ran out of time (see routeTimeout), 500
+// otherwise
+func storeError(w http.ResponseWriter, what string, err error) {
+	if errors.Is(err, models.ErrNotFound) || errors.Is(err, mongo.ErrNoDocuments) {
+		httpError(w, what+": not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, models.ErrVersionConflict) {
+		httpError(w, what+": "+err.Error(), http.StatusConflict)
+		return
+	}
+	if errors.Is(err, models.ErrDuplicate) || errors.Is(err, models.ErrIDOutOfRange) {
+		httpError(w, what+": "+err.Error(), http.StatusConflict)
+		return
+	}
+	if mongoUnavailable(err) {
+		w.Header().Set("Retry-After", "1")
+		httpError(w, what+": database unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	if errors.Is(err, context.DeadlineExceeded) || mongo.IsTimeout(err) {
+		httpError(w, what+": request timed out", http.StatusGatewayTimeout)
+		return
+	}
+	httpError(w, what+": "+err.Error(), http.StatusInternalServerError)
+}
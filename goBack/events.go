@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// eventCoalesce is how long changes to one employee are gathered into one event; a
+	// create writes Employee, Department and Developers, which should read as one "created"
+	eventCoalesce = 300 * time.Millisecond
+	// eventBacklog is how many recent events a reconnecting stream can replay
+	eventBacklog = 256
+)
+
+// EmployeeEvent tells open clients that an employee changed; they refetch what they show
+type EmployeeEvent struct {
+	Seq   int64     `json:"seq"`
+	Type  string    `json:"type"` // created, updated, deleted
+	EmpID int       `json:"emp_id"`
+	At    time.Time `json:"at"`
+	Org   string    `json:"-"` // streams only get the events of their organization
+}
+
+// eventHub fans employee events out to open SSE streams and keeps a short backlog
+// for clients reconnecting with Last-Event-ID
+var eventHub = struct {
+	sync.Mutex
+	seq     int64
+	backlog []EmployeeEvent
+	subs    map[chan EmployeeEvent]bool
+}{subs: map[chan EmployeeEvent]bool{}}
+
+// subscribeEvents registers a stream and returns the backlog after lastSeq
+func subscribeEvents(lastSeq int64) (chan EmployeeEvent, []EmployeeEvent) {
+	ch := make(chan EmployeeEvent, 64)
+	eventHub.Lock()
+	defer eventHub.Unlock()
+	eventHub.subs[ch] = true
+	var missed []EmployeeEvent
+	if lastSeq > 0 {
+		for _, e := range eventHub.backlog {
+			if e.Seq > lastSeq {
+				missed = append(missed, e)
+			}
+		}
+	}
+	return ch, missed
+}
+
+func unsubscribeEvents(ch chan EmployeeEvent) {
+	eventHub.Lock()
+	defer eventHub.Unlock()
+	delete(eventHub.subs, ch)
+}
+
+// publishEvent numbers e, keeps it in the backlog and pushes it to every stream
+func publishEvent(e EmployeeEvent) {
+	eventHub.Lock()
+	defer eventHub.Unlock()
+	eventHub.seq++
+	e.Seq = eventHub.seq
+	eventHub.backlog = append(eventHub.backlog, e)
+	if len(eventHub.backlog) > eventBacklog {
+		eventHub.backlog = eventHub.backlog[len(eventHub.backlog)-eventBacklog:]
+	}
+	for ch := range eventHub.subs {
+		select {
+		case ch <- e:
+		default: // slow consumer, it refetches on reconnect
+		}
+	}
+}
+
+// changeEvent is the part of a change stream document the watcher needs
+type changeEvent struct {
+	OperationType string `bson:"operationType"`
+	Ns            struct {
+		Coll string `bson:"coll"`
+	} `bson:"ns"`
+	FullDocument *struct {
+		EmpID int `bson:"emp_id"`
+	} `bson:"fullDocument"`
+	UpdateDescription *struct {
+		UpdatedFields bson.M   `bson:"updatedFields"`
+		RemovedFields []string `bson:"removedFields"`
+	} `bson:"updateDescription"`
+}
+
+// eventType maps a change to created/updated/deleted, "" for changes clients don't need.
+// Deletes carry no document: they are purges of employees already reported as deleted
+// or the delete half of a language replace.
+func (c changeEvent) eventType() string {
+	if c.FullDocument == nil {
+		return ""
+	}
+	if c.Ns.Coll != "Employee" {
+		return "updated"
+	}
+	switch c.OperationType {
+	case "insert":
+		return "created"
+	case "update":
+		if u := c.UpdateDescription; u != nil {
+			if _, ok := u.UpdatedFields["deleted_at"]; ok {
+				return "deleted"
+			}
+			for _, f := range u.RemovedFields {
+				if f == "deleted_at" {
+					return "created" // restored from the trash
+				}
+			}
+		}
+		return "updated"
+	case "replace":
+		return "updated"
+	}
+	return ""
+}
+
+// startEventWatcher watches every organization's database, including organizations
+// created later, until ctx ends; see watchEvents
+func startEventWatcher(ctx context.Context) {
+	for _, org := range orgIDs() {
+		watchEvents(ctx, org)
+	}
+	orgStarters = append(orgStarters, func(org string) { watchEvents(ctx, org) })
+}
+
+// watchEvents follows a change stream on the Employee, Department and Developers of an
+// organization and publishes employee events until ctx ends. Change streams need a
+// replica set; on a standalone server live updates are disabled with a warning.
+func watchEvents(ctx context.Context, org string) {
+	db := client.Database(orgDatabase(org))
+	pipeline := mongo.Pipeline{bson.D{{Key: "$match", Value: bson.M{
+		"ns.coll":       bson.M{"$in": bson.A{"Employee", "Department", "Developers"}},
+		"operationType": bson.M{"$in": bson.A{"insert", "update", "replace", "delete"}},
+	}}}}
+	opts := options.ChangeStream().SetFullDocument(options.UpdateLookup)
+	stream, err := db.Watch(ctx, pipeline, opts)
+	if err != nil {
+		slog.Warn("events: change stream unavailable, live updates and webhooks disabled", "org", org, "err", err)
+		return
+	}
+
+	go func() {
+		pending := map[int]string{}
+		flush := time.NewTimer(eventCoalesce)
+		flush.Stop()
+		armed := false
+		changes := make(chan changeEvent)
+		go func() {
+			defer close(changes)
+			for {
+				for stream.Next(ctx) {
+					var c changeEvent
+					if err := stream.Decode(&c); err == nil {
+						changes <- c
+					}
+				}
+				if ctx.Err() != nil {
+					stream.Close(context.Background())
+					return
+				}
+				// resume where the stream broke off
+				slog.Warn("events: change stream interrupted, resuming", "org", org, "err", stream.Err())
+				token := stream.ResumeToken()
+				stream.Close(context.Background())
+				time.Sleep(2 * time.Second)
+				for {
+					var err error
+					if stream, err = db.Watch(ctx, pipeline, options.ChangeStream().
+						SetFullDocument(options.UpdateLookup).SetResumeAfter(token)); err == nil {
+						break
+					}
+					if ctx.Err() != nil {
+						return
+					}
+					slog.Warn("events: reopen change stream", "org", org, "err", err)
+					time.Sleep(5 * time.Second)
+				}
+			}
+		}()
+
+		for {
+			select {
+			case c, ok := <-changes:
+				if !ok {
+					return
+				}
+				// writes from other instances and background jobs land here too
+				invalidateEmployeeLists()
+				typ := c.eventType()
+				if typ == "" {
+					continue
+				}
+				// a created or deleted employee stays so when more writes for it follow
+				if prev := pending[c.FullDocument.EmpID]; prev == "" || typ != "updated" {
+					pending[c.FullDocument.EmpID] = typ
+				}
+				if !armed {
+					flush.Reset(eventCoalesce)
+					armed = true
+				}
+			case <-flush.C:
+				now := time.Now().UTC()
+				for id, typ := range pending {
+					publishEvent(EmployeeEvent{Type: typ, EmpID: id, At: now, Org: org})
+				}
+				clear(pending)
+				armed = false
+			}
+		}
+	}()
+}
+
+// ---------------- Handlers ----------------
+
+// eventsHandler handles GET /api/events: created/updated/deleted employee events as
+// Server-Sent Events, those of the caller's organization. A reconnecting EventSource
+// sends Last-Event-ID and gets the events it missed, as far as the backlog reaches.
+func eventsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		httpError(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	lastSeq, _ := strconv.ParseInt(r.Header.Get("Last-Event-ID"), 10, 64)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	org := orgOf(r.Context())
+	ch, missed := subscribeEvents(lastSeq)
+	defer unsubscribeEvents(ch)
+	send := func(e EmployeeEvent) {
+		data, _ := json.Marshal(e)
+		fmt.Fprintf(w, "event: employee\nid: %d\ndata: %s\n\n", e.Seq, data)
+	}
+	for _, e := range missed {
+		if e.Org == org {
+			send(e)
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(30 * time.Second)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+		case e := <-ch:
+			if e.Org != org {
+				continue
+			}
+			send(e)
+		}
+		flusher.Flush()
+	}
+}
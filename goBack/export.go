@@ -0,0 +1,450 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/karthikeyan-meenachisundaram/goBack/models"
+	"github.com/xuri/excelize/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// exportFormats are the output formats an export can be written in
+var exportFormats = map[string]string{
+	"csv":  "text/csv; charset=utf-8",
+	"xlsx": xlsxContentType,
+	"json": "application/json",
+}
+
+// ExportColumn is one output column: a list field (or a dotted path into one,
+// e.g. custom_fields.cost_center or termination.reason) and its header label
+type ExportColumn struct {
+	Field  string `bson:"field" json:"field"`
+	Header string `bson:"header,omitempty" json:"header,omitempty"`
+}
+
+// ExportTemplate is a named column set, ordering and format for recurring exports
+type ExportTemplate struct {
+	Name      string         `bson:"name" json:"name"`
+	Columns   []ExportColumn `bson:"columns" json:"columns"` // in output order
+	Sort      string         `bson:"sort,omitempty" json:"sort,omitempty"`
+	Format    string         `bson:"format" json:"format"`
+	CreatedAt time.Time      `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time      `bson:"updated_at" json:"updated_at"`
+}
+
+// defaultExportTemplate is used when no ?template= is given
+var defaultExportTemplate = ExportTemplate{
+	Name: "employees",
+	Columns: []ExportColumn{
+		{Field: "emp_id", Header: "emp_id"},
+		{Field: "emp_name", Header: "emp_name"},
+		{Field: "department", Header: "department"},
+		{Field: "language", Header: "language"},
+		{Field: "status", Header: "status"},
+		{Field: "tags", Header: "tags"},
+	},
+	Format: "csv",
+}
+
+// check validates the template and fills in default headers and format
+func (t *ExportTemplate) check() error {
+	if t.Name == "" || len(t.Name) > 100 || strings.Contains(t.Name, "/") {
+		return fmt.Errorf("name is required (max 100 characters, no '/')")
+	}
+	if len(t.Columns) == 0 {
+		return fmt.Errorf("at least one column is required")
+	}
+	seen := map[string]bool{}
+	for i, c := range t.Columns {
+		root, _, _ := strings.Cut(c.Field, ".")
+		if !listColumns[root] {
+			return fmt.Errorf("unknown column %q", c.Field)
+		}
+		if c.Header == "" {
+			t.Columns[i].Header = c.Field
+		}
+		if seen[t.Columns[i].Header] {
+			return fmt.Errorf("duplicate header %q", t.Columns[i].Header)
+		}
+		seen[t.Columns[i].Header] = true
+	}
+	if t.Sort != "" {
+		if _, err := parseSort(t.Sort); err != nil {
+			return err
+		}
+	}
+	if t.Format == "" {
+		t.Format = "csv"
+	}
+	if _, ok := exportFormats[t.Format]; !ok {
+		return fmt.Errorf("format must be one of csv, xlsx, json")
+	}
+	return nil
+}
+
+// lookupPath resolves a dotted field path in a list row
+func lookupPath(doc bson.M, path string) interface{} {
+	var v interface{} = doc
+	for _, key := range strings.Split(path, ".") {
+		switch m := v.(type) {
+		case bson.M:
+			v = m[key]
+		case bson.D:
+			v = m.Map()[key]
+		default:
+			return nil
+		}
+	}
+	return v
+}
+
+// exportCell renders a value as a single CSV cell; lists are joined with "; "
+func exportCell(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case primitive.DateTime:
+		return t.Time().UTC().Format(time.RFC3339)
+	case bson.A:
+		parts := make([]string, 0, len(t))
+		for _, e := range t {
+			parts = append(parts, exportCell(e))
+		}
+		return strings.Join(parts, "; ")
+	case bson.M:
+		b, _ := json.Marshal(t)
+		return string(b)
+	default:
+		return fmt.Sprint(t)
+	}
+}
+
+// xlsxCell keeps numbers, booleans and dates native in a spreadsheet; everything else
+// is rendered like a CSV cell
+func xlsxCell(v interface{}) interface{} {
+	switch t := v.(type) {
+	case int32, int64, float64, bool:
+		return t
+	case primitive.DateTime:
+		return t.Time().UTC()
+	default:
+		return exportCell(v)
+	}
+}
+
+// writeExport streams the rows it pulls as CSV or XLSX (header row first) or as a JSON
+// array of objects whose keys are the header labels, in column order
+func writeExport(w http.ResponseWriter, t ExportTemplate, rows func() (models.Row, error, bool)) error {
+	header := make([]string, len(t.Columns))
+	for i, c := range t.Columns {
+		header[i] = c.Header
+	}
+	next := func() (bson.M, error) {
+		raw, err, ok := rows()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, io.EOF
+		}
+		var doc bson.M
+		err = raw.Decode(&doc)
+		return doc, err
+	}
+
+	switch t.Format {
+	case "json":
+		bw := bufio.NewWriter(w)
+		bw.WriteByte('[')
+		for i := 0; ; i++ {
+			doc, err := next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			if i > 0 {
+				bw.WriteByte(',')
+			}
+			bw.WriteByte('{')
+			for j, c := range t.Columns {
+				if j > 0 {
+					bw.WriteByte(',')
+				}
+				k, _ := json.Marshal(c.Header)
+				v, err := json.Marshal(lookupPath(doc, c.Field))
+				if err != nil {
+					return err
+				}
+				bw.Write(k)
+				bw.WriteByte(':')
+				bw.Write(v)
+			}
+			bw.WriteByte('}')
+		}
+		bw.WriteString("]\n")
+		return bw.Flush()
+
+	case "xlsx":
+		f := excelize.NewFile()
+		defer f.Close()
+		sheet := f.GetSheetName(0)
+		sw, err := f.NewStreamWriter(sheet)
+		if err != nil {
+			return err
+		}
+		row := make([]interface{}, len(header))
+		for i, h := range header {
+			row[i] = h
+		}
+		if err := sw.SetRow("A1", row); err != nil {
+			return err
+		}
+		for n := 2; ; n++ {
+			doc, err := next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			row := make([]interface{}, len(t.Columns))
+			for i, c := range t.Columns {
+				row[i] = xlsxCell(lookupPath(doc, c.Field))
+			}
+			cell, _ := excelize.CoordinatesToCellName(1, n)
+			if err := sw.SetRow(cell, row); err != nil {
+				return err
+			}
+		}
+		if err := sw.Flush(); err != nil {
+			return err
+		}
+		return f.Write(w)
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for {
+		doc, err := next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		record := make([]string, len(t.Columns))
+		for i, c := range t.Columns {
+			record[i] = exportCell(lookupPath(doc, c.Field))
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ---------------- Handlers ----------------
+
+// exportEmployeesHandler handles GET /api/employees/export?format=csv|xlsx|json[&template=payroll].
+// It accepts the same filters as the employee list; the template's sort applies unless
+// ?sort= is given. Rows are streamed from the cursor as they are encoded.
+func exportEmployeesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	ctx := r.Context()
+
+	q := r.URL.Query()
+	t := defaultExportTemplate
+	if name := q.Get("template"); name != "" {
+		if err := coll(ctx, "ExportTemplates").FindOne(ctx, bson.M{"name": name}).Decode(&t); err != nil {
+			if err == mongo.ErrNoDocuments {
+				httpError(w, "export template not found: "+name, http.StatusNotFound)
+				return
+			}
+			storeError(w, "find export template", err)
+			return
+		}
+	}
+	if f := q.Get("format"); f != "" {
+		if _, ok := exportFormats[f]; !ok {
+			httpError(w, "format must be one of csv, xlsx, json", http.StatusBadRequest)
+			return
+		}
+		t.Format = f
+	}
+	if t.Sort != "" && q.Get("sort") == "" {
+		q.Set("sort", t.Sort)
+	}
+
+	query, status, err := employeeQuery(ctx, q, actorFromRequest(r), isAdmin(r))
+	if err != nil {
+		httpError(w, err.Error(), status)
+		return
+	}
+	next, stop := iter.Pull2(employees.Stream(ctx, query))
+	defer stop()
+	// the first row, or the failure to list any, decides the status
+	first, err, ok := next()
+	if err != nil {
+		storeError(w, "list employees", err)
+		return
+	}
+	peeked := true
+	rows := func() (models.Row, error, bool) {
+		if peeked {
+			peeked = false
+			return first, nil, ok
+		}
+		return next()
+	}
+
+	w.Header().Set("Content-Type", exportFormats[t.Format])
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", t.Name+"-"+time.Now().UTC().Format("2006-01-02")+"."+t.Format))
+	if err := writeExport(w, t, rows); err != nil {
+		// headers are gone by now; the truncated download is all the client sees
+		logFor(r.Context()).Error("export aborted", "template", t.Name, "err", err)
+	}
+}
+
+// exportTemplatesHandler handles GET (list) and POST (define, admin) on /api/admin/export-templates
+func exportTemplatesHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	switch r.Method {
+	case http.MethodGet:
+		// readable by everyone so the UI can offer the templates
+		cur, err := coll(ctx, "ExportTemplates").Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+		if err != nil {
+			storeError(w, "find export templates", err)
+			return
+		}
+		defer cur.Close(ctx)
+		list := []ExportTemplate{}
+		if err := cur.All(ctx, &list); err != nil {
+			storeError(w, "cursor all", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(list)
+	case http.MethodPost:
+		if !requireAdmin(w, r) {
+			return
+		}
+		var t ExportTemplate
+		if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+			httpError(w, "invalid input: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := t.check(); err != nil {
+			httpError(w, "invalid export template: "+err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		t.CreatedAt = time.Now().UTC()
+		t.UpdatedAt = t.CreatedAt
+		res, err := coll(ctx, "ExportTemplates").UpdateOne(ctx, bson.M{"name": t.Name}, bson.M{"$setOnInsert": t}, options.Update().SetUpsert(true))
+		if err != nil {
+			storeError(w, "insert export template", err)
+			return
+		}
+		if res.UpsertedCount == 0 {
+			httpError(w, "export template already exists: "+t.Name, http.StatusConflict)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(t)
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// exportTemplateByNameHandler handles GET, PUT and DELETE (admin) on /api/admin/export-templates/{name}
+func exportTemplateByNameHandler(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/api/admin/export-templates/")
+	if name == "" {
+		httpError(w, "name required in path", http.StatusBadRequest)
+		return
+	}
+	if r.Method != http.MethodGet && !requireAdmin(w, r) {
+		return
+	}
+	ctx := r.Context()
+
+	switch r.Method {
+	case http.MethodGet:
+		var t ExportTemplate
+		if err := coll(ctx, "ExportTemplates").FindOne(ctx, bson.M{"name": name}).Decode(&t); err != nil {
+			if err == mongo.ErrNoDocuments {
+				httpError(w, "export template not found", http.StatusNotFound)
+				return
+			}
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(t)
+	case http.MethodPut:
+		var t ExportTemplate
+		if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+			httpError(w, "invalid input: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		t.Name = name
+		if err := t.check(); err != nil {
+			httpError(w, "invalid export template: "+err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		update := bson.M{"$set": bson.M{
+			"columns":    t.Columns,
+			"sort":       t.Sort,
+			"format":     t.Format,
+			"updated_at": time.Now().UTC(),
+		}}
+		err := coll(ctx, "ExportTemplates").FindOneAndUpdate(ctx, bson.M{"name": name}, update,
+			options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&t)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				httpError(w, "export template not found", http.StatusNotFound)
+				return
+			}
+			storeError(w, "update export template", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(t)
+	case http.MethodDelete:
+		res, err := coll(ctx, "ExportTemplates").DeleteOne(ctx, bson.M{"name": name})
+		if err != nil {
+			storeError(w, "delete export template", err)
+			return
+		}
+		if res.DeletedCount == 0 {
+			httpError(w, "export template not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(bson.M{"message": "Export template deleted successfully"})
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
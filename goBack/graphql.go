@@ -0,0 +1,550 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/karthikeyan-meenachisundaram/goBack/models"
+	"github.com/karthikeyan-meenachisundaram/goBack/repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// maxGraphQLBody caps the request body of /api/graphql
+	maxGraphQLBody = 1 << 20
+	// maxGraphQLBatch caps the operations of one batched request
+	maxGraphQLBatch = 10
+)
+
+// graphqlSchema is the /api/graphql API. It is a view over the same store, list pipeline
+// and validation as the REST handlers; custom fields, notes and the rest stay REST-only.
+const graphqlSchema = `
+scalar Time
+
+schema {
+	query: Query
+	mutation: Mutation
+}
+
+type Query {
+	employee(empId: Int!): Employee
+	# the filters of GET /api/employees; page defaults to 1, limit to 20 (max 100)
+	employees(page: Int, limit: Int, status: String, department: String, language: String,
+		tags: [String!], sort: String, savedSearch: String): EmployeePage!
+	departments: [Department!]!
+	# by deptId or by name
+	department(deptId: Int, name: String): Department
+	developers(language: String!): [Developer!]!
+}
+
+type Mutation {
+	createEmployee(input: EmployeeInput!): Employee!
+	# only the fields given change; with version it only applies to that version
+	updateEmployee(empId: Int!, input: EmployeeInput!, version: Int): Employee!
+	deleteEmployee(empId: Int!): Boolean!
+}
+
+input EmployeeInput {
+	empName: String
+	department: String
+	# the first one is the primary language; an empty list clears them
+	languages: [String!]
+}
+
+type Employee {
+	empId: Int!
+	empName: String!
+	status: String!
+	department: Department
+	languages: [String!]!
+	developers: [Developer!]!
+	tags: [String!]!
+	photoUrl: String
+	version: Int!
+	updatedAt: Time
+}
+
+type EmployeePage {
+	items: [Employee!]!
+	page: Int!
+	limit: Int!
+	total: Int!
+}
+
+type Department {
+	deptId: Int!
+	name: String!
+	createdAt: Time!
+	employees(page: Int, limit: Int): EmployeePage!
+}
+
+# one of an employee's languages (a Developers row)
+type Developer {
+	empId: Int!
+	language: String!
+	position: Int!
+	employee: Employee
+}
+`
+
+// graphqlAPI is the parsed schema bound to its resolvers
+var graphqlAPI = graphql.MustParseSchema(graphqlSchema, &graphqlResolver{},
+	graphql.MaxDepth(8),
+	graphql.MaxQueryLength(10000),
+	graphql.MaxParallelism(8),
+)
+
+// graphqlError is a resolver error carrying the REST error code (and details) in its
+// extensions
+type graphqlError struct {
+	code    string
+	message string
+	details interface{}
+}
+
+func (e graphqlError) Error() string { return e.message }
+
+func (e graphqlError) Extensions() map[string]interface{} {
+	ext := map[string]interface{}{"code": e.code}
+	if e.details != nil {
+		ext["details"] = e.details
+	}
+	return ext
+}
+
+// newGraphQLError is the GraphQL counterpart of writeError
+func newGraphQLError(status int, message string, details interface{}) error {
+	code, ok := errorCodes[status]
+	if !ok {
+		code = "error"
+	}
+	return graphqlError{code: code, message: message, details: details}
+}
+
+// graphqlStoreError is the GraphQL counterpart of storeError
+func graphqlStoreError(what string, err error) error {
+	switch {
+	case errors.Is(err, models.ErrNotFound), errors.Is(err, mongo.ErrNoDocuments):
+		return newGraphQLError(http.StatusNotFound, what+": not found", nil)
+	case errors.Is(err, models.ErrVersionConflict):
+		return newGraphQLError(http.StatusConflict, what+": "+err.Error(), nil)
+	}
+	return newGraphQLError(http.StatusInternalServerError, what+": "+err.Error(), nil)
+}
+
+// graphqlRequest is the HTTP request a resolver runs for, so resolvers share the
+// request-based helpers (actorFromRequest, isAdmin, employeeListPipeline) with REST
+func graphqlRequest(ctx context.Context) *http.Request {
+	r, _ := ctx.Value(requestKey).(*http.Request)
+	return r
+}
+
+// graphqlRequireRole fails unless the caller has at least role. /api/graphql itself only
+// needs viewer, so mutations check their role like requiredRole does for REST.
+func graphqlRequireRole(ctx context.Context, role string) error {
+	c, _ := ctx.Value(userKey).(*tokenClaims)
+	if c == nil || roleRank[c.Role] < roleRank[role] {
+		return newGraphQLError(http.StatusForbidden, role+" role required", nil)
+	}
+	return nil
+}
+
+// ---------------- Resolvers ----------------
+
+// graphqlResolver is the root of queries and mutations
+type graphqlResolver struct{}
+
+// loadEmployee resolves a live employee, nil when there is none
+func loadEmployee(ctx context.Context, empId int) (*employeeResolver, error) {
+	raw, err := employees.Get(ctx, empId, nil)
+	if errors.Is(err, models.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, graphqlStoreError("find employee", err)
+	}
+	var row employeeRow
+	if err := raw.Decode(&row); err != nil {
+		return nil, graphqlStoreError("decode", err)
+	}
+	return &employeeResolver{row}, nil
+}
+
+// listEmployees resolves a page of the REST list for the filters in q
+func listEmployees(ctx context.Context, q url.Values) (*employeePageResolver, error) {
+	r := graphqlRequest(ctx)
+	p, status, err := findEmployeePage(ctx, q, actorFromRequest(r), isAdmin(r))
+	if err != nil {
+		return nil, newGraphQLError(status, err.Error(), nil)
+	}
+	res := &employeePageResolver{page: p.Page, limit: p.Limit, total: p.Total, items: make([]*employeeResolver, len(p.Items))}
+	for i, row := range p.Items {
+		res.items[i] = &employeeResolver{row}
+	}
+	return res, nil
+}
+
+// pageValues sets the page and limit arguments on q
+func pageValues(q url.Values, page, limit *int32) url.Values {
+	if page != nil {
+		q.Set("page", strconv.Itoa(int(*page)))
+	}
+	if limit != nil {
+		q.Set("limit", strconv.Itoa(int(*limit)))
+	}
+	return q
+}
+
+func (*graphqlResolver) Employee(ctx context.Context, args struct{ EmpID int32 }) (*employeeResolver, error) {
+	return loadEmployee(ctx, int(args.EmpID))
+}
+
+func (*graphqlResolver) Employees(ctx context.Context, args struct {
+	Page, Limit                                     *int32
+	Status, Department, Language, Sort, SavedSearch *string
+	Tags                                            *[]string
+}) (*employeePageResolver, error) {
+	q := pageValues(url.Values{}, args.Page, args.Limit)
+	for name, v := range map[string]*string{
+		"status": args.Status, "department": args.Department, "language": args.Language,
+		"sort": args.Sort, "saved_search": args.SavedSearch,
+	} {
+		if v != nil {
+			q.Set(name, *v)
+		}
+	}
+	if args.Tags != nil {
+		q["tag"] = *args.Tags
+	}
+	return listEmployees(ctx, q)
+}
+
+func (*graphqlResolver) Departments(ctx context.Context) ([]*departmentResolver, error) {
+	list, err := departments.List(ctx)
+	if err != nil {
+		return nil, graphqlStoreError("find departments", err)
+	}
+	out := make([]*departmentResolver, len(list))
+	for i := range list {
+		out[i] = &departmentResolver{list[i]}
+	}
+	return out, nil
+}
+
+func (*graphqlResolver) Department(ctx context.Context, args struct {
+	DeptID *int32
+	Name   *string
+}) (*departmentResolver, error) {
+	var d models.Department
+	var err error
+	switch {
+	case args.DeptID != nil:
+		d, err = departments.Get(ctx, int(*args.DeptID))
+	case args.Name != nil:
+		d, err = departments.ByName(ctx, *args.Name)
+	default:
+		return nil, newGraphQLError(http.StatusBadRequest, "deptId or name is required", nil)
+	}
+	if errors.Is(err, models.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, graphqlStoreError("find department", err)
+	}
+	return &departmentResolver{d}, nil
+}
+
+func (*graphqlResolver) Developers(ctx context.Context, args struct{ Language string }) ([]*developerResolver, error) {
+	// rows of deleted employees stay until they are purged
+	cur, err := coll(ctx, "Developers").Aggregate(ctx, mongo.Pipeline{
+		bson.D{{Key: "$match", Value: bson.M{"language": args.Language}}},
+		bson.D{{Key: "$lookup", Value: bson.D{
+			{Key: "from", Value: "Employee"},
+			{Key: "localField", Value: "emp_id"},
+			{Key: "foreignField", Value: "emp_id"},
+			{Key: "pipeline", Value: bson.A{bson.M{"$match": repository.Live(bson.M{})}, bson.M{"$project": bson.M{"_id": 1}}}},
+			{Key: "as", Value: "employee"},
+		}}},
+		bson.D{{Key: "$match", Value: bson.M{"employee": bson.M{"$ne": bson.A{}}}}},
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "emp_id", Value: 1}}}},
+	})
+	if err != nil {
+		return nil, graphqlStoreError("aggregate", err)
+	}
+	defer cur.Close(ctx)
+	var rows []struct {
+		EmpID    int    `bson:"emp_id"`
+		Language string `bson:"language"`
+		Position int    `bson:"position"`
+	}
+	if err := cur.All(ctx, &rows); err != nil {
+		return nil, graphqlStoreError("cursor all", err)
+	}
+	out := make([]*developerResolver, len(rows))
+	for i, row := range rows {
+		out[i] = &developerResolver{empID: row.EmpID, language: row.Language, position: row.Position}
+	}
+	return out, nil
+}
+
+// employeeInput is the EmployeeInput of the create and update mutations
+type employeeInput struct {
+	EmpName    *string
+	Department *string
+	Languages  *[]string
+}
+
+// payload is the input as the REST payload, so it validates the same way
+func (in employeeInput) payload() models.EmployeePayload {
+	p := models.EmployeePayload{EmpName: in.EmpName, Department: in.Department}
+	if in.Languages != nil {
+		p.Languages = *in.Languages
+	}
+	return p
+}
+
+func (*graphqlResolver) CreateEmployee(ctx context.Context, args struct{ Input employeeInput }) (*employeeResolver, error) {
+	if err := graphqlRequireRole(ctx, "editor"); err != nil {
+		return nil, err
+	}
+	defs, err := loadCustomFields(ctx)
+	if err != nil {
+		return nil, graphqlStoreError("find custom fields", err)
+	}
+	ref, err := loadReferenceData(ctx)
+	if err != nil {
+		return nil, graphqlStoreError("reference data", err)
+	}
+	input := args.Input.payload()
+	emp, errs := newEmployee(defs, ref, &input)
+	if len(errs) > 0 {
+		return nil, newGraphQLError(http.StatusUnprocessableEntity, "validation failed", errs)
+	}
+	if emp.EmpID, err = nextID(ctx); err != nil {
+		return nil, graphqlStoreError("allocate id", err)
+	}
+	if err := employees.Create(ctx, emp, actorFromRequest(graphqlRequest(ctx))); err != nil {
+		return nil, graphqlStoreError("create employee", err)
+	}
+	invalidateEmployeeLists()
+	return loadEmployee(ctx, emp.EmpID)
+}
+
+func (*graphqlResolver) UpdateEmployee(ctx context.Context, args struct {
+	EmpID   int32
+	Input   employeeInput
+	Version *int32
+}) (*employeeResolver, error) {
+	if err := graphqlRequireRole(ctx, "editor"); err != nil {
+		return nil, err
+	}
+	r := graphqlRequest(ctx)
+	if approvalMode() && !isAdmin(r) {
+		return nil, newGraphQLError(http.StatusForbidden,
+			fmt.Sprintf("edits need approval; submit them with PUT /api/employees/%d", args.EmpID), nil)
+	}
+	defs, err := loadCustomFields(ctx)
+	if err != nil {
+		return nil, graphqlStoreError("find custom fields", err)
+	}
+	ref, err := loadReferenceData(ctx)
+	if err != nil {
+		return nil, graphqlStoreError("reference data", err)
+	}
+	input := args.Input.payload()
+	if args.Version != nil {
+		v := int(*args.Version)
+		input.Version = &v
+	}
+	if errs := validatePayload(&input, ref, false); len(errs) > 0 {
+		return nil, newGraphQLError(http.StatusUnprocessableEntity, "validation failed", errs)
+	}
+	empId := int(args.EmpID)
+	if err := applyEmployeeUpdate(ctx, empId, input, defs, actorFromRequest(r)); err != nil {
+		if errors.Is(err, models.ErrVersionConflict) {
+			current, _ := employees.Version(ctx, empId)
+			return nil, newGraphQLError(http.StatusConflict, "employee was changed by someone else; reload and retry",
+				map[string]int{"expected_version": *input.Version, "current_version": current})
+		}
+		return nil, graphqlStoreError("update employee", err)
+	}
+	invalidateEmployeeLists()
+	emp, err := loadEmployee(ctx, empId)
+	if err == nil && emp == nil {
+		err = newGraphQLError(http.StatusNotFound, fmt.Sprintf("Employee %d not found", empId), nil)
+	}
+	return emp, err
+}
+
+func (*graphqlResolver) DeleteEmployee(ctx context.Context, args struct{ EmpID int32 }) (bool, error) {
+	if err := graphqlRequireRole(ctx, "admin"); err != nil {
+		return false, err
+	}
+	n, err := employees.SoftDelete(ctx, int(args.EmpID), actorFromRequest(graphqlRequest(ctx)))
+	if err != nil {
+		return false, graphqlStoreError("delete employee", err)
+	}
+	invalidateEmployeeLists()
+	return n > 0, nil
+}
+
+// employeeResolver resolves an Employee
+type employeeResolver struct{ row employeeRow }
+
+func (e *employeeResolver) EmpID() int32    { return int32(e.row.EmpID) }
+func (e *employeeResolver) EmpName() string { return e.row.EmpName }
+func (e *employeeResolver) Status() string  { return e.row.Status }
+func (e *employeeResolver) Version() int32  { return int32(e.row.Version) }
+func (e *employeeResolver) Languages() []string {
+	if e.row.Languages == nil {
+		return []string{}
+	}
+	return e.row.Languages
+}
+
+func (e *employeeResolver) Tags() []string {
+	if e.row.Tags == nil {
+		return []string{}
+	}
+	return e.row.Tags
+}
+
+func (e *employeeResolver) PhotoURL() *string {
+	if e.row.PhotoURL == "" {
+		return nil
+	}
+	return &e.row.PhotoURL
+}
+
+func (e *employeeResolver) UpdatedAt() *graphql.Time {
+	if e.row.UpdatedAt == nil {
+		return nil
+	}
+	return &graphql.Time{Time: *e.row.UpdatedAt}
+}
+
+func (e *employeeResolver) Department(ctx context.Context) (*departmentResolver, error) {
+	if e.row.Department == "" {
+		return nil, nil
+	}
+	d, err := departments.ByName(ctx, e.row.Department)
+	if errors.Is(err, models.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, graphqlStoreError("find department", err)
+	}
+	return &departmentResolver{d}, nil
+}
+
+// Developers are the employee's languages in order; the list row already has them
+func (e *employeeResolver) Developers() []*developerResolver {
+	out := make([]*developerResolver, len(e.row.Languages))
+	for i, l := range e.row.Languages {
+		out[i] = &developerResolver{empID: e.row.EmpID, language: l, position: i}
+	}
+	return out
+}
+
+// employeePageResolver resolves an EmployeePage
+type employeePageResolver struct {
+	items              []*employeeResolver
+	page, limit, total int
+}
+
+func (p *employeePageResolver) Items() []*employeeResolver { return p.items }
+func (p *employeePageResolver) Page() int32                { return int32(p.page) }
+func (p *employeePageResolver) Limit() int32               { return int32(p.limit) }
+func (p *employeePageResolver) Total() int32               { return int32(p.total) }
+
+// departmentResolver resolves a Department
+type departmentResolver struct{ d models.Department }
+
+func (d *departmentResolver) DeptID() int32           { return int32(d.d.DeptID) }
+func (d *departmentResolver) Name() string            { return d.d.Name }
+func (d *departmentResolver) CreatedAt() graphql.Time { return graphql.Time{Time: d.d.CreatedAt} }
+
+func (d *departmentResolver) Employees(ctx context.Context, args struct{ Page, Limit *int32 }) (*employeePageResolver, error) {
+	q := pageValues(url.Values{}, args.Page, args.Limit)
+	q.Set("department", d.d.Name)
+	return listEmployees(ctx, q)
+}
+
+// developerResolver resolves a Developer
+type developerResolver struct {
+	empID    int
+	language string
+	position int
+}
+
+func (d *developerResolver) EmpID() int32     { return int32(d.empID) }
+func (d *developerResolver) Language() string { return d.language }
+func (d *developerResolver) Position() int32  { return int32(d.position) }
+
+func (d *developerResolver) Employee(ctx context.Context) (*employeeResolver, error) {
+	return loadEmployee(ctx, d.empID)
+}
+
+// ---------------- Handlers ----------------
+
+// graphqlParams is one GraphQL operation of a request
+type graphqlParams struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// graphqlHandler handles POST /api/graphql with {"query", "operationName", "variables"},
+// or an array of up to maxGraphQLBatch of them answered with an array of results.
+// Errors are reported in the result's errors, with the REST error code in extensions.
+func graphqlHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxGraphQLBody))
+	if err != nil {
+		httpError(w, "read body: "+err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	body = bytes.TrimSpace(body)
+	batched := len(body) > 0 && body[0] == '['
+	var ops []graphqlParams
+	if batched {
+		err = json.Unmarshal(body, &ops)
+	} else {
+		ops = make([]graphqlParams, 1)
+		err = json.Unmarshal(body, &ops[0])
+	}
+	if err != nil {
+		httpError(w, "invalid input: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(ops) == 0 || len(ops) > maxGraphQLBatch {
+		httpError(w, fmt.Sprintf("a batch must hold between 1 and %d operations", maxGraphQLBatch), http.StatusBadRequest)
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), requestKey, r)
+
+	results := make([]*graphql.Response, len(ops))
+	for i, op := range ops {
+		results[i] = graphqlAPI.Exec(ctx, op.Query, op.OperationName, op.Variables)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if batched {
+		_ = json.NewEncoder(w).Encode(results)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(results[0])
+}
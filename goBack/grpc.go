@@ -0,0 +1,514 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	pb "github.com/karthikeyan-meenachisundaram/goBack/employeepb"
+	"github.com/karthikeyan-meenachisundaram/goBack/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// grpcRoles is the least role for each EmployeeService method, like requiredRole for REST;
+// methods missing here are refused
+var grpcRoles = map[string]string{
+	pb.EmployeeService_List_FullMethodName:       "viewer",
+	pb.EmployeeService_StreamList_FullMethodName: "viewer",
+	pb.EmployeeService_Get_FullMethodName:        "viewer",
+	pb.EmployeeService_Create_FullMethodName:     "editor",
+	pb.EmployeeService_Update_FullMethodName:     "editor",
+	pb.EmployeeService_Delete_FullMethodName:     "admin",
+}
+
+// grpcCodes maps the HTTP statuses the shared helpers report to gRPC codes
+var grpcCodes = map[int]codes.Code{
+	http.StatusBadRequest:          codes.InvalidArgument,
+	http.StatusForbidden:           codes.PermissionDenied,
+	http.StatusNotFound:            codes.NotFound,
+	http.StatusConflict:            codes.Aborted,
+	http.StatusUnprocessableEntity: codes.InvalidArgument,
+}
+
+// grpcError is the gRPC status for an error a shared helper answered status for
+func grpcError(httpStatus int, err error) error {
+	code, ok := grpcCodes[httpStatus]
+	if !ok {
+		code = codes.Internal
+	}
+	return status.Error(code, err.Error())
+}
+
+// grpcStoreError is the gRPC counterpart of storeError
+func grpcStoreError(what string, err error) error {
+	switch {
+	case errors.Is(err, models.ErrNotFound), errors.Is(err, mongo.ErrNoDocuments):
+		return status.Error(codes.NotFound, what+": not found")
+	case errors.Is(err, models.ErrVersionConflict):
+		return status.Error(codes.Aborted, what+": "+err.Error())
+	}
+	return status.Error(codes.Internal, what+": "+err.Error())
+}
+
+// grpcValidationError is InvalidArgument with the per-field messages as BadRequest details
+func grpcValidationError(errs map[string]string) error {
+	br := &errdetails.BadRequest{}
+	for f, msg := range errs {
+		br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{Field: f, Description: msg})
+	}
+	st, err := status.New(codes.InvalidArgument, "validation failed").WithDetails(br)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, "validation failed")
+	}
+	return st.Err()
+}
+
+// grpcRole is the least role allowed to call method: its grpcRoles entry, viewer for the
+// reflection service (grpcurl lists and describes the services with it) and "" for
+// methods no one may call
+func grpcRole(method string) string {
+	if strings.HasPrefix(method, "/grpc.reflection.") {
+		return "viewer"
+	}
+	return grpcRoles[method]
+}
+
+// grpcCredentials are the bearer token and the x-api-key in the call's metadata
+func grpcCredentials(ctx context.Context) (token, key string) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if v := md.Get("authorization"); len(v) > 0 {
+		token, _ = strings.CutPrefix(v[0], "Bearer ")
+		token = strings.TrimSpace(token)
+	}
+	if v := md.Get("x-api-key"); len(v) > 0 {
+		key = v[0]
+	}
+	return token, key
+}
+
+// grpcAuthorize verifies the bearer token (or x-api-key) in the call's metadata against
+// the method's role and returns ctx carrying its claims and acting for its organization,
+// as authenticate and tenant do for HTTP
+func grpcAuthorize(ctx context.Context, method string) (context.Context, error) {
+	token, key := grpcCredentials(ctx)
+	var claims *tokenClaims
+	var err error
+	switch {
+	case token == "" && key != "":
+		if claims, err = apiKeyClaims(ctx, key); err != nil {
+			if err == errInvalidAPIKey {
+				return nil, status.Error(codes.Unauthenticated, err.Error())
+			}
+			return nil, grpcStoreError("find api key", err)
+		}
+	case token == "":
+		return nil, status.Error(codes.Unauthenticated, "missing bearer token")
+	default:
+		if claims, err = parseToken(token, "access"); err != nil {
+			return nil, status.Error(codes.Unauthenticated, "invalid token: "+err.Error())
+		}
+	}
+	need := grpcRole(method)
+	if need == "" || roleRank[claims.Role] < roleRank[need] {
+		return nil, status.Error(codes.PermissionDenied, need+" role required")
+	}
+	org := orgOfClaims(claims)
+	if ok, err := orgExists(ctx, org); err != nil || !ok {
+		if err == nil {
+			return nil, status.Errorf(codes.InvalidArgument, "unknown organization %q", org)
+		}
+		return nil, grpcStoreError("find organization", err)
+	}
+	return withOrg(context.WithValue(ctx, userKey, claims), org), nil
+}
+
+// logGRPC logs a finished call like accessLog does requests
+func logGRPC(ctx context.Context, method string, start time.Time, err error) {
+	level := slog.LevelInfo
+	if status.Code(err) == codes.Internal || status.Code(err) == codes.Unknown {
+		level = slog.LevelError
+	}
+	slog.Log(ctx, level, "grpc", "method", method, "code", status.Code(err).String(),
+		"duration_ms", time.Since(start).Milliseconds(), "actor", grpcActor(ctx))
+}
+
+// grpcLimitKey is the bucket a call counts against, like limitKey for requests
+func grpcLimitKey(ctx context.Context) string {
+	token, key := grpcCredentials(ctx)
+	if cfg.RateLimit.Key == "token" {
+		if token != "" {
+			if claims, err := parseToken(token, "access"); err == nil {
+				return "user:" + orgOfClaims(claims) + "/" + claims.Subject
+			}
+		} else if key != "" {
+			if claims, ok := cachedAPIKeyClaims(key); ok {
+				return "user:" + orgOfClaims(claims) + "/" + claims.Subject
+			}
+		}
+	}
+	if cfg.RateLimit.TrustProxy {
+		md, _ := metadata.FromIncomingContext(ctx)
+		if v := md.Get("x-forwarded-for"); len(v) > 0 {
+			ip, _, _ := strings.Cut(v[0], ",")
+			return "ip:" + strings.TrimSpace(ip)
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+			return "ip:" + host
+		}
+		return "ip:" + p.Addr.String()
+	}
+	return "ip:"
+}
+
+// grpcInterceptors authorize, rate limit (on buckets of their own, with the limits of
+// rateLimit; calls of methods needing more than viewer count as mutations), time out,
+// recover and log every call
+type grpcInterceptors struct {
+	limits []requestLimit
+}
+
+// rateLimit spends a token of every bucket that applies to the call; ResourceExhausted,
+// with the wait as RetryInfo, over a limit
+func (g grpcInterceptors) rateLimit(ctx context.Context, method string) error {
+	if len(g.limits) == 0 {
+		return nil
+	}
+	key := grpcLimitKey(ctx)
+	for _, l := range g.limits {
+		if l.mutations && grpcRole(method) == "viewer" {
+			continue
+		}
+		if ok, _, retryAfter, _ := l.rl.take(key); !ok {
+			secs := int(math.Ceil(retryAfter.Seconds()))
+			st := status.Newf(codes.ResourceExhausted, "too many requests, retry after %ds", secs)
+			if d, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(retryAfter)}); err == nil {
+				st = d
+			}
+			return st.Err()
+		}
+	}
+	return nil
+}
+
+// begin rate limits and authorizes a call to method, and puts timeout (0 for none) on
+// its context
+func (g grpcInterceptors) begin(ctx context.Context, method string, timeout time.Duration) (context.Context, context.CancelFunc, error) {
+	if err := g.rateLimit(ctx, method); err != nil {
+		return nil, nil, err
+	}
+	ctx, err := grpcAuthorize(ctx, method)
+	if err != nil {
+		return nil, nil, err
+	}
+	if timeout > 0 {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		return ctx, cancel, nil
+	}
+	return ctx, func() {}, nil
+}
+
+// recoverGRPC turns a panicking handler into a logged stack trace and Internal instead
+// of a crashed server, like recoverPanics; deferred with the handler's error
+func recoverGRPC(ctx context.Context, method string, err *error) {
+	v := recover()
+	if v == nil {
+		return
+	}
+	logFor(ctx).Error("panic", "method", method, "err", fmt.Sprint(v), "stack", string(debug.Stack()))
+	*err = status.Error(codes.Internal, "internal server error")
+}
+
+// unary handles calls within server.request_timeout
+func (g grpcInterceptors) unary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	start := time.Now()
+	ctx, cancel, err := g.begin(ctx, info.FullMethod, time.Duration(cfg.Server.RequestTimeout))
+	if err != nil {
+		logGRPC(context.Background(), info.FullMethod, start, err)
+		return nil, err
+	}
+	defer cancel()
+	defer func() { logGRPC(ctx, info.FullMethod, start, err) }()
+	defer recoverGRPC(ctx, info.FullMethod, &err)
+	return handler(ctx, req)
+}
+
+// stream handles streams within the timeout of an export (see limitRequests)
+func (g grpcInterceptors) stream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	start := time.Now()
+	ctx, cancel, err := g.begin(ss.Context(), info.FullMethod, routeTimeout("/api/employees/export"))
+	if err != nil {
+		logGRPC(context.Background(), info.FullMethod, start, err)
+		return err
+	}
+	defer cancel()
+	defer func() { logGRPC(ctx, info.FullMethod, start, err) }()
+	defer recoverGRPC(ctx, info.FullMethod, &err)
+	return handler(srv, &authorizedStream{ServerStream: ss, ctx: ctx})
+}
+
+// authorizedStream is a server stream whose context carries the caller's claims
+type authorizedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authorizedStream) Context() context.Context { return s.ctx }
+
+// grpcActor is the token subject of a call, like actorFromRequest
+func grpcActor(ctx context.Context) string {
+	if c, ok := ctx.Value(userKey).(*tokenClaims); ok && c.Subject != "" {
+		return c.Subject
+	}
+	return "anonymous"
+}
+
+// grpcIsAdmin reports whether the call's token carries the admin role
+func grpcIsAdmin(ctx context.Context) bool {
+	c, ok := ctx.Value(userKey).(*tokenClaims)
+	return ok && c.Role == "admin"
+}
+
+// startGRPC serves EmployeeService on addr (server.grpc_addr; nothing when empty) and
+// returns the function that drains and stops it
+func startGRPC(addr string) (func(), error) {
+	if addr == "" {
+		return func() {}, nil
+	}
+	g := grpcInterceptors{limits: newRequestLimits()}
+	opts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(g.unary),
+		grpc.StreamInterceptor(g.stream),
+	}
+	// the certificates of the HTTPS listener
+	tlsConfig, _, err := serverTLS(nil)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	srv := grpc.NewServer(opts...)
+	pb.RegisterEmployeeServiceServer(srv, employeeServer{})
+	// lets grpcurl and similar tools discover the service
+	reflection.Register(srv)
+
+	go func() {
+		if err := srv.Serve(ln); err != nil {
+			slog.Error("grpc server", "err", err)
+		}
+	}()
+	slog.Info("grpc server running", "addr", addr, "tls", tlsConfig != nil)
+
+	return func() {
+		done := make(chan struct{})
+		go func() {
+			srv.GracefulStop()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(drainTimeout()):
+			srv.Stop()
+		}
+		slog.Info("grpc server stopped")
+	}, nil
+}
+
+// ---------------- Service ----------------
+
+// employeeServer implements EmployeeService on the same store and helpers as REST
+type employeeServer struct {
+	pb.UnimplementedEmployeeServiceServer
+}
+
+// employeeProto converts a list row
+func employeeProto(row employeeRow) *pb.Employee {
+	e := &pb.Employee{
+		EmpId:      int32(row.EmpID),
+		EmpName:    row.EmpName,
+		Department: row.Department,
+		Languages:  row.Languages,
+		Status:     row.Status,
+		Tags:       row.Tags,
+		PhotoUrl:   row.PhotoURL,
+		Version:    int32(row.Version),
+	}
+	if row.UpdatedAt != nil {
+		e.UpdatedAt = timestamppb.New(*row.UpdatedAt)
+	}
+	return e
+}
+
+// filterValues turns a filter into the list params employeeQuery reads
+func filterValues(f *pb.EmployeeFilter) url.Values {
+	q := url.Values{}
+	for name, v := range map[string]string{
+		"status": f.GetStatus(), "department": f.GetDepartment(), "language": f.GetLanguage(), "sort": f.GetSort(),
+	} {
+		if v != "" {
+			q.Set(name, v)
+		}
+	}
+	if tags := f.GetTags(); len(tags) > 0 {
+		q["tag"] = append([]string(nil), tags...)
+	}
+	return q
+}
+
+// getEmployeeProto loads a live employee
+func getEmployeeProto(ctx context.Context, empId int) (*pb.Employee, error) {
+	raw, err := employees.Get(ctx, empId, nil)
+	if err != nil {
+		return nil, grpcStoreError(fmt.Sprintf("employee %d", empId), err)
+	}
+	var row employeeRow
+	if err := raw.Decode(&row); err != nil {
+		return nil, grpcStoreError("decode", err)
+	}
+	return employeeProto(row), nil
+}
+
+func (employeeServer) List(ctx context.Context, req *pb.ListEmployeesRequest) (*pb.ListEmployeesResponse, error) {
+	q := filterValues(req.GetFilter())
+	if req.GetPage() != 0 {
+		q.Set("page", strconv.Itoa(int(req.GetPage())))
+	}
+	if req.GetLimit() != 0 {
+		q.Set("limit", strconv.Itoa(int(req.GetLimit())))
+	}
+	p, status, err := findEmployeePage(ctx, q, grpcActor(ctx), grpcIsAdmin(ctx))
+	if err != nil {
+		return nil, grpcError(status, err)
+	}
+	resp := &pb.ListEmployeesResponse{Page: int32(p.Page), Limit: int32(p.Limit), Total: int32(p.Total)}
+	for _, row := range p.Items {
+		resp.Items = append(resp.Items, employeeProto(row))
+	}
+	return resp, nil
+}
+
+func (employeeServer) StreamList(req *pb.StreamEmployeesRequest, stream grpc.ServerStreamingServer[pb.Employee]) error {
+	ctx := stream.Context()
+	q := filterValues(req.GetFilter())
+	query, status, err := employeeQuery(ctx, q, grpcActor(ctx), grpcIsAdmin(ctx))
+	if err != nil {
+		return grpcError(status, err)
+	}
+	if len(query.Sort) == 0 {
+		query.Sort = bson.D{{Key: "emp_id", Value: 1}}
+	}
+	for raw, err := range employees.Stream(ctx, query) {
+		if err != nil {
+			return grpcStoreError("list employees", err)
+		}
+		var row employeeRow
+		if err := raw.Decode(&row); err != nil {
+			return grpcStoreError("decode", err)
+		}
+		if err := stream.Send(employeeProto(row)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (employeeServer) Get(ctx context.Context, req *pb.GetEmployeeRequest) (*pb.Employee, error) {
+	return getEmployeeProto(ctx, int(req.GetEmpId()))
+}
+
+func (employeeServer) Create(ctx context.Context, req *pb.CreateEmployeeRequest) (*pb.Employee, error) {
+	defs, err := loadCustomFields(ctx)
+	if err != nil {
+		return nil, grpcStoreError("find custom fields", err)
+	}
+	ref, err := loadReferenceData(ctx)
+	if err != nil {
+		return nil, grpcStoreError("reference data", err)
+	}
+	name, dept := req.GetEmpName(), req.GetDepartment()
+	input := models.EmployeePayload{EmpName: &name, Department: &dept, Languages: req.GetLanguages()}
+	emp, errs := newEmployee(defs, ref, &input)
+	if len(errs) > 0 {
+		return nil, grpcValidationError(errs)
+	}
+	if emp.EmpID, err = nextID(ctx); err != nil {
+		return nil, grpcStoreError("allocate id", err)
+	}
+	if err := employees.Create(ctx, emp, grpcActor(ctx)); err != nil {
+		return nil, grpcStoreError("create employee", err)
+	}
+	invalidateEmployeeLists()
+	return getEmployeeProto(ctx, emp.EmpID)
+}
+
+func (employeeServer) Update(ctx context.Context, req *pb.UpdateEmployeeRequest) (*pb.Employee, error) {
+	empId := int(req.GetEmpId())
+	// in approval mode non-admin edits wait for an approver, which only REST can submit
+	if approvalMode() && !grpcIsAdmin(ctx) {
+		return nil, status.Errorf(codes.FailedPrecondition, "edits need approval; submit them with PUT /api/employees/%d", empId)
+	}
+	defs, err := loadCustomFields(ctx)
+	if err != nil {
+		return nil, grpcStoreError("find custom fields", err)
+	}
+	ref, err := loadReferenceData(ctx)
+	if err != nil {
+		return nil, grpcStoreError("reference data", err)
+	}
+	input := models.EmployeePayload{EmpName: req.EmpName, Department: req.Department}
+	if req.Languages != nil {
+		input.Languages = append([]string{}, req.Languages.GetValues()...)
+	}
+	if req.Version != nil {
+		v := int(req.GetVersion())
+		input.Version = &v
+	}
+	if errs := validatePayload(&input, ref, false); len(errs) > 0 {
+		return nil, grpcValidationError(errs)
+	}
+	if err := applyEmployeeUpdate(ctx, empId, input, defs, grpcActor(ctx)); err != nil {
+		if errors.Is(err, models.ErrVersionConflict) {
+			current, _ := employees.Version(ctx, empId)
+			return nil, status.Errorf(codes.Aborted, "employee was changed by someone else (version %d, expected %d); reload and retry",
+				current, *input.Version)
+		}
+		return nil, grpcStoreError("update employee", err)
+	}
+	invalidateEmployeeLists()
+	return getEmployeeProto(ctx, empId)
+}
+
+func (employeeServer) Delete(ctx context.Context, req *pb.DeleteEmployeeRequest) (*pb.DeleteEmployeeResponse, error) {
+	n, err := employees.SoftDelete(ctx, int(req.GetEmpId()), grpcActor(ctx))
+	if err != nil {
+		return nil, grpcStoreError("delete employee", err)
+	}
+	invalidateEmployeeLists()
+	return &pb.DeleteEmployeeResponse{Deleted: n > 0}, nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/karthikeyan-meenachisundaram/goBack/employeepb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// grpcContext is the incoming context of a call by user, with role, of organization org
+func grpcContext(t *testing.T, user, role, org string) context.Context {
+	t.Helper()
+	token, err := issueToken(User{Username: user, Role: role}, org, "access", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+}
+
+// expectCode fails the test unless err has code
+func expectCode(t *testing.T, err error, code codes.Code) {
+	t.Helper()
+	if status.Code(err) != code {
+		t.Errorf("code = %v, want %v (%v)", status.Code(err), code, err)
+	}
+}
+
+func TestGRPCInterceptors(t *testing.T) {
+	useBootstrapAccount(t)
+	ctx := grpcContext(t, "ann", "viewer", defaultOrg)
+	ok := func(context.Context, any) (any, error) { return "ok", nil }
+	unary := func(g grpcInterceptors, method string, handler grpc.UnaryHandler) error {
+		_, err := g.unary(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+		return err
+	}
+	g := grpcInterceptors{}
+
+	t.Run("panic", func(t *testing.T) {
+		err := unary(g, pb.EmployeeService_Get_FullMethodName, func(context.Context, any) (any, error) { panic("boom") })
+		expectCode(t, err, codes.Internal)
+	})
+	t.Run("role", func(t *testing.T) {
+		expectCode(t, unary(g, pb.EmployeeService_Get_FullMethodName, ok), codes.OK)
+		expectCode(t, unary(g, pb.EmployeeService_Delete_FullMethodName, ok), codes.PermissionDenied)
+		expectCode(t, unary(g, "/grpc.reflection.v1.ServerReflection/ServerReflectionInfo", ok), codes.OK)
+		expectCode(t, unary(g, "/other.Service/Method", ok), codes.PermissionDenied)
+	})
+	t.Run("timeout", func(t *testing.T) {
+		err := unary(g, pb.EmployeeService_Get_FullMethodName, func(ctx context.Context, _ any) (any, error) {
+			if d, ok := ctx.Deadline(); !ok || time.Until(d) > time.Duration(cfg.Server.RequestTimeout) {
+				t.Errorf("deadline = %v, %v", d, ok)
+			}
+			return nil, nil
+		})
+		expectCode(t, err, codes.OK)
+	})
+	t.Run("rate limit", func(t *testing.T) {
+		cfg.RateLimit.PerMinute, cfg.RateLimit.Burst = 60, 2
+		g := grpcInterceptors{limits: newRequestLimits()}
+		for range 2 {
+			expectCode(t, unary(g, pb.EmployeeService_Get_FullMethodName, ok), codes.OK)
+		}
+		expectCode(t, unary(g, pb.EmployeeService_Get_FullMethodName, ok), codes.ResourceExhausted)
+	})
+}
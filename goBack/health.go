@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var (
+	startedAt = time.Now()
+	// draining is set once shutdown starts so readiness fails while requests drain
+	draining atomic.Bool
+)
+
+// uptime is the time since the process started, rounded to seconds
+func uptime() string {
+	return time.Since(startedAt).Round(time.Second).String()
+}
+
+// healthzHandler handles GET /healthz (liveness): the process is up and serving
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(bson.M{
+		"status":     "ok",
+		"started_at": startedAt.UTC(),
+		"uptime":     uptime(),
+	})
+}
+
+// readyzHandler handles GET /readyz (readiness): Mongo answers a ping within 2s and the
+// server is not draining. Anything else is reported as degraded with 503. The mongo check
+// also carries the driver's connection state (see mongoState).
+func readyzHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	mongoCheck := bson.M{"status": "ok", "connection": mongoState()}
+	start := time.Now()
+	err := client.Ping(ctx, nil)
+	mongoCheck["latency_ms"] = time.Since(start).Milliseconds()
+	if err != nil {
+		mongoCheck["status"] = "down"
+		mongoCheck["error"] = err.Error()
+	}
+
+	status, code := "ready", http.StatusOK
+	switch {
+	case draining.Load():
+		status, code = "draining", http.StatusServiceUnavailable
+	case err != nil:
+		status, code = "degraded", http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(bson.M{
+		"status":   status,
+		"database": cfg.Mongo.Database,
+		"uptime":   uptime(),
+		"checks":   bson.M{"mongo": mongoCheck},
+	})
+}
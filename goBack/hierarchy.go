@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/karthikeyan-meenachisundaram/goBack/models"
+	"github.com/karthikeyan-meenachisundaram/goBack/repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// An employee's manager_id is the emp_id of the employee they report to; employees
+// without one (or whose manager was deleted) are at the top of the org chart. Writes
+// check that the manager exists and that no chain of managers loops back.
+
+// OrgNode is one employee in the org chart with the employees reporting to them
+type OrgNode struct {
+	EmpID      int        `bson:"emp_id" json:"emp_id"`
+	EmpName    string     `bson:"emp_name" json:"emp_name"`
+	Department string     `bson:"department" json:"department"`
+	Status     string     `bson:"status" json:"status"`
+	ManagerID  int        `bson:"manager_id,omitempty" json:"manager_id,omitempty"`
+	PhotoURL   string     `bson:"photo_url,omitempty" json:"photo_url,omitempty"`
+	Reports    []*OrgNode `bson:"-" json:"reports"`
+}
+
+// managerID is a payload's manager_id as stored, 0 for none
+func managerID(p *int) int {
+	if p == nil {
+		return 0
+	}
+	return *p
+}
+
+// reportsLookup follows manager_id down from each matched employee into "reports", live
+// employees only, with their distance in "level" (0 for direct reports). maxDepth < 0
+// follows every level.
+func reportsLookup(maxDepth int) bson.D {
+	lookup := bson.D{
+		{Key: "from", Value: "Employee"},
+		{Key: "startWith", Value: "$emp_id"},
+		{Key: "connectFromField", Value: "emp_id"},
+		{Key: "connectToField", Value: "manager_id"},
+		{Key: "as", Value: "reports"},
+		{Key: "depthField", Value: "level"},
+		{Key: "restrictSearchWithMatch", Value: repository.Live(bson.M{})},
+	}
+	if maxDepth >= 0 {
+		lookup = append(lookup, bson.E{Key: "maxDepth", Value: maxDepth})
+	}
+	return bson.D{{Key: "$graphLookup", Value: lookup}}
+}
+
+// checkManager validates managerId as the manager of empId (0 for an employee not created
+// yet): it must be a live employee who doesn't report to empId, directly or not. It
+// returns the problem for the manager_id field, "" when there is none.
+func checkManager(ctx context.Context, empId, managerId int) (string, error) {
+	if managerId == empId {
+		return "an employee cannot be their own manager", nil
+	}
+	// the manager's own chain of managers, up to the top
+	chain, err := employees.Managers(ctx, managerId)
+	switch {
+	case errors.Is(err, models.ErrNotFound):
+		return fmt.Sprintf("employee %d not found", managerId), nil
+	case err != nil:
+		return "", err
+	case empId != 0 && slices.Contains(chain, empId):
+		return fmt.Sprintf("employee %d reports to %d, directly or not", managerId, empId), nil
+	}
+	return "", nil
+}
+
+// buildOrgChart nests rows under their managers. Rows whose emp_id is in roots stay at the
+// top; every other row's manager must be among the rows.
+func buildOrgChart(rows []OrgNode, roots map[int]bool) []*OrgNode {
+	nodes := make(map[int]*OrgNode, len(rows))
+	for i := range rows {
+		rows[i].Reports = []*OrgNode{}
+		nodes[rows[i].EmpID] = &rows[i]
+	}
+	top := []*OrgNode{}
+	for i := range rows {
+		n := &rows[i]
+		if roots[n.EmpID] {
+			top = append(top, n)
+		} else if m, ok := nodes[n.ManagerID]; ok {
+			m.Reports = append(m.Reports, n)
+		}
+	}
+	return top
+}
+
+// ---------------- Handlers ----------------
+
+// employeeReports handles GET /api/employees/{id}/reports: the employee's direct reports
+// as list rows, or with ?recursive=true everyone below them, nearest first
+func employeeReports(w http.ResponseWriter, r *http.Request, empId int) {
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	maxDepth := 0
+	if r.URL.Query().Get("recursive") == "true" {
+		maxDepth = -1
+	}
+
+	ctx := r.Context()
+
+	if _, err := employees.Version(ctx, empId); err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			httpError(w, fmt.Sprintf("Employee %d not found", empId), http.StatusNotFound)
+			return
+		}
+		storeError(w, "find employee", err)
+		return
+	}
+	defs, err := loadCustomFields(ctx)
+	if err != nil {
+		storeError(w, "find custom fields", err)
+		return
+	}
+	pipeline := mongo.Pipeline{
+		bson.D{{Key: "$match", Value: repository.Live(bson.M{"emp_id": empId})}},
+		reportsLookup(maxDepth),
+		bson.D{{Key: "$unwind", Value: "$reports"}},
+		bson.D{{Key: "$replaceRoot", Value: bson.M{"newRoot": "$reports"}}},
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "level", Value: 1}, {Key: "emp_id", Value: 1}}}},
+	}
+	// the reports are live already; the details stages keep their order
+	pipeline = append(pipeline, repository.DetailsPipeline(bson.M{})...)
+	if hidden := hiddenCustomFields(defs, isAdmin(r)); len(hidden) > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$unset", Value: hidden}})
+	}
+	cur, err := coll(ctx, "Employee").Aggregate(ctx, pipeline)
+	if err != nil {
+		storeError(w, "aggregate", err)
+		return
+	}
+	defer cur.Close(ctx)
+	var raws []models.Row
+	for cur.Next(ctx) {
+		raws = append(raws, models.Row(slices.Clone(cur.Current)))
+	}
+	if err := cur.Err(); err != nil {
+		storeError(w, "cursor all", err)
+		return
+	}
+	items, err := employeeRows(raws, false, apiVersion(r) == 2)
+	if err != nil {
+		storeError(w, "decode", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(items)
+}
+
+// orgChartHandler handles GET /api/orgchart?root=&depth=: the reporting tree of every
+// employee at the top (no manager, or a deleted one), or below ?root= only. ?depth= limits
+// how many levels of reports are included.
+func orgChartHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	match := repository.Live(bson.M{})
+	if v := q.Get("root"); v != "" {
+		root, err := strconv.Atoi(v)
+		if err != nil {
+			httpError(w, "invalid root", http.StatusBadRequest)
+			return
+		}
+		match["emp_id"] = root
+	}
+	maxDepth := -1
+	if v := q.Get("depth"); v != "" {
+		depth, err := strconv.Atoi(v)
+		if err != nil || depth < 1 {
+			httpError(w, "depth must be a positive number", http.StatusBadRequest)
+			return
+		}
+		maxDepth = depth - 1
+	}
+
+	ctx := r.Context()
+
+	// the roots and the ids of everyone below them
+	_, rooted := match["emp_id"]
+	pipeline := mongo.Pipeline{bson.D{{Key: "$match", Value: match}}}
+	if !rooted {
+		pipeline = append(pipeline,
+			bson.D{{Key: "$lookup", Value: bson.D{
+				{Key: "from", Value: "Employee"},
+				{Key: "localField", Value: "manager_id"},
+				{Key: "foreignField", Value: "emp_id"},
+				{Key: "pipeline", Value: bson.A{bson.D{{Key: "$match", Value: repository.Live(bson.M{})}}}},
+				{Key: "as", Value: "manager"},
+			}}},
+			bson.D{{Key: "$match", Value: bson.M{"manager": bson.M{"$size": 0}}}},
+		)
+	}
+	pipeline = append(pipeline,
+		reportsLookup(maxDepth),
+		bson.D{{Key: "$project", Value: bson.M{"_id": 0, "emp_id": 1, "reports": "$reports.emp_id"}}},
+	)
+	cur, err := coll(ctx, "Employee").Aggregate(ctx, pipeline)
+	if err != nil {
+		storeError(w, "aggregate", err)
+		return
+	}
+	var trees []struct {
+		EmpID   int   `bson:"emp_id"`
+		Reports []int `bson:"reports"`
+	}
+	err = cur.All(ctx, &trees)
+	cur.Close(ctx)
+	if err != nil {
+		storeError(w, "cursor all", err)
+		return
+	}
+	if len(trees) == 0 && rooted {
+		httpError(w, fmt.Sprintf("Employee %s not found", q.Get("root")), http.StatusNotFound)
+		return
+	}
+	roots := map[int]bool{}
+	ids := bson.A{}
+	for _, t := range trees {
+		roots[t.EmpID] = true
+		ids = append(ids, t.EmpID)
+		for _, id := range t.Reports {
+			ids = append(ids, id)
+		}
+	}
+
+	// then everyone in the chart as a list row, nested by manager
+	pipeline = repository.DetailsPipeline(repository.Live(bson.M{"emp_id": bson.M{"$in": ids}}))
+	pipeline = append(pipeline, bson.D{{Key: "$sort", Value: bson.D{{Key: "emp_id", Value: 1}}}})
+	cur, err = coll(ctx, "Employee").Aggregate(ctx, pipeline)
+	if err != nil {
+		storeError(w, "aggregate", err)
+		return
+	}
+	defer cur.Close(ctx)
+	rows := []OrgNode{}
+	if err := cur.All(ctx, &rows); err != nil {
+		storeError(w, "cursor all", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(bson.M{"roots": buildOrgChart(rows, roots), "total": len(rows)})
+}
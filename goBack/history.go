@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/karthikeyan-meenachisundaram/goBack/repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// EmployeeRevision is the state of an employee after one change, in the EmployeeHistory
+// collection. A revision is valid from its valid_from until the next one, so the
+// employee as of a date is the last revision before it.
+type EmployeeRevision struct {
+	ID        primitive.ObjectID     `bson:"_id,omitempty" json:"-"`
+	EmpID     int                    `bson:"emp_id" json:"emp_id"`
+	Version   int                    `bson:"version" json:"version"`
+	Action    string                 `bson:"action" json:"action"` // the audit action, or "baseline" for the state history started from
+	Actor     string                 `bson:"actor,omitempty" json:"actor,omitempty"`
+	ValidFrom time.Time              `bson:"valid_from" json:"valid_from"`
+	Deleted   bool                   `bson:"deleted,omitempty" json:"deleted,omitempty"`
+	Employee  bson.M                 `bson:"employee,omitempty" json:"employee,omitempty"` // audited fields, nested
+	Changes   map[string]FieldChange `bson:"changes,omitempty" json:"changes,omitempty"`
+}
+
+// nestSnapshot turns a flat employee snapshot back into a document: "custom_fields.x"
+// and "termination.x" become embedded fields
+func nestSnapshot(snap bson.M) bson.M {
+	if snap == nil {
+		return nil
+	}
+	doc := bson.M{}
+	for k, v := range snap {
+		parent, sub, ok := strings.Cut(k, ".")
+		if !ok {
+			doc[k] = v
+			continue
+		}
+		m, _ := doc[parent].(bson.M)
+		if m == nil {
+			m = bson.M{}
+			doc[parent] = m
+		}
+		m[sub] = v
+	}
+	return doc
+}
+
+// newRevision is the revision after a change with these field changes; after is the
+// flat snapshot of the employee, nil once deleted
+func newRevision(action string, empId, version int, actor string, at time.Time, after bson.M, changes map[string]FieldChange) EmployeeRevision {
+	return EmployeeRevision{
+		EmpID:     empId,
+		Version:   version,
+		Action:    action,
+		Actor:     actor,
+		ValidFrom: at,
+		Deleted:   after == nil,
+		Employee:  nestSnapshot(after),
+		Changes:   changes,
+	}
+}
+
+// recordRevision appends the revision after an audited change; see recordAuditDiff
+func recordRevision(ctx context.Context, action string, empId int, actor string, at time.Time, after bson.M, changes map[string]FieldChange) error {
+	var emp struct {
+		Version int `bson:"version"`
+	}
+	err := coll(ctx, "Employee").FindOne(ctx, bson.M{"emp_id": empId},
+		options.FindOne().SetProjection(bson.M{"version": 1})).Decode(&emp)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return err
+	}
+	_, err = coll(ctx, "EmployeeHistory").InsertOne(ctx, newRevision(action, empId, emp.Version, actor, at, after, changes))
+	return err
+}
+
+// initHistory records a baseline revision, the current state, for every live employee
+// without any history (those from before history was kept, or restored from a backup).
+// It is safe to run on every start.
+func initHistory(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+
+	pipeline := mongo.Pipeline{
+		bson.D{{Key: "$match", Value: repository.Live(bson.M{})}},
+		bson.D{{Key: "$lookup", Value: bson.D{
+			{Key: "from", Value: "EmployeeHistory"},
+			{Key: "localField", Value: "emp_id"},
+			{Key: "foreignField", Value: "emp_id"},
+			{Key: "pipeline", Value: bson.A{bson.D{{Key: "$limit", Value: 1}}}},
+			{Key: "as", Value: "history"},
+		}}},
+		bson.D{{Key: "$match", Value: bson.M{"history": bson.M{"$size": 0}}}},
+		bson.D{{Key: "$project", Value: bson.M{"emp_id": 1}}},
+	}
+	cur, err := coll(ctx, "Employee").Aggregate(ctx, pipeline)
+	if err != nil {
+		slog.Error("initHistory: find employees", "err", err)
+		return
+	}
+	var missing []struct {
+		EmpID int `bson:"emp_id"`
+	}
+	err = cur.All(ctx, &missing)
+	cur.Close(ctx)
+	if err != nil {
+		slog.Error("initHistory: find employees", "err", err)
+		return
+	}
+
+	now := time.Now().UTC()
+	var models []mongo.WriteModel
+	for _, m := range missing {
+		raw, err := employees.Get(ctx, m.EmpID, nil)
+		if err != nil {
+			continue // deleted meanwhile
+		}
+		var doc bson.M
+		if err := raw.Decode(&doc); err != nil {
+			continue
+		}
+		rev := newRevision("baseline", m.EmpID, raw.Version(), "", now, snapshotFields(doc), nil)
+		// keyed on the baseline, so instances starting together add it once
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"emp_id": m.EmpID, "action": "baseline"}).
+			SetUpdate(bson.M{"$setOnInsert": rev}).
+			SetUpsert(true))
+	}
+	for start := 0; start < len(models); start += importBatchSize {
+		batch := models[start:min(start+importBatchSize, len(models))]
+		if _, err := coll(ctx, "EmployeeHistory").BulkWrite(ctx, batch, options.BulkWrite().SetOrdered(false)); err != nil {
+			slog.Error("initHistory: write baselines", "err", err)
+			return
+		}
+	}
+	if len(models) > 0 {
+		slog.Info("recorded baseline history", "employees", len(models))
+	}
+}
+
+// revisionAsOf is the revision of an employee in effect at t
+func revisionAsOf(ctx context.Context, empId int, t time.Time) (EmployeeRevision, error) {
+	var rev EmployeeRevision
+	err := coll(ctx, "EmployeeHistory").FindOne(ctx,
+		bson.M{"emp_id": empId, "valid_from": bson.M{"$lte": t}},
+		options.FindOne().SetSort(bson.D{{Key: "valid_from", Value: -1}, {Key: "_id", Value: -1}}),
+	).Decode(&rev)
+	return rev, err
+}
+
+// hideRevisionFields drops the hidden custom fields (as hiddenCustomFields lists them)
+// from a revision and makes it plain for JSON
+func hideRevisionFields(rev *EmployeeRevision, hidden []string) {
+	rev.Employee, _ = plainValue(rev.Employee).(bson.M)
+	for f, c := range rev.Changes {
+		rev.Changes[f] = FieldChange{Before: plainValue(c.Before), After: plainValue(c.After)}
+	}
+	for _, path := range hidden {
+		delete(rev.Changes, path)
+		if _, name, ok := strings.Cut(path, "."); ok {
+			if cf, _ := rev.Employee["custom_fields"].(bson.M); cf != nil {
+				delete(cf, name)
+			}
+		}
+	}
+}
+
+// ---------------- Handlers ----------------
+
+// employeeHistory handles GET /api/employees/{id}/history?page=&limit=: the employee's
+// revisions, newest first, each with its state and what changed. It stays available
+// while the employee is in the trash.
+func employeeHistory(w http.ResponseWriter, r *http.Request, empId int) {
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	page, limit, err := parsePage(r.URL.Query())
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	filter := bson.M{"emp_id": empId}
+	total, err := coll(ctx, "EmployeeHistory").CountDocuments(ctx, filter)
+	if err != nil {
+		storeError(w, "count history", err)
+		return
+	}
+	if total == 0 {
+		httpError(w, fmt.Sprintf("no history for employee %d", empId), http.StatusNotFound)
+		return
+	}
+	defs, err := loadCustomFields(ctx)
+	if err != nil {
+		storeError(w, "find custom fields", err)
+		return
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "valid_from", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(int64((page - 1) * limit)).
+		SetLimit(int64(limit))
+	cur, err := coll(ctx, "EmployeeHistory").Find(ctx, filter, opts)
+	if err != nil {
+		storeError(w, "find history", err)
+		return
+	}
+	defer cur.Close(ctx)
+	items := []EmployeeRevision{}
+	if err := cur.All(ctx, &items); err != nil {
+		storeError(w, "cursor all", err)
+		return
+	}
+	hidden := hiddenCustomFields(defs, isAdmin(r))
+	for i := range items {
+		hideRevisionFields(&items[i], hidden)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(bson.M{"items": items, "page": page, "limit": limit, "total": total})
+}
+
+// getEmployeeAsOf answers GET /api/employees/{id}?as_of= (YYYY-MM-DD meaning the end of
+// that day, or RFC 3339) with the employee as they were then, shaped like the current one
+func getEmployeeAsOf(w http.ResponseWriter, r *http.Request, empId int, asOf string) {
+	t, err := parseAuditTime(asOf, true)
+	if err != nil {
+		httpError(w, "invalid as_of, expected YYYY-MM-DD or RFC 3339", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	rev, err := revisionAsOf(ctx, empId, t)
+	if err == mongo.ErrNoDocuments || (err == nil && rev.Deleted) {
+		httpError(w, fmt.Sprintf("Employee %d not found as of %s", empId, asOf), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		storeError(w, "find history", err)
+		return
+	}
+	defs, err := loadCustomFields(ctx)
+	if err != nil {
+		storeError(w, "find custom fields", err)
+		return
+	}
+	hideRevisionFields(&rev, hiddenCustomFields(defs, isAdmin(r)))
+
+	doc := rev.Employee
+	doc["emp_id"] = rev.EmpID
+	doc["version"] = rev.Version
+	doc["updated_at"] = rev.ValidFrom
+	if langs, _ := doc["languages"].(bson.A); len(langs) > 0 {
+		doc["language"] = langs[0]
+	}
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		storeError(w, "encode", err)
+		return
+	}
+	writeEmployee(w, r, raw)
+}
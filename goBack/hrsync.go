@@ -0,0 +1,491 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/karthikeyan-meenachisundaram/goBack/repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Inbound roster sync from an external HR system, configured by sync.source (an HTTP
+// URL, or a file:// one for CSVs dropped over SFTP), sync.auth_header and sync.interval;
+// see SyncConfig.
+//
+// The CSV needs a header row with emp_id, emp_name, department and language
+// (several languages separated by ";"). Employees created by the sync that are
+// missing from a later roster are deactivated.
+
+// SyncRowError is a roster row that could not be applied
+type SyncRowError struct {
+	Row     int    `bson:"row" json:"row"`
+	EmpID   string `bson:"emp_id" json:"emp_id"`
+	Message string `bson:"message" json:"message"`
+}
+
+// SyncReport is the reconciliation report of one sync run
+type SyncReport struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Source      string             `bson:"source" json:"source"`
+	Trigger     string             `bson:"trigger" json:"trigger"` // schedule|manual
+	Status      string             `bson:"status" json:"status"`   // ok|failed
+	Error       string             `bson:"error,omitempty" json:"error,omitempty"`
+	StartedAt   time.Time          `bson:"started_at" json:"started_at"`
+	FinishedAt  time.Time          `bson:"finished_at" json:"finished_at"`
+	RosterSize  int                `bson:"roster_size" json:"roster_size"`
+	Created     []int              `bson:"created" json:"created"`
+	Updated     []int              `bson:"updated" json:"updated"`
+	Unchanged   int                `bson:"unchanged" json:"unchanged"`
+	Deactivated []int              `bson:"deactivated" json:"deactivated"`
+	RowErrors   []SyncRowError     `bson:"row_errors" json:"row_errors"`
+}
+
+// rosterRow is one employee as described by the external source
+type rosterRow struct {
+	line       int
+	empId      int
+	empName    string
+	department string
+	languages  []string
+}
+
+var syncMu sync.Mutex // one run at a time
+
+// fetchRoster opens the configured source
+func fetchRoster(ctx context.Context, source string) (io.ReadCloser, error) {
+	u, err := url.Parse(source)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https":
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+		if err != nil {
+			return nil, err
+		}
+		if h := cfg.Sync.AuthHeader; h != "" {
+			req.Header.Set("Authorization", h)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("source returned %s", resp.Status)
+		}
+		return resp.Body, nil
+	case "file":
+		path := u.Path
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if info.IsDir() {
+			matches, _ := filepath.Glob(filepath.Join(path, "*.csv"))
+			var newest time.Time
+			path = ""
+			for _, m := range matches {
+				if fi, err := os.Stat(m); err == nil && fi.ModTime().After(newest) {
+					newest, path = fi.ModTime(), m
+				}
+			}
+			if path == "" {
+				return nil, fmt.Errorf("no .csv files in %s", u.Path)
+			}
+		}
+		return os.Open(path)
+	}
+	return nil, fmt.Errorf("unsupported sync source scheme %q", u.Scheme)
+}
+
+// parseRoster reads the CSV roster; bad rows are reported instead of aborting the run
+func parseRoster(r io.Reader) ([]rosterRow, []SyncRowError, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("read header: %w", err)
+	}
+	col := map[string]int{}
+	for i, h := range header {
+		col[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	for _, c := range []string{"emp_id", "emp_name", "department", "language"} {
+		if _, ok := col[c]; !ok {
+			return nil, nil, fmt.Errorf("missing column %q", c)
+		}
+	}
+
+	var rows []rosterRow
+	var rowErrs []SyncRowError
+	seen := map[int]bool{}
+	for line := 2; ; line++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			rowErrs = append(rowErrs, SyncRowError{Row: line, Message: err.Error()})
+			continue
+		}
+		get := func(c string) string {
+			if i := col[c]; i < len(rec) {
+				return strings.TrimSpace(rec[i])
+			}
+			return ""
+		}
+		id, err := strconv.Atoi(get("emp_id"))
+		if err != nil || id <= 0 {
+			rowErrs = append(rowErrs, SyncRowError{Row: line, EmpID: get("emp_id"), Message: "invalid emp_id"})
+			continue
+		}
+		if seen[id] {
+			rowErrs = append(rowErrs, SyncRowError{Row: line, EmpID: get("emp_id"), Message: "duplicate emp_id in roster"})
+			continue
+		}
+		seen[id] = true
+		if get("emp_name") == "" {
+			rowErrs = append(rowErrs, SyncRowError{Row: line, EmpID: get("emp_id"), Message: "emp_name is required"})
+			continue
+		}
+		var langs []string
+		for _, l := range strings.Split(get("language"), ";") {
+			if l = strings.TrimSpace(l); l != "" {
+				langs = append(langs, l)
+			}
+		}
+		rows = append(rows, rosterRow{line: line, empId: id, empName: get("emp_name"), department: get("department"), languages: langs})
+	}
+	return rows, rowErrs, nil
+}
+
+// currentEmployee is the stored state a roster row is compared against
+type currentEmployee struct {
+	EmpID      int      `bson:"emp_id"`
+	EmpName    string   `bson:"emp_name"`
+	Status     string   `bson:"status"`
+	Synced     bool     `bson:"synced"`
+	Department string   `bson:"department"`
+	Languages  []string `bson:"languages"`
+}
+
+// loadCurrentEmployees returns every live employee keyed by emp_id
+func loadCurrentEmployees(ctx context.Context) (map[int]currentEmployee, error) {
+	pipeline := mongo.Pipeline{
+		bson.D{{Key: "$match", Value: repository.Live(bson.M{})}},
+		repository.DepartmentLookup(),
+		bson.D{{Key: "$lookup", Value: bson.D{
+			{Key: "from", Value: "Developers"},
+			{Key: "localField", Value: "emp_id"},
+			{Key: "foreignField", Value: "emp_id"},
+			{Key: "pipeline", Value: bson.A{bson.D{{Key: "$sort", Value: bson.D{{Key: "position", Value: 1}}}}}},
+			{Key: "as", Value: "languages"},
+		}}},
+		bson.D{{Key: "$project", Value: bson.D{
+			{Key: "emp_id", Value: 1},
+			{Key: "emp_name", Value: 1},
+			{Key: "status", Value: 1},
+			{Key: "synced", Value: 1},
+			{Key: "department", Value: bson.M{"$arrayElemAt": bson.A{"$departments.department_name", 0}}},
+			{Key: "languages", Value: "$languages.language"},
+		}}},
+	}
+	cur, err := coll(ctx, "Employee").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	current := map[int]currentEmployee{}
+	for cur.Next(ctx) {
+		var doc currentEmployee
+		if err := cur.Decode(&doc); err != nil {
+			return nil, err
+		}
+		current[doc.EmpID] = doc
+	}
+	return current, cur.Err()
+}
+
+// sameLanguages compares two ordered language lists, ignoring blanks
+func sameLanguages(a, b []string) bool {
+	a, b = without(a, ""), without(b, "")
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// runSync fetches the roster, applies creates/updates/deactivations and stores the report
+func runSync(ctx context.Context, trigger string) (SyncReport, error) {
+	syncMu.Lock()
+	defer syncMu.Unlock()
+
+	source := cfg.Sync.Source
+	report := SyncReport{
+		Source:      source,
+		Trigger:     trigger,
+		Status:      "ok",
+		StartedAt:   time.Now().UTC(),
+		Created:     []int{},
+		Updated:     []int{},
+		Deactivated: []int{},
+		RowErrors:   []SyncRowError{},
+	}
+	err := applyRoster(ctx, source, &report)
+	invalidateEmployeeLists()
+	if err != nil {
+		report.Status = "failed"
+		report.Error = err.Error()
+	}
+	report.FinishedAt = time.Now().UTC()
+
+	res, insErr := coll(ctx, "SyncReports").InsertOne(ctx, report)
+	if insErr != nil {
+		return report, insErr
+	}
+	report.ID, _ = res.InsertedID.(primitive.ObjectID)
+	return report, err
+}
+
+// applyRoster does the actual diff and writes for runSync
+func applyRoster(ctx context.Context, source string, report *SyncReport) error {
+	if source == "" {
+		return errors.New("sync.source is not configured")
+	}
+	body, err := fetchRoster(ctx, source)
+	if err != nil {
+		return fmt.Errorf("fetch roster: %w", err)
+	}
+	defer body.Close()
+	rows, rowErrs, err := parseRoster(body)
+	if err != nil {
+		return fmt.Errorf("parse roster: %w", err)
+	}
+	report.RosterSize = len(rows)
+	report.RowErrors = append(report.RowErrors, rowErrs...)
+
+	current, err := loadCurrentEmployees(ctx)
+	if err != nil {
+		return fmt.Errorf("load employees: %w", err)
+	}
+
+	inRoster := map[int]bool{}
+	for _, row := range rows {
+		inRoster[row.empId] = true
+		cur, exists := current[row.empId]
+		if !exists {
+			err := createSyncedEmployee(ctx, row)
+			if err != nil {
+				report.RowErrors = append(report.RowErrors, SyncRowError{Row: row.line, EmpID: strconv.Itoa(row.empId), Message: err.Error()})
+				continue
+			}
+			report.Created = append(report.Created, row.empId)
+			continue
+		}
+		if cur.EmpName == row.empName && cur.Department == row.department &&
+			sameLanguages(cur.Languages, row.languages) && cur.Status != "inactive" {
+			report.Unchanged++
+			continue
+		}
+		if err := updateSyncedEmployee(ctx, row, cur); err != nil {
+			report.RowErrors = append(report.RowErrors, SyncRowError{Row: row.line, EmpID: strconv.Itoa(row.empId), Message: err.Error()})
+			continue
+		}
+		report.Updated = append(report.Updated, row.empId)
+	}
+
+	// synced employees that disappeared from the roster are deactivated
+	for id, cur := range current {
+		if !cur.Synced || inRoster[id] || cur.Status == "inactive" || cur.Status == "terminated" {
+			continue
+		}
+		err := auditChange(ctx, "deactivate", id, "sync", nil, func() error {
+			_, err := coll(ctx, "Employee").UpdateOne(ctx, bson.M{"emp_id": id}, repository.BumpVersion(bson.M{"$set": bson.M{"status": "inactive"}}))
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("deactivate %d: %w", id, err)
+		}
+		report.Deactivated = append(report.Deactivated, id)
+	}
+	return nil
+}
+
+func createSyncedEmployee(ctx context.Context, row rosterRow) error {
+	if err := employees.ReserveID(ctx, row.empId); err != nil {
+		return err
+	}
+	details := bson.M{"emp_name": row.empName, "department": row.department, "languages": row.languages}
+	return auditChange(ctx, "create", row.empId, "sync", details, func() error {
+		if _, err := coll(ctx, "Employee").InsertOne(ctx, bson.M{"emp_id": row.empId, "emp_name": row.empName, "synced": true, "created_at": time.Now().UTC()}); err != nil {
+			return err
+		}
+		if err := mongoStores.SetDepartment(ctx, row.empId, row.department); err != nil {
+			return err
+		}
+		return mongoStores.ReplaceLanguages(ctx, row.empId, row.languages)
+	})
+}
+
+func updateSyncedEmployee(ctx context.Context, row rosterRow, cur currentEmployee) error {
+	before := employeeSnapshot(ctx, row.empId)
+	set := bson.M{"emp_name": row.empName, "synced": true}
+	if cur.Status == "inactive" {
+		set["status"] = "active"
+	}
+	if _, err := coll(ctx, "Employee").UpdateOne(ctx, bson.M{"emp_id": row.empId}, repository.BumpVersion(bson.M{"$set": set})); err != nil {
+		return err
+	}
+	if cur.Department != row.department {
+		if err := mongoStores.SetDepartment(ctx, row.empId, row.department); err != nil {
+			return err
+		}
+	}
+	if !sameLanguages(cur.Languages, row.languages) {
+		if err := mongoStores.ReplaceLanguages(ctx, row.empId, row.languages); err != nil {
+			return err
+		}
+	}
+	return recordAuditDiff(ctx, "update", row.empId, "sync", bson.M{
+		"emp_name":   row.empName,
+		"department": row.department,
+		"languages":  row.languages,
+	}, before, employeeSnapshot(ctx, row.empId))
+}
+
+// waitForSync blocks until a sync that is in progress has finished
+func waitForSync() {
+	syncMu.Lock()
+	syncMu.Unlock()
+}
+
+// startSyncScheduler runs the sync every sync.interval in the background until ctx is done
+func startSyncScheduler(ctx context.Context) {
+	every := time.Duration(cfg.Sync.Interval)
+	if every <= 0 || cfg.Sync.Source == "" {
+		return
+	}
+	slog.Info("sync: scheduler started", "every", every.String())
+	go func() {
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			// a started run is not cut short by shutdown; see waitForSync
+			runCtx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+			report, err := runSync(runCtx, "schedule")
+			cancel()
+			if err != nil {
+				slog.Error("sync: run failed", "err", err)
+				continue
+			}
+			slog.Info("sync: run finished", "created", len(report.Created), "updated", len(report.Updated),
+				"deactivated", len(report.Deactivated), "row_errors", len(report.RowErrors))
+		}
+	}()
+}
+
+// ---------------- Handlers ----------------
+
+// syncRunHandler handles POST /api/sync/run (admin of the default organization, which
+// the roster syncs into), running a sync now
+func syncRunHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requirePlatformAdmin(w, r) {
+		return
+	}
+	ctx := r.Context()
+
+	report, err := runSync(ctx, "manual")
+	if err != nil && report.ID.IsZero() {
+		storeError(w, "sync", err)
+		return
+	}
+	_ = notify(ctx, actorFromRequest(r), "sync_finished",
+		fmt.Sprintf("HR sync %s: %d created, %d updated, %d deactivated", report.Status,
+			len(report.Created), len(report.Updated), len(report.Deactivated)),
+		"/api/sync/reports/"+report.ID.Hex())
+
+	if report.Status == "failed" {
+		writeError(w, http.StatusBadGateway, "HR sync failed", report)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+// syncReportsHandler handles GET /api/sync/reports (latest 50) and GET /api/sync/reports/{id}
+// (admin of the default organization)
+func syncReportsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requirePlatformAdmin(w, r) {
+		return
+	}
+	ctx := r.Context()
+
+	w.Header().Set("Content-Type", "application/json")
+	if idStr := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/sync/reports"), "/"); idStr != "" {
+		id, err := primitive.ObjectIDFromHex(idStr)
+		if err != nil {
+			httpError(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		var report SyncReport
+		if err := coll(ctx, "SyncReports").FindOne(ctx, bson.M{"_id": id}).Decode(&report); err != nil {
+			if err == mongo.ErrNoDocuments {
+				httpError(w, "sync report not found", http.StatusNotFound)
+				return
+			}
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(report)
+		return
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "started_at", Value: -1}}).SetLimit(50)
+	cur, err := coll(ctx, "SyncReports").Find(ctx, bson.M{}, opts)
+	if err != nil {
+		storeError(w, "find sync reports", err)
+		return
+	}
+	defer cur.Close(ctx)
+	reports := []SyncReport{}
+	if err := cur.All(ctx, &reports); err != nil {
+		storeError(w, "cursor all", err)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(reports)
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/karthikeyan-meenachisundaram/goBack/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// idempotencyTTL is how long a key's response is kept for replay
+	idempotencyTTL = 24 * time.Hour
+	// maxIdempotencyKey caps the Idempotency-Key header length
+	maxIdempotencyKey = 255
+)
+
+// IdempotencyRecord is a row of the IdempotencyKeys collection: the first request made
+// with a key and, once it finished, the response to replay for retries
+type IdempotencyRecord struct {
+	Key         string    `bson:"key"`
+	Actor       string    `bson:"actor"`
+	RequestHash string    `bson:"request_hash"`
+	Done        bool      `bson:"done"`
+	Status      int       `bson:"status,omitempty"`
+	ContentType string    `bson:"content_type,omitempty"`
+	Body        []byte    `bson:"body,omitempty"`
+	CreatedAt   time.Time `bson:"created_at"`
+	ExpiresAt   time.Time `bson:"expires_at"`
+}
+
+// idempotencyStore keeps the IdempotencyRecords of the request's organization
+type idempotencyStore interface {
+	// Begin stores rec for a request starting with its key; errIdempotencyKeyUsed when
+	// the caller already used the key
+	Begin(ctx context.Context, rec IdempotencyRecord) error
+	// Get returns actor's record of key; models.ErrNotFound when there is none
+	Get(ctx context.Context, actor, key string) (IdempotencyRecord, error)
+	// Finish stores the response to replay for actor's key
+	Finish(ctx context.Context, actor, key string, status int, contentType string, body []byte) error
+	// Release forgets actor's key, so it can be used again
+	Release(ctx context.Context, actor, key string) error
+}
+
+// idempotencyKeys are the IdempotencyKeys collection
+var idempotencyKeys idempotencyStore = mongoIdempotencyKeys{}
+
+// errIdempotencyKeyUsed is a Begin with a key the caller already used
+var errIdempotencyKeyUsed = errors.New("idempotency key already used")
+
+// mongoIdempotencyKeys keeps the records in the IdempotencyKeys collection, unique by
+// actor and key
+type mongoIdempotencyKeys struct{}
+
+func (mongoIdempotencyKeys) Begin(ctx context.Context, rec IdempotencyRecord) error {
+	_, err := coll(ctx, "IdempotencyKeys").InsertOne(ctx, rec)
+	if mongo.IsDuplicateKeyError(err) {
+		return errIdempotencyKeyUsed
+	}
+	return err
+}
+
+func (mongoIdempotencyKeys) Get(ctx context.Context, actor, key string) (IdempotencyRecord, error) {
+	var rec IdempotencyRecord
+	err := coll(ctx, "IdempotencyKeys").FindOne(ctx, bson.M{"actor": actor, "key": key}).Decode(&rec)
+	if err == mongo.ErrNoDocuments {
+		err = models.ErrNotFound
+	}
+	return rec, err
+}
+
+func (mongoIdempotencyKeys) Finish(ctx context.Context, actor, key string, status int, contentType string, body []byte) error {
+	_, err := coll(ctx, "IdempotencyKeys").UpdateOne(ctx, bson.M{"actor": actor, "key": key}, bson.M{"$set": bson.M{
+		"done":         true,
+		"status":       status,
+		"content_type": contentType,
+		"body":         body,
+	}})
+	return err
+}
+
+func (mongoIdempotencyKeys) Release(ctx context.Context, actor, key string) error {
+	_, err := coll(ctx, "IdempotencyKeys").DeleteOne(ctx, bson.M{"actor": actor, "key": key})
+	return err
+}
+
+// responseCapture passes a response through while keeping a copy for replay
+type responseCapture struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (c *responseCapture) WriteHeader(code int) {
+	if c.status == 0 {
+		c.status = code
+	}
+	c.ResponseWriter.WriteHeader(code)
+}
+
+func (c *responseCapture) Write(b []byte) (int, error) {
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+	c.body.Write(b)
+	return c.ResponseWriter.Write(b)
+}
+
+func (c *responseCapture) Unwrap() http.ResponseWriter { return c.ResponseWriter }
+
+// idempotent makes POSTs to next safe to retry: a request carrying an Idempotency-Key the
+// same caller already used gets the stored response (marked Idempotent-Replayed) instead
+// of running again. Keys are per caller and kept for idempotencyTTL; a failed attempt
+// (5xx) frees its key for the next retry. Dry runs don't use up the key, so the request
+// that follows one can carry it.
+func idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if r.Method != http.MethodPost || key == "" || isDryRun(r) {
+			next(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKey {
+			httpError(w, "Idempotency-Key is too long", http.StatusBadRequest)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			httpError(w, "read body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		sum := sha256.Sum256(append([]byte(r.URL.Path+"\n"), body...))
+		hash := hex.EncodeToString(sum[:])
+
+		ctx := r.Context()
+
+		actor := actorFromRequest(r)
+		now := time.Now().UTC()
+		err = idempotencyKeys.Begin(ctx, IdempotencyRecord{
+			Key:         key,
+			Actor:       actor,
+			RequestHash: hash,
+			CreatedAt:   now,
+			ExpiresAt:   now.Add(idempotencyTTL),
+		})
+		if errors.Is(err, errIdempotencyKeyUsed) {
+			replayIdempotent(ctx, w, actor, key, hash)
+			return
+		}
+		if err != nil {
+			storeError(w, "idempotency key", err)
+			return
+		}
+
+		capture := &responseCapture{ResponseWriter: w}
+		next(capture, r)
+
+		// the handler's own context may be gone; the record must still be settled
+		done, cancelDone := context.WithTimeout(context.WithoutCancel(r.Context()), 10*time.Second)
+		defer cancelDone()
+		if capture.status >= 500 || capture.status == 0 {
+			err = idempotencyKeys.Release(done, actor, key)
+		} else {
+			err = idempotencyKeys.Finish(done, actor, key, capture.status, w.Header().Get("Content-Type"), capture.body.Bytes())
+		}
+		if err != nil {
+			logFor(r.Context()).Error("idempotency: settle key", "key", key, "err", err)
+		}
+	}
+}
+
+// replayIdempotent answers a request whose key was already used
+func replayIdempotent(ctx context.Context, w http.ResponseWriter, actor, key, hash string) {
+	rec, err := idempotencyKeys.Get(ctx, actor, key)
+	if err != nil {
+		storeError(w, "idempotency key", err)
+		return
+	}
+	switch {
+	case rec.RequestHash != hash:
+		httpError(w, "Idempotency-Key was already used for a different request", http.StatusUnprocessableEntity)
+	case !rec.Done:
+		httpError(w, "a request with this Idempotency-Key is still in progress", http.StatusConflict)
+	default:
+		w.Header().Set("Idempotent-Replayed", "true")
+		if rec.ContentType != "" {
+			w.Header().Set("Content-Type", rec.ContentType)
+		}
+		w.WriteHeader(rec.Status)
+		_, _ = w.Write(rec.Body)
+	}
+}
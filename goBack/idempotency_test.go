@@ -0,0 +1,93 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"testing"
+)
+
+func TestIdempotentReplay(t *testing.T) {
+	s := useTestStores(t)
+	h := idempotent(employeesHandler)
+	post := func(target, body, user, key string) *http.Request {
+		r := request(http.MethodPost, target, body, user, "admin")
+		if key != "" {
+			r.Header.Set("Idempotency-Key", key)
+		}
+		return r
+	}
+	asha := `{"emp_name":"Asha Rao","department":"Engg"}`
+
+	first := record(h, post("/api/employees", asha, "admin", "k1"))
+	expectStatus(t, first, http.StatusCreated)
+	if first.Header().Get("Idempotent-Replayed") != "" {
+		t.Error("first request marked as replayed")
+	}
+
+	// a retry gets the stored response and creates nothing
+	again := record(h, post("/api/employees", asha, "admin", "k1"))
+	expectStatus(t, again, http.StatusCreated)
+	if again.Header().Get("Idempotent-Replayed") != "true" || again.Body.String() != first.Body.String() ||
+		again.Header().Get("Content-Type") != first.Header().Get("Content-Type") {
+		t.Errorf("replay = %v %s, want %s", again.Header(), again.Body.String(), first.Body.String())
+	}
+	if n, _ := s.employees.LastID(t.Context()); n != 1 {
+		t.Errorf("last id = %d after a replay, want 1", n)
+	}
+
+	// the key is spent on that request: another body or route is refused
+	w := record(h, post("/api/employees", `{"emp_name":"Ravi","department":"Ops"}`, "admin", "k1"))
+	expectStatus(t, w, http.StatusUnprocessableEntity)
+	w = record(h, post("/api/employees/create", asha, "admin", "k1"))
+	expectStatus(t, w, http.StatusUnprocessableEntity)
+
+	// keys are per caller; dry runs and requests without a key don't use one
+	w = record(h, post("/api/employees", asha, "meena", "k1"))
+	expectStatus(t, w, http.StatusCreated)
+	if w.Header().Get("Idempotent-Replayed") != "" {
+		t.Error("another caller's key was replayed")
+	}
+	w = record(h, post("/api/employees?dry_run=true", asha, "admin", "k2"))
+	expectStatus(t, w, http.StatusOK)
+	w = record(h, post("/api/employees", asha, "admin", "k2"))
+	expectStatus(t, w, http.StatusCreated)
+	if w.Header().Get("Idempotent-Replayed") != "" {
+		t.Error("a dry run used up its key")
+	}
+	if n, _ := s.employees.LastID(t.Context()); n != 3 {
+		t.Errorf("last id = %d, want 3", n)
+	}
+
+	// a request still running holds its key
+	sum := sha256.Sum256([]byte("/api/employees\n" + asha))
+	if err := s.idempotency.Begin(t.Context(), IdempotencyRecord{Actor: "admin", Key: "k3", RequestHash: hex.EncodeToString(sum[:])}); err != nil {
+		t.Fatal(err)
+	}
+	w = record(h, post("/api/employees", asha, "admin", "k3"))
+	expectStatus(t, w, http.StatusConflict)
+}
+
+func TestIdempotentFailureFreesKey(t *testing.T) {
+	s := useTestStores(t)
+	calls := 0
+	h := idempotent(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			httpError(w, "database down", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	})
+	for range 3 {
+		r := request(http.MethodPost, "/api/employees", `{}`, "admin", "admin")
+		r.Header.Set("Idempotency-Key", "k1")
+		record(h, r)
+	}
+	if calls != 2 {
+		t.Errorf("handler ran %d times, want 2: after the 503 and not for the replay", calls)
+	}
+	if rec, err := s.idempotency.Get(t.Context(), "admin", "k1"); err != nil || !rec.Done || rec.Status != http.StatusCreated {
+		t.Errorf("record = %+v, %v", rec, err)
+	}
+}
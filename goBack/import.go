@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/karthikeyan-meenachisundaram/goBack/models"
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	importBatchSize = 500
+	maxImportBytes  = 20 << 20
+	xlsxContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+)
+
+// ImportRowResult is the outcome of one data row of an import file
+type ImportRowResult struct {
+	Row    int               `json:"row"` // 1-based line/row number in the file, header is row 1
+	Status string            `json:"status"`
+	EmpID  int               `json:"emp_id,omitempty"`
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// ImportReport summarizes an import
+type ImportReport struct {
+	DryRun  bool              `json:"dry_run"`
+	Total   int               `json:"total"`
+	Created int               `json:"created"`
+	Failed  int               `json:"failed"`
+	Rows    []ImportRowResult `json:"rows"`
+}
+
+// importRow is a validated row waiting for its batch to be written
+type importRow struct {
+	result  *ImportRowResult
+	payload models.EmployeePayload
+}
+
+// rowReader yields the records of an import file one at a time, io.EOF at the end
+type rowReader func() ([]string, error)
+
+// csvRows streams records from a CSV file
+func csvRows(r io.Reader) rowReader {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	return cr.Read
+}
+
+// xlsxRows streams records from the first sheet of an XLSX workbook
+func xlsxRows(r io.Reader) (rowReader, error) {
+	f, err := excelize.OpenReader(r)
+	if err != nil {
+		return nil, err
+	}
+	sheets := f.GetSheetList()
+	if len(sheets) == 0 {
+		return nil, fmt.Errorf("workbook has no sheets")
+	}
+	rows, err := f.Rows(sheets[0])
+	if err != nil {
+		return nil, err
+	}
+	return func() ([]string, error) {
+		if !rows.Next() {
+			if err := rows.Error(); err != nil {
+				return nil, err
+			}
+			return nil, io.EOF
+		}
+		return rows.Columns()
+	}, nil
+}
+
+// importFileRows picks the reader for an uploaded file by ?format=, extension or content type
+func importFileRows(r *http.Request, part io.Reader, filename, contentType string) (rowReader, error) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+		if strings.EqualFold(filepath.Ext(filename), ".xlsx") || contentType == xlsxContentType {
+			format = "xlsx"
+		}
+	}
+	switch format {
+	case "csv":
+		return csvRows(part), nil
+	case "xlsx":
+		return xlsxRows(part)
+	}
+	return nil, fmt.Errorf("format must be csv or xlsx")
+}
+
+// insertImportBatch allocates ids for a batch of validated rows and writes them with InsertMany
+func insertImportBatch(ctx context.Context, batch []importRow, actor string) error {
+	first, err := employees.NextIDs(ctx, len(batch))
+	if err != nil {
+		return fmt.Errorf("allocate ids: %w", err)
+	}
+	list := make([]models.NewEmployee, 0, len(batch))
+	for i, row := range batch {
+		list = append(list, models.NewEmployee{
+			EmpID:      first + i,
+			EmpName:    *row.payload.EmpName,
+			Department: *row.payload.Department,
+			Languages:  row.payload.Languages,
+		})
+	}
+	if err := employees.CreateMany(ctx, list, actor, "import"); err != nil {
+		return err
+	}
+	for i, row := range batch {
+		row.result.EmpID = first + i
+		row.result.Status = "created"
+	}
+	return nil
+}
+
+// importEmployeesHandler handles POST /api/employees/import: a multipart upload (field "file")
+// of a CSV or XLSX file with the columns emp_name, department and language (several
+// languages separated by ";"). Rows are validated like single creates and inserted in
+// batches; the response reports every row. A dry run only validates.
+func importEmployeesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+	mr, err := r.MultipartReader()
+	if err != nil {
+		httpError(w, "expected a multipart upload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	var next rowReader
+	for next == nil {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			httpError(w, "file is required", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			httpError(w, "read upload: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if part.FormName() != "file" {
+			continue
+		}
+		if next, err = importFileRows(r, part, part.FileName(), part.Header.Get("Content-Type")); err != nil {
+			httpError(w, "read file: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	header, err := next()
+	if err != nil {
+		httpError(w, "read header: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	col := map[string]int{}
+	for i, h := range header {
+		col[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	if _, ok := col["language"]; !ok {
+		if i, ok := col["languages"]; ok {
+			col["language"] = i
+		}
+	}
+	for _, c := range []string{"emp_name", "department", "language"} {
+		if _, ok := col[c]; !ok {
+			httpError(w, fmt.Sprintf("missing column %q", c), http.StatusUnprocessableEntity)
+			return
+		}
+	}
+
+	ctx := r.Context()
+	defs, err := loadCustomFields(ctx)
+	if err != nil {
+		storeError(w, "find custom fields", err)
+		return
+	}
+	ref, err := loadReferenceData(ctx)
+	if err != nil {
+		storeError(w, "reference data", err)
+		return
+	}
+	// imports carry no custom field values, so only required definitions can fail here
+	_, _, cfErrs := validateCustomFields(defs, nil, true)
+
+	report := ImportReport{DryRun: dryRun(w, r), Rows: []ImportRowResult{}}
+	var results []*ImportRowResult
+	var batch []importRow
+	actor := actorFromRequest(r)
+	// a failed batch is reported on its rows; batches already written stay
+	flush := func() {
+		if len(batch) > 0 && !report.DryRun {
+			if err := insertImportBatch(ctx, batch, actor); err != nil {
+				for _, row := range batch {
+					row.result.EmpID = 0
+					row.result.Errors = map[string]string{"row": err.Error()}
+				}
+			}
+		}
+		batch = batch[:0]
+	}
+
+	for line := 2; ; line++ {
+		rec, err := next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		res := &ImportRowResult{Row: line, Status: "error"}
+		results = append(results, res)
+		if err != nil {
+			res.Errors = map[string]string{"row": err.Error()}
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				break
+			}
+			continue
+		}
+		get := func(c string) string {
+			if i := col[c]; i < len(rec) {
+				return strings.TrimSpace(rec[i])
+			}
+			return ""
+		}
+		if strings.Join(rec, "") == "" {
+			results = results[:len(results)-1] // blank line
+			continue
+		}
+		name, department := get("emp_name"), get("department")
+		p := models.EmployeePayload{EmpName: &name, Department: &department}
+		for _, l := range strings.Split(get("language"), ";") {
+			if l = strings.TrimSpace(l); l != "" {
+				p.Languages = append(p.Languages, l)
+			}
+		}
+		if errs := mergeFieldErrors(validatePayload(&p, ref, true), "custom_fields.", cfErrs); len(errs) > 0 {
+			res.Errors = errs
+			continue
+		}
+		if report.DryRun {
+			res.Status = "valid"
+		}
+		batch = append(batch, importRow{result: res, payload: p})
+		if len(batch) == importBatchSize {
+			flush()
+		}
+	}
+	flush()
+
+	for _, res := range results {
+		report.Total++
+		switch res.Status {
+		case "created":
+			report.Created++
+		case "error":
+			report.Failed++
+		}
+		report.Rows = append(report.Rows, *res)
+	}
+	if !report.DryRun {
+		_ = notify(ctx, actor, "import_finished",
+			fmt.Sprintf("Import finished: %d of %d row(s) created, %d failed", report.Created, report.Total, report.Failed),
+			"/api/employees")
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if report.Created > 0 {
+		w.WriteHeader(http.StatusCreated)
+	}
+	_ = json.NewEncoder(w).Encode(report)
+}
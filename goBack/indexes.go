@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/karthikeyan-meenachisundaram/goBack/repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// collectionIndexes are the indexes ensureIndexes keeps on one collection
+type collectionIndexes struct {
+	collection string
+	models     []mongo.IndexModel
+}
+
+// indexes lists every index the app relies on, so they are created in one place
+func indexes() []collectionIndexes {
+	unique := options.Index().SetUnique(true)
+	// named apart from the case-sensitive name_1 of older deployments, which stays
+	uniqueName := options.Index().SetUnique(true).SetCollation(repository.CaseInsensitive).SetName("name_ci")
+	return []collectionIndexes{
+		{"Employee", []mongo.IndexModel{
+			{Keys: bson.D{{Key: "emp_id", Value: 1}}, Options: unique},
+			// stemmed name matches for /api/employees/search; departments and languages live
+			// in other collections, see the Mongo search store in repository
+			{Keys: bson.D{{Key: "emp_name", Value: "text"}}, Options: options.Index().SetName("emp_name_text")},
+			// the distinct tags and their employees, for search and the tags filter
+			{Keys: bson.D{{Key: "tags", Value: 1}}},
+			// reports and the org chart follow manager_id down
+			{Keys: bson.D{{Key: "manager_id", Value: 1}}},
+		}},
+		{"Department", []mongo.IndexModel{
+			{Keys: bson.D{{Key: "emp_id", Value: 1}}},
+			{Keys: bson.D{{Key: "dept_id", Value: 1}}},
+		}},
+		{"Developers", []mongo.IndexModel{
+			{Keys: bson.D{{Key: "emp_id", Value: 1}}},
+			// the distinct languages and their employees, for search
+			{Keys: bson.D{{Key: "language", Value: 1}}},
+		}},
+		{"Departments", []mongo.IndexModel{
+			{Keys: bson.D{{Key: "dept_id", Value: 1}}, Options: unique},
+			// "Engg" and "engg" are the same department; lookups pass the same collation
+			{Keys: bson.D{{Key: "name", Value: 1}}, Options: uniqueName},
+		}},
+		{"Languages", []mongo.IndexModel{
+			{Keys: bson.D{{Key: "lang_id", Value: 1}}, Options: unique},
+			{Keys: bson.D{{Key: "name", Value: 1}}, Options: uniqueName},
+		}},
+		{"Projects", []mongo.IndexModel{
+			{Keys: bson.D{{Key: "project_id", Value: 1}}, Options: unique},
+			{Keys: bson.D{{Key: "name", Value: 1}}, Options: unique},
+		}},
+		{"ProjectMembers", []mongo.IndexModel{
+			{Keys: bson.D{{Key: "project_id", Value: 1}, {Key: "emp_id", Value: 1}}},
+			{Keys: bson.D{{Key: "emp_id", Value: 1}}},
+		}},
+		{"Leaves", []mongo.IndexModel{
+			{Keys: bson.D{{Key: "emp_id", Value: 1}, {Key: "start_date", Value: 1}}},
+			{Keys: bson.D{{Key: "status", Value: 1}, {Key: "start_date", Value: 1}}},
+		}},
+		{"ApiKeys", []mongo.IndexModel{
+			{Keys: bson.D{{Key: "key_hash", Value: 1}}, Options: unique},
+			// a name is free again once its key is revoked
+			{Keys: bson.D{{Key: "name", Value: 1}}, Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"active": true})},
+		}},
+		{"Notes", []mongo.IndexModel{
+			{Keys: bson.D{{Key: "emp_id", Value: 1}, {Key: "created_at", Value: 1}}},
+		}},
+		{"Transfers", []mongo.IndexModel{
+			{Keys: bson.D{{Key: "emp_id", Value: 1}}},
+		}},
+		{"AuditLog", []mongo.IndexModel{
+			{Keys: bson.D{{Key: "emp_id", Value: 1}, {Key: "timestamp", Value: -1}}},
+			{Keys: bson.D{{Key: "timestamp", Value: -1}}},
+		}},
+		{"EmployeeHistory", []mongo.IndexModel{
+			{Keys: bson.D{{Key: "emp_id", Value: 1}, {Key: "valid_from", Value: -1}}},
+		}},
+		{"Users", []mongo.IndexModel{
+			{Keys: bson.D{{Key: "username", Value: 1}}, Options: unique},
+		}},
+		{"Jobs", []mongo.IndexModel{
+			{Keys: bson.D{{Key: "status", Value: 1}, {Key: "run_at", Value: 1}}},
+			{Keys: bson.D{{Key: "created_at", Value: -1}}},
+			{Keys: bson.D{{Key: "key", Value: 1}}, Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"key": bson.M{"$exists": true}})},
+			// finished jobs are kept a week for inspection; failed ones until retried or removed
+			{Keys: bson.D{{Key: "finished_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(7 * 24 * 3600).
+				SetPartialFilterExpression(bson.M{"status": "done"})},
+		}},
+		{"IdempotencyKeys", []mongo.IndexModel{
+			{Keys: bson.D{{Key: "actor", Value: 1}, {Key: "key", Value: 1}}, Options: unique},
+			// expire records once expires_at has passed
+			{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		}},
+	}
+}
+
+// ensureIndexes creates any missing index from indexes(); existing ones are left alone.
+// Failures are logged per collection and don't stop startup. With mongo.skip_indexes
+// (e.g. a read-only user) nothing is created.
+func ensureIndexes(ctx context.Context) {
+	if cfg.Mongo.SkipIndexes {
+		slog.Info("skipping index creation (mongo.skip_indexes)")
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+	for _, ci := range indexes() {
+		names, err := coll(ctx, ci.collection).Indexes().CreateMany(ctx, ci.models)
+		if err != nil {
+			slog.Error("ensureIndexes: create indexes", "collection", ci.collection, "err", err)
+			if ci.collection == "Employee" && mongo.IsDuplicateKeyError(err) {
+				logDuplicateEmpIDs(ctx)
+			}
+			continue
+		}
+		slog.Debug("indexes ready", "collection", ci.collection, "indexes", names)
+	}
+	slog.Info("ensured indexes", "collections", len(indexes()))
+}
+
+// logDuplicateEmpIDs reports the emp_ids that block the unique Employee index
+func logDuplicateEmpIDs(ctx context.Context) {
+	cur, err := coll(ctx, "Employee").Aggregate(ctx, mongo.Pipeline{
+		bson.D{{Key: "$group", Value: bson.M{"_id": "$emp_id", "count": bson.M{"$sum": 1}}}},
+		bson.D{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+		bson.D{{Key: "$limit", Value: 20}},
+	})
+	if err != nil {
+		return
+	}
+	defer cur.Close(ctx)
+	var dups []struct {
+		EmpID int `bson:"_id"`
+		Count int `bson:"count"`
+	}
+	if err := cur.All(ctx, &dups); err != nil || len(dups) == 0 {
+		return
+	}
+	ids := make([]int, len(dups))
+	for i, d := range dups {
+		ids[i] = d.EmpID
+	}
+	slog.Error("Employee has duplicate emp_ids; merge or purge them so the unique index can be built", "emp_ids", ids)
+}
@@ -0,0 +1,439 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// jobActor is who background jobs show up as in the audit log
+	jobActor = "jobs"
+	// jobLeaseMargin is added to a kind's timeout for how long a claimed job stays
+	// claimed; a job still running after that is taken over (its worker is gone)
+	jobLeaseMargin = time.Minute
+	// jobMaxBackoff caps the delay between two attempts
+	jobMaxBackoff = time.Hour
+)
+
+// Job is one unit of background work in the Jobs collection of an organization. Workers
+// of every instance claim due jobs atomically, so each runs once at a time; failed
+// attempts are retried with exponential backoff until the kind's attempts are used up.
+type Job struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Kind        string             `bson:"kind" json:"kind"`
+	Key         string             `bson:"key,omitempty" json:"key,omitempty"` // at most one job per key, e.g. one purge per schedule slot
+	Payload     bson.M             `bson:"payload,omitempty" json:"payload,omitempty"`
+	Status      string             `bson:"status" json:"status"` // pending, running, done, failed
+	Attempts    int                `bson:"attempts" json:"attempts"`
+	MaxAttempts int                `bson:"max_attempts" json:"max_attempts"`
+	RunAt       time.Time          `bson:"run_at" json:"run_at"`
+	LockedUntil *time.Time         `bson:"locked_until,omitempty" json:"locked_until,omitempty"`
+	LastError   string             `bson:"last_error,omitempty" json:"last_error,omitempty"`
+	Result      string             `bson:"result,omitempty" json:"result,omitempty"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+	FinishedAt  *time.Time         `bson:"finished_at,omitempty" json:"finished_at,omitempty"`
+	Org         string             `bson:"-" json:"-"` // whose Jobs collection it came from
+}
+
+// jobKind is how one kind of job runs. run returns a short result for the job record;
+// an error wrapped with permanent is not retried.
+type jobKind struct {
+	run         func(ctx context.Context, j Job) (string, error)
+	timeout     func() time.Duration
+	maxAttempts func() int
+	retryBase   func() time.Duration
+}
+
+// jobKinds are the kinds the workers know
+var jobKinds = map[string]jobKind{
+	"webhook": {
+		run:         runWebhookJob,
+		timeout:     func() time.Duration { return time.Duration(cfg.Webhooks.Timeout) + 10*time.Second },
+		maxAttempts: func() int { return cfg.Webhooks.MaxAttempts },
+		retryBase:   func() time.Duration { return time.Duration(cfg.Webhooks.RetryBase) },
+	},
+	"email": {
+		run:         runEmailJob,
+		timeout:     func() time.Duration { return time.Duration(cfg.Email.Timeout) + 10*time.Second },
+		maxAttempts: func() int { return cfg.Email.MaxAttempts },
+		retryBase:   func() time.Duration { return time.Duration(cfg.Email.RetryBase) },
+	},
+	"trash_purge": {
+		run:         runTrashPurgeJob,
+		timeout:     func() time.Duration { return 10 * time.Minute },
+		maxAttempts: func() int { return 3 },
+		retryBase:   func() time.Duration { return time.Minute },
+	},
+	"stats_rebuild": {
+		run:         runStatsRebuildJob,
+		timeout:     func() time.Duration { return time.Minute },
+		maxAttempts: func() int { return 1 }, // the next slot rebuilds anyway
+		retryBase:   func() time.Duration { return time.Minute },
+	},
+}
+
+// jobSchedules are the kinds enqueued every interval; 0 turns one off
+var jobSchedules = []struct {
+	kind  string
+	every func() time.Duration
+}{
+	{"trash_purge", func() time.Duration {
+		if cfg.Jobs.PurgeAfterDays > 0 {
+			return time.Hour
+		}
+		return 0
+	}},
+	{"stats_rebuild", func() time.Duration { return time.Duration(cfg.Jobs.StatsInterval) }},
+}
+
+// permanentError is a job failure that retrying won't fix
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// permanent marks err as not worth retrying
+func permanent(err error) error {
+	return permanentError{err}
+}
+
+var (
+	jobsWG   sync.WaitGroup
+	jobsWake = make(chan struct{}, 1)
+	// jobsTurn rotates the organization the workers look at first, so one with a long
+	// queue doesn't hold up the others
+	jobsTurn atomic.Uint64
+)
+
+// enqueueJob stores a job to run at runAt. A job whose key is already taken is not
+// added again, so instances scheduling the same slot create it once.
+func enqueueJob(ctx context.Context, kind, key string, payload bson.M, runAt time.Time) error {
+	k, ok := jobKinds[kind]
+	if !ok {
+		return fmt.Errorf("unknown job kind %q", kind)
+	}
+	_, err := coll(ctx, "Jobs").InsertOne(ctx, Job{
+		Kind:        kind,
+		Key:         key,
+		Payload:     payload,
+		Status:      "pending",
+		MaxAttempts: k.maxAttempts(),
+		RunAt:       runAt.UTC(),
+		CreatedAt:   time.Now().UTC(),
+	})
+	if key != "" && mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+	if err == nil {
+		wakeJobs()
+	}
+	return err
+}
+
+// wakeJobs tells an idle worker of this instance to look for due jobs now
+func wakeJobs() {
+	select {
+	case jobsWake <- struct{}{}:
+	default:
+	}
+}
+
+// claimJob takes the next due job of any organization; see claimOrgJob
+func claimJob(ctx context.Context) (Job, error) {
+	orgs := orgIDs()
+	first := int(jobsTurn.Add(1) % uint64(len(orgs)))
+	for i := range orgs {
+		org := orgs[(first+i)%len(orgs)]
+		j, err := claimOrgJob(withOrg(ctx, org))
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			continue
+		}
+		j.Org = org
+		return j, err
+	}
+	return Job{}, mongo.ErrNoDocuments
+}
+
+// claimOrgJob takes the next due job of the organization ctx acts for: a pending one whose
+// time has come, or a running one whose claim ran out
+func claimOrgJob(ctx context.Context) (Job, error) {
+	now := time.Now().UTC()
+	filter := bson.M{"$or": bson.A{
+		bson.M{"status": "pending", "run_at": bson.M{"$lte": now}},
+		bson.M{"status": "running", "locked_until": bson.M{"$lt": now}},
+	}}
+	var j Job
+	err := coll(ctx, "Jobs").FindOneAndUpdate(ctx, filter,
+		bson.M{"$set": bson.M{"status": "running", "locked_until": now.Add(time.Hour)}, "$inc": bson.M{"attempts": 1}},
+		options.FindOneAndUpdate().SetSort(bson.D{{Key: "run_at", Value: 1}}).SetReturnDocument(options.After),
+	).Decode(&j)
+	if err != nil {
+		return j, err
+	}
+	// the claim lasts as long as this kind may take
+	if k, ok := jobKinds[j.Kind]; ok {
+		until := now.Add(k.timeout() + jobLeaseMargin)
+		j.LockedUntil = &until
+		_, err = coll(ctx, "Jobs").UpdateOne(ctx, bson.M{"_id": j.ID, "attempts": j.Attempts}, bson.M{"$set": bson.M{"locked_until": until}})
+	}
+	return j, err
+}
+
+// runJob runs a claimed job and records the outcome: done, pending again after a backoff,
+// or failed once it is out of attempts, which the admins are notified of
+func runJob(j Job) {
+	log := slog.With("job", j.ID.Hex(), "kind", j.Kind, "attempt", j.Attempts, "org", j.Org)
+	k, ok := jobKinds[j.Kind]
+	var result string
+	var err error
+	if !ok {
+		err = permanent(fmt.Errorf("unknown job kind %q", j.Kind))
+	} else {
+		ctx, cancel := context.WithTimeout(withOrg(context.Background(), j.Org), k.timeout())
+		result, err = k.run(ctx, j)
+		cancel()
+	}
+
+	now := time.Now().UTC()
+	set := bson.M{"result": result}
+	switch {
+	case err == nil:
+		set["status"] = "done"
+		set["finished_at"] = now
+		log.Debug("jobs: done", "result", result)
+	case errors.As(err, new(permanentError)) || j.Attempts >= j.MaxAttempts:
+		set["status"] = "failed"
+		set["finished_at"] = now
+		set["last_error"] = err.Error()
+		log.Error("jobs: failed", "err", err)
+	default:
+		backoff := min(k.retryBase()<<min(j.Attempts-1, 16), jobMaxBackoff)
+		set["status"] = "pending"
+		set["run_at"] = now.Add(backoff)
+		set["last_error"] = err.Error()
+		log.Warn("jobs: attempt failed, will retry", "err", err, "in", backoff.String())
+	}
+	ctx, cancel := context.WithTimeout(withOrg(context.Background(), j.Org), 5*time.Second)
+	defer cancel()
+	// a job taken over after its claim ran out is recorded by whoever runs it now
+	_, err = coll(ctx, "Jobs").UpdateOne(ctx, bson.M{"_id": j.ID, "status": "running", "attempts": j.Attempts},
+		bson.M{"$set": set, "$unset": bson.M{"locked_until": ""}})
+	if err != nil {
+		log.Error("jobs: record outcome", "err", err)
+		return
+	}
+	if set["status"] == "failed" {
+		_ = notifyAdmins(ctx, "job_failed", fmt.Sprintf("Job %s (%s) failed: %s", j.ID.Hex(), j.Kind, set["last_error"]),
+			"/api/admin/jobs/"+j.ID.Hex())
+	}
+}
+
+// startJobs runs cfg.Jobs.Workers workers and the schedules until ctx ends; see
+// waitForJobs for the jobs running at that point
+func startJobs(ctx context.Context) {
+	for range cfg.Jobs.Workers {
+		jobsWG.Add(1)
+		go func() {
+			defer jobsWG.Done()
+			poll := time.NewTicker(time.Duration(cfg.Jobs.PollInterval))
+			defer poll.Stop()
+			for {
+				j, err := claimJob(ctx)
+				switch {
+				case err == nil:
+					runJob(j)
+					continue
+				case errors.Is(err, mongo.ErrNoDocuments), ctx.Err() != nil:
+				default:
+					slog.Error("jobs: claim", "err", err)
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case <-poll.C:
+				case <-jobsWake:
+				}
+			}
+		}()
+	}
+
+	for _, s := range jobSchedules {
+		every := s.every()
+		if every <= 0 {
+			continue
+		}
+		go func() {
+			ticker := time.NewTicker(every)
+			defer ticker.Stop()
+			for {
+				// one job per organization and slot, however many instances schedule it
+				slot := time.Now().UTC().Truncate(every)
+				for _, org := range orgIDs() {
+					ectx, cancel := context.WithTimeout(withOrg(ctx, org), 10*time.Second)
+					if err := enqueueJob(ectx, s.kind, s.kind+":"+slot.Format(time.RFC3339), nil, slot); err != nil && ctx.Err() == nil {
+						slog.Error("jobs: schedule", "kind", s.kind, "org", org, "err", err)
+					}
+					cancel()
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}()
+	}
+	slog.Info("jobs: workers started", "workers", cfg.Jobs.Workers)
+}
+
+// waitForJobs blocks until the workers have finished the jobs they were running
+func waitForJobs() {
+	jobsWG.Wait()
+}
+
+// runTrashPurgeJob permanently deletes employees soft-deleted more than
+// jobs.purge_after_days ago
+func runTrashPurgeJob(ctx context.Context, _ Job) (string, error) {
+	if cfg.Jobs.PurgeAfterDays <= 0 {
+		return "purging is off", nil
+	}
+	cutoff := time.Now().UTC().AddDate(0, 0, -cfg.Jobs.PurgeAfterDays)
+	purged, err := purgeDeleted(ctx, bson.M{"deleted_at": bson.M{"$exists": true, "$lte": cutoff}}, jobActor)
+	if err != nil {
+		return fmt.Sprintf("purged %d before failing", len(purged)), err
+	}
+	if len(purged) > 0 {
+		_ = notifyAdmins(ctx, "purge_finished", fmt.Sprintf("Scheduled trash purge finished: %d employee(s) permanently deleted", len(purged)), "/api/trash")
+	}
+	return fmt.Sprintf("purged %d", len(purged)), nil
+}
+
+// ---------------- Handlers ----------------
+
+// jobsHandler handles GET /api/admin/jobs?status=&kind=&page=&limit= (admin), newest first
+func jobsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	q := r.URL.Query()
+	filter := bson.M{}
+	if v := q.Get("status"); v != "" {
+		filter["status"] = bson.M{"$in": strings.Split(v, ",")}
+	}
+	if v := q.Get("kind"); v != "" {
+		filter["kind"] = bson.M{"$in": strings.Split(v, ",")}
+	}
+	page, limit, err := parsePage(q)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	total, err := coll(ctx, "Jobs").CountDocuments(ctx, filter)
+	if err != nil {
+		storeError(w, "count jobs", err)
+		return
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(int64((page - 1) * limit)).
+		SetLimit(int64(limit))
+	cur, err := coll(ctx, "Jobs").Find(ctx, filter, opts)
+	if err != nil {
+		storeError(w, "find jobs", err)
+		return
+	}
+	defer cur.Close(ctx)
+	items := []Job{}
+	if err := cur.All(ctx, &items); err != nil {
+		storeError(w, "cursor all", err)
+		return
+	}
+	for i := range items {
+		items[i].Payload = jobPayloadView(items[i].Payload)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(bson.M{"items": items, "page": page, "limit": limit, "total": total})
+}
+
+// jobByIDHandler handles GET /api/admin/jobs/{id} and POST /api/admin/jobs/{id}/retry
+// (admin). A retry puts a failed job back in the queue with fresh attempts.
+func jobByIDHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	idStr, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/admin/jobs/"), "/")
+	id, err := primitive.ObjectIDFromHex(idStr)
+	if err != nil {
+		httpError(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	ctx := r.Context()
+
+	var j Job
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		err = coll(ctx, "Jobs").FindOne(ctx, bson.M{"_id": id}).Decode(&j)
+	case action == "retry" && r.Method == http.MethodPost:
+		err = coll(ctx, "Jobs").FindOneAndUpdate(ctx, bson.M{"_id": id, "status": "failed"}, bson.M{
+			"$set":   bson.M{"status": "pending", "attempts": 0, "run_at": time.Now().UTC()},
+			"$unset": bson.M{"finished_at": ""},
+		}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&j)
+		if err == mongo.ErrNoDocuments {
+			if n, cerr := coll(ctx, "Jobs").CountDocuments(ctx, bson.M{"_id": id}); cerr == nil && n > 0 {
+				httpError(w, "only failed jobs can be retried", http.StatusConflict)
+				return
+			}
+		}
+		if err == nil {
+			logFor(r.Context()).Info("jobs: retry requested", "job", id.Hex(), "actor", actorFromRequest(r))
+			wakeJobs()
+		}
+	case action == "" || action == "retry":
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if err == mongo.ErrNoDocuments {
+		httpError(w, "job not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		storeError(w, "job", err)
+		return
+	}
+	j.Payload = jobPayloadView(j.Payload)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(j)
+}
+
+// jobPayloadView is a payload as plain JSON values
+func jobPayloadView(p bson.M) bson.M {
+	if p == nil {
+		return nil
+	}
+	m, _ := plainValue(p).(bson.M)
+	return m
+}
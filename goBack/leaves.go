@@ -0,0 +1,489 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/karthikeyan-meenachisundaram/goBack/repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	maxLeaveReasonLength = 500
+	maxLeaveSpanDays     = 366
+)
+
+// leaveTypes are the kinds of leave an employee can take
+var leaveTypes = []string{"vacation", "sick", "personal", "parental", "unpaid"}
+
+// Leave is a leave request in the Leaves collection. Dates are whole days, both ends
+// included; days counts the weekdays among them.
+type Leave struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	EmpID       int                `bson:"emp_id" json:"emp_id"`
+	Type        string             `bson:"type" json:"type"`
+	StartDate   time.Time          `bson:"start_date" json:"start_date"`
+	EndDate     time.Time          `bson:"end_date" json:"end_date"`
+	Days        int                `bson:"days" json:"days"`
+	Reason      string             `bson:"reason,omitempty" json:"reason,omitempty"`
+	Status      string             `bson:"status" json:"status"` // pending|approved|rejected|cancelled
+	RequestedBy string             `bson:"requested_by" json:"requested_by"`
+	RequestedAt time.Time          `bson:"requested_at" json:"requested_at"`
+	DecidedBy   string             `bson:"decided_by,omitempty" json:"decided_by,omitempty"`
+	DecidedAt   *time.Time         `bson:"decided_at,omitempty" json:"decided_at,omitempty"`
+	Comment     string             `bson:"comment,omitempty" json:"comment,omitempty"`
+}
+
+var (
+	errLeaveNotPending = errors.New("leave is not pending")
+	errLeaveOverlaps   = errors.New("overlaps another leave of the employee")
+)
+
+// weekdays counts the days from start to end (both included) that aren't weekends
+func weekdays(start, end time.Time) int {
+	n := 0
+	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+		if wd := d.Weekday(); wd != time.Saturday && wd != time.Sunday {
+			n++
+		}
+	}
+	return n
+}
+
+// overlappingLeaves lists the employee's pending and approved leaves that share a day with
+// start..end, other than the one with id skip
+func overlappingLeaves(ctx context.Context, empId int, start, end time.Time, skip primitive.ObjectID) ([]Leave, error) {
+	filter := bson.M{
+		"emp_id":     empId,
+		"status":     bson.M{"$in": bson.A{"pending", "approved"}},
+		"start_date": bson.M{"$lte": end},
+		"end_date":   bson.M{"$gte": start},
+		"_id":        bson.M{"$ne": skip},
+	}
+	cur, err := coll(ctx, "Leaves").Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "start_date", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	list := []Leave{}
+	err = cur.All(ctx, &list)
+	return list, err
+}
+
+// decideLeave approves or rejects a pending leave; an approval is refused when the leave
+// overlaps one approved since it was requested
+func decideLeave(ctx context.Context, l *Leave, status, approver, comment string) error {
+	return withTransaction(ctx, func(sc mongo.SessionContext) error {
+		if status == "approved" {
+			others, err := overlappingLeaves(sc, l.EmpID, l.StartDate, l.EndDate, l.ID)
+			if err != nil {
+				return err
+			}
+			if slices.ContainsFunc(others, func(o Leave) bool { return o.Status == "approved" }) {
+				return errLeaveOverlaps
+			}
+		}
+		now := time.Now().UTC()
+		err := coll(sc, "Leaves").FindOneAndUpdate(sc, bson.M{"_id": l.ID, "status": "pending"}, bson.M{"$set": bson.M{
+			"status":     status,
+			"decided_by": approver,
+			"decided_at": now,
+			"comment":    comment,
+		}}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(l)
+		if err == mongo.ErrNoDocuments {
+			return errLeaveNotPending
+		}
+		return err
+	})
+}
+
+// ---------------- Handlers ----------------
+
+// employeeLeaves handles /api/employees/{id}/leaves[/{leaveId}[/approve|reject|cancel]]
+func employeeLeaves(w http.ResponseWriter, r *http.Request, empId int, sub string) {
+	if sub == "" {
+		switch r.Method {
+		case http.MethodGet:
+			listLeaves(w, r, empId)
+		case http.MethodPost:
+			requestLeave(w, r, empId)
+		default:
+			httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+	leaveID, action, _ := strings.Cut(sub, "/")
+	id, err := primitive.ObjectIDFromHex(leaveID)
+	if err != nil {
+		httpError(w, "invalid leave id", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	var l Leave
+	if err := coll(ctx, "Leaves").FindOne(ctx, bson.M{"_id": id, "emp_id": empId}).Decode(&l); err != nil {
+		if err == mongo.ErrNoDocuments {
+			httpError(w, "leave not found", http.StatusNotFound)
+			return
+		}
+		storeError(w, "find leave", err)
+		return
+	}
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(l)
+	case action == "approve" || action == "reject":
+		if r.Method != http.MethodPost {
+			httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !requireAdmin(w, r) {
+			return
+		}
+		decideLeaveRequest(ctx, w, r, &l, action)
+	case action == "cancel":
+		if r.Method != http.MethodPost {
+			httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		cancelLeave(ctx, w, r, &l)
+	case action == "":
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// listLeaves returns an employee's leaves, latest first; ?status= and ?year= narrow them
+func listLeaves(w http.ResponseWriter, r *http.Request, empId int) {
+	q := r.URL.Query()
+	filter := bson.M{"emp_id": empId}
+	if v := q.Get("status"); v != "" {
+		filter["status"] = bson.M{"$in": strings.Split(v, ",")}
+	}
+	if v := q.Get("year"); v != "" {
+		start, err := time.Parse("2006", v)
+		if err != nil {
+			httpError(w, "invalid year", http.StatusBadRequest)
+			return
+		}
+		filter["start_date"] = bson.M{"$lt": start.AddDate(1, 0, 0)}
+		filter["end_date"] = bson.M{"$gte": start}
+	}
+
+	ctx := r.Context()
+
+	cur, err := coll(ctx, "Leaves").Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "start_date", Value: -1}}))
+	if err != nil {
+		storeError(w, "find leaves", err)
+		return
+	}
+	defer cur.Close(ctx)
+	list := []Leave{}
+	if err := cur.All(ctx, &list); err != nil {
+		storeError(w, "cursor all", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(list)
+}
+
+// requestLeave handles POST /api/employees/{id}/leaves: a pending leave, refused when it
+// overlaps a pending or approved one of the same employee
+func requestLeave(w http.ResponseWriter, r *http.Request, empId int) {
+	var input struct {
+		Type      string `json:"type"`
+		StartDate string `json:"start_date"` // YYYY-MM-DD
+		EndDate   string `json:"end_date"`   // YYYY-MM-DD, defaults to start_date
+		Reason    string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		httpError(w, "invalid input: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	errs := map[string]string{}
+	if !slices.Contains(leaveTypes, input.Type) {
+		errs["type"] = "must be " + strings.Join(leaveTypes, ", ")
+	}
+	start, err := time.Parse("2006-01-02", input.StartDate)
+	if err != nil {
+		errs["start_date"] = "expected YYYY-MM-DD"
+	}
+	end := start
+	if input.EndDate != "" {
+		if end, err = time.Parse("2006-01-02", input.EndDate); err != nil {
+			errs["end_date"] = "expected YYYY-MM-DD"
+		}
+	}
+	switch {
+	case errs["start_date"] != "" || errs["end_date"] != "":
+	case end.Before(start):
+		errs["end_date"] = "must not be before start_date"
+	case end.Sub(start) >= maxLeaveSpanDays*24*time.Hour:
+		errs["end_date"] = fmt.Sprintf("a leave spans at most %d days", maxLeaveSpanDays)
+	case weekdays(start, end) == 0:
+		errs["end_date"] = "the leave has no weekdays"
+	}
+	input.Reason = strings.TrimSpace(input.Reason)
+	if utf8.RuneCountInString(input.Reason) > maxLeaveReasonLength {
+		errs["reason"] = fmt.Sprintf("must be at most %d characters", maxLeaveReasonLength)
+	}
+	if len(errs) > 0 {
+		writeValidationErrors(w, errs)
+		return
+	}
+
+	ctx := r.Context()
+
+	var emp struct {
+		Status string `bson:"status"`
+	}
+	if err := coll(ctx, "Employee").FindOne(ctx, repository.Live(bson.M{"emp_id": empId})).Decode(&emp); err != nil {
+		if err == mongo.ErrNoDocuments {
+			httpError(w, "employee not found", http.StatusNotFound)
+			return
+		}
+		storeError(w, "find employee", err)
+		return
+	}
+	if emp.Status == "terminated" {
+		httpError(w, "employee is terminated", http.StatusConflict)
+		return
+	}
+
+	l := Leave{
+		EmpID:       empId,
+		Type:        input.Type,
+		StartDate:   start,
+		EndDate:     end,
+		Days:        weekdays(start, end),
+		Reason:      input.Reason,
+		Status:      "pending",
+		RequestedBy: actorFromRequest(r),
+		RequestedAt: time.Now().UTC(),
+	}
+	var overlaps []Leave
+	err = withTransaction(ctx, func(sc mongo.SessionContext) error {
+		var err error
+		if overlaps, err = overlappingLeaves(sc, empId, start, end, primitive.NilObjectID); err != nil {
+			return err
+		}
+		if len(overlaps) > 0 {
+			return errLeaveOverlaps
+		}
+		res, err := coll(sc, "Leaves").InsertOne(sc, l)
+		if err != nil {
+			return err
+		}
+		l.ID, _ = res.InsertedID.(primitive.ObjectID)
+		return nil
+	})
+	if errors.Is(err, errLeaveOverlaps) {
+		writeError(w, http.StatusConflict, "leave "+err.Error(), bson.M{"overlaps": overlaps})
+		return
+	}
+	if err != nil {
+		storeError(w, "insert leave", err)
+		return
+	}
+	_ = recordAudit(ctx, "leave_requested", empId, l.RequestedBy, bson.M{
+		"leave_id": l.ID, "type": l.Type, "start_date": l.StartDate, "end_date": l.EndDate,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(l)
+}
+
+// decideLeaveRequest handles POST .../leaves/{leaveId}/approve|reject (admin) and tells the
+// requester
+func decideLeaveRequest(ctx context.Context, w http.ResponseWriter, r *http.Request, l *Leave, action string) {
+	var input struct {
+		Comment string `json:"comment"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			httpError(w, "invalid input: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	status := "rejected"
+	if action == "approve" {
+		status = "approved"
+	}
+	approver := actorFromRequest(r)
+	err := decideLeave(ctx, l, status, approver, strings.TrimSpace(input.Comment))
+	switch {
+	case errors.Is(err, errLeaveNotPending), errors.Is(err, errLeaveOverlaps):
+		httpError(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		storeError(w, action+" leave", err)
+		return
+	}
+	_ = recordAudit(ctx, "leave_"+status, l.EmpID, approver, bson.M{"leave_id": l.ID, "comment": l.Comment})
+	_ = notify(ctx, l.RequestedBy, "leave_"+status,
+		fmt.Sprintf("The %s leave of employee %d from %s was %s by %s", l.Type, l.EmpID, l.StartDate.Format("2006-01-02"), status, approver),
+		fmt.Sprintf("/api/employees/%d/leaves/%s", l.EmpID, l.ID.Hex()))
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(l)
+}
+
+// cancelLeave handles POST .../leaves/{leaveId}/cancel: the requester or an admin cancels
+// a pending leave, or an approved one that hasn't started yet
+func cancelLeave(ctx context.Context, w http.ResponseWriter, r *http.Request, l *Leave) {
+	actor := actorFromRequest(r)
+	if !isAdmin(r) && l.RequestedBy != actor {
+		writeForbidden(w, "only the requester or an admin can cancel a leave")
+		return
+	}
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	if l.Status != "pending" && (l.Status != "approved" || !l.StartDate.After(today)) {
+		httpError(w, "only pending leaves and approved ones that haven't started can be cancelled", http.StatusConflict)
+		return
+	}
+	err := coll(ctx, "Leaves").FindOneAndUpdate(ctx, bson.M{"_id": l.ID, "status": l.Status},
+		bson.M{"$set": bson.M{"status": "cancelled"}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(l)
+	if err == mongo.ErrNoDocuments {
+		httpError(w, "leave was changed meanwhile; reload and retry", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		storeError(w, "cancel leave", err)
+		return
+	}
+	_ = recordAudit(ctx, "leave_cancelled", l.EmpID, actor, bson.M{"leave_id": l.ID})
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(l)
+}
+
+// CalendarLeave is a leave on the team calendar, with its weekdays in the month
+type CalendarLeave struct {
+	EmpID       int       `bson:"emp_id" json:"emp_id"`
+	EmpName     string    `bson:"emp_name" json:"emp_name"`
+	Department  string    `bson:"department" json:"-"`
+	Type        string    `bson:"type" json:"type"`
+	Status      string    `bson:"status" json:"status"`
+	StartDate   time.Time `bson:"start_date" json:"start_date"`
+	EndDate     time.Time `bson:"end_date" json:"end_date"`
+	DaysInMonth int       `bson:"-" json:"days_in_month"`
+}
+
+// CalendarDepartment is one department's leaves in a month
+type CalendarDepartment struct {
+	Department string          `json:"department"`
+	Employees  int             `json:"employees"` // on leave at some point of the month
+	Days       int             `json:"days"`      // weekdays of leave in the month
+	Leaves     []CalendarLeave `json:"leaves"`
+}
+
+// leaveCalendarHandler handles GET /api/leaves/calendar?month=YYYY-MM&department=&pending=true:
+// the approved (and with pending=true the pending) leaves of live employees that touch the
+// month, grouped by department
+func leaveCalendarHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	now := time.Now().UTC()
+	first := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if v := q.Get("month"); v != "" {
+		t, err := time.Parse("2006-01", v)
+		if err != nil {
+			httpError(w, "invalid month, expected YYYY-MM", http.StatusBadRequest)
+			return
+		}
+		first = t
+	}
+	last := first.AddDate(0, 1, -1)
+	statuses := bson.A{"approved"}
+	if q.Get("pending") == "true" {
+		statuses = append(statuses, "pending")
+	}
+
+	ctx := r.Context()
+
+	pipeline := mongo.Pipeline{
+		bson.D{{Key: "$match", Value: bson.M{
+			"status":     bson.M{"$in": statuses},
+			"start_date": bson.M{"$lte": last},
+			"end_date":   bson.M{"$gte": first},
+		}}},
+		bson.D{{Key: "$lookup", Value: bson.D{
+			{Key: "from", Value: "Employee"},
+			{Key: "localField", Value: "emp_id"},
+			{Key: "foreignField", Value: "emp_id"},
+			{Key: "pipeline", Value: bson.A{bson.D{{Key: "$match", Value: repository.Live(bson.M{})}}}},
+			{Key: "as", Value: "emp"},
+		}}},
+		bson.D{{Key: "$match", Value: bson.M{"emp": bson.M{"$ne": bson.A{}}}}},
+		repository.DepartmentLookup(),
+		bson.D{{Key: "$set", Value: bson.M{
+			"emp_name":   bson.M{"$arrayElemAt": bson.A{"$emp.emp_name", 0}},
+			"department": bson.M{"$ifNull": bson.A{bson.M{"$arrayElemAt": bson.A{"$departments.department_name", 0}}, ""}},
+		}}},
+	}
+	if d := q.Get("department"); d != "" {
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: bson.M{"department": d}}})
+	}
+	pipeline = append(pipeline, bson.D{{Key: "$sort", Value: bson.D{{Key: "start_date", Value: 1}, {Key: "emp_id", Value: 1}}}})
+	cur, err := coll(ctx, "Leaves").Aggregate(ctx, pipeline)
+	if err != nil {
+		storeError(w, "aggregate leaves", err)
+		return
+	}
+	defer cur.Close(ctx)
+	var leaves []CalendarLeave
+	if err := cur.All(ctx, &leaves); err != nil {
+		storeError(w, "cursor all", err)
+		return
+	}
+
+	byDept := map[string]*CalendarDepartment{}
+	onLeave := map[string]map[int]bool{}
+	for _, l := range leaves {
+		d := byDept[l.Department]
+		if d == nil {
+			d = &CalendarDepartment{Department: l.Department, Leaves: []CalendarLeave{}}
+			byDept[l.Department] = d
+			onLeave[l.Department] = map[int]bool{}
+		}
+		from, to := l.StartDate, l.EndDate
+		if from.Before(first) {
+			from = first
+		}
+		if to.After(last) {
+			to = last
+		}
+		l.DaysInMonth = weekdays(from, to)
+		d.Leaves = append(d.Leaves, l)
+		d.Days += l.DaysInMonth
+		onLeave[l.Department][l.EmpID] = true
+	}
+	departments := make([]CalendarDepartment, 0, len(byDept))
+	for name, d := range byDept {
+		d.Employees = len(onLeave[name])
+		departments = append(departments, *d)
+	}
+	sort.Slice(departments, func(i, j int) bool { return departments[i].Department < departments[j].Department })
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(bson.M{"month": first.Format("2006-01"), "departments": departments})
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cachedList is one rendered GET /api/employees response
+type cachedList struct {
+	body       []byte
+	etag       string
+	generation uint64
+	at         time.Time
+}
+
+// listCache keeps rendered employee lists until the next write. generation counts
+// invalidations, so a list computed while a write happened is not stored as current;
+// changedAt is when the last one happened (startup counts as one).
+var listCache = struct {
+	mu         sync.Mutex
+	generation uint64
+	changedAt  time.Time
+	entries    map[string]cachedList
+}{changedAt: time.Now(), entries: map[string]cachedList{}}
+
+// invalidateEmployeeLists drops every cached list; called after any write that may show
+// up in one (successful API mutations, change stream events, HR syncs)
+func invalidateEmployeeLists() {
+	listCache.mu.Lock()
+	defer listCache.mu.Unlock()
+	listCache.generation++
+	listCache.changedAt = time.Now()
+	clear(listCache.entries)
+}
+
+// lastEmployeeChange is when this instance last saw a write that may change employee data
+func lastEmployeeChange() time.Time {
+	listCache.mu.Lock()
+	defer listCache.mu.Unlock()
+	return listCache.changedAt
+}
+
+// listGeneration is the generation a list computed from now on belongs to
+func listGeneration() uint64 {
+	listCache.mu.Lock()
+	defer listCache.mu.Unlock()
+	return listCache.generation
+}
+
+// listCacheKey identifies a list response: the caller's organization and the caller
+// (saved searches are per user), the response shape and the query with its parameters sorted
+func listCacheKey(r *http.Request) string {
+	return orgOf(r.Context()) + "|" + actorFromRequest(r) + "|v" + strconv.Itoa(apiVersion(r)) + "|" + r.URL.Query().Encode()
+}
+
+// cachedEmployeeList returns the stored list for key if it is still fresh
+func cachedEmployeeList(key string) (cachedList, bool) {
+	ttl := time.Duration(cfg.Cache.ListTTL)
+	if ttl <= 0 {
+		return cachedList{}, false
+	}
+	listCache.mu.Lock()
+	defer listCache.mu.Unlock()
+	c, ok := listCache.entries[key]
+	if !ok || c.generation != listCache.generation || time.Since(c.at) > ttl {
+		return cachedList{}, false
+	}
+	return c, true
+}
+
+// storeEmployeeList keeps a list computed at generation, unless a write invalidated it
+// meanwhile. A full cache is emptied rather than tracking which entry is oldest.
+func storeEmployeeList(key string, c cachedList) {
+	if cfg.Cache.ListTTL <= 0 {
+		return
+	}
+	listCache.mu.Lock()
+	defer listCache.mu.Unlock()
+	if c.generation != listCache.generation {
+		return
+	}
+	if len(listCache.entries) >= cfg.Cache.ListEntries {
+		clear(listCache.entries)
+	}
+	listCache.entries[key] = c
+}
+
+// bodyETag is a strong ETag derived from the response body
+func bodyETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches reports whether an If-None-Match header names etag (or is "*"); weak
+// validators compare equal to their strong form
+func etagMatches(header, etag string) bool {
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+		if t == "*" || t == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// writeEmployeeList answers with a rendered list, or 304 when the client already has it
+func writeEmployeeList(w http.ResponseWriter, r *http.Request, c cachedList) {
+	h := w.Header()
+	h.Set("ETag", c.etag)
+	h.Set("Cache-Control", "private, no-cache")
+	if m := r.Header.Get("If-None-Match"); m != "" && etagMatches(m, c.etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	h.Set("Content-Type", "application/json")
+	_, _ = w.Write(c.body)
+}
+
+// invalidateOnWrite drops the cached employee lists after every mutation on the API that
+// wasn't rejected (4xx), whatever route made it; a 5xx may have written part of its change.
+// Dry runs write nothing.
+func invalidateOnWrite(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isMutation(r) || !strings.HasPrefix(r.URL.Path, "/api/") || isDryRun(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.status < http.StatusBadRequest || rec.status >= http.StatusInternalServerError {
+			invalidateEmployeeLists()
+		}
+	})
+}
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/karthikeyan-meenachisundaram/goBack/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestCreateEmployee(t *testing.T) {
+	s := useTestStores(t)
+
+	w := asAdmin(t, employeesHandler, http.MethodPost, "/api/employees", `{"emp_name":"Asha Rao","department":"Engg","languages":["Go"]}`)
+	expectStatus(t, w, http.StatusCreated)
+	id := int(decode(t, w)["emp_id"].(float64))
+	if id != 1 {
+		t.Fatalf("emp_id = %d, want 1", id)
+	}
+	raw, err := s.employees.Get(t.Context(), id, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := bson.Raw(raw).Lookup("department").StringValue(); got != "Engg" {
+		t.Errorf("department = %q, want Engg", got)
+	}
+
+	// a caller-chosen id moves the counter past it
+	w = asAdmin(t, employeesHandler, http.MethodPost, "/api/employees", `{"emp_id":10,"emp_name":"Ravi","department":"Ops"}`)
+	expectStatus(t, w, http.StatusCreated)
+	w = asAdmin(t, employeesHandler, http.MethodPost, "/api/employees", `{"emp_name":"Meena","department":"Ops"}`)
+	expectStatus(t, w, http.StatusCreated)
+	if id := int(decode(t, w)["emp_id"].(float64)); id != 11 {
+		t.Errorf("emp_id after 10 = %d, want 11", id)
+	}
+}
+
+func TestCreateEmployeeValidation(t *testing.T) {
+	s := useTestStores(t)
+
+	w := asAdmin(t, employeesHandler, http.MethodPost, "/api/employees", `{"department":"Engg"}`)
+	expectStatus(t, w, http.StatusUnprocessableEntity)
+	if last, _ := s.employees.LastID(t.Context()); last != 0 {
+		t.Errorf("an invalid payload created employee %d", last)
+	}
+
+	w = asAdmin(t, employeesHandler, http.MethodPost, "/api/employees", `{"emp_name":"Asha","department":"Engg","manager_id":42}`)
+	expectStatus(t, w, http.StatusUnprocessableEntity)
+
+	w = asAdmin(t, employeesHandler, http.MethodPost, "/api/employees", `{"emp_name":`)
+	expectStatus(t, w, http.StatusBadRequest)
+
+	// ids past 2^53-1 don't survive JSON and would run the id counter out
+	w = asAdmin(t, employeesHandler, http.MethodPost, "/api/employees", `{"emp_id":9007199254740992,"emp_name":"Asha","department":"Engg"}`)
+	expectStatus(t, w, http.StatusUnprocessableEntity)
+	if !strings.Contains(w.Body.String(), `"emp_id"`) {
+		t.Errorf("no error on emp_id: %s", w.Body.String())
+	}
+}
+
+func TestCreateEmployeeIDsRunOut(t *testing.T) {
+	s := useTestStores(t)
+
+	w := asAdmin(t, employeesHandler, http.MethodPost, "/api/employees", `{"emp_id":9007199254740991,"emp_name":"Asha","department":"Engg"}`)
+	expectStatus(t, w, http.StatusCreated)
+	// the next allocated id would be past the largest one
+	w = asAdmin(t, employeesHandler, http.MethodPost, "/api/employees", `{"emp_name":"Ravi","department":"Engg"}`)
+	expectStatus(t, w, http.StatusConflict)
+	if last, _ := s.employees.LastID(t.Context()); last != models.MaxEmpID {
+		t.Errorf("last id = %d, want %d", last, models.MaxEmpID)
+	}
+}
+
+func TestGetEmployee(t *testing.T) {
+	s := useTestStores(t)
+	s.addEmployees(t, models.NewEmployee{EmpID: 1, EmpName: "Asha", Department: "Engg", Languages: []string{"Go", "SQL"}})
+
+	w := asAdmin(t, empByIDHandler, http.MethodGet, empPath(1, ""), "")
+	expectStatus(t, w, http.StatusOK)
+	if etag := w.Header().Get("ETag"); etag != `"0"` {
+		t.Errorf("ETag = %s, want \"0\"", etag)
+	}
+	emp := decode(t, w)
+	if emp["emp_name"] != "Asha" || emp["language"] != "Go" {
+		t.Errorf("employee = %v", emp)
+	}
+
+	w = asAdmin(t, empByIDHandler, http.MethodGet, empPath(2, ""), "")
+	expectStatus(t, w, http.StatusNotFound)
+	w = asAdmin(t, empByIDHandler, http.MethodGet, "/api/employees/abc", "")
+	expectStatus(t, w, http.StatusBadRequest)
+}
+
+func TestGetEmployeeVersions(t *testing.T) {
+	s := useTestStores(t)
+	s.addEmployees(t, models.NewEmployee{EmpID: 1, EmpName: "Asha", Department: "Engg", Languages: []string{"Go", "SQL"}})
+	h := apiVersions(http.HandlerFunc(empByIDHandler)).ServeHTTP
+
+	// v1 and the deprecated alias share the flat format, v2 nests the department
+	for _, target := range []string{"/api/v1/employees/1", "/api/employees/1", "/api/v2/employees/1"} {
+		w := asAdmin(t, h, http.MethodGet, target, "")
+		expectStatus(t, w, http.StatusOK)
+		emp := decode(t, w)
+		v2 := strings.HasPrefix(target, "/api/v2/")
+		if _, nested := emp["department"].(map[string]interface{}); nested != v2 {
+			t.Errorf("GET %s: employee = %v", target, emp)
+		}
+		if deprecated := w.Header().Get("Deprecation") != ""; deprecated != (target == "/api/employees/1") {
+			t.Errorf("GET %s: Deprecation = %q", target, w.Header().Get("Deprecation"))
+		}
+	}
+}
+
+func TestUpdateEmployee(t *testing.T) {
+	s := useTestStores(t)
+	s.addEmployees(t,
+		models.NewEmployee{EmpID: 1, EmpName: "Asha", Department: "Engg"},
+		models.NewEmployee{EmpID: 2, EmpName: "Ravi", Department: "Engg", ManagerID: 1},
+	)
+
+	w := asAdmin(t, empByIDHandler, http.MethodPut, empPath(1, ""), `{"emp_name":"Asha Rao"}`)
+	expectStatus(t, w, http.StatusOK)
+	if etag := w.Header().Get("ETag"); etag != `"1"` {
+		t.Errorf("ETag = %s, want \"1\"", etag)
+	}
+	raw, _ := s.employees.Get(t.Context(), 1, nil)
+	if got := bson.Raw(raw).Lookup("emp_name").StringValue(); got != "Asha Rao" {
+		t.Errorf("emp_name = %q, want Asha Rao", got)
+	}
+
+	// the version the caller read is stale now: a failed If-Match precondition, or a
+	// conflict when the version came in the body
+	r := request(http.MethodPut, empPath(1, ""), `{"emp_name":"A. Rao"}`, "admin", "admin")
+	r.Header.Set("If-Match", strconv.Quote("0"))
+	w = record(empByIDHandler, r)
+	expectStatus(t, w, http.StatusPreconditionFailed)
+	if got := decode(t, w)["error"].(map[string]interface{})["details"].(map[string]interface{})["current_version"]; got != 1.0 {
+		t.Errorf("current_version = %v, want 1", got)
+	}
+	w = asAdmin(t, empByIDHandler, http.MethodPut, empPath(1, ""), `{"emp_name":"A. Rao","version":0}`)
+	expectStatus(t, w, http.StatusConflict)
+
+	// Asha can't report to Ravi, who reports to her
+	w = asAdmin(t, empByIDHandler, http.MethodPut, empPath(1, ""), `{"manager_id":2}`)
+	expectStatus(t, w, http.StatusUnprocessableEntity)
+}
+
+func TestEmployeeETags(t *testing.T) {
+	s := useTestStores(t)
+	s.addEmployees(t, models.NewEmployee{EmpID: 1, EmpName: "Asha", Department: "Engg"})
+	byID := invalidateOnWrite(http.HandlerFunc(empByIDHandler)).ServeHTTP
+	list := invalidateOnWrite(http.HandlerFunc(employeesHandler)).ServeHTTP
+	get := func(h http.HandlerFunc, target, ifNoneMatch string) *httptest.ResponseRecorder {
+		r := request(http.MethodGet, target, "", "admin", "admin")
+		if ifNoneMatch != "" {
+			r.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		return record(h, r)
+	}
+
+	for _, target := range []string{empPath(1, ""), "/api/employees"} {
+		h := list
+		if target != "/api/employees" {
+			h = byID
+		}
+		w := get(h, target, "")
+		expectStatus(t, w, http.StatusOK)
+		etag := w.Header().Get("ETag")
+		if etag == "" {
+			t.Fatalf("GET %s: no ETag", target)
+		}
+		for _, m := range []string{etag, "W/" + etag, `"other", ` + etag, "*"} {
+			w = get(h, target, m)
+			expectStatus(t, w, http.StatusNotModified)
+			if w.Body.Len() != 0 || w.Header().Get("ETag") != etag {
+				t.Errorf("GET %s If-None-Match %s: %v %q", target, m, w.Header(), w.Body.String())
+			}
+		}
+		expectStatus(t, get(h, target, `"other"`), http.StatusOK)
+	}
+
+	// a change makes both ETags stale
+	etags := []string{get(byID, empPath(1, ""), "").Header().Get("ETag"), get(list, "/api/employees", "").Header().Get("ETag")}
+	r := request(http.MethodPut, empPath(1, ""), `{"emp_name":"Asha Rao"}`, "admin", "admin")
+	r.Header.Set("If-Match", etags[0])
+	expectStatus(t, record(byID, r), http.StatusOK)
+	expectStatus(t, get(byID, empPath(1, ""), etags[0]), http.StatusOK)
+	expectStatus(t, get(list, "/api/employees", etags[1]), http.StatusOK)
+
+	// the old ETag no longer matches for another update
+	r = request(http.MethodPut, empPath(1, ""), `{"emp_name":"A. Rao"}`, "admin", "admin")
+	r.Header.Set("If-Match", etags[0])
+	expectStatus(t, record(byID, r), http.StatusPreconditionFailed)
+}
+
+func TestUpdateEmployeeNeedsApproval(t *testing.T) {
+	s := useTestStores(t)
+	cfg.Auth.ApprovalMode = true
+	s.addEmployees(t, models.NewEmployee{EmpID: 1, EmpName: "Asha", Department: "Engg"})
+
+	w := call(t, empByIDHandler, http.MethodPut, empPath(1, ""), `{"emp_name":"Asha Rao"}`, "ravi", "editor")
+	expectStatus(t, w, http.StatusAccepted)
+	if len(s.changeRequests.list) != 1 || s.changeRequests.list[0].RequestedBy != "ravi" {
+		t.Fatalf("change requests = %+v", s.changeRequests.list)
+	}
+	raw, _ := s.employees.Get(t.Context(), 1, nil)
+	if got := bson.Raw(raw).Lookup("emp_name").StringValue(); got != "Asha" {
+		t.Errorf("emp_name = %q before approval", got)
+	}
+
+	w = call(t, empByIDHandler, http.MethodDelete, empPath(7, ""), "", "ravi", "editor")
+	expectStatus(t, w, http.StatusNotFound)
+}
+
+func TestDeleteEmployee(t *testing.T) {
+	s := useTestStores(t)
+	s.addEmployees(t, models.NewEmployee{EmpID: 1, EmpName: "Asha", Department: "Engg"})
+
+	w := asAdmin(t, empByIDHandler, http.MethodDelete, empPath(1, ""), "")
+	expectStatus(t, w, http.StatusOK)
+	if n := decode(t, w)["deleted_count"]; n != 1.0 {
+		t.Errorf("deleted_count = %v, want 1", n)
+	}
+	w = asAdmin(t, empByIDHandler, http.MethodGet, empPath(1, ""), "")
+	expectStatus(t, w, http.StatusNotFound)
+
+	// deleted already, or never there
+	for _, id := range []int{1, 99} {
+		w = asAdmin(t, empByIDHandler, http.MethodDelete, empPath(id, ""), "")
+		expectStatus(t, w, http.StatusNotFound)
+		if e, _ := decode(t, w)["error"].(map[string]interface{}); e["code"] != "not_found" {
+			t.Errorf("DELETE employee %d: body %s", id, w.Body.String())
+		}
+	}
+}
+
+// listedIDs lists with the query string and returns the emp_ids of the rows
+func listedIDs(t *testing.T, query string) []int {
+	t.Helper()
+	w := asAdmin(t, employeesHandler, http.MethodGet, "/api/employees?"+query, "")
+	expectStatus(t, w, http.StatusOK)
+	var rows []map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &rows); err != nil {
+		t.Fatal(err)
+	}
+	ids := []int{}
+	for _, row := range rows {
+		ids = append(ids, int(row["emp_id"].(float64)))
+	}
+	return ids
+}
+
+// addListEmployees adds the employees the list tests filter and sort
+func addListEmployees(t *testing.T, s *testStores) {
+	t.Helper()
+	s.definitions.Fields = []models.CustomField{
+		{Name: "level", Type: "number", Visibility: "public"},
+		{Name: "salary", Type: "number", Visibility: "admin"},
+	}
+	s.addEmployees(t,
+		models.NewEmployee{EmpID: 1, EmpName: "Ravi", Department: "Sales", Languages: []string{"Go"}, CustomFields: bson.M{"level": 3.0, "salary": 50.0}},
+		models.NewEmployee{EmpID: 2, EmpName: "Asha", Department: "Engg", Languages: []string{"Rust", "Go"}, CustomFields: bson.M{"level": 2.0}},
+		models.NewEmployee{EmpID: 3, EmpName: "Meena", Department: "Engg", Languages: []string{"Python"}, CustomFields: bson.M{"level": 3.0}},
+	)
+	if err := s.employees.Terminate(t.Context(), 3, models.Termination{Reason: "layoff"}, "admin"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestListEmployees(t *testing.T) {
+	s := useTestStores(t)
+	addListEmployees(t, s)
+
+	tests := []struct {
+		query string
+		want  []int
+	}{
+		{"", []int{1, 2, 3}},
+		{"status=active", []int{1, 2}},
+		{"status=terminated", []int{3}},
+		{"department=Engg", []int{2, 3}},
+		{"language=Go", []int{1, 2}},
+		{"cf.level=3", []int{1, 3}},
+		{"cf.level=3&status=active", []int{1}},
+		{"sort=emp_name", []int{2, 3, 1}},
+		{"sort=-custom_fields.level,-emp_id", []int{3, 1, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			if got := listedIDs(t, tt.query); !slices.Equal(got, tt.want) {
+				t.Errorf("list = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListEmployeesRejected(t *testing.T) {
+	s := useTestStores(t)
+	addListEmployees(t, s)
+
+	for query, status := range map[string]int{
+		"status=gone":           http.StatusBadRequest,
+		"sort=salary":           http.StatusBadRequest,
+		"fields=emp_id,age":     http.StatusBadRequest,
+		"cf.level=high":         http.StatusBadRequest,
+		"cf.shoe=42":            http.StatusBadRequest,
+		"limit=500":             http.StatusBadRequest,
+		"saved_search=somebody": http.StatusNotFound,
+	} {
+		w := asAdmin(t, employeesHandler, http.MethodGet, "/api/employees?"+query, "")
+		if w.Code != status {
+			t.Errorf("%s: status = %d, want %d", query, w.Code, status)
+		}
+	}
+	// an admin-only field is unknown to everyone else
+	w := call(t, employeesHandler, http.MethodGet, "/api/employees?cf.salary=50", "", "ravi", "viewer")
+	expectStatus(t, w, http.StatusBadRequest)
+}
+
+func TestListEmployeesPageAndFields(t *testing.T) {
+	s := useTestStores(t)
+	addListEmployees(t, s)
+
+	w := asAdmin(t, employeesHandler, http.MethodGet, "/api/employees?fields=emp_name&sort=emp_name&page=2&limit=2", "")
+	expectStatus(t, w, http.StatusOK)
+	out := decode(t, w)
+	if out["total"] != 3.0 || out["page"] != 2.0 || out["limit"] != 2.0 {
+		t.Fatalf("envelope = %v", out)
+	}
+	items := out["items"].([]interface{})
+	if len(items) != 1 {
+		t.Fatalf("items = %v", items)
+	}
+	if row := items[0].(map[string]interface{}); len(row) != 2 || row["emp_name"] != "Ravi" || row["emp_id"] != 1.0 {
+		t.Errorf("row = %v, want emp_id and emp_name only", row)
+	}
+
+	// hidden custom fields stay hidden from everyone but admins
+	w = call(t, employeesHandler, http.MethodGet, "/api/employees?fields=custom_fields", "", "ravi", "viewer")
+	expectStatus(t, w, http.StatusOK)
+	if strings.Contains(w.Body.String(), "salary") {
+		t.Errorf("a viewer sees the salary: %s", w.Body.String())
+	}
+}
+
+func TestListEmployeesSavedSearch(t *testing.T) {
+	s := useTestStores(t)
+	addListEmployees(t, s)
+	s.definitions.Searches = []models.SavedSearch{
+		{Name: "engg", Owner: "admin", Query: map[string][]string{"department": {"Engg"}}, Sort: "-emp_id"},
+	}
+
+	if got := listedIDs(t, "saved_search=engg"); !slices.Equal(got, []int{3, 2}) {
+		t.Errorf("saved search = %v, want [3 2]", got)
+	}
+	// explicit params win
+	if got := listedIDs(t, "saved_search=engg&sort=emp_id"); !slices.Equal(got, []int{2, 3}) {
+		t.Errorf("saved search sorted by emp_id = %v, want [2 3]", got)
+	}
+}
+
+func TestStreamEmployees(t *testing.T) {
+	s := useTestStores(t)
+	addListEmployees(t, s)
+
+	r := request(http.MethodGet, "/api/employees?status=active&sort=-emp_id", "", "admin", "admin")
+	r.Header.Set("Accept", ndjsonContentType)
+	w := record(employeesHandler, r)
+	expectStatus(t, w, http.StatusOK)
+	if ct := w.Header().Get("Content-Type"); ct != ndjsonContentType {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"emp_id":2`) || !strings.Contains(lines[1], `"emp_id":1`) {
+		t.Errorf("stream = %q", lines)
+	}
+
+	w = asAdmin(t, employeesHandler, http.MethodGet, "/api/employees?stream=true&page=1", "")
+	expectStatus(t, w, http.StatusBadRequest)
+}
+
+func TestCreateEmployeeDefinitions(t *testing.T) {
+	s := useTestStores(t)
+	s.definitions.Fields = []models.CustomField{{Name: "level", Type: "number", Required: true, Visibility: "public"}}
+	s.definitions.Departments = map[string]bool{"engg": true, "ops": false}
+
+	for body, field := range map[string]string{
+		`{"emp_name":"Asha","department":"Engg"}`:                                 "custom_fields.level",
+		`{"emp_name":"Asha","department":"Sales","custom_fields":{"level":2}}`:    "department",
+		`{"emp_name":"Asha","department":"Ops","custom_fields":{"level":2}}`:      "department",
+		`{"emp_name":"Asha","department":"Engg","custom_fields":{"level":"two"}}`: "custom_fields.level",
+	} {
+		w := asAdmin(t, employeesHandler, http.MethodPost, "/api/employees", body)
+		expectStatus(t, w, http.StatusUnprocessableEntity)
+		if !strings.Contains(w.Body.String(), `"`+field+`"`) {
+			t.Errorf("%s: no error on %s: %s", body, field, w.Body.String())
+		}
+	}
+	w := asAdmin(t, employeesHandler, http.MethodPost, "/api/employees", `{"emp_name":"Asha","department":"engg","custom_fields":{"level":2}}`)
+	expectStatus(t, w, http.StatusCreated)
+}
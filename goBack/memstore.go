@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/karthikeyan-meenachisundaram/goBack/models"
+	"github.com/karthikeyan-meenachisundaram/goBack/repository"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// With db.driver memory the employees live in this process instead of MongoDB (see
+// repository.Memory), for demos, frontend development and handler tests. The store keeps
+// no audit log, history or tags, and there are no custom field definitions, reference
+// lists or saved searches (repository.MemoryMetadata). The server then only serves the
+// routes of handleMemoryRoutes, and nothing survives a restart. Requests authenticate as
+// on MongoDB: auth.bootstrap_user, an admin of the default organization, is the only
+// account (memoryAccounts), and the API keys it issues are kept in memory too
+// (memoryAPIKeys).
+
+// seedMemory adds the employees of a fixture file to the in-memory store
+func seedMemory(ctx context.Context, file string) error {
+	payloads, err := readSeedFile(file)
+	if err != nil {
+		return err
+	}
+	list := make([]models.NewEmployee, 0, len(payloads))
+	for i := range payloads {
+		emp, errs := newEmployee(map[string]models.CustomField{}, referenceData{}, &payloads[i])
+		if len(errs) > 0 {
+			return fmt.Errorf("employee %d is invalid: %v", i, errs)
+		}
+		list = append(list, emp)
+	}
+	return insertSeed(ctx, list)
+}
+
+// serveMemory runs the server on the in-memory store, starting with the employees of
+// db.seed_file
+func serveMemory() error {
+	employees, definitions = repository.NewMemory(), &repository.MemoryMetadata{}
+	if f := cfg.DB.SeedFile; f != "" {
+		if err := seedMemory(withOrg(context.Background(), defaultOrg), f); err != nil {
+			return fmt.Errorf("seed %s: %w", f, err)
+		}
+	}
+	account, err := memoryAccount(cfg.Auth.BootstrapUser, cfg.Auth.BootstrapPassword)
+	if err != nil {
+		return err
+	}
+	accounts, apiKeys = memoryAccounts{account.Username: account}, &memoryAPIKeys{}
+	initJWTSecret()
+	slog.Warn("employees, API keys and the account of auth.bootstrap_user are kept in memory: nothing survives a restart")
+
+	handleMemoryRoutes(http.DefaultServeMux, healthzHandler) // ready at once: there is nothing to wait for
+
+	slog.Info("server running", "addr", cfg.Server.Addr, "tls", cfg.TLS.enabled(), "driver", "memory")
+	return serve(cfg.Server.Addr, traceRequests(http.DefaultServeMux, accessLog(recoverPanics(apiVersions(limitRequests(instrument(http.DefaultServeMux, cors(rateLimit(authenticate(defaultOrgOnly(invalidateOnWrite(http.DefaultServeMux))))))))))))
+}
+
+// handleMemoryRoutes registers on mux the routes of the in-memory store: those of
+// handleEmployeeCRUD, signing in and the API keys
+func handleMemoryRoutes(mux *http.ServeMux, readyz http.HandlerFunc) {
+	handleEmployeeCRUD(mux, readyz)
+	mux.HandleFunc("/api/auth/login", loginHandler)           // POST {username, password}
+	mux.HandleFunc("/api/auth/refresh", refreshHandler)       // POST {refresh_token}
+	mux.HandleFunc("/api/admin/api-keys", apiKeysHandler)     // GET ?revoked= / POST (admin)
+	mux.HandleFunc("/api/admin/api-keys/", apiKeyByIDHandler) // GET / DELETE (admin)
+}
+
+// handleEmployeeCRUD registers on mux the routes the stores without MongoDB serve (the
+// memory and postgres drivers, which add their sign-in routes; see handleMemoryRoutes
+// and handlePostgresRoutes). Every other /api path answers 404:
+//
+//	GET, POST          /api/employees (filters, sort, fields, pages, NDJSON)
+//	POST               /api/employees/create
+//	GET                /api/employees/last-id
+//	GET, PUT, DELETE   /api/employees/{id}, without ?as_of or the {id}/... sub-resources
+//	GET                /api/openapi.json, /api/docs
+//	GET                /healthz, /readyz (readyz), /metrics
+//	GET                everything outside /api: the frontend
+func handleEmployeeCRUD(mux *http.ServeMux, readyz http.HandlerFunc) {
+	mux.HandleFunc("/api/employees", employeesHandler)         // GET (filters, sort, fields, pages, NDJSON) / POST
+	mux.HandleFunc("/api/employees/create", createEmployee)    // POST alias
+	mux.HandleFunc("/api/employees/last-id", lastIDHandler)    // GET
+	mux.HandleFunc("/api/employees/", crudEmployeeByIDHandler) // GET / PUT / DELETE by id
+	mux.HandleFunc("/api/openapi.json", openAPIHandler)        // GET
+	mux.HandleFunc("/api/docs", apiDocsHandler)                // GET Swagger UI
+	mux.HandleFunc("/api/", notServedHandler)                  // the rest of the API
+	mux.HandleFunc("/healthz", healthzHandler)                 // GET liveness
+	mux.HandleFunc("/readyz", readyz)                          // GET readiness
+	mux.Handle("/metrics", promhttp.Handler())                 // GET Prometheus scrape
+	mux.Handle("/", frontendHandler())
+}
+
+// ---------------- Handlers ----------------
+
+// crudEmployeeByIDHandler is empByIDHandler without what only the Mongo store keeps: the
+// sub-resources ({id}/notes, photo, history, ...) and ?as_of
+func crudEmployeeByIDHandler(w http.ResponseWriter, r *http.Request) {
+	if strings.Contains(strings.TrimPrefix(r.URL.Path, "/api/employees/"), "/") {
+		notServedHandler(w, r)
+		return
+	}
+	if r.URL.Query().Has("as_of") {
+		httpError(w, "as_of needs the mongo driver", http.StatusBadRequest)
+		return
+	}
+	empByIDHandler(w, r)
+}
+
+// notServedHandler answers 404 to the API routes only the mongo driver serves
+func notServedHandler(w http.ResponseWriter, r *http.Request) {
+	httpError(w, r.URL.Path+" needs the mongo driver", http.StatusNotFound)
+}
+
+// ---------------- Stores ----------------
+
+// memoryAccount is the account of auth.bootstrap_user on the in-memory store
+func memoryAccount(name, password string) (User, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return User{}, fmt.Errorf("hash auth.bootstrap_password: %w", err)
+	}
+	return User{Username: name, PasswordHash: string(hash), Role: "admin", CreatedAt: time.Now().UTC()}, nil
+}
+
+// memoryAccounts are the accounts of the in-memory store, by name
+type memoryAccounts map[string]User
+
+func (a memoryAccounts) Find(_ context.Context, username string) (User, error) {
+	u, ok := a[username]
+	if !ok {
+		return User{}, models.ErrNotFound
+	}
+	return u, nil
+}
+
+func (a memoryAccounts) Admins(context.Context) ([]string, error) {
+	var names []string
+	for name, u := range a {
+		if u.Role == "admin" {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// memoryAPIKeys keeps the API keys of the in-memory store, oldest first; there is only
+// the default organization
+type memoryAPIKeys struct {
+	mu   sync.Mutex
+	keys []APIKey
+}
+
+func (m *memoryAPIKeys) List(_ context.Context, revoked bool) ([]APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := []APIKey{}
+	for _, k := range m.keys {
+		if k.Active || revoked {
+			list = append(list, k)
+		}
+	}
+	return list, nil
+}
+
+func (m *memoryAPIKeys) Get(_ context.Context, id primitive.ObjectID) (APIKey, error) {
+	return m.find(func(k APIKey) bool { return k.ID == id })
+}
+
+func (m *memoryAPIKeys) Active(_ context.Context, hash string) (APIKey, error) {
+	return m.find(func(k APIKey) bool { return k.Active && k.Hash == hash })
+}
+
+func (m *memoryAPIKeys) Issue(_ context.Context, k *APIKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, o := range m.keys {
+		if o.Active && o.Name == k.Name {
+			return errAPIKeyNameTaken
+		}
+	}
+	k.ID = primitive.NewObjectID()
+	m.keys = append(m.keys, *k)
+	return nil
+}
+
+func (m *memoryAPIKeys) Used(_ context.Context, id primitive.ObjectID, t time.Time) error {
+	return m.update(id, func(k *APIKey) { k.LastUsedAt = &t })
+}
+
+func (m *memoryAPIKeys) Revoke(_ context.Context, id primitive.ObjectID, actor string, t time.Time) error {
+	return m.update(id, func(k *APIKey) { k.Active, k.RevokedBy, k.RevokedAt = false, actor, &t })
+}
+
+// find is the first key match accepts; models.ErrNotFound when there is none
+func (m *memoryAPIKeys) find(match func(APIKey) bool) (APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, k := range m.keys {
+		if match(k) {
+			return k, nil
+		}
+	}
+	return APIKey{}, models.ErrNotFound
+}
+
+// update applies set to the key with id; models.ErrNotFound when there is none
+func (m *memoryAPIKeys) update(id primitive.ObjectID, set func(*APIKey)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.keys {
+		if m.keys[i].ID == id {
+			set(&m.keys[i])
+			return nil
+		}
+	}
+	return models.ErrNotFound
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/karthikeyan-meenachisundaram/goBack/models"
+)
+
+// route is a request to one of the routes and the status it gets
+type route struct {
+	method, target, body string
+	status               int
+}
+
+// crudRoutes are the routes handleEmployeeCRUD serves and a few of those it doesn't,
+// run in order on a store holding employee 1
+var crudRoutes = []route{
+	{http.MethodGet, "/api/employees", "", http.StatusOK},
+	{http.MethodPost, "/api/employees", `{"emp_name":"Ravi","department":"Ops"}`, http.StatusCreated},
+	{http.MethodPost, "/api/employees/create", `{"emp_name":"Meena","department":"Ops"}`, http.StatusCreated},
+	{http.MethodGet, "/api/employees/last-id", "", http.StatusOK},
+	{http.MethodGet, "/api/employees/1", "", http.StatusOK},
+	{http.MethodPut, "/api/employees/1", `{"emp_name":"Asha Rao"}`, http.StatusOK},
+	{http.MethodDelete, "/api/employees/3", "", http.StatusOK},
+	{http.MethodGet, "/api/openapi.json", "", http.StatusOK},
+	{http.MethodGet, "/api/docs", "", http.StatusOK},
+	{http.MethodGet, "/healthz", "", http.StatusOK},
+	{http.MethodGet, "/readyz", "", http.StatusOK},
+	{http.MethodGet, "/metrics", "", http.StatusOK},
+
+	// what only the mongo driver serves
+	{http.MethodGet, "/api/employees/1?as_of=2024-01-01T00:00:00Z", "", http.StatusBadRequest},
+	{http.MethodGet, "/api/employees/1/notes", "", http.StatusNotFound},
+	{http.MethodGet, "/api/employees/1/history", "", http.StatusNotFound},
+	{http.MethodPost, "/api/employees/1/terminate", `{"reason":"layoff"}`, http.StatusNotFound},
+	{http.MethodPost, "/api/employees/1/merge/2", "", http.StatusNotFound},
+	{http.MethodGet, "/api/departments", "", http.StatusNotFound},
+	{http.MethodGet, "/api/stats", "", http.StatusNotFound},
+	{http.MethodGet, "/api/users", "", http.StatusNotFound},
+	{http.MethodPost, "/api/keys", `{"name":"ci"}`, http.StatusNotFound},
+	{http.MethodGet, "/api/trash", "", http.StatusNotFound},
+}
+
+// serveRoutes runs routes in order as an admin on mux
+func serveRoutes(t *testing.T, mux *http.ServeMux, routes []route) {
+	t.Helper()
+	for _, rt := range routes {
+		w := record(mux.ServeHTTP, request(rt.method, rt.target, rt.body, "admin", "admin"))
+		if w.Code != rt.status {
+			t.Errorf("%s %s = %d, want %d; body %s", rt.method, rt.target, w.Code, rt.status, w.Body.String())
+		}
+	}
+}
+
+func TestMemoryRoutes(t *testing.T) {
+	s := useTestStores(t)
+	s.addEmployees(t, models.NewEmployee{EmpID: 1, EmpName: "Asha", Department: "Engg"})
+	mux := http.NewServeMux()
+	handleMemoryRoutes(mux, healthzHandler)
+
+	serveRoutes(t, mux, append(crudRoutes,
+		route{http.MethodPost, "/api/admin/api-keys", `{"name":"ci"}`, http.StatusCreated},
+		route{http.MethodGet, "/api/admin/api-keys", "", http.StatusOK},
+		route{http.MethodPost, "/api/auth/refresh", `{"refresh_token":"x"}`, http.StatusUnauthorized},
+	))
+}
+
+// TestMemoryAuthentication signs in as the bootstrap account, issues an API key with its
+// token and revokes it, through authenticate like serveMemory
+func TestMemoryAuthentication(t *testing.T) {
+	s := useBootstrapAccount(t)
+	account, err := memoryAccount("admin", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.accounts[account.Username] = account
+	mux := http.NewServeMux()
+	handleMemoryRoutes(mux, healthzHandler)
+	h := authenticate(defaultOrgOnly(mux))
+	serve := func(method, target, body string, headers ...string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		for i := 0; i+1 < len(headers); i += 2 {
+			r.Header.Set(headers[i], headers[i+1])
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	expectStatus(t, serve(http.MethodGet, "/api/employees", ""), http.StatusUnauthorized)
+	expectStatus(t, serve(http.MethodPost, "/api/auth/login", `{"username":"admin","password":"wrong"}`), http.StatusUnauthorized)
+	w := serve(http.MethodPost, "/api/auth/login", `{"username":"admin","password":"secret"}`)
+	expectStatus(t, w, http.StatusOK)
+	access, _ := decode(t, w)["access_token"].(string)
+	bearer := "Bearer " + access
+	expectStatus(t, serve(http.MethodGet, "/api/employees", "", "Authorization", bearer), http.StatusOK)
+
+	w = serve(http.MethodPost, "/api/admin/api-keys", `{"name":"ci"}`, "Authorization", bearer)
+	expectStatus(t, w, http.StatusCreated)
+	issued := decode(t, w)
+	key, _ := issued["key"].(string)
+	expectStatus(t, serve(http.MethodGet, "/api/employees", "", apiKeyHeader, key), http.StatusOK)
+	expectStatus(t, serve(http.MethodPost, "/api/employees", `{"emp_name":"Ravi","department":"Ops"}`, apiKeyHeader, key), http.StatusForbidden)
+	expectStatus(t, serve(http.MethodPost, "/api/admin/api-keys", `{"name":"ci"}`, "Authorization", bearer), http.StatusConflict)
+
+	expectStatus(t, serve(http.MethodDelete, "/api/admin/api-keys/"+issued["id"].(string), "", "Authorization", bearer), http.StatusOK)
+	expectStatus(t, serve(http.MethodGet, "/api/employees", "", apiKeyHeader, key), http.StatusUnauthorized)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/karthikeyan-meenachisundaram/goBack/models"
+	"github.com/karthikeyan-meenachisundaram/goBack/service"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// defaultDuplicateScore is the least name similarity /api/employees/duplicates reports
+const defaultDuplicateScore = 0.85
+
+// ---------------- Handlers ----------------
+
+// duplicatesHandler handles GET /api/employees/duplicates?min_score=&page=&limit=: pairs
+// of live employees with the same or a similar name, most alike first
+func duplicatesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	minScore := defaultDuplicateScore
+	if v := q.Get("min_score"); v != "" {
+		var err error
+		if minScore, err = strconv.ParseFloat(v, 64); err != nil || minScore <= 0 || minScore > 1 {
+			httpError(w, "min_score must be a number above 0 and at most 1", http.StatusBadRequest)
+			return
+		}
+	}
+	page, limit, err := parsePage(q)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx := r.Context()
+
+	people, err := merges.Candidates(ctx)
+	if err != nil {
+		storeError(w, "find duplicates", err)
+		return
+	}
+	pairs := service.Duplicates(people, minScore)
+	total := len(pairs)
+	items := pairs[min((page-1)*limit, total):min(page*limit, total)]
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(bson.M{"items": items, "page": page, "limit": limit, "total": total})
+}
+
+// mergeEmployeesHandler handles POST /api/employees/merge {primary_id, duplicate_id}
+// (admin). The duplicate's data is folded into the primary record and the duplicate goes
+// to the trash.
+func mergeEmployeesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var input struct {
+		PrimaryID   int `json:"primary_id"`
+		DuplicateID int `json:"duplicate_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		httpError(w, "invalid input: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if input.PrimaryID == 0 || input.DuplicateID == 0 {
+		httpError(w, "primary_id and duplicate_id are required", http.StatusUnprocessableEntity)
+		return
+	}
+	mergeEmployeePair(w, r, input.PrimaryID, input.DuplicateID)
+}
+
+// mergeEmployee handles POST /api/employees/{id}/merge/{otherId}: like
+// /api/employees/merge with id as the primary and otherId as the duplicate
+func mergeEmployee(w http.ResponseWriter, r *http.Request, empId int, other string) {
+	if r.Method != http.MethodPost {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	duplicate, err := strconv.Atoi(other)
+	if err != nil {
+		httpError(w, "invalid id of the employee to merge", http.StatusBadRequest)
+		return
+	}
+	mergeEmployeePair(w, r, empId, duplicate)
+}
+
+// mergeEmployeePair merges duplicate into primary in a transaction and answers with the
+// fields taken over. Merges delete the duplicate, so like deletes they are admin only; in
+// approval mode everyone else's wait for an approver.
+func mergeEmployeePair(w http.ResponseWriter, r *http.Request, primary, duplicate int) {
+	if !approvalMode() && !requireAdmin(w, r) {
+		return
+	}
+	if primary == duplicate {
+		httpError(w, "primary_id and duplicate_id must differ", http.StatusUnprocessableEntity)
+		return
+	}
+	ctx := r.Context()
+
+	if !isAdmin(r) {
+		submitChangeRequest(ctx, w, r, models.ChangeRequest{Kind: "merge", EmpID: primary, Merge: &models.MergeChange{DuplicateID: duplicate}})
+		return
+	}
+
+	m, err := mergePair(ctx, primary, duplicate, actorFromRequest(r))
+	if err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			httpError(w, "employee not found", http.StatusNotFound)
+			return
+		}
+		storeError(w, "merge", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(bson.M{
+		"message":       "Employees merged successfully",
+		"emp_id":        primary,
+		"merged_from":   duplicate,
+		"merged_fields": m.Fields,
+	})
+}
+
+// mergePair merges duplicate into primary as actor and deletes the duplicate's photo when
+// the primary kept its own
+func mergePair(ctx context.Context, primary, duplicate int, actor string) (models.Merge, error) {
+	m, err := merges.Merge(ctx, primary, duplicate, actor)
+	if err != nil {
+		return m, err
+	}
+	// only once the merge is committed, so a rollback keeps the duplicate's photo
+	if m.OrphanPhoto != "" {
+		if err := photos.Delete(ctx, m.OrphanPhoto); err != nil {
+			logFor(ctx).Warn("delete merged duplicate's photo", "key", m.OrphanPhoto, "err", err)
+		}
+	}
+	return m, nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/karthikeyan-meenachisundaram/goBack/models"
+)
+
+func TestDuplicatesHandler(t *testing.T) {
+	s := useTestStores(t)
+	s.addEmployees(t,
+		models.NewEmployee{EmpID: 1, EmpName: "Asha Rao", Department: "Engg"},
+		models.NewEmployee{EmpID: 2, EmpName: "asha  rao", Department: "Ops"},
+		models.NewEmployee{EmpID: 3, EmpName: "Ravi Kumar", Department: "Ops"},
+	)
+
+	w := asAdmin(t, duplicatesHandler, http.MethodGet, "/api/employees/duplicates", "")
+	expectStatus(t, w, http.StatusOK)
+	out := decode(t, w)
+	if out["total"] != 1.0 {
+		t.Fatalf("duplicates = %v", out)
+	}
+
+	w = asAdmin(t, duplicatesHandler, http.MethodGet, "/api/employees/duplicates?min_score=2", "")
+	expectStatus(t, w, http.StatusBadRequest)
+}
+
+func TestMergeEmployees(t *testing.T) {
+	s := useTestStores(t)
+	s.addEmployees(t,
+		models.NewEmployee{EmpID: 1, EmpName: "Asha Rao", Department: "Engg"},
+		models.NewEmployee{EmpID: 2, EmpName: "Asha Rao", Department: "Engg"},
+	)
+	s.merges.result = models.Merge{Fields: []string{"custom_fields.city"}}
+
+	w := asAdmin(t, mergeEmployeesHandler, http.MethodPost, "/api/employees/merge", `{"primary_id":1,"duplicate_id":2}`)
+	expectStatus(t, w, http.StatusOK)
+	if !slices.Equal(s.merges.merged, [][2]int{{1, 2}}) {
+		t.Errorf("merged = %v", s.merges.merged)
+	}
+	if fields := decode(t, w)["merged_fields"].([]interface{}); len(fields) != 1 || fields[0] != "custom_fields.city" {
+		t.Errorf("merged_fields = %v", fields)
+	}
+
+	// the duplicate is gone
+	w = asAdmin(t, empByIDHandler, http.MethodPost, "/api/employees/1/merge/2", "")
+	expectStatus(t, w, http.StatusNotFound)
+	w = asAdmin(t, empByIDHandler, http.MethodPost, "/api/employees/1/merge/1", "")
+	expectStatus(t, w, http.StatusUnprocessableEntity)
+	w = asAdmin(t, mergeEmployeesHandler, http.MethodPost, "/api/employees/merge", `{"primary_id":1}`)
+	expectStatus(t, w, http.StatusUnprocessableEntity)
+}
+
+func TestMergeEmployeesNeedsAdmin(t *testing.T) {
+	s := useTestStores(t)
+	s.addEmployees(t,
+		models.NewEmployee{EmpID: 1, EmpName: "Asha Rao", Department: "Engg"},
+		models.NewEmployee{EmpID: 2, EmpName: "Asha Rao", Department: "Engg"},
+	)
+
+	w := call(t, mergeEmployeesHandler, http.MethodPost, "/api/employees/merge", `{"primary_id":1,"duplicate_id":2}`, "ravi", "editor")
+	expectStatus(t, w, http.StatusForbidden)
+	w = call(t, empByIDHandler, http.MethodPost, "/api/employees/1/merge/2", "", "ravi", "editor")
+	expectStatus(t, w, http.StatusForbidden)
+	if len(s.merges.merged) != 0 {
+		t.Errorf("an editor merged %v", s.merges.merged)
+	}
+	for _, path := range []string{"/api/employees/merge", "/api/employees/1/merge/2"} {
+		if role := requiredRole(http.MethodPost, path); role != "admin" {
+			t.Errorf("requiredRole(POST %s) = %s, want admin", path, role)
+		}
+	}
+}
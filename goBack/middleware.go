@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// uploadRoutes read bodies larger than server.max_body_bytes and enforce their own limits
+// (photos.max_bytes, maxImportBytes, maxRestoreBytes)
+var uploadRoutes = []string{"/api/employees/import", "/api/employees/*/photo", "/api/admin/restore"}
+
+// matchRoute reports whether the path matches one of the patterns
+func matchRoute(patterns []string, p string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, p); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// routeTimeout is how long a request to p may take: server.request_timeout, or the
+// longest server.route_timeouts pattern matching p. 0 means no limit.
+func routeTimeout(p string) time.Duration {
+	timeout, best := cfg.Server.RequestTimeout, ""
+	for pattern, d := range cfg.Server.RouteTimeouts {
+		if ok, _ := path.Match(pattern, p); ok && len(pattern) > len(best) {
+			timeout, best = d, pattern
+		}
+	}
+	return time.Duration(timeout)
+}
+
+// responseStarted reports whether a status was already sent through w, as far as the
+// recorders wrapping it know
+func responseStarted(w http.ResponseWriter) bool {
+	for {
+		if rec, ok := w.(*statusRecorder); ok && rec.status != 0 {
+			return true
+		}
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return false
+		}
+		w = u.Unwrap()
+	}
+}
+
+// recoverPanics turns a panicking handler into a logged stack trace and a 500 envelope
+// (when nothing was sent yet) instead of a dropped connection
+func recoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v) // the server's own way to abort a response
+			}
+			logFor(r.Context()).Error("panic", "method", r.Method, "path", r.URL.Path,
+				"err", fmt.Sprint(v), "stack", string(debug.Stack()))
+			if !responseStarted(w) {
+				httpError(w, "internal server error", http.StatusInternalServerError)
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// limitRequests puts the route's deadline (see routeTimeout) on the context of every /api
+// request and caps its body at server.max_body_bytes, upload routes excepted. Handlers
+// use r.Context() for their store calls so the deadline applies to them. An NDJSON
+// employee stream has the deadline of /api/employees/export.
+func limitRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if r.Body != nil && r.Body != http.NoBody && !matchRoute(uploadRoutes, r.URL.Path) {
+			if r.ContentLength > cfg.Server.MaxBodyBytes {
+				httpError(w, fmt.Sprintf("request body is larger than %d bytes", cfg.Server.MaxBodyBytes), http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, cfg.Server.MaxBodyBytes)
+		}
+		route := r.URL.Path
+		if wantsNDJSON(r) {
+			// a streamed employee list is an export by another name and gets its time
+			route = "/api/employees/export"
+		}
+		if d := routeTimeout(route); d > 0 {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+			r = r.WithContext(ctx)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
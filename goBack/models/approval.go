@@ -0,0 +1,63 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// ChangeRequest is a pending change to an employee awaiting an approver. Its kind's field
+// says what the change is: Payload for update, Termination, Transfer, Tags, Photo (nil
+// removes the photo) or Merge; a delete needs none.
+type ChangeRequest struct {
+	ID          string             `bson:"-" json:"id"`      // set by the store
+	Kind        string             `bson:"kind" json:"kind"` // update|delete|terminate|transfer|tags|photo|merge
+	EmpID       int                `bson:"emp_id" json:"emp_id"`
+	Payload     *EmployeePayload   `bson:"payload,omitempty" json:"payload,omitempty"`
+	Termination *TerminationChange `bson:"termination,omitempty" json:"termination,omitempty"`
+	Transfer    *TransferChange    `bson:"transfer,omitempty" json:"transfer,omitempty"`
+	Tags        *TagsChange        `bson:"tags,omitempty" json:"tags,omitempty"`
+	Photo       *PhotoChange       `bson:"photo,omitempty" json:"photo,omitempty"`
+	Merge       *MergeChange       `bson:"merge,omitempty" json:"merge,omitempty"`
+	Status      string             `bson:"status" json:"status"` // pending|approved|rejected
+	RequestedBy string             `bson:"requested_by" json:"requested_by"`
+	RequestedAt time.Time          `bson:"requested_at" json:"requested_at"`
+	DecidedBy   string             `bson:"decided_by,omitempty" json:"decided_by,omitempty"`
+	DecidedAt   *time.Time         `bson:"decided_at,omitempty" json:"decided_at,omitempty"`
+	Comment     string             `bson:"comment,omitempty" json:"comment,omitempty"`
+}
+
+// TerminationChange is a termination awaiting approval
+type TerminationChange struct {
+	EndDate time.Time `bson:"end_date" json:"end_date"`
+	Reason  string    `bson:"reason" json:"reason"`
+	Note    string    `bson:"note,omitempty" json:"note,omitempty"`
+}
+
+// TransferChange is a transfer awaiting approval
+type TransferChange struct {
+	ToDepartment  string    `bson:"to_department" json:"to_department"`
+	EffectiveDate time.Time `bson:"effective_date" json:"effective_date"`
+	Reason        string    `bson:"reason,omitempty" json:"reason,omitempty"`
+}
+
+// TagsChange adds the normalized Add tags or removes the Remove one
+type TagsChange struct {
+	Add    []string `bson:"add,omitempty" json:"add,omitempty"`
+	Remove string   `bson:"remove,omitempty" json:"remove,omitempty"`
+}
+
+// PhotoChange is an uploaded photo awaiting approval, already in the photo store
+type PhotoChange struct {
+	Key         string `bson:"key" json:"-"`
+	ContentType string `bson:"content_type" json:"content_type"`
+	ETag        string `bson:"etag" json:"etag"`
+	Size        int    `bson:"size" json:"size"`
+}
+
+// MergeChange folds DuplicateID into the request's employee
+type MergeChange struct {
+	DuplicateID int `bson:"duplicate_id" json:"duplicate_id"`
+}
+
+// ErrChangeNotPending is returned when deciding a change request that was decided before
+var ErrChangeNotPending = errors.New("change request is not pending")
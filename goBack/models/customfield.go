@@ -0,0 +1,115 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// CustomField defines a deployment-specific employee attribute
+type CustomField struct {
+	Name       string    `bson:"name" json:"name"`
+	Label      string    `bson:"label" json:"label"`
+	Type       string    `bson:"type" json:"type"` // string|number|boolean|date|enum
+	Required   bool      `bson:"required" json:"required"`
+	Pattern    string    `bson:"pattern,omitempty" json:"pattern,omitempty"`       // string only
+	MaxLength  int       `bson:"max_length,omitempty" json:"max_length,omitempty"` // string only
+	Min        *float64  `bson:"min,omitempty" json:"min,omitempty"`               // number only
+	Max        *float64  `bson:"max,omitempty" json:"max,omitempty"`               // number only
+	Options    []string  `bson:"options,omitempty" json:"options,omitempty"`       // enum only
+	Visibility string    `bson:"visibility" json:"visibility"`                     // public|admin
+	CreatedAt  time.Time `bson:"created_at" json:"created_at"`
+}
+
+var customFieldName = regexp.MustCompile(`^[a-z][a-z0-9_]{0,39}$`)
+
+// Check validates the definition itself
+func (f *CustomField) Check() error {
+	if !customFieldName.MatchString(f.Name) {
+		return fmt.Errorf("name must match %s", customFieldName)
+	}
+	if f.Label == "" {
+		f.Label = f.Name
+	}
+	if f.Visibility == "" {
+		f.Visibility = "public"
+	}
+	if f.Visibility != "public" && f.Visibility != "admin" {
+		return fmt.Errorf("visibility must be public or admin")
+	}
+	switch f.Type {
+	case "string":
+		if f.Pattern != "" {
+			if _, err := regexp.Compile(f.Pattern); err != nil {
+				return fmt.Errorf("invalid pattern: %v", err)
+			}
+		}
+	case "number":
+		if f.Min != nil && f.Max != nil && *f.Min > *f.Max {
+			return fmt.Errorf("min must not exceed max")
+		}
+	case "boolean", "date":
+	case "enum":
+		if len(f.Options) == 0 {
+			return fmt.Errorf("enum fields need options")
+		}
+	default:
+		return fmt.Errorf("type must be one of string, number, boolean, date, enum")
+	}
+	return nil
+}
+
+// Coerce validates v against the field definition and returns the value to store
+func (f CustomField) Coerce(v interface{}) (interface{}, error) {
+	switch f.Type {
+	case "string":
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("must be a string")
+		}
+		if f.MaxLength > 0 && len(s) > f.MaxLength {
+			return nil, fmt.Errorf("must be at most %d characters", f.MaxLength)
+		}
+		if f.Pattern != "" && !regexp.MustCompile(f.Pattern).MatchString(s) {
+			return nil, fmt.Errorf("must match %s", f.Pattern)
+		}
+		return s, nil
+	case "number":
+		n, ok := v.(float64)
+		if !ok {
+			return nil, fmt.Errorf("must be a number")
+		}
+		if f.Min != nil && n < *f.Min {
+			return nil, fmt.Errorf("must be >= %v", *f.Min)
+		}
+		if f.Max != nil && n > *f.Max {
+			return nil, fmt.Errorf("must be <= %v", *f.Max)
+		}
+		return n, nil
+	case "boolean":
+		b, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("must be a boolean")
+		}
+		return b, nil
+	case "date":
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("must be a date string YYYY-MM-DD")
+		}
+		if _, err := time.Parse("2006-01-02", s); err != nil {
+			return nil, fmt.Errorf("must be a date string YYYY-MM-DD")
+		}
+		return s, nil
+	case "enum":
+		s, _ := v.(string)
+		for _, o := range f.Options {
+			if s == o {
+				return s, nil
+			}
+		}
+		return nil, fmt.Errorf("must be one of %s", strings.Join(f.Options, ", "))
+	}
+	return nil, fmt.Errorf("unsupported type %s", f.Type)
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Department is a row of the Departments collection. Employees reference it by dept_id
+// through their Department membership document, so a rename is a single update.
+type Department struct {
+	DeptID    int       `bson:"dept_id" json:"dept_id"`
+	Name      string    `bson:"name" json:"name"`
+	Inactive  bool      `bson:"inactive,omitempty" json:"inactive,omitempty"` // deactivated, see referencedata.go
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+}
+
+// Reassignment is the outcome of moving a department's employees to another one
+type Reassignment struct {
+	Moved   []int // employees transferred
+	Skipped []int // terminated or deleted members, left where they were
+}
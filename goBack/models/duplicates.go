@@ -0,0 +1,23 @@
+package models
+
+// DuplicatePair is two live employees whose names look like the same person
+type DuplicatePair struct {
+	Score     float64               `json:"score"`  // 1 for the same name, less the more edits apart
+	Reason    string                `json:"reason"` // same_name, similar_name
+	Employees [2]DuplicateCandidate `json:"employees"`
+}
+
+// DuplicateCandidate is a live employee as duplicate detection compares it
+type DuplicateCandidate struct {
+	EmpID      int    `bson:"emp_id" json:"emp_id"`
+	EmpName    string `bson:"emp_name" json:"emp_name"`
+	Department string `bson:"department" json:"department,omitempty"`
+}
+
+// Merge is the outcome of folding a duplicate employee into the primary one
+type Merge struct {
+	Fields []string // fields the primary took over, sorted
+	// OrphanPhoto is the key of the duplicate's photo blob when the primary kept its own;
+	// nothing refers to it any more
+	OrphanPhoto string
+}
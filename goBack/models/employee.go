@@ -0,0 +1,161 @@
+// Package models holds the domain types the HTTP handlers, the rules in service and the
+// stores in repository share.
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// EmployeePayload is the normalized create/update body. It accepts both the legacy
+// flat shape
+//
+//	{"emp_name": "Asha", "department": "Engg", "language": "Go"}
+//
+// and the nested one
+//
+//	{"emp_name": "Asha", "department": {"name": "Engg"}, "languages": ["Go", "Rust"]}
+//
+// On update, nil fields are left unchanged; an empty languages list clears them, a
+// manager_id of 0 removes the manager, and a version makes the update conditional on the
+// stored one (like If-Match).
+type EmployeePayload struct {
+	EmpId        int                    `bson:"emp_id,omitempty" json:"emp_id,omitempty"`
+	Version      *int                   `bson:"version,omitempty" json:"version,omitempty"`
+	EmpName      *string                `bson:"emp_name,omitempty" json:"emp_name,omitempty"`
+	Department   *string                `bson:"department,omitempty" json:"department,omitempty"`
+	Languages    []string               `bson:"languages,omitempty" json:"languages,omitempty"`   // first one is the primary language
+	ManagerID    *int                   `bson:"manager_id,omitempty" json:"manager_id,omitempty"` // emp_id of the employee's manager
+	CustomFields map[string]interface{} `bson:"custom_fields,omitempty" json:"custom_fields,omitempty"`
+}
+
+// UnmarshalJSON accepts both the legacy and the nested payload shape
+func (p *EmployeePayload) UnmarshalJSON(b []byte) error {
+	var raw struct {
+		EmpId        int                    `json:"emp_id"`
+		Version      *int                   `json:"version"`
+		EmpName      *string                `json:"emp_name"`
+		Department   json.RawMessage        `json:"department"`
+		Language     *string                `json:"language"`
+		Languages    []string               `json:"languages"`
+		ManagerID    *int                   `json:"manager_id"`
+		CustomFields map[string]interface{} `json:"custom_fields"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	*p = EmployeePayload{EmpId: raw.EmpId, Version: raw.Version, EmpName: raw.EmpName, ManagerID: raw.ManagerID, CustomFields: raw.CustomFields}
+
+	if d := bytes.TrimSpace(raw.Department); len(d) > 0 && !bytes.Equal(d, []byte("null")) {
+		var name string
+		if d[0] == '{' {
+			var nested struct {
+				Name string `json:"name"`
+			}
+			if err := json.Unmarshal(d, &nested); err != nil {
+				return fmt.Errorf("department: %w", err)
+			}
+			name = nested.Name
+		} else if err := json.Unmarshal(d, &name); err != nil {
+			return fmt.Errorf("department must be a string or {\"name\": ...}")
+		}
+		p.Department = &name
+	}
+
+	switch {
+	case raw.Languages != nil:
+		p.Languages = raw.Languages
+		// a legacy "language" sent alongside is treated as the primary one
+		if raw.Language != nil {
+			p.Languages = append([]string{*raw.Language}, slices.DeleteFunc(slices.Clone(raw.Languages), func(l string) bool { return l == *raw.Language })...)
+		}
+	case raw.Language != nil:
+		p.Languages = []string{*raw.Language}
+	}
+	return nil
+}
+
+// NewEmployee is a validated employee ready to be written
+type NewEmployee struct {
+	EmpID        int
+	EmpName      string
+	Department   string
+	Languages    []string
+	ManagerID    int // 0 for none
+	CustomFields bson.M
+}
+
+// EmployeeChange is a validated partial update; nil fields are left alone
+type EmployeeChange struct {
+	Version      *int // expected current version; nil updates whatever is stored
+	EmpName      *string
+	Department   *string
+	Languages    []string // nil keeps, empty clears
+	ManagerID    *int     // 0 removes the manager
+	CustomFields bson.M   // values to set
+	ClearFields  []string // custom fields to remove
+}
+
+// Termination is a validated termination of an employee
+type Termination struct {
+	EndDate time.Time
+	Reason  string
+	Note    string
+}
+
+// EmployeeQuery selects, orders and pages the rows of the employee list. Rows are
+// shaped like EmployeeStore.Get's.
+type EmployeeQuery struct {
+	Status       string                 // active (neither inactive nor terminated), inactive or terminated; "" for all
+	Department   string                 // exact name
+	Language     string                 // one of the employee's languages
+	Tags         []string               // carried all, normalized
+	CustomFields map[string]interface{} // custom field name to the value it equals
+	Hidden       []string               // custom_fields.<name> paths left out of the rows
+	Sort         bson.D                 // field to 1 or -1, emp_id breaking ties; emp_id order when paged without one
+	Fields       []string               // the fields kept, emp_id first; nil for whole rows
+	Page, Limit  int                    // Limit 0 for every row
+}
+
+// Row is an employee as the list and detail routes show it (see EmployeeStore.Get): a
+// BSON document, so the fields a list selects and the custom fields keep their shape
+// whichever store the row comes from
+type Row bson.Raw
+
+// Decode reads the row into v, a struct with bson tags or a bson.M
+func (r Row) Decode(v interface{}) error {
+	return bson.Unmarshal(r, v)
+}
+
+// Version is the version of the employee in the row, 0 when the row has none
+func (r Row) Version() int {
+	v, _ := bson.Raw(r).Lookup("version").AsInt64OK()
+	return int(v)
+}
+
+// MaxEmpID is the largest emp_id, 2^53-1: the largest integer JSON numbers (and so the
+// frontend) carry exactly
+const MaxEmpID = 1<<53 - 1
+
+// ErrIDOutOfRange is returned by NextIDs and ReserveID when the id counter would pass
+// MaxEmpID
+var ErrIDOutOfRange = errors.New("no emp_ids left below the largest one")
+
+// ErrNotFound is returned when the employee (or record) asked for does not exist
+var ErrNotFound = errors.New("not found")
+
+// ErrVersionConflict is returned by Update when the employee's version is no longer the
+// one the change was based on
+var ErrVersionConflict = errors.New("employee was changed by someone else")
+
+// ErrAlreadyTerminated is returned by Terminate for an employee terminated before
+var ErrAlreadyTerminated = errors.New("employee already terminated")
+
+// ErrDuplicate is returned when a name or id that must be unique is already taken
+var ErrDuplicate = errors.New("already exists")
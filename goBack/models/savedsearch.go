@@ -0,0 +1,33 @@
+package models
+
+import (
+	"net/url"
+	"time"
+)
+
+// SavedSearch is a named filter+sort+columns combination for the employee list
+type SavedSearch struct {
+	Name      string              `bson:"name" json:"name"`
+	Owner     string              `bson:"owner" json:"owner"`
+	Shared    bool                `bson:"shared" json:"shared"`
+	Query     map[string][]string `bson:"query" json:"query"` // list filters, e.g. {"status": ["active"], "department": ["Engg"]}
+	Sort      string              `bson:"sort,omitempty" json:"sort,omitempty"`
+	Columns   string              `bson:"columns,omitempty" json:"columns,omitempty"`
+	CreatedAt time.Time           `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time           `bson:"updated_at" json:"updated_at"`
+}
+
+// Apply fills in list params from the saved search; params given explicitly win
+func (s SavedSearch) Apply(q url.Values) {
+	for k, v := range s.Query {
+		if _, ok := q[k]; !ok {
+			q[k] = v
+		}
+	}
+	if s.Sort != "" && q.Get("sort") == "" {
+		q.Set("sort", s.Sort)
+	}
+	if s.Columns != "" && q.Get("columns") == "" {
+		q.Set("columns", s.Columns)
+	}
+}
@@ -0,0 +1,9 @@
+package models
+
+// SearchQuery is a free-text employee search
+type SearchQuery struct {
+	Query       string             // as typed
+	Words       []string           // the words of Query that are scored
+	Weights     map[string]float64 // per field, see search.weights
+	Page, Limit int
+}
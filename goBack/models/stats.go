@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// StatCount is one bucket of a per-department or per-language breakdown
+type StatCount struct {
+	Name  string `bson:"_id" json:"name"`
+	Count int    `bson:"count" json:"count"`
+}
+
+// RecentHire is an employee in the recent hires list
+type RecentHire struct {
+	EmpID      int       `bson:"emp_id" json:"emp_id"`
+	EmpName    string    `bson:"emp_name" json:"emp_name"`
+	Department string    `bson:"department" json:"department"`
+	HiredAt    time.Time `bson:"hired_at" json:"hired_at"`
+}
+
+// EmployeeStats is the dashboard payload of GET /api/employees/stats
+type EmployeeStats struct {
+	Headcount struct {
+		Total    int            `json:"total"` // everyone not terminated
+		ByStatus map[string]int `json:"by_status"`
+	} `json:"headcount"`
+	ByDepartment []StatCount  `json:"by_department"`
+	ByLanguage   []StatCount  `json:"by_language"`
+	HiredSince   int          `json:"hired_since_count"`
+	Since        time.Time    `json:"since"`
+	RecentHires  []RecentHire `json:"recent_hires"`
+	ComputedAt   time.Time    `bson:"computed_at" json:"computed_at"`
+}
+
+// AttritionQuery selects the terminations an attrition report counts
+type AttritionQuery struct {
+	Period   string     // month, quarter or year
+	From, To *time.Time // on the end date, both inclusive; nil for open
+}
+
+// AttritionRow counts the terminations of one period, department and reason
+type AttritionRow struct {
+	Period     string `bson:"period" json:"period"`
+	Department string `bson:"department" json:"department"`
+	Reason     string `bson:"reason" json:"reason"`
+	Count      int    `bson:"count" json:"count"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// Transfer is one entry of an employee's department transfer history
+type Transfer struct {
+	EmpID          int       `bson:"emp_id" json:"emp_id"`
+	FromDepartment string    `bson:"from_department" json:"from_department"`
+	ToDepartment   string    `bson:"to_department" json:"to_department"`
+	EffectiveDate  time.Time `bson:"effective_date" json:"effective_date"`
+	Reason         string    `bson:"reason,omitempty" json:"reason,omitempty"`
+	RecordedAt     time.Time `bson:"recorded_at" json:"recorded_at"`
+	RecordedBy     string    `bson:"recorded_by" json:"recorded_by"`
+}
+
+// ErrTerminated is returned for a change a terminated employee can't take, like a transfer
+var ErrTerminated = errors.New("employee is terminated")
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
+)
+
+// The driver reconnects on its own; what this file adds is the pool settings from
+// cfg.Mongo, a record of whether a writable server is reachable (for /readyz, the
+// metrics and the log), and retry for the employee store's calls that fail on a blip.
+
+// mongoConn is the connection state the driver's monitors report
+var mongoConn struct {
+	sync.Mutex
+	connected  bool
+	since      time.Time // of the last change of connected
+	reconnects int       // times connected came back after having been lost
+	open       atomic.Int64
+	inUse      atomic.Int64
+}
+
+var (
+	// notPrimaryCodes are server errors refusing a command because the server is no
+	// longer (or not) the primary; nothing was run
+	notPrimaryCodes = []int{10107, 13435}
+	// stepDownCodes are server errors for a primary stepping down or shutting down under a
+	// running command, which may have been partly applied
+	stepDownCodes = []int{189, 91, 11600, 11602}
+)
+
+// mongoClientOptions are the client options from cfg.Mongo; the pool settings there win
+// over the same ones in the URI
+func mongoClientOptions() *options.ClientOptions {
+	m := cfg.Mongo
+	return options.Client().ApplyURI(m.URI).
+		SetMonitor(mongoMonitor()).
+		SetServerMonitor(&event.ServerMonitor{TopologyDescriptionChanged: topologyChanged}).
+		SetPoolMonitor(&event.PoolMonitor{Event: poolEvent}).
+		SetRetryReads(true).
+		SetRetryWrites(true).
+		SetMaxPoolSize(uint64(m.MaxPoolSize)).
+		SetMinPoolSize(uint64(m.MinPoolSize)).
+		SetMaxConnIdleTime(time.Duration(m.MaxConnIdleTime)).
+		SetServerSelectionTimeout(time.Duration(m.ServerSelectionTimeout))
+}
+
+// topologyChanged tracks whether a writable server is reachable. The driver calls it
+// with the topology locked, so it only records and logs.
+func topologyChanged(e *event.TopologyDescriptionChangedEvent) {
+	up := e.NewDescription.HasWritableServer()
+	mongoConn.Lock()
+	defer mongoConn.Unlock()
+	if up == mongoConn.connected {
+		return
+	}
+	now := time.Now()
+	switch {
+	case up && !mongoConn.since.IsZero():
+		mongoConn.reconnects++
+		slog.Info("mongo: reconnected", "down_for", now.Sub(mongoConn.since).Round(time.Millisecond).String())
+	case !up:
+		slog.Warn("mongo: lost the writable server", "topology", e.NewDescription.Kind.String())
+	}
+	mongoConn.connected, mongoConn.since = up, now
+	if up {
+		mongoConnected.Set(1)
+	} else {
+		mongoConnected.Set(0)
+	}
+}
+
+// poolEvent counts the driver's open and checked-out connections
+func poolEvent(e *event.PoolEvent) {
+	switch e.Type {
+	case event.ConnectionCreated:
+		mongoPoolConnections.WithLabelValues("open").Set(float64(mongoConn.open.Add(1)))
+	case event.ConnectionClosed:
+		mongoPoolConnections.WithLabelValues("open").Set(float64(mongoConn.open.Add(-1)))
+	case event.GetSucceeded:
+		mongoPoolConnections.WithLabelValues("in_use").Set(float64(mongoConn.inUse.Add(1)))
+	case event.ConnectionReturned:
+		mongoPoolConnections.WithLabelValues("in_use").Set(float64(mongoConn.inUse.Add(-1)))
+	case event.PoolCleared:
+		slog.Warn("mongo: connection pool cleared", "address", e.Address)
+	}
+}
+
+// mongoState is the connection part of the /readyz mongo check
+func mongoState() map[string]interface{} {
+	mongoConn.Lock()
+	defer mongoConn.Unlock()
+	state := map[string]interface{}{
+		"connected":  mongoConn.connected,
+		"reconnects": mongoConn.reconnects,
+		"pool": map[string]int64{
+			"open":   mongoConn.open.Load(),
+			"in_use": mongoConn.inUse.Load(),
+		},
+	}
+	if !mongoConn.since.IsZero() {
+		state["since"] = mongoConn.since.UTC()
+	}
+	return state
+}
+
+// mongoUnavailable reports whether err means no usable server was reachable (rather
+// than that the request ran out of time or the command failed)
+func mongoUnavailable(err error) bool {
+	if err == nil || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var wq topology.WaitQueueTimeoutError
+	return errors.Is(err, topology.ErrServerSelectionTimeout) || errors.As(err, &wq) || mongo.IsNetworkError(err)
+}
+
+// transientError reports whether retrying err may succeed. Reads and idempotent writes
+// are retried on any connection trouble, other writes only when the server cannot have
+// run them, so a retry never applies such a write twice.
+func transientError(err error, idempotent bool) bool {
+	if err == nil || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var wq topology.WaitQueueTimeoutError
+	if errors.Is(err, topology.ErrServerSelectionTimeout) || errors.As(err, &wq) {
+		return true
+	}
+	var se mongo.ServerError
+	if errors.As(err, &se) {
+		if se.HasErrorLabel("NoWritesPerformed") {
+			return true
+		}
+		for _, code := range notPrimaryCodes {
+			if se.HasErrorCode(code) {
+				return true
+			}
+		}
+		for _, code := range stepDownCodes {
+			if idempotent && se.HasErrorCode(code) {
+				return true
+			}
+		}
+	}
+	return idempotent && mongo.IsNetworkError(err)
+}
+
+// retry runs the store operation op and, after a transient error (see transientError),
+// up to mongo.retries more times. The waits start at mongo.retry_backoff and double,
+// with jitter so instances recovering together don't retry in step; the request's
+// deadline still bounds the whole.
+func retry(ctx context.Context, name string, idempotent bool, op func() error) error {
+	wait := time.Duration(cfg.Mongo.RetryBackoff)
+	for attempt := 0; ; attempt++ {
+		err := op()
+		if attempt >= cfg.Mongo.Retries || !transientError(err, idempotent) {
+			return err
+		}
+		mongoRetries.WithLabelValues(name).Inc()
+		logFor(ctx).Warn("mongo: retrying", "op", name, "attempt", attempt+1, "err", err)
+		t := time.NewTimer(wait/2 + rand.N(wait/2+1))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		case <-t.C:
+		}
+		wait *= 2
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"testing"
+
+	"github.com/karthikeyan-meenachisundaram/goBack/repository"
+	"github.com/karthikeyan-meenachisundaram/goBack/repository/storetest"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// TestMongoEmployeeStore holds the Mongo store to the tests the in-memory and Postgres
+// ones pass, each in a database of its own on the TEST_MONGO_URI server, dropped when it
+// ends. Its batches are not all or nothing, so RunAtomicBatches is left out.
+func TestMongoEmployeeStore(t *testing.T) {
+	uri := os.Getenv("TEST_MONGO_URI")
+	if uri == "" {
+		t.Skip("TEST_MONGO_URI is not set, e.g. mongodb://localhost:27017")
+	}
+	savedCfg, savedClient, savedEmployees := cfg, client, employees
+	t.Cleanup(func() { cfg, client, employees = savedCfg, savedClient, savedEmployees })
+
+	ctx := context.Background()
+	cfg = defaultConfig()
+	cfg.Mongo.URI = uri
+	var err error
+	if client, err = mongo.Connect(ctx, mongoClientOptions()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = client.Disconnect(context.Background()) })
+	employees = repository.NewMongo(mongoEnv{}).Employees()
+
+	storetest.Run(t, func(t *testing.T) repository.EmployeeStore {
+		cfg.Mongo.Database = fmt.Sprintf("goback_test_%d", rand.Uint32())
+		db := database(ctx)
+		t.Cleanup(func() {
+			if err := db.Drop(context.Background()); err != nil {
+				t.Error(err)
+			}
+		})
+		ensureIndexes(ctx)
+		initIDCounter(ctx)
+		return employees
+	})
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ndjsonContentType = "application/x-ndjson"
+	// ndjsonFlushInterval is how often a stream hands the rows written so far to the client
+	ndjsonFlushInterval = time.Second
+)
+
+// wantsNDJSON reports whether r asks for the employee list as a stream of
+// newline-delimited JSON, with Accept: application/x-ndjson or ?stream=true
+func wantsNDJSON(r *http.Request) bool {
+	if r.Method != http.MethodGet || r.URL.Path != "/api/employees" {
+		return false
+	}
+	if stream, err := strconv.ParseBool(r.URL.Query().Get("stream")); err == nil {
+		return stream
+	}
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		if t, _, err := mime.ParseMediaType(part); err == nil && t == ndjsonContentType {
+			return true
+		}
+	}
+	return false
+}
+
+// ---------------- Handlers ----------------
+
+// streamEmployees handles GET /api/employees as NDJSON: one row per line, in the shape
+// and with the filters, sort and fields of the JSON list, written as the store yields
+// them so the whole list is never held in memory. It is not paged (the stream is all of
+// it) and bypasses the list cache. A failure after the first row ends the stream with an
+// {"error": ...} line instead of a status.
+func streamEmployees(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	q := r.URL.Query()
+	if q.Has("page") || q.Has("limit") {
+		httpError(w, "a stream has no pages; drop page and limit", http.StatusBadRequest)
+		return
+	}
+	query, status, err := selectedEmployeeQuery(ctx, r, q)
+	if err != nil {
+		httpError(w, err.Error(), status)
+		return
+	}
+
+	flusher, _ := w.(http.Flusher)
+	w.Header().Set("Content-Type", ndjsonContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	flush := func() error {
+		if err := bw.Flush(); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	}
+
+	v2 := apiVersion(r) == 2
+	var streamErr error
+	rows, flushed := 0, time.Now()
+	for raw, err := range employees.Stream(ctx, query) {
+		var row interface{}
+		if err == nil {
+			row, err = decodeEmployeeRow(raw, query.Fields != nil, v2)
+		}
+		if err == nil {
+			err = enc.Encode(row)
+		}
+		if err != nil {
+			streamErr = err
+			break
+		}
+		rows++
+		if time.Since(flushed) >= ndjsonFlushInterval {
+			if err := flush(); err != nil {
+				// the client went away
+				logFor(ctx).Info("employee stream closed by the client", "rows", rows)
+				return
+			}
+			flushed = time.Now()
+		}
+	}
+	switch {
+	case streamErr != nil && rows == 0:
+		// nothing has been sent yet, so the failure can still be a status
+		storeError(w, "list employees", streamErr)
+		return
+	case streamErr != nil:
+		logFor(ctx).Error("employee stream aborted", "rows", rows, "err", streamErr)
+		_ = enc.Encode(map[string]apiError{"error": {Code: errorCodes[http.StatusInternalServerError], Message: "stream aborted: " + streamErr.Error()}})
+	}
+	_ = flush()
+}
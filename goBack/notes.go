@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/karthikeyan-meenachisundaram/goBack/repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Note is a comment on an employee record; replies point at their parent
+type Note struct {
+	ID         primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	EmpID      int                 `bson:"emp_id" json:"emp_id"`
+	ParentID   *primitive.ObjectID `bson:"parent_id,omitempty" json:"parent_id,omitempty"`
+	Author     string              `bson:"author" json:"author"`
+	Body       string              `bson:"body" json:"body"`
+	Visibility string              `bson:"visibility" json:"visibility"` // public|hr|private
+	CreatedAt  time.Time           `bson:"created_at" json:"created_at"`
+	UpdatedAt  *time.Time          `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+	Replies    []*Note             `bson:"-" json:"replies,omitempty"`
+}
+
+const maxNoteLength = 5000
+
+// noteVisibleTo narrows a Notes filter to what the caller may read:
+// public for everyone, hr for admins, private for the author (and admins)
+func noteVisibleTo(filter bson.M, user string, admin bool) bson.M {
+	if !admin {
+		filter["$or"] = bson.A{
+			bson.M{"visibility": "public"},
+			bson.M{"author": user},
+		}
+	}
+	return filter
+}
+
+// employeeNotes handles /api/employees/{id}/notes[/{noteId}]
+func employeeNotes(w http.ResponseWriter, r *http.Request, empId int, noteID string) {
+	if noteID == "" {
+		switch r.Method {
+		case http.MethodGet:
+			listNotes(w, r, empId)
+		case http.MethodPost:
+			createNote(w, r, empId)
+		default:
+			httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+	id, err := primitive.ObjectIDFromHex(noteID)
+	if err != nil {
+		httpError(w, "invalid note id", http.StatusBadRequest)
+		return
+	}
+	switch r.Method {
+	case http.MethodPut:
+		updateNote(w, r, empId, id)
+	case http.MethodDelete:
+		deleteNote(w, r, empId, id)
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// listNotes returns the notes on an employee as threads, oldest first
+func listNotes(w http.ResponseWriter, r *http.Request, empId int) {
+	ctx := r.Context()
+
+	filter := noteVisibleTo(bson.M{"emp_id": empId}, actorFromRequest(r), isAdmin(r))
+	cur, err := coll(ctx, "Notes").Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		storeError(w, "find notes", err)
+		return
+	}
+	defer cur.Close(ctx)
+	var notes []*Note
+	if err := cur.All(ctx, &notes); err != nil {
+		storeError(w, "cursor all", err)
+		return
+	}
+
+	byID := make(map[primitive.ObjectID]*Note, len(notes))
+	for _, n := range notes {
+		byID[n.ID] = n
+	}
+	threads := []*Note{}
+	for _, n := range notes {
+		if n.ParentID != nil {
+			if parent, ok := byID[*n.ParentID]; ok {
+				parent.Replies = append(parent.Replies, n)
+				continue
+			}
+		}
+		// top-level note, or a reply whose parent the caller cannot see
+		threads = append(threads, n)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(threads)
+}
+
+// createNote adds a note (or a reply when parent_id is set)
+func createNote(w http.ResponseWriter, r *http.Request, empId int) {
+	var input struct {
+		Body       string `json:"body"`
+		ParentID   string `json:"parent_id"`
+		Visibility string `json:"visibility"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		httpError(w, "invalid input: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if input.Body == "" || len(input.Body) > maxNoteLength {
+		httpError(w, "body is required (max 5000 characters)", http.StatusUnprocessableEntity)
+		return
+	}
+	if input.Visibility == "" {
+		input.Visibility = "public"
+	}
+	if input.Visibility != "public" && input.Visibility != "hr" && input.Visibility != "private" {
+		httpError(w, "visibility must be public, hr or private", http.StatusUnprocessableEntity)
+		return
+	}
+	if input.Visibility == "hr" && !isAdmin(r) {
+		httpError(w, "only admins can write hr notes", http.StatusForbidden)
+		return
+	}
+
+	ctx := r.Context()
+
+	n, err := coll(ctx, "Employee").CountDocuments(ctx, repository.Live(bson.M{"emp_id": empId}))
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if n == 0 {
+		httpError(w, "employee not found", http.StatusNotFound)
+		return
+	}
+
+	note := Note{
+		EmpID:      empId,
+		Author:     actorFromRequest(r),
+		Body:       input.Body,
+		Visibility: input.Visibility,
+		CreatedAt:  time.Now().UTC(),
+	}
+	if input.ParentID != "" {
+		pid, err := primitive.ObjectIDFromHex(input.ParentID)
+		if err != nil {
+			httpError(w, "invalid parent_id", http.StatusUnprocessableEntity)
+			return
+		}
+		filter := noteVisibleTo(bson.M{"_id": pid, "emp_id": empId}, note.Author, isAdmin(r))
+		if n, err := coll(ctx, "Notes").CountDocuments(ctx, filter); err != nil || n == 0 {
+			httpError(w, "parent note not found", http.StatusUnprocessableEntity)
+			return
+		}
+		note.ParentID = &pid
+	}
+
+	res, err := coll(ctx, "Notes").InsertOne(ctx, note)
+	if err != nil {
+		storeError(w, "insert note", err)
+		return
+	}
+	note.ID, _ = res.InsertedID.(primitive.ObjectID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(note)
+}
+
+// findOwnNote loads a note and checks the caller is its author or an admin
+func findOwnNote(ctx context.Context, w http.ResponseWriter, r *http.Request, empId int, id primitive.ObjectID) (Note, bool) {
+	var note Note
+	if err := coll(ctx, "Notes").FindOne(ctx, bson.M{"_id": id, "emp_id": empId}).Decode(&note); err != nil {
+		if err == mongo.ErrNoDocuments {
+			httpError(w, "note not found", http.StatusNotFound)
+			return note, false
+		}
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return note, false
+	}
+	if note.Author != actorFromRequest(r) && !isAdmin(r) {
+		httpError(w, "only the author or an admin can change this note", http.StatusForbidden)
+		return note, false
+	}
+	return note, true
+}
+
+// updateNote edits the body and/or visibility of a note
+func updateNote(w http.ResponseWriter, r *http.Request, empId int, id primitive.ObjectID) {
+	var input struct {
+		Body       *string `json:"body"`
+		Visibility *string `json:"visibility"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		httpError(w, "invalid input: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	now := time.Now().UTC()
+	set := bson.M{"updated_at": now}
+	if input.Body != nil {
+		if *input.Body == "" || len(*input.Body) > maxNoteLength {
+			httpError(w, "body is required (max 5000 characters)", http.StatusUnprocessableEntity)
+			return
+		}
+		set["body"] = *input.Body
+	}
+	if input.Visibility != nil {
+		v := *input.Visibility
+		if v != "public" && v != "hr" && v != "private" {
+			httpError(w, "visibility must be public, hr or private", http.StatusUnprocessableEntity)
+			return
+		}
+		if v == "hr" && !isAdmin(r) {
+			httpError(w, "only admins can write hr notes", http.StatusForbidden)
+			return
+		}
+		set["visibility"] = v
+	}
+
+	ctx := r.Context()
+
+	if _, ok := findOwnNote(ctx, w, r, empId, id); !ok {
+		return
+	}
+	var note Note
+	err := coll(ctx, "Notes").FindOneAndUpdate(ctx, bson.M{"_id": id}, bson.M{"$set": set},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&note)
+	if err != nil {
+		storeError(w, "update note", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(note)
+}
+
+// deleteNote removes a note together with its replies
+func deleteNote(w http.ResponseWriter, r *http.Request, empId int, id primitive.ObjectID) {
+	ctx := r.Context()
+
+	if _, ok := findOwnNote(ctx, w, r, empId, id); !ok {
+		return
+	}
+	res, err := coll(ctx, "Notes").DeleteMany(ctx, bson.M{"$or": bson.A{bson.M{"_id": id}, bson.M{"parent_id": id}}})
+	if err != nil {
+		storeError(w, "delete note", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(bson.M{"message": "Note deleted successfully", "deleted_count": res.DeletedCount})
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Notification is an in-app message for one user
+type Notification struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	User      string             `bson:"user" json:"user"`
+	Kind      string             `bson:"kind" json:"kind"` // e.g. import_finished, approval_requested
+	Message   string             `bson:"message" json:"message"`
+	Link      string             `bson:"link,omitempty" json:"link,omitempty"`
+	Read      bool               `bson:"read" json:"read"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// notificationHub fans new notifications out to open SSE streams, keyed by
+// notificationKey
+var notificationHub = struct {
+	sync.Mutex
+	subs map[string]map[chan Notification]bool
+}{subs: map[string]map[chan Notification]bool{}}
+
+// notificationKey tells apart users of the same name in different organizations
+func notificationKey(ctx context.Context, user string) string {
+	return orgOf(ctx) + "/" + user
+}
+
+func subscribeNotifications(user string) chan Notification {
+	ch := make(chan Notification, 16)
+	notificationHub.Lock()
+	defer notificationHub.Unlock()
+	if notificationHub.subs[user] == nil {
+		notificationHub.subs[user] = map[chan Notification]bool{}
+	}
+	notificationHub.subs[user][ch] = true
+	return ch
+}
+
+func unsubscribeNotifications(user string, ch chan Notification) {
+	notificationHub.Lock()
+	defer notificationHub.Unlock()
+	delete(notificationHub.subs[user], ch)
+	if len(notificationHub.subs[user]) == 0 {
+		delete(notificationHub.subs, user)
+	}
+}
+
+// notificationStore keeps the notifications of an organization
+type notificationStore interface {
+	// Insert stores n and sets its ID
+	Insert(ctx context.Context, n *Notification) error
+}
+
+// notifications is where notify stores notifications
+var notifications notificationStore = mongoNotifications{}
+
+// mongoNotifications keeps notifications in the Notifications collection
+type mongoNotifications struct{}
+
+func (mongoNotifications) Insert(ctx context.Context, n *Notification) error {
+	res, err := coll(ctx, "Notifications").InsertOne(ctx, n)
+	if err != nil {
+		return err
+	}
+	n.ID, _ = res.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// notify stores a notification for user and pushes it to their open streams
+func notify(ctx context.Context, user, kind, message, link string) error {
+	n := Notification{User: user, Kind: kind, Message: message, Link: link, CreatedAt: time.Now().UTC()}
+	if err := notifications.Insert(ctx, &n); err != nil {
+		return err
+	}
+
+	notificationHub.Lock()
+	defer notificationHub.Unlock()
+	for ch := range notificationHub.subs[notificationKey(ctx, user)] {
+		select {
+		case ch <- n:
+		default: // slow consumer, it can catch up via the list endpoint
+		}
+	}
+	return nil
+}
+
+// notifyAdmins notifies every admin of the organization ctx acts for
+func notifyAdmins(ctx context.Context, kind, message, link string) error {
+	admins, err := accounts.Admins(ctx)
+	if err != nil {
+		return err
+	}
+	for _, name := range admins {
+		if err := notify(ctx, name, kind, message, link); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ---------------- Handlers ----------------
+
+// notificationsHandler handles GET /api/notifications?unread=true&limit=50
+func notificationsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	limit := 50
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 200 {
+			httpError(w, "limit must be between 1 and 200", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	filter := bson.M{"user": actorFromRequest(r)}
+	if r.URL.Query().Get("unread") == "true" {
+		filter["read"] = false
+	}
+
+	ctx := r.Context()
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(int64(limit))
+	cur, err := coll(ctx, "Notifications").Find(ctx, filter, opts)
+	if err != nil {
+		storeError(w, "find notifications", err)
+		return
+	}
+	defer cur.Close(ctx)
+	list := []Notification{}
+	if err := cur.All(ctx, &list); err != nil {
+		storeError(w, "cursor all", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(list)
+}
+
+// notificationActionHandler handles the /api/notifications/... sub-routes:
+// GET unread-count, GET stream (SSE), POST read-all, POST {id}/read
+func notificationActionHandler(w http.ResponseWriter, r *http.Request) {
+	user := actorFromRequest(r)
+	rest := strings.TrimPrefix(r.URL.Path, "/api/notifications/")
+
+	switch {
+	case rest == "stream" && r.Method == http.MethodGet:
+		streamNotifications(w, r, user)
+		return
+	case rest == "unread-count" && r.Method == http.MethodGet:
+		ctx := r.Context()
+		n, err := coll(ctx, "Notifications").CountDocuments(ctx, bson.M{"user": user, "read": false})
+		if err != nil {
+			storeError(w, "count notifications", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(bson.M{"unread": n})
+	case rest == "read-all" && r.Method == http.MethodPost:
+		ctx := r.Context()
+		res, err := coll(ctx, "Notifications").UpdateMany(ctx, bson.M{"user": user, "read": false}, bson.M{"$set": bson.M{"read": true}})
+		if err != nil {
+			storeError(w, "mark read", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(bson.M{"message": "Notifications marked as read", "updated_count": res.ModifiedCount})
+	case strings.HasSuffix(rest, "/read") && r.Method == http.MethodPost:
+		id, err := primitive.ObjectIDFromHex(strings.TrimSuffix(rest, "/read"))
+		if err != nil {
+			httpError(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		ctx := r.Context()
+		res, err := coll(ctx, "Notifications").UpdateOne(ctx, bson.M{"_id": id, "user": user}, bson.M{"$set": bson.M{"read": true}})
+		if err != nil {
+			storeError(w, "mark read", err)
+			return
+		}
+		if res.MatchedCount == 0 {
+			httpError(w, "notification not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(bson.M{"message": "Notification marked as read"})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// streamNotifications pushes new notifications to the client as Server-Sent Events
+func streamNotifications(w http.ResponseWriter, r *http.Request, user string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		httpError(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	flusher.Flush()
+
+	key := notificationKey(r.Context(), user)
+	ch := subscribeNotifications(key)
+	defer unsubscribeNotifications(key, ch)
+
+	heartbeat := time.NewTicker(30 * time.Second)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+		case n := <-ch:
+			data, _ := json.Marshal(n)
+			fmt.Fprintf(w, "event: notification\nid: %s\ndata: %s\n\n", n.ID.Hex(), data)
+		}
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// without returns list minus every occurrence of s
+func without(list []string, s string) []string {
+	out := make([]string, 0, len(list))
+	for _, v := range list {
+		if v != s {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// apiVersion returns the response format the caller asked for: the /api/v1 or /api/v2
+// prefix decides; on the unversioned alias 2 via ?api_version=2 or an Accept-Version: 2
+// header, otherwise the legacy flat format (1)
+func apiVersion(r *http.Request) int {
+	if v, ok := r.Context().Value(apiVersionKey).(int); ok {
+		return v
+	}
+	if r.URL.Query().Get("api_version") == "2" || r.Header.Get("Accept-Version") == "2" {
+		return 2
+	}
+	return 1
+}
+
+// adaptEmployeeV2 reshapes a list row into the nested v2 format:
+// department becomes {"name": ...} and the single language is dropped in favor of languages
+func adaptEmployeeV2(doc bson.M) bson.M {
+	if d, ok := doc["department"]; ok {
+		doc["department"] = bson.M{"name": d}
+	}
+	if _, ok := doc["language"]; ok {
+		if _, ok := doc["languages"]; !ok {
+			doc["languages"] = bson.A{}
+		}
+		delete(doc, "language")
+	}
+	return doc
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/karthikeyan-meenachisundaram/goBack/repository"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// With db.driver postgres the employees are kept in PostgreSQL (postgres.dsn) by
+// repository.Postgres, for environments where MongoDB isn't approved; its migrations run
+// at startup. Everything else the server keeps lives in MongoDB, so this serves only the
+// routes of handleEmployeeCRUD, plus POST /api/auth/login and /api/auth/refresh, without
+// custom field definitions, reference lists or saved searches.
+// There are no user accounts or API keys either: auth.bootstrap_user is the only account,
+// an admin of the default organization signing in for the usual bearer tokens.
+
+// servePostgres runs the server on the Postgres store
+func servePostgres() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Mongo.ConnectTimeout))
+	defer cancel()
+	poolCfg, err := pgxpool.ParseConfig(cfg.Postgres.DSN)
+	if err != nil {
+		return fmt.Errorf("postgres.dsn: %w", err)
+	}
+	if n := cfg.Postgres.MaxConns; n > 0 {
+		poolCfg.MaxConns = int32(n)
+	}
+	pool, err := pgxpool.NewWithConfig(ctx, poolCfg)
+	if err != nil {
+		return fmt.Errorf("postgres connect: %w", err)
+	}
+	defer pool.Close()
+	store := repository.NewPostgres(pool)
+	if err := store.Ping(ctx); err != nil {
+		return fmt.Errorf("postgres ping: %w", err)
+	}
+	if err := store.Migrate(ctx); err != nil {
+		return fmt.Errorf("postgres migrate: %w", err)
+	}
+	slog.Info("connected to PostgreSQL", "database", poolCfg.ConnConfig.Database)
+
+	employees, definitions = store, &repository.MemoryMetadata{}
+	initJWTSecret()
+
+	handlePostgresRoutes(http.DefaultServeMux, postgresReadyzHandler(store))
+
+	slog.Info("server running", "addr", cfg.Server.Addr, "tls", cfg.TLS.enabled(), "driver", "postgres")
+	return serve(cfg.Server.Addr, traceRequests(http.DefaultServeMux, accessLog(recoverPanics(apiVersions(limitRequests(instrument(http.DefaultServeMux, cors(rateLimit(refuseAPIKeys(authenticate(defaultOrgOnly(invalidateOnWrite(http.DefaultServeMux)))))))))))))
+}
+
+// handlePostgresRoutes registers on mux the routes of the Postgres store: those of
+// handleEmployeeCRUD and signing in as auth.bootstrap_user
+func handlePostgresRoutes(mux *http.ServeMux, readyz http.HandlerFunc) {
+	handleEmployeeCRUD(mux, readyz)
+	mux.HandleFunc("/api/auth/login", bootstrapLoginHandler)     // POST {username, password}
+	mux.HandleFunc("/api/auth/refresh", bootstrapRefreshHandler) // POST {refresh_token}
+}
+
+// refuseAPIKeys answers 401 to requests authenticating with an API key, which are kept in
+// MongoDB, before authenticate looks them up
+func refuseAPIKeys(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(apiKeyHeader) != "" && bearerToken(r) == "" {
+			writeUnauthorized(w, "API keys need the mongo driver")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// defaultOrgOnly stands in for tenant on the Postgres store, which has one organization:
+// requests naming another one, by X-Org-ID or through their token, are refused
+func defaultOrgOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		orgs := []string{r.Header.Get(orgHeader)}
+		if c, ok := r.Context().Value(userKey).(*tokenClaims); ok {
+			orgs = append(orgs, orgOfClaims(c))
+		}
+		for _, org := range orgs {
+			if org != "" && org != defaultOrg {
+				httpError(w, fmt.Sprintf("unknown organization %q", org), http.StatusBadRequest)
+				return
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(withOrg(r.Context(), defaultOrg)))
+	})
+}
+
+// bootstrapUser is the account of auth.bootstrap_user
+func bootstrapUser() User {
+	return User{Username: cfg.Auth.BootstrapUser, Role: "admin"}
+}
+
+// ---------------- Handlers ----------------
+
+// postgresReadyzHandler is readyzHandler for the Postgres store: the database answers a
+// ping within 2s and the server is not draining
+func postgresReadyzHandler(store *repository.Postgres) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+		defer cancel()
+
+		check := bson.M{"status": "ok"}
+		start := time.Now()
+		err := store.Ping(ctx)
+		check["latency_ms"] = time.Since(start).Milliseconds()
+		if err != nil {
+			check["status"] = "down"
+			check["error"] = err.Error()
+		}
+
+		status, code := "ready", http.StatusOK
+		switch {
+		case draining.Load():
+			status, code = "draining", http.StatusServiceUnavailable
+		case err != nil:
+			status, code = "degraded", http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(code)
+		_ = json.NewEncoder(w).Encode(bson.M{
+			"status": status,
+			"uptime": uptime(),
+			"checks": bson.M{"postgres": check},
+		})
+	}
+}
+
+// bootstrapLoginHandler is loginHandler on the Postgres store, where auth.bootstrap_user
+// is the only account
+func bootstrapLoginHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var input struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Org      string `json:"org"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		httpError(w, "invalid input: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	// both are compared, so the time taken doesn't tell which one was wrong
+	userOK := subtle.ConstantTimeCompare([]byte(input.Username), []byte(cfg.Auth.BootstrapUser))
+	passwordOK := subtle.ConstantTimeCompare([]byte(input.Password), []byte(cfg.Auth.BootstrapPassword))
+	if userOK&passwordOK != 1 || (input.Org != "" && input.Org != defaultOrg) {
+		writeUnauthorized(w, "invalid username or password")
+		return
+	}
+	writeTokens(r.Context(), w, bootstrapUser())
+}
+
+// bootstrapRefreshHandler is refreshHandler on the Postgres store
+func bootstrapRefreshHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var input struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		httpError(w, "invalid input: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	claims, err := parseToken(input.RefreshToken, "refresh")
+	if err != nil {
+		writeUnauthorized(w, "invalid refresh token: "+err.Error())
+		return
+	}
+	// auth.bootstrap_user may have been changed since the token was issued
+	if claims.Subject != cfg.Auth.BootstrapUser || orgOfClaims(claims) != defaultOrg {
+		writeUnauthorized(w, "user no longer exists")
+		return
+	}
+	writeTokens(r.Context(), w, bootstrapUser())
+}
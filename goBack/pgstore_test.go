@@ -0,0 +1,119 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/karthikeyan-meenachisundaram/goBack/models"
+)
+
+// useBootstrapAccount is useTestStores that also signs tokens with a test secret for the
+// rest of the test and makes admin/secret the bootstrap account
+func useBootstrapAccount(t *testing.T) *testStores {
+	t.Helper()
+	s := useTestStores(t)
+	savedSecret := jwtSecret
+	t.Cleanup(func() { jwtSecret = savedSecret })
+	jwtSecret = []byte(strings.Repeat("k", minJWTSecretBytes))
+	cfg.Auth.BootstrapUser, cfg.Auth.BootstrapPassword = "admin", "secret"
+	return s
+}
+
+func TestBootstrapLogin(t *testing.T) {
+	useBootstrapAccount(t)
+
+	w := record(bootstrapLoginHandler, request(http.MethodPost, "/api/auth/login", `{"username":"admin","password":"secret"}`, "", ""))
+	expectStatus(t, w, http.StatusOK)
+	body := decode(t, w)
+	if body["role"] != "admin" || body["org"] != defaultOrg {
+		t.Errorf("login = %v", body)
+	}
+	access, _ := body["access_token"].(string)
+	if claims, err := parseToken(access, "access"); err != nil || claims.Subject != "admin" {
+		t.Errorf("access token claims = %+v, %v", claims, err)
+	}
+
+	for _, body := range []string{
+		`{"username":"admin","password":"wrong"}`,
+		`{"username":"someone","password":"secret"}`,
+		`{"username":"admin","password":"secret","org":"acme"}`,
+	} {
+		w := record(bootstrapLoginHandler, request(http.MethodPost, "/api/auth/login", body, "", ""))
+		expectStatus(t, w, http.StatusUnauthorized)
+	}
+}
+
+func TestBootstrapRefresh(t *testing.T) {
+	useBootstrapAccount(t)
+	refresh, err := issueToken(bootstrapUser(), defaultOrg, "refresh", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := record(bootstrapRefreshHandler, request(http.MethodPost, "/api/auth/refresh", `{"refresh_token":"`+refresh+`"}`, "", ""))
+	expectStatus(t, w, http.StatusOK)
+	if decode(t, w)["access_token"] == "" {
+		t.Error("no access token")
+	}
+
+	// the bootstrap account was renamed since
+	cfg.Auth.BootstrapUser = "root"
+	w = record(bootstrapRefreshHandler, request(http.MethodPost, "/api/auth/refresh", `{"refresh_token":"`+refresh+`"}`, "", ""))
+	expectStatus(t, w, http.StatusUnauthorized)
+}
+
+func TestPostgresAuthMiddleware(t *testing.T) {
+	useBootstrapAccount(t)
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if org := orgOf(r.Context()); org != defaultOrg {
+			t.Errorf("org = %q", org)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	h := refuseAPIKeys(authenticate(defaultOrgOnly(ok)))
+	token := func(org string) string {
+		access, err := issueToken(bootstrapUser(), org, "access", time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return "Bearer " + access
+	}
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		status  int
+	}{
+		{"bearer token", map[string]string{"Authorization": token(defaultOrg)}, http.StatusNoContent},
+		{"no credentials", nil, http.StatusUnauthorized},
+		{"API key", map[string]string{apiKeyHeader: "gbk_anything"}, http.StatusUnauthorized},
+		{"token of another organization", map[string]string{"Authorization": token("acme")}, http.StatusBadRequest},
+		{"X-Org-ID of another organization", map[string]string{"Authorization": token(defaultOrg), orgHeader: "acme"}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/employees", nil)
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			expectStatus(t, w, tt.status)
+		})
+	}
+}
+
+func TestPostgresRoutes(t *testing.T) {
+	s := useBootstrapAccount(t)
+	s.addEmployees(t, models.NewEmployee{EmpID: 1, EmpName: "Asha", Department: "Engg"})
+	mux := http.NewServeMux()
+	handlePostgresRoutes(mux, healthzHandler)
+
+	serveRoutes(t, mux, append(crudRoutes,
+		route{http.MethodPost, "/api/auth/login", `{"username":"admin","password":"secret"}`, http.StatusOK},
+		route{http.MethodPost, "/api/auth/refresh", `{"refresh_token":"x"}`, http.StatusUnauthorized},
+	))
+}
@@ -0,0 +1,414 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/karthikeyan-meenachisundaram/goBack/models"
+	"github.com/karthikeyan-meenachisundaram/goBack/repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/image/draw"
+)
+
+// PhotoStore keeps photo bytes under a key. The photo's metadata lives on the employee
+// document; every upload gets a new key, so stored blobs never change.
+type PhotoStore interface {
+	Put(ctx context.Context, key string, data []byte) error
+	// Get returns errPhotoNotFound when there is nothing under key
+	Get(ctx context.Context, key string) ([]byte, error)
+	Delete(ctx context.Context, key string) error
+}
+
+var (
+	errPhotoNotFound = errors.New("photo not found")
+	// errNoPhoto is the removal of the photo of an employee that has none
+	errNoPhoto = errors.New("employee has no photo")
+)
+
+// photos is the store selected by photos.store, set up by initPhotos
+var photos PhotoStore
+
+// PhotoInfo is the photo field of an Employee document
+type PhotoInfo struct {
+	Key         string    `bson:"key"`
+	ContentType string    `bson:"content_type"`
+	ETag        string    `bson:"etag"`
+	Size        int       `bson:"size"`
+	UpdatedAt   time.Time `bson:"updated_at"`
+}
+
+// initPhotos picks the configured photo store
+func initPhotos() {
+	switch cfg.Photos.Store {
+	case "dir":
+		if err := os.MkdirAll(cfg.Photos.Dir, 0o755); err != nil {
+			fatal("initPhotos: create photo dir", "dir", cfg.Photos.Dir, "err", err)
+		}
+		photos = dirPhotoStore{dir: cfg.Photos.Dir}
+	default:
+		photos = gridfsPhotoStore{}
+	}
+}
+
+// gridfsPhotoStore keeps photos in the "photos" GridFS bucket, using the key as file id
+type gridfsPhotoStore struct{}
+
+func (gridfsPhotoStore) bucket(ctx context.Context) (*gridfs.Bucket, error) {
+	b, err := gridfs.NewBucket(database(ctx), options.GridFSBucket().SetName("photos"))
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = b.SetReadDeadline(deadline)
+		_ = b.SetWriteDeadline(deadline)
+	}
+	return b, nil
+}
+
+func (s gridfsPhotoStore) Put(ctx context.Context, key string, data []byte) error {
+	b, err := s.bucket(ctx)
+	if err != nil {
+		return err
+	}
+	return b.UploadFromStreamWithID(key, key, bytes.NewReader(data))
+}
+
+func (s gridfsPhotoStore) Get(ctx context.Context, key string) ([]byte, error) {
+	b, err := s.bucket(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if _, err := b.DownloadToStream(key, &buf); err != nil {
+		if errors.Is(err, gridfs.ErrFileNotFound) {
+			return nil, errPhotoNotFound
+		}
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (s gridfsPhotoStore) Delete(ctx context.Context, key string) error {
+	b, err := s.bucket(ctx)
+	if err != nil {
+		return err
+	}
+	if err := b.DeleteContext(ctx, key); err != nil && !errors.Is(err, gridfs.ErrFileNotFound) {
+		return err
+	}
+	return nil
+}
+
+// dirPhotoStore keeps photos as files in a directory, those of organizations other than
+// the default one in a subdirectory named after the organization
+type dirPhotoStore struct {
+	dir string
+}
+
+func (s dirPhotoStore) path(ctx context.Context, key string) string {
+	if org := orgOf(ctx); org != defaultOrg {
+		return filepath.Join(s.dir, org, key)
+	}
+	return filepath.Join(s.dir, key)
+}
+
+func (s dirPhotoStore) Put(ctx context.Context, key string, data []byte) error {
+	p := s.path(ctx, key)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(p, data, 0o644)
+}
+
+func (s dirPhotoStore) Get(ctx context.Context, key string) ([]byte, error) {
+	data, err := os.ReadFile(s.path(ctx, key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errPhotoNotFound
+	}
+	return data, err
+}
+
+func (s dirPhotoStore) Delete(ctx context.Context, key string) error {
+	if err := os.Remove(s.path(ctx, key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// preparePhoto checks that data is a JPEG or PNG image and scales it down to
+// photos.max_dimension, returning the bytes to store and their content type
+func preparePhoto(data []byte) ([]byte, string, error) {
+	contentType := http.DetectContentType(data)
+	if contentType != "image/jpeg" && contentType != "image/png" {
+		return nil, "", fmt.Errorf("photo must be a JPEG or PNG image, got %s", contentType)
+	}
+	conf, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("unreadable image: %v", err)
+	}
+	limit := cfg.Photos.MaxDimension
+	if limit == 0 || (conf.Width <= limit && conf.Height <= limit) {
+		return data, contentType, nil
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("unreadable image: %v", err)
+	}
+	w, h := conf.Width, conf.Height
+	if w >= h {
+		w, h = limit, max(1, h*limit/w)
+	} else {
+		w, h = max(1, w*limit/h), limit
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), draw.Over, nil)
+
+	var out bytes.Buffer
+	if contentType == "image/png" {
+		err = png.Encode(&out, dst)
+	} else {
+		err = jpeg.Encode(&out, dst, &jpeg.Options{Quality: 85})
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return out.Bytes(), contentType, nil
+}
+
+// ---------------- Handlers ----------------
+
+// employeePhoto handles GET, POST (multipart field "photo") and DELETE on
+// /api/employees/{id}/photo
+func employeePhoto(w http.ResponseWriter, r *http.Request, empId int) {
+	switch r.Method {
+	case http.MethodGet:
+		getPhoto(w, r, empId)
+	case http.MethodPost, http.MethodPut:
+		uploadPhoto(w, r, empId)
+	case http.MethodDelete:
+		deletePhoto(w, r, empId)
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// findPhoto returns the photo metadata of a live employee; ErrNoDocuments when the
+// employee doesn't exist, a nil info when it has no photo
+func findPhoto(ctx context.Context, empId int) (*PhotoInfo, error) {
+	var emp struct {
+		Photo *PhotoInfo `bson:"photo"`
+	}
+	err := coll(ctx, "Employee").FindOne(ctx, repository.Live(bson.M{"emp_id": empId}),
+		options.FindOne().SetProjection(bson.M{"photo": 1})).Decode(&emp)
+	return emp.Photo, err
+}
+
+func getPhoto(w http.ResponseWriter, r *http.Request, empId int) {
+	ctx := r.Context()
+
+	info, err := findPhoto(ctx, empId)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			httpError(w, "employee not found", http.StatusNotFound)
+			return
+		}
+		storeError(w, "find photo", err)
+		return
+	}
+	if info == nil {
+		httpError(w, "employee has no photo", http.StatusNotFound)
+		return
+	}
+
+	etag := `"` + info.ETag + `"`
+	cacheHeaders := func() {
+		w.Header().Set("ETag", etag)
+		// photo_url carries ?v=<etag>, so that URL never changes content
+		if r.URL.Query().Get("v") == info.ETag {
+			w.Header().Set("Cache-Control", "private, max-age=31536000, immutable")
+		} else {
+			w.Header().Set("Cache-Control", "private, no-cache")
+		}
+	}
+	if r.Header.Get("If-None-Match") == etag {
+		cacheHeaders()
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	data, err := photos.Get(ctx, info.Key)
+	if err != nil {
+		if err == errPhotoNotFound {
+			httpError(w, "photo not found", http.StatusNotFound)
+			return
+		}
+		storeError(w, "read photo", err)
+		return
+	}
+	cacheHeaders()
+	w.Header().Set("Content-Type", info.ContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Header().Set("Last-Modified", info.UpdatedAt.UTC().Format(http.TimeFormat))
+	_, _ = w.Write(data)
+}
+
+func uploadPhoto(w http.ResponseWriter, r *http.Request, empId int) {
+	// room for the multipart framing around the file
+	r.Body = http.MaxBytesReader(w, r.Body, cfg.Photos.MaxBytes+64<<10)
+	file, _, err := r.FormFile("photo")
+	if err != nil {
+		var tooBig *http.MaxBytesError
+		if errors.As(err, &tooBig) {
+			httpError(w, fmt.Sprintf("photo is larger than %d bytes", cfg.Photos.MaxBytes), http.StatusRequestEntityTooLarge)
+			return
+		}
+		httpError(w, "multipart form with a \"photo\" file is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, cfg.Photos.MaxBytes+1))
+	if err != nil {
+		httpError(w, "read photo: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if int64(len(data)) > cfg.Photos.MaxBytes {
+		httpError(w, fmt.Sprintf("photo is larger than %d bytes", cfg.Photos.MaxBytes), http.StatusRequestEntityTooLarge)
+		return
+	}
+	data, contentType, err := preparePhoto(data)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	ctx := r.Context()
+
+	sum := sha256.Sum256(data)
+	etag := hex.EncodeToString(sum[:8])
+	info := PhotoInfo{
+		Key:         fmt.Sprintf("emp-%d-%s", empId, etag),
+		ContentType: contentType,
+		ETag:        etag,
+		Size:        len(data),
+		UpdatedAt:   time.Now().UTC(),
+	}
+	if err := photos.Put(ctx, info.Key, data); err != nil && !mongo.IsDuplicateKeyError(err) {
+		storeError(w, "store photo", err)
+		return
+	}
+
+	// in approval mode non-admin uploads wait for an approver, the photo stored already
+	if approvalMode() && !isAdmin(r) {
+		change := &models.PhotoChange{Key: info.Key, ContentType: info.ContentType, ETag: info.ETag, Size: info.Size}
+		if !submitChangeRequest(ctx, w, r, models.ChangeRequest{Kind: "photo", EmpID: empId, Photo: change}) {
+			_ = photos.Delete(ctx, info.Key)
+		}
+		return
+	}
+
+	if err := setPhoto(ctx, empId, info, actorFromRequest(r)); err != nil {
+		_ = photos.Delete(ctx, info.Key)
+		if errors.Is(err, models.ErrNotFound) {
+			httpError(w, "employee not found", http.StatusNotFound)
+			return
+		}
+		storeError(w, "update photo", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(bson.M{
+		"message":   "Photo uploaded successfully",
+		"photo_url": fmt.Sprintf("/api/v1/employees/%d/photo?v=%s", empId, etag),
+	})
+}
+
+func deletePhoto(w http.ResponseWriter, r *http.Request, empId int) {
+	ctx := r.Context()
+
+	// in approval mode non-admin removals wait for an approver
+	if approvalMode() && !isAdmin(r) {
+		submitChangeRequest(ctx, w, r, models.ChangeRequest{Kind: "photo", EmpID: empId})
+		return
+	}
+
+	if err := removePhoto(ctx, empId, actorFromRequest(r)); err != nil {
+		switch {
+		case errors.Is(err, models.ErrNotFound):
+			httpError(w, "employee not found", http.StatusNotFound)
+		case errors.Is(err, errNoPhoto):
+			httpError(w, err.Error(), http.StatusNotFound)
+		default:
+			storeError(w, "remove photo", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(bson.M{"message": "Photo deleted successfully"})
+}
+
+// setPhoto makes info, already in the photo store, the photo of a live employee as
+// actor and deletes the one it replaces; models.ErrNotFound when there is no such employee
+func setPhoto(ctx context.Context, empId int, info PhotoInfo, actor string) error {
+	var before struct {
+		Photo *PhotoInfo `bson:"photo"`
+	}
+	err := coll(ctx, "Employee").FindOneAndUpdate(ctx, repository.Live(bson.M{"emp_id": empId}), repository.BumpVersion(bson.M{"$set": bson.M{"photo": info}}),
+		options.FindOneAndUpdate().SetProjection(bson.M{"photo": 1})).Decode(&before)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return models.ErrNotFound
+		}
+		return err
+	}
+	if before.Photo != nil && before.Photo.Key != info.Key {
+		if err := photos.Delete(ctx, before.Photo.Key); err != nil {
+			logFor(ctx).Warn("delete replaced photo", "key", before.Photo.Key, "err", err)
+		}
+	}
+	_ = recordAudit(ctx, "photo", empId, actor, bson.M{"etag": info.ETag, "size": info.Size})
+	return nil
+}
+
+// removePhoto removes the photo of a live employee as actor; models.ErrNotFound when
+// there is no such employee, errNoPhoto when it has none
+func removePhoto(ctx context.Context, empId int, actor string) error {
+	var before struct {
+		Photo *PhotoInfo `bson:"photo"`
+	}
+	err := coll(ctx, "Employee").FindOneAndUpdate(ctx, repository.Live(bson.M{"emp_id": empId}), repository.BumpVersion(bson.M{"$unset": bson.M{"photo": ""}}),
+		options.FindOneAndUpdate().SetProjection(bson.M{"photo": 1})).Decode(&before)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return models.ErrNotFound
+		}
+		return err
+	}
+	if before.Photo == nil {
+		return errNoPhoto
+	}
+	if err := photos.Delete(ctx, before.Photo.Key); err != nil {
+		logFor(ctx).Warn("delete photo", "key", before.Photo.Key, "err", err)
+	}
+	_ = recordAudit(ctx, "photo_removed", empId, actor, nil)
+	return nil
+}
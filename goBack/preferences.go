@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Preferences are per-user UI settings for the SPA
+type Preferences struct {
+	User           string    `bson:"user" json:"-"`
+	VisibleColumns []string  `bson:"visible_columns" json:"visible_columns"`
+	PageSize       int       `bson:"page_size" json:"page_size"`
+	DefaultSort    string    `bson:"default_sort" json:"default_sort"`
+	Theme          string    `bson:"theme" json:"theme"` // light|dark|system
+	UpdatedAt      time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// defaultPreferences are returned until the user saves their own
+func defaultPreferences(user string) Preferences {
+	return Preferences{
+		User:           user,
+		VisibleColumns: []string{"emp_id", "emp_name", "department", "language"},
+		PageSize:       25,
+		DefaultSort:    "emp_id",
+		Theme:          "system",
+	}
+}
+
+// check validates the preferences
+func (p Preferences) check() error {
+	for _, c := range p.VisibleColumns {
+		if !listColumns[c] {
+			return fmt.Errorf("unknown column %q", c)
+		}
+	}
+	if p.PageSize < 1 || p.PageSize > 500 {
+		return fmt.Errorf("page_size must be between 1 and 500")
+	}
+	if p.DefaultSort != "" {
+		if _, err := parseSort(p.DefaultSort); err != nil {
+			return err
+		}
+	}
+	switch p.Theme {
+	case "light", "dark", "system":
+	default:
+		return fmt.Errorf("theme must be light, dark or system")
+	}
+	return nil
+}
+
+// preferencesHandler handles GET and PUT on /api/me/preferences
+func preferencesHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := actorFromRequest(r)
+
+	switch r.Method {
+	case http.MethodGet:
+		p := defaultPreferences(user)
+		err := coll(ctx, "Preferences").FindOne(ctx, bson.M{"user": user}).Decode(&p)
+		if err != nil && err != mongo.ErrNoDocuments {
+			storeError(w, "find preferences", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(p)
+	case http.MethodPut:
+		// fields left out of the body keep their defaults
+		p := defaultPreferences(user)
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			httpError(w, "invalid input: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := p.check(); err != nil {
+			httpError(w, "invalid preferences: "+err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		p.User = user
+		p.UpdatedAt = time.Now().UTC()
+		if _, err := coll(ctx, "Preferences").ReplaceOne(ctx, bson.M{"user": user}, p, options.Replace().SetUpsert(true)); err != nil {
+			storeError(w, "save preferences", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(p)
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,642 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/karthikeyan-meenachisundaram/goBack/models"
+	"github.com/karthikeyan-meenachisundaram/goBack/repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	maxProjectNameLength = 100
+	maxProjectDescLength = 1000
+	maxProjectRoleLength = 64
+)
+
+// projectStatuses are the states a project can be in
+var projectStatuses = []string{"planned", "active", "on_hold", "completed"}
+
+// Project is a row of the Projects collection
+type Project struct {
+	ProjectID   int        `bson:"project_id" json:"project_id"`
+	Name        string     `bson:"name" json:"name"`
+	Description string     `bson:"description,omitempty" json:"description,omitempty"`
+	Status      string     `bson:"status" json:"status"`
+	StartDate   *time.Time `bson:"start_date,omitempty" json:"start_date,omitempty"`
+	EndDate     *time.Time `bson:"end_date,omitempty" json:"end_date,omitempty"`
+	CreatedAt   time.Time  `bson:"created_at" json:"created_at"`
+	CreatedBy   string     `bson:"created_by" json:"created_by"`
+	Members     *int       `bson:"members,omitempty" json:"members,omitempty"` // current members, in lists
+}
+
+// ProjectInput is the create/update body of a project; nil fields are left unchanged on
+// update, and an empty date removes it
+type ProjectInput struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+	Status      *string `json:"status"`
+	StartDate   *string `json:"start_date"` // YYYY-MM-DD
+	EndDate     *string `json:"end_date"`   // YYYY-MM-DD
+}
+
+// Assignment is an employee's membership of a project in the ProjectMembers collection.
+// It is current until its end_date; ending one keeps it as history.
+type Assignment struct {
+	ProjectID  int        `bson:"project_id" json:"project_id"`
+	EmpID      int        `bson:"emp_id" json:"emp_id"`
+	Role       string     `bson:"role,omitempty" json:"role,omitempty"`
+	Allocation int        `bson:"allocation" json:"allocation"` // percent of the employee's time
+	StartDate  time.Time  `bson:"start_date" json:"start_date"`
+	EndDate    *time.Time `bson:"end_date,omitempty" json:"end_date,omitempty"`
+	AssignedAt time.Time  `bson:"assigned_at" json:"assigned_at"`
+	AssignedBy string     `bson:"assigned_by" json:"assigned_by"`
+}
+
+// ProjectMember is an assignment as the members list shows it
+type ProjectMember struct {
+	Assignment `bson:",inline"`
+	EmpName    string `bson:"emp_name" json:"emp_name"`
+}
+
+// EmployeeProject is one of an employee's current assignments with its project
+type EmployeeProject struct {
+	ProjectID  int       `bson:"project_id" json:"project_id"`
+	Name       string    `bson:"name" json:"name"`
+	Status     string    `bson:"status" json:"status"`
+	Role       string    `bson:"role,omitempty" json:"role,omitempty"`
+	Allocation int       `bson:"allocation" json:"allocation"`
+	StartDate  time.Time `bson:"start_date" json:"start_date"`
+}
+
+// AssignmentInput is the body of POST /api/projects/{id}/members and of the PUT on a member
+type AssignmentInput struct {
+	EmpID      int     `json:"emp_id"` // POST only
+	Role       *string `json:"role"`
+	Allocation *int    `json:"allocation"`
+	StartDate  *string `json:"start_date"` // YYYY-MM-DD, POST only, defaults to today
+}
+
+var (
+	// errOverAllocated is returned when an assignment would take an employee over 100%
+	errOverAllocated = errors.New("employee would be allocated over 100%")
+	// errAlreadyAssigned is returned when an employee is already a current member
+	errAlreadyAssigned = errors.New("employee is already assigned to the project")
+)
+
+// parseProjectDate reads an optional YYYY-MM-DD date; "" is none
+func parseProjectDate(v string) (*time.Time, error) {
+	if v = strings.TrimSpace(v); v == "" {
+		return nil, nil
+	}
+	t, err := time.Parse("2006-01-02", v)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// validate checks the input and applies it to p; on create name is required
+func (in ProjectInput) validate(p *Project, creating bool) map[string]string {
+	errs := map[string]string{}
+	if in.Name != nil || creating {
+		name := ""
+		if in.Name != nil {
+			name = strings.TrimSpace(*in.Name)
+		}
+		switch {
+		case name == "":
+			errs["name"] = "is required"
+		case utf8.RuneCountInString(name) > maxProjectNameLength:
+			errs["name"] = fmt.Sprintf("must be at most %d characters", maxProjectNameLength)
+		}
+		p.Name = name
+	}
+	if in.Description != nil {
+		p.Description = strings.TrimSpace(*in.Description)
+		if utf8.RuneCountInString(p.Description) > maxProjectDescLength {
+			errs["description"] = fmt.Sprintf("must be at most %d characters", maxProjectDescLength)
+		}
+	}
+	if in.Status != nil || creating {
+		status := "active"
+		if in.Status != nil && *in.Status != "" {
+			status = *in.Status
+		}
+		if !slices.Contains(projectStatuses, status) {
+			errs["status"] = "must be " + strings.Join(projectStatuses, ", ")
+		}
+		p.Status = status
+	}
+	for _, d := range []struct {
+		field string
+		in    *string
+		out   **time.Time
+	}{{"start_date", in.StartDate, &p.StartDate}, {"end_date", in.EndDate, &p.EndDate}} {
+		if d.in == nil {
+			continue
+		}
+		t, err := parseProjectDate(*d.in)
+		if err != nil {
+			errs[d.field] = "expected YYYY-MM-DD"
+			continue
+		}
+		*d.out = t
+	}
+	if p.StartDate != nil && p.EndDate != nil && p.EndDate.Before(*p.StartDate) {
+		errs["end_date"] = "must not be before start_date"
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// currentAssignment is the filter for assignments that haven't ended
+func currentAssignment(filter bson.M) bson.M {
+	filter["$or"] = bson.A{
+		bson.M{"end_date": bson.M{"$exists": false}},
+		bson.M{"end_date": bson.M{"$gt": time.Now().UTC()}},
+	}
+	return filter
+}
+
+// insertProject allocates a project_id and stores a new project
+func insertProject(ctx context.Context, p Project) (Project, error) {
+	var counter struct {
+		Seq int `bson:"seq"`
+	}
+	err := coll(ctx, "Counters").FindOneAndUpdate(ctx,
+		bson.M{"_id": "project_id"},
+		bson.M{"$inc": bson.M{"seq": 1}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&counter)
+	if err != nil {
+		return Project{}, err
+	}
+	p.ProjectID = counter.Seq
+	p.CreatedAt = time.Now().UTC()
+	_, err = coll(ctx, "Projects").InsertOne(ctx, p)
+	return p, err
+}
+
+// allocatedElsewhere is the employee's current allocation on projects other than projectId
+func allocatedElsewhere(ctx context.Context, empId, projectId int) (int, error) {
+	cur, err := coll(ctx, "ProjectMembers").Aggregate(ctx, mongo.Pipeline{
+		bson.D{{Key: "$match", Value: currentAssignment(bson.M{"emp_id": empId, "project_id": bson.M{"$ne": projectId}})}},
+		bson.D{{Key: "$group", Value: bson.M{"_id": nil, "total": bson.M{"$sum": "$allocation"}}}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer cur.Close(ctx)
+	var out []struct {
+		Total int `bson:"total"`
+	}
+	if err := cur.All(ctx, &out); err != nil || len(out) == 0 {
+		return 0, err
+	}
+	return out[0].Total, nil
+}
+
+// assignEmployee adds an employee to a project, or with update changes the role and
+// allocation of their current assignment, keeping their total allocation within 100%
+func assignEmployee(ctx context.Context, a Assignment, update bool) error {
+	return withTransaction(ctx, func(sc mongo.SessionContext) error {
+		other, err := allocatedElsewhere(sc, a.EmpID, a.ProjectID)
+		if err != nil {
+			return err
+		}
+		if other+a.Allocation > 100 {
+			return fmt.Errorf("%w: %d%% already on other projects", errOverAllocated, other)
+		}
+		filter := currentAssignment(bson.M{"project_id": a.ProjectID, "emp_id": a.EmpID})
+		if update {
+			res, err := coll(sc, "ProjectMembers").UpdateOne(sc, filter, bson.M{"$set": bson.M{"role": a.Role, "allocation": a.Allocation}})
+			if err == nil && res.MatchedCount == 0 {
+				err = mongo.ErrNoDocuments
+			}
+			return err
+		}
+		n, err := coll(sc, "ProjectMembers").CountDocuments(sc, filter)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			return errAlreadyAssigned
+		}
+		_, err = coll(sc, "ProjectMembers").InsertOne(sc, a)
+		return err
+	})
+}
+
+// checkAssignment validates role and allocation into a; allocation is required unless
+// the assignment keeps its current one
+func (in AssignmentInput) checkAssignment(a *Assignment) map[string]string {
+	errs := map[string]string{}
+	if in.Role != nil {
+		a.Role = strings.TrimSpace(*in.Role)
+		if utf8.RuneCountInString(a.Role) > maxProjectRoleLength {
+			errs["role"] = fmt.Sprintf("must be at most %d characters", maxProjectRoleLength)
+		}
+	}
+	if in.Allocation != nil {
+		a.Allocation = *in.Allocation
+	}
+	if a.Allocation < 1 || a.Allocation > 100 {
+		errs["allocation"] = "must be a percentage between 1 and 100"
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// ---------------- Handlers ----------------
+
+// projectsHandler handles GET (list, ?status=) and POST (create) on /api/projects
+func projectsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	switch r.Method {
+	case http.MethodGet:
+		match := bson.M{}
+		if v := r.URL.Query().Get("status"); v != "" {
+			match["status"] = bson.M{"$in": strings.Split(v, ",")}
+		}
+		cur, err := coll(ctx, "Projects").Aggregate(ctx, mongo.Pipeline{
+			bson.D{{Key: "$match", Value: match}},
+			bson.D{{Key: "$lookup", Value: bson.D{
+				{Key: "from", Value: "ProjectMembers"},
+				{Key: "localField", Value: "project_id"},
+				{Key: "foreignField", Value: "project_id"},
+				{Key: "pipeline", Value: bson.A{bson.D{{Key: "$match", Value: currentAssignment(bson.M{})}}}},
+				{Key: "as", Value: "current"},
+			}}},
+			bson.D{{Key: "$set", Value: bson.M{"members": bson.M{"$size": "$current"}}}},
+			bson.D{{Key: "$sort", Value: bson.D{{Key: "name", Value: 1}}}},
+		})
+		if err != nil {
+			storeError(w, "find projects", err)
+			return
+		}
+		defer cur.Close(ctx)
+		list := []Project{}
+		if err := cur.All(ctx, &list); err != nil {
+			storeError(w, "cursor all", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(list)
+	case http.MethodPost:
+		var input ProjectInput
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			httpError(w, "invalid input: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		p := Project{CreatedBy: actorFromRequest(r)}
+		if errs := input.validate(&p, true); len(errs) > 0 {
+			writeValidationErrors(w, errs)
+			return
+		}
+		p, err := insertProject(ctx, p)
+		if err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				httpError(w, "project already exists: "+p.Name, http.StatusConflict)
+				return
+			}
+			storeError(w, "insert project", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(p)
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// projectByIDHandler handles /api/projects/{id}[/members[/{emp_id}]]
+func projectByIDHandler(w http.ResponseWriter, r *http.Request) {
+	// path: /api/projects/{id}[/{action}[/{sub}]]
+	idStr, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/projects/"), "/")
+	action, sub, _ := strings.Cut(rest, "/")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		httpError(w, "invalid project id", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	var p Project
+	if err := coll(ctx, "Projects").FindOne(ctx, bson.M{"project_id": id}).Decode(&p); err != nil {
+		if err == mongo.ErrNoDocuments {
+			httpError(w, "project not found", http.StatusNotFound)
+			return
+		}
+		storeError(w, "find project", err)
+		return
+	}
+
+	switch {
+	case action == "":
+		project(w, r, p)
+	case action == "members" && sub == "":
+		projectMembers(w, r, p)
+	case action == "members":
+		empId, err := strconv.Atoi(sub)
+		if err != nil {
+			httpError(w, "invalid emp_id", http.StatusBadRequest)
+			return
+		}
+		projectMember(w, r, p, empId)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// project handles GET, PUT and DELETE on /api/projects/{id}. A project with current
+// members can't be deleted; end their assignments or mark it completed instead.
+func project(w http.ResponseWriter, r *http.Request, p Project) {
+	ctx := r.Context()
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(p)
+	case http.MethodPut, http.MethodPatch:
+		var input ProjectInput
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			httpError(w, "invalid input: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errs := input.validate(&p, false); len(errs) > 0 {
+			writeValidationErrors(w, errs)
+			return
+		}
+		if _, err := coll(ctx, "Projects").ReplaceOne(ctx, bson.M{"project_id": p.ProjectID}, p); err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				httpError(w, "project already exists: "+p.Name, http.StatusConflict)
+				return
+			}
+			storeError(w, "update project", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(p)
+	case http.MethodDelete:
+		n, err := coll(ctx, "ProjectMembers").CountDocuments(ctx, currentAssignment(bson.M{"project_id": p.ProjectID}))
+		if err != nil {
+			storeError(w, "count members", err)
+			return
+		}
+		if n > 0 {
+			writeError(w, http.StatusConflict, "project still has members; end their assignments first", bson.M{"members": n})
+			return
+		}
+		err = withTransaction(ctx, func(sc mongo.SessionContext) error {
+			if _, err := coll(sc, "ProjectMembers").DeleteMany(sc, bson.M{"project_id": p.ProjectID}); err != nil {
+				return err
+			}
+			_, err := coll(sc, "Projects").DeleteOne(sc, bson.M{"project_id": p.ProjectID})
+			return err
+		})
+		if err != nil {
+			storeError(w, "delete project", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(bson.M{"message": "Project deleted successfully"})
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// projectMembers handles GET (current members, ?all=true with past ones) and POST (assign
+// an employee) on /api/projects/{id}/members. An employee's allocations across current
+// assignments can't add up to more than 100%.
+func projectMembers(w http.ResponseWriter, r *http.Request, p Project) {
+	ctx := r.Context()
+
+	switch r.Method {
+	case http.MethodGet:
+		filter := bson.M{"project_id": p.ProjectID}
+		if r.URL.Query().Get("all") != "true" {
+			filter = currentAssignment(filter)
+		}
+		cur, err := coll(ctx, "ProjectMembers").Aggregate(ctx, mongo.Pipeline{
+			bson.D{{Key: "$match", Value: filter}},
+			bson.D{{Key: "$lookup", Value: bson.D{
+				{Key: "from", Value: "Employee"},
+				{Key: "localField", Value: "emp_id"},
+				{Key: "foreignField", Value: "emp_id"},
+				{Key: "as", Value: "emp"},
+			}}},
+			bson.D{{Key: "$set", Value: bson.M{"emp_name": bson.M{"$arrayElemAt": bson.A{"$emp.emp_name", 0}}}}},
+			bson.D{{Key: "$sort", Value: bson.D{{Key: "start_date", Value: 1}, {Key: "emp_id", Value: 1}}}},
+		})
+		if err != nil {
+			storeError(w, "find members", err)
+			return
+		}
+		defer cur.Close(ctx)
+		items := []ProjectMember{}
+		if err := cur.All(ctx, &items); err != nil {
+			storeError(w, "cursor all", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(items)
+	case http.MethodPost:
+		var input AssignmentInput
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			httpError(w, "invalid input: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		now := time.Now().UTC()
+		a := Assignment{
+			ProjectID:  p.ProjectID,
+			EmpID:      input.EmpID,
+			StartDate:  now.Truncate(24 * time.Hour),
+			AssignedAt: now,
+			AssignedBy: actorFromRequest(r),
+		}
+		errs := input.checkAssignment(&a)
+		if input.StartDate != nil {
+			if t, err := parseProjectDate(*input.StartDate); err != nil {
+				errs = mergeFieldErrors(errs, "", map[string]string{"start_date": "expected YYYY-MM-DD"})
+			} else if t != nil {
+				a.StartDate = *t
+			}
+		}
+		if len(errs) > 0 {
+			writeValidationErrors(w, errs)
+			return
+		}
+		if p.Status == "completed" {
+			httpError(w, "project is completed", http.StatusConflict)
+			return
+		}
+		var emp struct {
+			Status string `bson:"status"`
+		}
+		if err := coll(ctx, "Employee").FindOne(ctx, repository.Live(bson.M{"emp_id": a.EmpID})).Decode(&emp); err != nil {
+			if err == mongo.ErrNoDocuments {
+				writeValidationErrors(w, map[string]string{"emp_id": fmt.Sprintf("employee %d not found", a.EmpID)})
+				return
+			}
+			storeError(w, "find employee", err)
+			return
+		}
+		if emp.Status == "terminated" {
+			writeValidationErrors(w, map[string]string{"emp_id": "employee is terminated"})
+			return
+		}
+		if !writeAssignment(ctx, w, r, a, false) {
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(a)
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// projectMember handles PUT (role, allocation) and DELETE (end the assignment today) on
+// /api/projects/{id}/members/{emp_id}
+func projectMember(w http.ResponseWriter, r *http.Request, p Project, empId int) {
+	ctx := r.Context()
+
+	var a Assignment
+	err := coll(ctx, "ProjectMembers").FindOne(ctx, currentAssignment(bson.M{"project_id": p.ProjectID, "emp_id": empId})).Decode(&a)
+	if err == mongo.ErrNoDocuments {
+		httpError(w, fmt.Sprintf("employee %d is not a member of the project", empId), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		storeError(w, "find member", err)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut, http.MethodPatch:
+		var input AssignmentInput
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			httpError(w, "invalid input: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errs := input.checkAssignment(&a); len(errs) > 0 {
+			writeValidationErrors(w, errs)
+			return
+		}
+		if !writeAssignment(ctx, w, r, a, true) {
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(a)
+	case http.MethodDelete:
+		now := time.Now().UTC()
+		_, err := coll(ctx, "ProjectMembers").UpdateOne(ctx, currentAssignment(bson.M{"project_id": p.ProjectID, "emp_id": empId}),
+			bson.M{"$set": bson.M{"end_date": now}})
+		if err != nil {
+			storeError(w, "end assignment", err)
+			return
+		}
+		_ = recordAudit(ctx, "project_unassigned", empId, actorFromRequest(r), bson.M{"project_id": p.ProjectID, "project": p.Name})
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(bson.M{"message": "Assignment ended", "end_date": now})
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// writeAssignment stores an assignment and audits it, answering the error when it
+// can't; it reports whether it was written
+func writeAssignment(ctx context.Context, w http.ResponseWriter, r *http.Request, a Assignment, update bool) bool {
+	err := assignEmployee(ctx, a, update)
+	switch {
+	case errors.Is(err, errOverAllocated):
+		writeValidationErrors(w, map[string]string{"allocation": err.Error()})
+		return false
+	case errors.Is(err, errAlreadyAssigned):
+		httpError(w, err.Error(), http.StatusConflict)
+		return false
+	case err == mongo.ErrNoDocuments:
+		httpError(w, fmt.Sprintf("employee %d is not a member of the project", a.EmpID), http.StatusNotFound)
+		return false
+	case err != nil:
+		storeError(w, "assign employee", err)
+		return false
+	}
+	action := "project_assigned"
+	if update {
+		action = "project_assignment_changed"
+	}
+	_ = recordAudit(ctx, action, a.EmpID, actorFromRequest(r), bson.M{
+		"project_id": a.ProjectID,
+		"role":       a.Role,
+		"allocation": a.Allocation,
+	})
+	return true
+}
+
+// employeeProjects handles GET /api/employees/{id}/projects: the employee's current
+// projects with their role and allocation, and the total allocated
+func employeeProjects(w http.ResponseWriter, r *http.Request, empId int) {
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	ctx := r.Context()
+
+	if _, err := employees.Version(ctx, empId); err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			httpError(w, fmt.Sprintf("Employee %d not found", empId), http.StatusNotFound)
+			return
+		}
+		storeError(w, "find employee", err)
+		return
+	}
+	cur, err := coll(ctx, "ProjectMembers").Aggregate(ctx, mongo.Pipeline{
+		bson.D{{Key: "$match", Value: currentAssignment(bson.M{"emp_id": empId})}},
+		bson.D{{Key: "$lookup", Value: bson.D{
+			{Key: "from", Value: "Projects"},
+			{Key: "localField", Value: "project_id"},
+			{Key: "foreignField", Value: "project_id"},
+			{Key: "as", Value: "project"},
+		}}},
+		bson.D{{Key: "$set", Value: bson.M{
+			"name":   bson.M{"$arrayElemAt": bson.A{"$project.name", 0}},
+			"status": bson.M{"$arrayElemAt": bson.A{"$project.status", 0}},
+		}}},
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "allocation", Value: -1}, {Key: "project_id", Value: 1}}}},
+	})
+	if err != nil {
+		storeError(w, "find projects", err)
+		return
+	}
+	defer cur.Close(ctx)
+	items := []EmployeeProject{}
+	if err := cur.All(ctx, &items); err != nil {
+		storeError(w, "cursor all", err)
+		return
+	}
+	total := 0
+	for _, it := range items {
+		total += it.Allocation
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(bson.M{"emp_id": empId, "projects": items, "allocated": total})
+}
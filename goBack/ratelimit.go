@@ -0,0 +1,193 @@
+package main
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// bucket is a token bucket for one client
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter hands out tokens per client key, refilling at rate tokens/second up to burst
+type rateLimiter struct {
+	mu      sync.Mutex
+	rate    float64
+	burst   float64
+	buckets map[string]*bucket
+}
+
+func newRateLimiter(perMinute, burst int) *rateLimiter {
+	rl := &rateLimiter{
+		rate:    float64(perMinute) / 60,
+		burst:   float64(burst),
+		buckets: map[string]*bucket{},
+	}
+	go rl.sweep()
+	return rl
+}
+
+// take spends one token for key and reports whether it was allowed, the tokens left,
+// how long until the next token and how long until the bucket is full again
+func (rl *rateLimiter) take(key string) (ok bool, remaining int, retryAfter, reset time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+	b, found := rl.buckets[key]
+	if !found {
+		b = &bucket{tokens: rl.burst, last: now}
+		rl.buckets[key] = b
+	}
+	b.tokens = math.Min(rl.burst, b.tokens+now.Sub(b.last).Seconds()*rl.rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		ok = true
+	} else {
+		retryAfter = time.Duration((1 - b.tokens) / rl.rate * float64(time.Second))
+	}
+	reset = time.Duration((rl.burst - b.tokens) / rl.rate * float64(time.Second))
+	return ok, int(b.tokens), retryAfter, reset
+}
+
+// sweep drops buckets that have been idle long enough to be full again
+func (rl *rateLimiter) sweep() {
+	for range time.Tick(time.Minute) {
+		full := time.Duration(rl.burst / rl.rate * float64(time.Second))
+		rl.mu.Lock()
+		for k, b := range rl.buckets {
+			if time.Since(b.last) > full {
+				delete(rl.buckets, k)
+			}
+		}
+		rl.mu.Unlock()
+	}
+}
+
+// clientIP returns the caller's address, honoring X-Forwarded-For with rate_limit.trust_proxy
+func clientIP(r *http.Request) string {
+	if cfg.RateLimit.TrustProxy {
+		if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+			ip, _, _ := strings.Cut(fwd, ",")
+			return strings.TrimSpace(ip)
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// limitKey is the bucket a request counts against: the token's user (or the API key)
+// with rate_limit.key token and a valid access token, the client IP otherwise. rateLimit
+// runs before authenticate, so the token is verified here; made-up tokens don't get fresh
+// buckets. API keys only count as verified once authenticate has cached them, which
+// spares the database a lookup for every made-up key.
+func limitKey(r *http.Request) string {
+	if cfg.RateLimit.Key == "token" {
+		if t := bearerToken(r); t != "" {
+			if claims, err := parseToken(t, "access"); err == nil {
+				return "user:" + orgOfClaims(claims) + "/" + claims.Subject
+			}
+		} else if key := r.Header.Get(apiKeyHeader); key != "" {
+			if claims, ok := cachedAPIKeyClaims(key); ok {
+				return "user:" + orgOfClaims(claims) + "/" + claims.Subject
+			}
+		}
+	}
+	return "ip:" + clientIP(r)
+}
+
+// isMutation reports whether r changes data. GraphQL queries are POSTs too, so
+// /api/graphql counts as a read; its mutations invalidate what they change themselves.
+func isMutation(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return r.URL.Path != "/api/graphql"
+	}
+	return false
+}
+
+// requestLimit is one of the token buckets of rate_limit
+type requestLimit struct {
+	perMinute int
+	rl        *rateLimiter
+	mutations bool // only counts mutations
+}
+
+// newRequestLimits are fresh buckets for the limits of rate_limit: one for every request
+// (rate_limit.per_minute/burst) and a stricter one for mutations
+// (rate_limit.mutation_per_minute/mutation_burst); either is off at 0 per minute
+func newRequestLimits() []requestLimit {
+	var limits []requestLimit
+	if c := cfg.RateLimit; c.PerMinute > 0 {
+		limits = append(limits, requestLimit{c.PerMinute, newRateLimiter(c.PerMinute, c.Burst), false})
+	}
+	if c := cfg.RateLimit; c.MutationPerMinute > 0 {
+		limits = append(limits, requestLimit{c.MutationPerMinute, newRateLimiter(c.MutationPerMinute, c.MutationBurst), true})
+	}
+	return limits
+}
+
+// rateLimit wraps next with the buckets of newRequestLimits on /api routes, keyed by
+// limitKey; POST, PUT, PATCH and DELETE count as mutations. Responses carry
+// X-RateLimit-Limit/Remaining/Reset of the bucket with the fewest tokens left, none with
+// rate limiting off; over a limit the client gets 429 with Retry-After and a JSON body.
+func rateLimit(next http.Handler) http.Handler {
+	limits := newRequestLimits()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(limits) == 0 || !strings.HasPrefix(r.URL.Path, "/api/") || r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+		key := limitKey(r)
+		// the bucket with the fewest tokens left, the one the headers describe
+		var least *requestLimit
+		var ok bool
+		var remaining int
+		var retryAfter, reset time.Duration
+		for i, l := range limits {
+			if l.mutations && !isMutation(r) {
+				continue
+			}
+			lok, lremaining, lretryAfter, lreset := l.rl.take(key)
+			if least == nil || lremaining < remaining {
+				least, remaining, reset = &limits[i], lremaining, lreset
+			}
+			ok, retryAfter = lok, lretryAfter
+			if !ok {
+				break // the other buckets keep their tokens
+			}
+		}
+		if least == nil { // only mutations are limited
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Set("X-RateLimit-Limit", strconv.Itoa(least.perMinute))
+		h.Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		h.Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(reset).Unix(), 10))
+		if !ok {
+			secs := int(math.Ceil(retryAfter.Seconds()))
+			h.Set("Retry-After", strconv.Itoa(secs))
+			writeError(w, http.StatusTooManyRequests, "Too many requests, retry after "+strconv.Itoa(secs)+"s",
+				bson.M{"retry_after": secs})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimitHeaders(t *testing.T) {
+	useTestStores(t)
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	send := func(h http.Handler, method string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, "/api/employees", nil))
+		return w
+	}
+
+	cfg.RateLimit.PerMinute, cfg.RateLimit.Burst = 60, 5
+	cfg.RateLimit.MutationPerMinute, cfg.RateLimit.MutationBurst = 6, 2
+	h := rateLimit(ok)
+	tests := []struct {
+		method           string
+		status           int
+		limit, remaining string
+	}{
+		{http.MethodGet, http.StatusOK, "60", "4"},
+		// the mutation bucket has fewer tokens left than the one for every request
+		{http.MethodPost, http.StatusOK, "6", "1"},
+		{http.MethodPost, http.StatusOK, "6", "0"},
+		{http.MethodPost, http.StatusTooManyRequests, "6", "0"},
+		{http.MethodGet, http.StatusOK, "60", "0"},
+		{http.MethodGet, http.StatusTooManyRequests, "60", "0"},
+	}
+	for i, tt := range tests {
+		w := send(h, tt.method)
+		expectStatus(t, w, tt.status)
+		if got := w.Header(); got.Get("X-RateLimit-Limit") != tt.limit || got.Get("X-RateLimit-Remaining") != tt.remaining ||
+			got.Get("X-RateLimit-Reset") == "" {
+			t.Errorf("%d %s: headers %v", i, tt.method, got)
+		}
+		if retry := w.Header().Get("Retry-After"); (tt.status == http.StatusTooManyRequests) != (retry != "") {
+			t.Errorf("%d %s: Retry-After %q", i, tt.method, retry)
+		}
+	}
+
+	// reads carry no headers when only mutations are limited, and nothing does with
+	// rate limiting off
+	cfg.RateLimit.PerMinute = 0
+	h = rateLimit(ok)
+	if w := send(h, http.MethodGet); w.Header().Get("X-RateLimit-Limit") != "" {
+		t.Errorf("GET with only mutations limited: headers %v", w.Header())
+	}
+	if w := send(h, http.MethodPost); w.Header().Get("X-RateLimit-Limit") != "6" {
+		t.Errorf("POST with only mutations limited: headers %v", w.Header())
+	}
+	cfg.RateLimit.MutationPerMinute = 0
+	h = rateLimit(ok)
+	if w := send(h, http.MethodPost); len(w.Header()) != 0 {
+		t.Errorf("rate limiting off: headers %v", w.Header())
+	}
+}
+
+func TestRateLimitKeys(t *testing.T) {
+	useBootstrapAccount(t)
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	bearer := func(user string) string {
+		token, err := issueToken(User{Username: user, Role: "viewer"}, defaultOrg, "access", time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return "Bearer " + token
+	}
+	ann, bob := bearer("ann"), bearer("bob")
+	// send answers a GET from addr with the given headers
+	send := func(h http.Handler, addr string, headers ...string) int {
+		r := httptest.NewRequest(http.MethodGet, "/api/employees", nil)
+		r.RemoteAddr = addr
+		for i := 0; i+1 < len(headers); i += 2 {
+			r.Header.Set(headers[i], headers[i+1])
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+	cfg.RateLimit.PerMinute, cfg.RateLimit.Burst = 60, 1
+
+	t.Run("ip", func(t *testing.T) {
+		cfg.RateLimit.Key = "ip"
+		h := rateLimit(ok)
+		if got := send(h, "192.0.2.1:1000", "Authorization", ann); got != http.StatusOK {
+			t.Errorf("first request: %d", got)
+		}
+		// another port or user is the same client; another address is not
+		if got := send(h, "192.0.2.1:2000", "Authorization", bob); got != http.StatusTooManyRequests {
+			t.Errorf("same IP, other user: %d", got)
+		}
+		if got := send(h, "192.0.2.2:1000"); got != http.StatusOK {
+			t.Errorf("other IP: %d", got)
+		}
+		// X-Forwarded-For only counts behind a trusted proxy
+		if got := send(h, "192.0.2.1:1000", "X-Forwarded-For", "198.51.100.7"); got != http.StatusTooManyRequests {
+			t.Errorf("untrusted X-Forwarded-For: %d", got)
+		}
+		cfg.RateLimit.TrustProxy = true
+		if got := send(h, "192.0.2.1:1000", "X-Forwarded-For", "198.51.100.7, 192.0.2.1"); got != http.StatusOK {
+			t.Errorf("trusted X-Forwarded-For: %d", got)
+		}
+		if got := send(h, "192.0.2.9:1000", "X-Forwarded-For", "198.51.100.7"); got != http.StatusTooManyRequests {
+			t.Errorf("same forwarded client, other proxy: %d", got)
+		}
+	})
+
+	t.Run("token", func(t *testing.T) {
+		cfg.RateLimit.Key = "token"
+		h := rateLimit(ok)
+		if got := send(h, "192.0.2.1:1000", "Authorization", ann); got != http.StatusOK {
+			t.Errorf("ann: %d", got)
+		}
+		// users behind one address have buckets of their own, wherever they come from
+		if got := send(h, "192.0.2.1:1000", "Authorization", bob); got != http.StatusOK {
+			t.Errorf("bob: %d", got)
+		}
+		if got := send(h, "192.0.2.2:1000", "Authorization", ann); got != http.StatusTooManyRequests {
+			t.Errorf("ann from another IP: %d", got)
+		}
+		// made-up tokens and unverified API keys count against the IP
+		if got := send(h, "192.0.2.3:1000", "Authorization", "Bearer made.up.token"); got != http.StatusOK {
+			t.Errorf("made-up token: %d", got)
+		}
+		if got := send(h, "192.0.2.3:1000", apiKeyHeader, newAPIKey(defaultOrg)); got != http.StatusTooManyRequests {
+			t.Errorf("unverified API key from the same IP: %d", got)
+		}
+		if got := send(h, "192.0.2.3:1000", "Authorization", bearer("carl")); got != http.StatusOK {
+			t.Errorf("carl from the same IP: %d", got)
+		}
+	})
+}
+
+func TestRateLimitRoutes(t *testing.T) {
+	useTestStores(t)
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	cfg.RateLimit.PerMinute, cfg.RateLimit.Burst = 0, 0
+	cfg.RateLimit.MutationPerMinute, cfg.RateLimit.MutationBurst = 60, 1
+
+	tests := []struct {
+		method, path string
+		limited      bool // counts against the mutation bucket
+	}{
+		{http.MethodGet, "/api/employees", false},
+		{http.MethodHead, "/api/employees", false},
+		{http.MethodOptions, "/api/employees", false}, // CORS preflights
+		{http.MethodPost, "/api/graphql", false},      // queries are POSTs too
+		{http.MethodPost, "/api/auth/login", true},
+		{http.MethodPost, "/api/employees", true},
+		{http.MethodPut, "/api/employees/1", true},
+		{http.MethodPatch, "/api/employees/1", true},
+		{http.MethodDelete, "/api/employees/1", true},
+		{http.MethodPost, "/index.html", false}, // the SPA isn't rate limited
+	}
+	for _, tt := range tests {
+		h := rateLimit(ok) // a fresh bucket for every route
+		for i := range 3 {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+			want := http.StatusOK
+			if tt.limited && i > 0 {
+				want = http.StatusTooManyRequests
+			}
+			if w.Code != want {
+				t.Errorf("%s %s, request %d: %d, want %d", tt.method, tt.path, i+1, w.Code, want)
+			}
+		}
+	}
+
+	// the mutation bucket is shared by every route
+	h := rateLimit(ok)
+	for i, r := range []*http.Request{
+		httptest.NewRequest(http.MethodPost, "/api/employees", nil),
+		httptest.NewRequest(http.MethodDelete, "/api/departments/3", nil),
+	} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if want := []int{http.StatusOK, http.StatusTooManyRequests}[i]; w.Code != want {
+			t.Errorf("%s %s: %d, want %d", r.Method, r.URL.Path, w.Code, want)
+		}
+	}
+}
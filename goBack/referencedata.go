@@ -0,0 +1,363 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/karthikeyan-meenachisundaram/goBack/repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Departments and languages are reference data: the lists the frontend offers in its
+// dropdowns and employee payloads are checked against. Departments are the Departments
+// collection (see departments.go), languages the Languages collection. A deactivated
+// entry drops out of the lists and can't be given to new employees; employees that have
+// it keep it. A list without entries restricts nothing, so deployments that never
+// curated their languages accept any, as before.
+
+// referenceList is one of the managed lists
+type referenceList struct {
+	kind       string // singular, for messages
+	collection string
+	idField    string // also the Counters id that allocates it
+	maxLength  int
+	pattern    *regexp.Regexp
+}
+
+var (
+	departmentList = referenceList{"department", "Departments", "dept_id", maxDepartmentLength, departmentPattern}
+	languageList   = referenceList{"language", "Languages", "lang_id", maxLanguageLength, languagePattern}
+	// referenceLists are the lists by their /api/meta/{list} name
+	referenceLists = map[string]referenceList{"departments": departmentList, "languages": languageList}
+)
+
+// ReferenceItem is an entry of a reference list
+type ReferenceItem struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// decode reads an entry; the id field differs between the lists
+func (l referenceList) decode(raw bson.Raw) (ReferenceItem, error) {
+	var d struct {
+		Name      string    `bson:"name"`
+		Inactive  bool      `bson:"inactive"`
+		CreatedAt time.Time `bson:"created_at"`
+	}
+	if err := bson.Unmarshal(raw, &d); err != nil {
+		return ReferenceItem{}, err
+	}
+	id, _ := raw.Lookup(l.idField).AsInt64OK()
+	return ReferenceItem{ID: int(id), Name: d.Name, Active: !d.Inactive, CreatedAt: d.CreatedAt}, nil
+}
+
+// find lists the entries by name, the deactivated ones too when all
+func (l referenceList) find(ctx context.Context, all bool) ([]ReferenceItem, error) {
+	filter := bson.M{}
+	if !all {
+		filter["inactive"] = bson.M{"$ne": true}
+	}
+	cur, err := coll(ctx, l.collection).Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	list := []ReferenceItem{}
+	for cur.Next(ctx) {
+		item, err := l.decode(cur.Current)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, item)
+	}
+	return list, cur.Err()
+}
+
+// get returns the entry with id; mongo.ErrNoDocuments when there is none
+func (l referenceList) get(ctx context.Context, id int) (ReferenceItem, error) {
+	raw, err := coll(ctx, l.collection).FindOne(ctx, bson.M{l.idField: id}).Raw()
+	if err != nil {
+		return ReferenceItem{}, err
+	}
+	return l.decode(raw)
+}
+
+// insert allocates an id and stores a new entry
+func (l referenceList) insert(ctx context.Context, name string) (ReferenceItem, error) {
+	var counter struct {
+		Seq int `bson:"seq"`
+	}
+	err := coll(ctx, "Counters").FindOneAndUpdate(ctx,
+		bson.M{"_id": l.idField},
+		bson.M{"$inc": bson.M{"seq": 1}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&counter)
+	if err != nil {
+		return ReferenceItem{}, err
+	}
+	item := ReferenceItem{ID: counter.Seq, Name: name, Active: true, CreatedAt: time.Now().UTC()}
+	_, err = coll(ctx, l.collection).InsertOne(ctx, bson.M{l.idField: item.ID, "name": item.Name, "created_at": item.CreatedAt})
+	return item, err
+}
+
+// checkName trims and validates a name like the employee payload does
+func (l referenceList) checkName(name *string) string {
+	*name = strings.TrimSpace(*name)
+	if *name == "" {
+		return "name is required"
+	}
+	if msg := checkValue(*name, l.maxLength, l.pattern); msg != "" {
+		return "name " + msg
+	}
+	return ""
+}
+
+// nameTaken reports whether another entry than id (0 for none) is called name, ignoring case
+func (l referenceList) nameTaken(ctx context.Context, name string, id int) (bool, error) {
+	err := coll(ctx, l.collection).FindOne(ctx, bson.M{"name": name, l.idField: bson.M{"$ne": id}},
+		options.FindOne().SetCollation(repository.CaseInsensitive)).Err()
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// rename renames an entry. Departments are referenced by dept_id, so that is all; the
+// employees' Developers rows carry the language name and follow it, their employees
+// getting a new version.
+func (l referenceList) rename(ctx context.Context, item ReferenceItem, name string) error {
+	if l.collection != languageList.collection {
+		_, err := coll(ctx, l.collection).UpdateOne(ctx, bson.M{l.idField: item.ID}, bson.M{"$set": bson.M{"name": name}})
+		return err
+	}
+	return withTransaction(ctx, func(sc mongo.SessionContext) error {
+		if _, err := coll(sc, l.collection).UpdateOne(sc, bson.M{l.idField: item.ID}, bson.M{"$set": bson.M{"name": name}}); err != nil {
+			return err
+		}
+		rows := bson.M{"language": item.Name}
+		ids, err := coll(sc, "Developers").Distinct(sc, "emp_id", rows, options.Distinct().SetCollation(repository.CaseInsensitive))
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+		if _, err := coll(sc, "Developers").UpdateMany(sc, rows, bson.M{"$set": bson.M{"language": name}},
+			options.Update().SetCollation(repository.CaseInsensitive)); err != nil {
+			return err
+		}
+		_, err = coll(sc, "Employee").UpdateMany(sc, bson.M{"emp_id": bson.M{"$in": ids}}, repository.BumpVersion(bson.M{}))
+		return err
+	})
+}
+
+// setActive activates or deactivates an entry
+func (l referenceList) setActive(ctx context.Context, id int, active bool) error {
+	update := bson.M{"$set": bson.M{"inactive": true}}
+	if active {
+		update = bson.M{"$unset": bson.M{"inactive": ""}}
+	}
+	_, err := coll(ctx, l.collection).UpdateOne(ctx, bson.M{l.idField: id}, update)
+	return err
+}
+
+// referenceData are the department and language lists employee payloads are checked
+// against (see EmployeePayload.validate); the zero value restricts nothing
+type referenceData struct {
+	departments, languages map[string]bool
+}
+
+// loadReferenceData reads both lists
+func loadReferenceData(ctx context.Context) (referenceData, error) {
+	var ref referenceData
+	var err error
+	if ref.departments, err = definitions.ReferenceNames(ctx, "departments"); err != nil {
+		return ref, fmt.Errorf("find departments: %w", err)
+	}
+	if ref.languages, err = definitions.ReferenceNames(ctx, "languages"); err != nil {
+		return ref, fmt.Errorf("find languages: %w", err)
+	}
+	return ref, nil
+}
+
+// checkReference validates v against names (see referenceList.names): unknown values are
+// refused, deactivated ones only when creating
+func checkReference(names map[string]bool, kind, v string, creating bool) string {
+	if names == nil {
+		return ""
+	}
+	active, ok := names[strings.ToLower(v)]
+	switch {
+	case !ok:
+		return "is not a known " + kind
+	case !active && creating:
+		return "is a deactivated " + kind
+	}
+	return ""
+}
+
+// ---------------- Handlers ----------------
+
+// metaHandler handles /api/meta/{departments|languages}[/{id}[/{action}]]: GET lists the
+// active entries (?all=true the deactivated ones too) and POST {name} adds one; on an
+// entry GET reads it, PUT {name} renames it and POST .../deactivate or .../activate
+// retires or restores it. Changes are admin only.
+func metaHandler(w http.ResponseWriter, r *http.Request) {
+	// path: /api/meta/{list}[/{id}[/{action}]]
+	name, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/meta/"), "/")
+	l, ok := referenceLists[name]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if rest == "" {
+		referenceListHandler(w, r, l)
+		return
+	}
+	idStr, action, _ := strings.Cut(rest, "/")
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id < 1 {
+		httpError(w, "invalid "+l.kind+" id", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	item, err := l.get(ctx, id)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			httpError(w, l.kind+" not found", http.StatusNotFound)
+			return
+		}
+		storeError(w, "find "+l.kind, err)
+		return
+	}
+
+	switch action {
+	case "":
+		referenceItemHandler(w, r, l, item)
+	case "activate", "deactivate":
+		if r.Method != http.MethodPost {
+			httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !requireAdmin(w, r) {
+			return
+		}
+		item.Active = action == "activate"
+		if err := l.setActive(ctx, item.ID, item.Active); err != nil {
+			storeError(w, action+" "+l.kind, err)
+			return
+		}
+		logFor(ctx).Info(action+"d "+l.kind, "id", item.ID, "name", item.Name, "actor", actorFromRequest(r))
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(item)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// referenceListHandler handles GET and POST (admin) on /api/meta/{list}
+func referenceListHandler(w http.ResponseWriter, r *http.Request, l referenceList) {
+	ctx := r.Context()
+
+	switch r.Method {
+	case http.MethodGet:
+		list, err := l.find(ctx, r.URL.Query().Get("all") == "true")
+		if err != nil {
+			storeError(w, "find "+l.kind+"s", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(list)
+	case http.MethodPost:
+		if !requireAdmin(w, r) {
+			return
+		}
+		var input struct {
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			httpError(w, "invalid input: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if msg := l.checkName(&input.Name); msg != "" {
+			writeValidationErrors(w, map[string]string{"name": msg})
+			return
+		}
+		taken, err := l.nameTaken(ctx, input.Name, 0)
+		if err != nil {
+			storeError(w, "find "+l.kind, err)
+			return
+		}
+		if taken {
+			httpError(w, l.kind+" already exists: "+input.Name, http.StatusConflict)
+			return
+		}
+		item, err := l.insert(ctx, input.Name)
+		if err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				httpError(w, l.kind+" already exists: "+input.Name, http.StatusConflict)
+				return
+			}
+			storeError(w, "insert "+l.kind, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(item)
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// referenceItemHandler handles GET and PUT (rename, admin) on /api/meta/{list}/{id}
+func referenceItemHandler(w http.ResponseWriter, r *http.Request, l referenceList, item ReferenceItem) {
+	ctx := r.Context()
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(item)
+	case http.MethodPut:
+		if !requireAdmin(w, r) {
+			return
+		}
+		var input struct {
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			httpError(w, "invalid input: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if msg := l.checkName(&input.Name); msg != "" {
+			writeValidationErrors(w, map[string]string{"name": msg})
+			return
+		}
+		taken, err := l.nameTaken(ctx, input.Name, item.ID)
+		if err != nil {
+			storeError(w, "find "+l.kind, err)
+			return
+		}
+		if taken {
+			httpError(w, l.kind+" already exists: "+input.Name, http.StatusConflict)
+			return
+		}
+		if err := l.rename(ctx, item, input.Name); err != nil {
+			storeError(w, "rename "+l.kind, err)
+			return
+		}
+		logFor(ctx).Info("renamed "+l.kind, "id", item.ID, "from", item.Name, "to", input.Name, "actor", actorFromRequest(r))
+		item.Name = input.Name
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(item)
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
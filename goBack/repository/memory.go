@@ -0,0 +1,493 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"iter"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/karthikeyan-meenachisundaram/goBack/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// memoryEmployee is an employee of the in-memory store; department and languages stand
+// for its rows in the Department and Developers collections
+type memoryEmployee struct {
+	empName      string
+	managerID    int
+	customFields bson.M
+	status       string // "" for active
+	termination  bson.M
+	version      int
+	updatedAt    *time.Time
+	deleted      bool
+	department   string
+	languages    []string
+}
+
+// Memory is an EmployeeStore on a map, safe for concurrent use. It hands out ids and
+// versions and joins departments and languages into rows the way the Mongo store does,
+// but keeps no audit log, history or tags.
+type Memory struct {
+	mu   sync.Mutex
+	seq  int // the emp_id counter
+	emps map[int]*memoryEmployee
+}
+
+// NewMemory returns an empty in-memory store
+func NewMemory() *Memory {
+	return &Memory{emps: map[int]*memoryEmployee{}}
+}
+
+// row is e shaped like a row of the Mongo store's employee list, minus the hidden custom
+// fields
+func (e *memoryEmployee) row(empId int, hidden []string) bson.D {
+	d := bson.D{{Key: "emp_id", Value: empId}, {Key: "emp_name", Value: e.empName}}
+	if e.department != "" {
+		d = append(d, bson.E{Key: "department", Value: e.department})
+	}
+	if len(e.languages) > 0 {
+		d = append(d, bson.E{Key: "language", Value: e.languages[0]})
+	}
+	status := e.status
+	if status == "" {
+		status = "active"
+	}
+	d = append(d, bson.E{Key: "status", Value: status})
+	if e.termination != nil {
+		d = append(d, bson.E{Key: "termination", Value: e.termination})
+	}
+	custom := maps.Clone(e.customFields)
+	for _, name := range hidden {
+		delete(custom, strings.TrimPrefix(name, "custom_fields."))
+	}
+	if len(custom) > 0 {
+		d = append(d, bson.E{Key: "custom_fields", Value: custom})
+	}
+	if e.managerID != 0 {
+		d = append(d, bson.E{Key: "manager_id", Value: e.managerID})
+	}
+	d = append(d, bson.E{Key: "languages", Value: append([]string{}, e.languages...)})
+	d = append(d, bson.E{Key: "version", Value: e.version})
+	if e.updatedAt != nil {
+		d = append(d, bson.E{Key: "updated_at", Value: *e.updatedAt})
+	}
+	return d
+}
+
+// live is the employee with empId unless there is none or it was deleted
+func (s *Memory) live(empId int) (*memoryEmployee, bool) {
+	e, ok := s.emps[empId]
+	if !ok || e.deleted {
+		return nil, false
+	}
+	return e, true
+}
+
+// rows are the live employees' rows q selects, in its order
+func (s *Memory) rows(q models.EmployeeQuery) ([]models.Row, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var docs []bson.D
+	for _, id := range slices.Sorted(maps.Keys(s.emps)) {
+		if e, ok := s.live(id); ok && e.matches(q) {
+			docs = append(docs, e.row(id, q.Hidden))
+		}
+	}
+	if len(q.Sort) > 0 {
+		// stable, so emp_id order breaks the ties
+		slices.SortStableFunc(docs, func(a, b bson.D) int {
+			for _, k := range q.Sort {
+				if c := compareValues(lookup(a, k.Key), lookup(b, k.Key)); c != 0 {
+					if dir, _ := k.Value.(int); dir < 0 {
+						return -c
+					}
+					return c
+				}
+			}
+			return 0
+		})
+	}
+	raws := make([]models.Row, 0, len(docs))
+	for _, d := range docs {
+		raw, err := bson.Marshal(project(d, q.Fields))
+		if err != nil {
+			return nil, err
+		}
+		raws = append(raws, raw)
+	}
+	return raws, nil
+}
+
+// project keeps emp_id and fields of a row; nil fields keep the whole row
+func project(d bson.D, fields []string) bson.D {
+	if fields == nil {
+		return d
+	}
+	return slices.DeleteFunc(d, func(e bson.E) bool { return e.Key != "emp_id" && !slices.Contains(fields, e.Key) })
+}
+
+// matches reports whether e passes the filters of q. The in-memory store keeps no tags,
+// so a tag filter matches nobody.
+func (e *memoryEmployee) matches(q models.EmployeeQuery) bool {
+	switch q.Status {
+	case "":
+	case "active":
+		if e.status == "terminated" || e.status == "inactive" {
+			return false
+		}
+	default:
+		if e.status != q.Status {
+			return false
+		}
+	}
+	if q.Department != "" && e.department != q.Department {
+		return false
+	}
+	if q.Language != "" && !slices.Contains(e.languages, q.Language) {
+		return false
+	}
+	if len(q.Tags) > 0 {
+		return false
+	}
+	for name, v := range q.CustomFields {
+		stored, ok := e.customFields[name]
+		if !ok || compareValues(stored, v) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Memory) List(ctx context.Context, q models.EmployeeQuery) ([]models.Row, int, error) {
+	raws, err := s.rows(q)
+	if err != nil {
+		return nil, 0, err
+	}
+	total := len(raws)
+	if q.Limit > 0 {
+		start := min((q.Page-1)*q.Limit, total)
+		raws = raws[start:min(start+q.Limit, total)]
+	}
+	return raws, total, nil
+}
+
+func (s *Memory) Stream(ctx context.Context, q models.EmployeeQuery) iter.Seq2[models.Row, error] {
+	return func(yield func(models.Row, error) bool) {
+		raws, err := s.rows(q)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		for _, raw := range raws {
+			if !yield(raw, nil) {
+				return
+			}
+		}
+	}
+}
+
+// lookup is the value of a row field, or of a custom_fields.<name> path; nil when unset
+func lookup(d bson.D, path string) interface{} {
+	key, sub, nested := strings.Cut(path, ".")
+	for _, e := range d {
+		if e.Key != key {
+			continue
+		}
+		if !nested {
+			return e.Value
+		}
+		m, _ := e.Value.(bson.M)
+		return m[sub]
+	}
+	return nil
+}
+
+// compareValues orders row values the way MongoDB sorts them: missing values first, then
+// numbers, strings, documents, arrays, booleans and times. Documents and arrays compare
+// equal to their kind.
+func compareValues(a, b interface{}) int {
+	if c := cmp.Compare(typeOrder(a), typeOrder(b)); c != 0 {
+		return c
+	}
+	switch a := a.(type) {
+	case string:
+		return strings.Compare(a, b.(string))
+	case bool:
+		switch {
+		case a == b.(bool):
+			return 0
+		case a:
+			return 1
+		}
+		return -1
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	}
+	if x, ok := number(a); ok {
+		y, _ := number(b)
+		return cmp.Compare(x, y)
+	}
+	return 0
+}
+
+// typeOrder ranks a value's type for compareValues
+func typeOrder(v interface{}) int {
+	if _, ok := number(v); ok {
+		return 1
+	}
+	switch v.(type) {
+	case nil:
+		return 0
+	case string:
+		return 2
+	case bson.M, bson.D:
+		return 3
+	case []string, bson.A:
+		return 4
+	case bool:
+		return 5
+	case time.Time:
+		return 6
+	}
+	return 7
+}
+
+// number is v as a float64 when it is a number
+func number(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+func (s *Memory) NextIDs(ctx context.Context, n int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.seq > models.MaxEmpID-n {
+		return 0, models.ErrIDOutOfRange
+	}
+	s.seq += n
+	return s.seq - n + 1, nil
+}
+
+func (s *Memory) ReserveID(ctx context.Context, empId int) error {
+	if empId > models.MaxEmpID {
+		return models.ErrIDOutOfRange
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq = max(s.seq, empId)
+	return nil
+}
+
+func (s *Memory) LastID(ctx context.Context) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	last := 0
+	for id := range s.emps {
+		last = max(last, id)
+	}
+	return last, nil
+}
+
+func (s *Memory) Exists(ctx context.Context, empId int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.emps[empId]
+	return ok, nil
+}
+
+func (s *Memory) Version(ctx context.Context, empId int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.live(empId)
+	if !ok {
+		return 0, models.ErrNotFound
+	}
+	return e.version, nil
+}
+
+func (s *Memory) Managers(ctx context.Context, empId int) ([]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.live(empId)
+	if !ok {
+		return nil, models.ErrNotFound
+	}
+	chain := []int{}
+	for m := e.managerID; m != 0 && !slices.Contains(chain, m); {
+		manager, ok := s.live(m)
+		if !ok {
+			break
+		}
+		chain = append(chain, m)
+		m = manager.managerID
+	}
+	return chain, nil
+}
+
+func (s *Memory) Get(ctx context.Context, empId int, hidden []string) (models.Row, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.live(empId)
+	if !ok {
+		return nil, models.ErrNotFound
+	}
+	return bson.Marshal(e.row(empId, hidden))
+}
+
+func (s *Memory) Create(ctx context.Context, e models.NewEmployee, actor string) error {
+	return s.CreateMany(ctx, []models.NewEmployee{e}, actor, "")
+}
+
+// CreateMany adds all of list or, when an emp_id is taken, none
+func (s *Memory) CreateMany(ctx context.Context, list []models.NewEmployee, actor, source string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	seen := map[int]bool{}
+	for _, e := range list {
+		if _, ok := s.emps[e.EmpID]; ok || seen[e.EmpID] {
+			return fmt.Errorf("insert employee: emp_id %d is taken", e.EmpID)
+		}
+		seen[e.EmpID] = true
+	}
+	for _, e := range list {
+		// like the Mongo store, an employee without languages gets an empty one
+		languages := slices.Clone(e.Languages)
+		if len(languages) == 0 {
+			languages = []string{""}
+		}
+		s.emps[e.EmpID] = &memoryEmployee{
+			empName:      e.EmpName,
+			managerID:    e.ManagerID,
+			customFields: maps.Clone(e.CustomFields),
+			department:   e.Department,
+			languages:    languages,
+		}
+	}
+	return nil
+}
+
+func (s *Memory) Update(ctx context.Context, empId int, c models.EmployeeChange, actor string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.live(empId)
+	if !ok {
+		return models.ErrNotFound
+	}
+	if c.Version != nil && *c.Version != e.version {
+		return models.ErrVersionConflict
+	}
+	if c.EmpName != nil {
+		e.empName = *c.EmpName
+	}
+	if c.Department != nil {
+		e.department = *c.Department
+	}
+	if c.Languages != nil {
+		e.languages = slices.Clone(c.Languages)
+	}
+	if c.ManagerID != nil {
+		e.managerID = *c.ManagerID
+	}
+	if len(c.CustomFields) > 0 && e.customFields == nil {
+		e.customFields = bson.M{}
+	}
+	maps.Copy(e.customFields, c.CustomFields)
+	for _, name := range c.ClearFields {
+		delete(e.customFields, name)
+	}
+	now := time.Now().UTC()
+	e.updatedAt = &now
+	e.version++
+	return nil
+}
+
+func (s *Memory) Terminate(ctx context.Context, empId int, t models.Termination, actor string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.live(empId)
+	if !ok {
+		return models.ErrNotFound
+	}
+	if e.status == "terminated" {
+		return models.ErrAlreadyTerminated
+	}
+	now := time.Now().UTC()
+	e.status = "terminated"
+	e.termination = bson.M{"end_date": t.EndDate, "reason": t.Reason, "note": t.Note, "recorded_at": now}
+	e.updatedAt = &now
+	e.version++
+	return nil
+}
+
+func (s *Memory) SoftDelete(ctx context.Context, empId int, actor string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.live(empId)
+	if !ok {
+		return 0, nil
+	}
+	e.deleted = true
+	return 1, nil
+}
+
+func (s *Memory) Purge(ctx context.Context, empId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.emps, empId)
+	return nil
+}
+
+// MemoryMetadata is a MetadataStore on fixed values. The zero value defines nothing, so
+// payloads are checked against no custom fields or reference lists.
+type MemoryMetadata struct {
+	Fields      []models.CustomField
+	Departments map[string]bool // lower-cased name to whether it is active
+	Languages   map[string]bool
+	Searches    []models.SavedSearch
+}
+
+func (m *MemoryMetadata) CustomFields(ctx context.Context) ([]models.CustomField, error) {
+	return slices.Clone(m.Fields), nil
+}
+
+func (m *MemoryMetadata) ReferenceNames(ctx context.Context, list string) (map[string]bool, error) {
+	switch list {
+	case "departments":
+		return m.Departments, nil
+	case "languages":
+		return m.Languages, nil
+	}
+	return nil, fmt.Errorf("unknown reference list %q", list)
+}
+
+func (m *MemoryMetadata) SavedSearch(ctx context.Context, name, user string) (models.SavedSearch, error) {
+	shared := -1
+	for i, s := range m.Searches {
+		switch {
+		case s.Name != name:
+		case s.Owner == user:
+			return s, nil
+		case s.Shared && shared < 0:
+			shared = i
+		}
+	}
+	if shared < 0 {
+		return models.SavedSearch{}, models.ErrNotFound
+	}
+	return m.Searches[shared], nil
+}
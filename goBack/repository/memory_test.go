@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/karthikeyan-meenachisundaram/goBack/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// the in-memory store is what the handler tests run on, so it must behave like the Mongo one
+var (
+	_ EmployeeStore = (*Memory)(nil)
+	_ MetadataStore = (*MemoryMetadata)(nil)
+)
+
+func TestCompareValues(t *testing.T) {
+	now := time.Now()
+	ordered := []interface{}{nil, 1, 2.5, int64(3), "a", "b", bson.M{}, []string{}, false, true, now, now.Add(time.Second)}
+	for i := range ordered {
+		for j := range ordered {
+			want := 0
+			if i < j {
+				want = -1
+			} else if i > j {
+				want = 1
+			}
+			if got := compareValues(ordered[i], ordered[j]); got != want {
+				t.Errorf("compareValues(%v, %v) = %d, want %d", ordered[i], ordered[j], got, want)
+			}
+		}
+	}
+}
+
+func TestMemoryMetadataSavedSearch(t *testing.T) {
+	ctx := context.Background()
+	m := &MemoryMetadata{Searches: []models.SavedSearch{
+		{Name: "engg", Owner: "ravi", Shared: true, Sort: "emp_name"},
+		{Name: "engg", Owner: "asha", Sort: "-emp_id"},
+		{Name: "mine", Owner: "ravi"},
+	}}
+	if s, err := m.SavedSearch(ctx, "engg", "asha"); err != nil || s.Owner != "asha" {
+		t.Errorf("own search = %+v, %v", s, err)
+	}
+	if s, err := m.SavedSearch(ctx, "engg", "meena"); err != nil || s.Owner != "ravi" {
+		t.Errorf("shared search = %+v, %v", s, err)
+	}
+	if _, err := m.SavedSearch(ctx, "mine", "meena"); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("someone else's private search: %v", err)
+	}
+	if _, err := m.ReferenceNames(ctx, "projects"); err == nil {
+		t.Error("unknown reference list read")
+	}
+}
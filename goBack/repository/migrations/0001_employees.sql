@@ -0,0 +1,54 @@
+-- The employees, their departments and languages, and the audit log of their changes.
+-- A department name is unique whatever its case; languages keep their order.
+
+CREATE TABLE departments (
+	dept_id    serial PRIMARY KEY,
+	name       text NOT NULL,
+	created_at timestamptz NOT NULL DEFAULT now()
+);
+CREATE UNIQUE INDEX departments_name_key ON departments (lower(name));
+
+CREATE TABLE employees (
+	emp_id               integer PRIMARY KEY,
+	emp_name             text NOT NULL,
+	dept_id              integer REFERENCES departments,
+	manager_id           integer,
+	status               text NOT NULL DEFAULT 'active',
+	termination_end_date timestamptz,
+	termination_reason   text,
+	termination_note     text,
+	terminated_at        timestamptz,
+	custom_fields        jsonb NOT NULL DEFAULT '{}',
+	version              integer NOT NULL DEFAULT 0,
+	created_at           timestamptz NOT NULL DEFAULT now(),
+	updated_at           timestamptz,
+	deleted_at           timestamptz,
+	deleted_by           text
+);
+CREATE INDEX employees_dept_idx ON employees (dept_id) WHERE deleted_at IS NULL;
+CREATE INDEX employees_manager_idx ON employees (manager_id) WHERE deleted_at IS NULL;
+CREATE INDEX employees_custom_fields_idx ON employees USING gin (custom_fields);
+
+CREATE TABLE employee_languages (
+	emp_id   integer NOT NULL REFERENCES employees ON DELETE CASCADE,
+	position integer NOT NULL,
+	language text NOT NULL,
+	PRIMARY KEY (emp_id, position)
+);
+CREATE INDEX employee_languages_language_idx ON employee_languages (language);
+
+-- the emp_id counter; one row, moved by NextIDs and ReserveID
+CREATE TABLE id_counter (
+	seq integer NOT NULL
+);
+INSERT INTO id_counter (seq) VALUES (0);
+
+CREATE TABLE audit_log (
+	id      bigserial PRIMARY KEY,
+	action  text NOT NULL,
+	emp_id  integer NOT NULL,
+	actor   text NOT NULL,
+	details jsonb,
+	at      timestamptz NOT NULL DEFAULT now()
+);
+CREATE INDEX audit_log_emp_idx ON audit_log (emp_id, at);
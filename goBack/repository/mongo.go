@@ -0,0 +1,740 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/karthikeyan-meenachisundaram/goBack/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoEnv is what the Mongo stores take from the server that runs them: the database
+// of the organization a request acts for, the retry policy, transactions, and the audit
+// log and photos the handlers writing to MongoDB directly share with the stores
+type MongoEnv interface {
+	// Collection is the collection called name of the organization ctx acts for
+	Collection(ctx context.Context, name string) *mongo.Collection
+	// Retry runs op and runs it again after transient errors, writes only when idempotent
+	Retry(ctx context.Context, what string, idempotent bool, op func() error) error
+	// Transaction runs fn inside a multi-document transaction
+	Transaction(ctx context.Context, fn func(sc mongo.SessionContext) error) error
+	// Snapshot is the audited state of a live employee, nil when there is none
+	Snapshot(ctx context.Context, empId int) bson.M
+	// Audit records action on empId in the audit log with the changes between two
+	// snapshots, and the employee's new state in its history when any field changed
+	Audit(ctx context.Context, action string, empId int, actor string, details interface{}, before, after bson.M) error
+	// AuditCreated records the creation of list like Audit would one by one, with one
+	// InsertMany per collection; source is noted in the entries
+	AuditCreated(ctx context.Context, list []models.NewEmployee, actor, source string) error
+	// DeletePhoto removes the photo blob stored under key
+	DeletePhoto(ctx context.Context, key string) error
+}
+
+// Mongo holds the stores on MongoDB: employees in the Employee collection, joined with
+// their Department membership and Developers (language) rows, and the departments,
+// transfers, reports, merges, search, change requests and definitions around them
+type Mongo struct {
+	env MongoEnv
+}
+
+// NewMongo returns the Mongo stores running in env
+func NewMongo(env MongoEnv) *Mongo {
+	return &Mongo{env: env}
+}
+
+// The stores; each is a view of m
+func (m *Mongo) Employees() EmployeeStore           { return mongoEmployees{m} }
+func (m *Mongo) Transfers() TransferStore           { return mongoTransfers{m} }
+func (m *Mongo) Departments() DepartmentStore       { return mongoDepartments{m} }
+func (m *Mongo) Reports() ReportStore               { return mongoReports{m} }
+func (m *Mongo) Merges() MergeStore                 { return mongoMerges{m} }
+func (m *Mongo) Searches() SearchStore              { return mongoSearch{m} }
+func (m *Mongo) ChangeRequests() ChangeRequestStore { return mongoChangeRequests{m} }
+func (m *Mongo) Metadata() MetadataStore            { return mongoMetadata{m} }
+
+// coll is a collection of the organization ctx acts for
+func (m *Mongo) coll(ctx context.Context, name string) *mongo.Collection {
+	return m.env.Collection(ctx, name)
+}
+
+// noDocument turns the driver's missing document into models.ErrNotFound
+func noDocument(err error) error {
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return models.ErrNotFound
+	}
+	return err
+}
+
+// CaseInsensitive compares names the way employee payloads are checked
+var CaseInsensitive = &options.Collation{Locale: "en", Strength: 2}
+
+// RelatedCollections hold per-employee records that follow the employee on a merge and
+// go with it when it is purged
+var RelatedCollections = []string{"Transfers", "Notes", "ProjectMembers", "Leaves"}
+
+// Live adds the "not soft-deleted" condition to an Employee filter
+func Live(filter bson.M) bson.M {
+	filter["deleted_at"] = bson.M{"$exists": false}
+	return filter
+}
+
+// BumpVersion adds the version increment and updated_at to an Employee update; every
+// mutation of an employee goes through it so optimistic updates notice the change
+func BumpVersion(update bson.M) bson.M {
+	set, _ := update["$set"].(bson.M)
+	if set == nil {
+		set = bson.M{}
+		update["$set"] = set
+	}
+	set["updated_at"] = time.Now().UTC()
+	update["$inc"] = bson.M{"version": 1}
+	return update
+}
+
+// PhotoURLExpr is the aggregation expression for photo_url: the versioned photo URL, or
+// nothing when the employee has no photo
+func PhotoURLExpr() bson.M {
+	return bson.M{"$cond": bson.A{
+		bson.M{"$ifNull": bson.A{"$photo.etag", false}},
+		bson.M{"$concat": bson.A{"/api/v1/employees/", bson.M{"$toString": "$emp_id"}, "/photo?v=", "$photo.etag"}},
+		"$$REMOVE",
+	}}
+}
+
+// DepartmentLookup is the $lookup stage joining an employee's Department membership as
+// "departments", with department_name resolved from the Departments collection
+func DepartmentLookup() bson.D {
+	return bson.D{{Key: "$lookup", Value: bson.D{
+		{Key: "from", Value: "Department"},
+		{Key: "localField", Value: "emp_id"},
+		{Key: "foreignField", Value: "emp_id"},
+		{Key: "pipeline", Value: bson.A{
+			bson.D{{Key: "$lookup", Value: bson.D{
+				{Key: "from", Value: "Departments"},
+				{Key: "localField", Value: "dept_id"},
+				{Key: "foreignField", Value: "dept_id"},
+				{Key: "as", Value: "dept"},
+			}}},
+			bson.D{{Key: "$set", Value: bson.D{
+				{Key: "department_name", Value: bson.D{{Key: "$ifNull", Value: bson.A{
+					bson.D{{Key: "$arrayElemAt", Value: bson.A{"$dept.name", 0}}},
+					"$department_name",
+				}}}},
+			}}},
+		}},
+		{Key: "as", Value: "departments"},
+	}}}
+}
+
+// DetailsPipeline matches employees and joins their department and languages into the
+// list row shape
+func DetailsPipeline(match bson.M) mongo.Pipeline {
+	return mongo.Pipeline{
+		bson.D{{Key: "$match", Value: match}},
+		DepartmentLookup(),
+		bson.D{{Key: "$lookup", Value: bson.D{
+			{Key: "from", Value: "Developers"},
+			{Key: "localField", Value: "emp_id"},
+			{Key: "foreignField", Value: "emp_id"},
+			{Key: "pipeline", Value: bson.A{bson.D{{Key: "$sort", Value: bson.D{{Key: "position", Value: 1}}}}}},
+			{Key: "as", Value: "languages"},
+		}}},
+		bson.D{{Key: "$project", Value: bson.D{
+			{Key: "_id", Value: 0},
+			{Key: "emp_id", Value: 1},
+			{Key: "emp_name", Value: 1},
+			{Key: "department", Value: bson.D{
+				{Key: "$arrayElemAt", Value: bson.A{"$departments.department_name", 0}},
+			}},
+			{Key: "language", Value: bson.D{
+				{Key: "$arrayElemAt", Value: bson.A{"$languages.language", 0}},
+			}},
+			{Key: "status", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$status", "active"}}}},
+			{Key: "termination", Value: 1},
+			{Key: "custom_fields", Value: 1},
+			{Key: "tags", Value: 1},
+			{Key: "manager_id", Value: 1},
+			{Key: "languages", Value: "$languages.language"},
+			{Key: "photo_url", Value: PhotoURLExpr()},
+			{Key: "version", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$version", 0}}}},
+			{Key: "updated_at", Value: 1},
+		}}},
+	}
+}
+
+// PageStage is a $facet returning one page of items plus the total match count
+func PageStage(page, limit int) bson.D {
+	return bson.D{{Key: "$facet", Value: bson.M{
+		"items": bson.A{
+			bson.M{"$skip": (page - 1) * limit},
+			bson.M{"$limit": limit},
+		},
+		"total": bson.A{bson.M{"$count": "n"}},
+	}}}
+}
+
+// PageResult is the decoded output of PageStage
+type PageResult struct {
+	Items []bson.Raw `bson:"items"`
+	Total []struct {
+		N int `bson:"n"`
+	} `bson:"total"`
+}
+
+// Count returns the match count (0 when nothing matched)
+func (p PageResult) Count() int {
+	if len(p.Total) == 0 {
+		return 0
+	}
+	return p.Total[0].N
+}
+
+// toRows are raws as the stores return them
+func toRows(raws []bson.Raw) []models.Row {
+	out := make([]models.Row, 0, len(raws))
+	for _, raw := range raws {
+		out = append(out, models.Row(raw))
+	}
+	return out
+}
+
+// DepartmentID returns the dept_id of the department called name, creating it the first
+// time the name is used so employee payloads can keep naming their department
+func (m *Mongo) DepartmentID(ctx context.Context, name string) (int, error) {
+	d, err := m.departmentByName(ctx, name)
+	if err == nil {
+		return d.DeptID, nil
+	}
+	if !errors.Is(err, models.ErrNotFound) {
+		return 0, err
+	}
+	d, err = m.insertDepartment(ctx, name)
+	if mongo.IsDuplicateKeyError(err) {
+		// created concurrently
+		d, err = m.departmentByName(ctx, name)
+	}
+	return d.DeptID, err
+}
+
+// SetDepartment moves an employee into the department called name
+func (m *Mongo) SetDepartment(ctx context.Context, empId int, name string) error {
+	id, err := m.DepartmentID(ctx, name)
+	if err != nil {
+		return err
+	}
+	_, err = m.coll(ctx, "Department").UpdateOne(ctx, bson.M{"emp_id": empId},
+		bson.M{"$set": bson.M{"dept_id": id}, "$unset": bson.M{"department_name": ""}},
+		options.Update().SetUpsert(true))
+	return err
+}
+
+// ReplaceLanguages swaps the employee's Developers rows for one row per language, in order
+func (m *Mongo) ReplaceLanguages(ctx context.Context, empId int, languages []string) error {
+	if _, err := m.coll(ctx, "Developers").DeleteMany(ctx, bson.M{"emp_id": empId}); err != nil {
+		return err
+	}
+	if len(languages) == 0 {
+		return nil
+	}
+	docs := make([]interface{}, 0, len(languages))
+	for i, l := range languages {
+		docs = append(docs, bson.M{"emp_id": empId, "language": l, "position": i})
+	}
+	_, err := m.coll(ctx, "Developers").InsertMany(ctx, docs)
+	return err
+}
+
+// auditChange runs change and audits it with the employee's before/after snapshots
+func (m *Mongo) auditChange(ctx context.Context, action string, empId int, actor string, details interface{}, change func() error) error {
+	before := m.env.Snapshot(ctx, empId)
+	if err := change(); err != nil {
+		return err
+	}
+	return m.env.Audit(ctx, action, empId, actor, details, before, m.env.Snapshot(ctx, empId))
+}
+
+// touchEmployee bumps the version of an employee whose related records changed
+func (m *Mongo) touchEmployee(ctx context.Context, empId int) error {
+	return m.env.Retry(ctx, "touch employee", false, func() error {
+		_, err := m.coll(ctx, "Employee").UpdateOne(ctx, Live(bson.M{"emp_id": empId}), BumpVersion(bson.M{}))
+		return err
+	})
+}
+
+// mongoEmployees keeps employees in the Employee, Department and Developers collections;
+// its Mongo calls are retried after transient errors (see MongoEnv.Retry)
+type mongoEmployees struct{ *Mongo }
+
+// NextIDs uses the Counters collection; the $inc is atomic, so instances behind a load
+// balancer never hand out the same id. It only matches a counter with n ids left.
+func (s mongoEmployees) NextIDs(ctx context.Context, n int) (int, error) {
+	var counter struct {
+		Seq int `bson:"seq"`
+	}
+	filter := bson.M{"_id": "emp_id", "seq": bson.M{"$not": bson.M{"$gt": models.MaxEmpID - n}}}
+	inc := func(upsert bool) error {
+		return s.coll(ctx, "Counters").FindOneAndUpdate(ctx, filter, bson.M{"$inc": bson.M{"seq": n}},
+			options.FindOneAndUpdate().SetUpsert(upsert).SetReturnDocument(options.After),
+		).Decode(&counter)
+	}
+	err := s.env.Retry(ctx, "next ids", false, func() error {
+		err := inc(true)
+		if mongo.IsDuplicateKeyError(err) {
+			// the counter exists but didn't match: another instance created it just now,
+			// or it has no ids left
+			if err = inc(false); err == mongo.ErrNoDocuments {
+				return models.ErrIDOutOfRange
+			}
+		}
+		return err
+	})
+	return counter.Seq - n + 1, err
+}
+
+// ReserveID's $max can be repeated safely, so it is retried like a read
+func (s mongoEmployees) ReserveID(ctx context.Context, empId int) error {
+	if empId > models.MaxEmpID {
+		return models.ErrIDOutOfRange
+	}
+	return s.env.Retry(ctx, "reserve id", true, func() error {
+		_, err := s.coll(ctx, "Counters").UpdateOne(ctx, bson.M{"_id": "emp_id"}, bson.M{"$max": bson.M{"seq": empId}}, options.Update().SetUpsert(true))
+		if mongo.IsDuplicateKeyError(err) {
+			// another instance created the counter concurrently; retry as a plain update
+			_, err = s.coll(ctx, "Counters").UpdateOne(ctx, bson.M{"_id": "emp_id"}, bson.M{"$max": bson.M{"seq": empId}})
+		}
+		return err
+	})
+}
+
+func (s mongoEmployees) LastID(ctx context.Context) (int, error) {
+	opts := options.FindOne().SetSort(bson.D{{Key: "emp_id", Value: -1}})
+	var last struct {
+		EmpID int `bson:"emp_id"`
+	}
+	err := s.env.Retry(ctx, "last id", true, func() error {
+		return s.coll(ctx, "Employee").FindOne(ctx, bson.D{}, opts).Decode(&last)
+	})
+	if err == mongo.ErrNoDocuments {
+		return 0, nil
+	}
+	return last.EmpID, err
+}
+
+func (s mongoEmployees) Get(ctx context.Context, empId int, hidden []string) (models.Row, error) {
+	pipeline := DetailsPipeline(Live(bson.M{"emp_id": empId}))
+	if len(hidden) > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$unset", Value: hidden}})
+	}
+	var doc bson.Raw
+	err := s.env.Retry(ctx, "get employee", true, func() error {
+		cur, err := s.coll(ctx, "Employee").Aggregate(ctx, pipeline)
+		if err != nil {
+			return err
+		}
+		defer cur.Close(ctx)
+		if !cur.Next(ctx) {
+			if err := cur.Err(); err != nil {
+				return err
+			}
+			return models.ErrNotFound
+		}
+		doc = cur.Current
+		return nil
+	})
+	return models.Row(doc), err
+}
+
+// listPipeline is the employee list aggregation for q, without its page: the filters on
+// the Employee document first, those on the joined department and languages after
+func listPipeline(q models.EmployeeQuery) mongo.Pipeline {
+	match := Live(bson.M{})
+	switch q.Status {
+	case "":
+	case "active":
+		match["status"] = bson.M{"$nin": bson.A{"terminated", "inactive"}}
+	default:
+		match["status"] = q.Status
+	}
+	for name, v := range q.CustomFields {
+		match["custom_fields."+name] = v
+	}
+	if len(q.Tags) > 0 {
+		match["tags"] = bson.M{"$all": q.Tags}
+	}
+
+	pipeline := DetailsPipeline(match)
+	if len(q.Hidden) > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$unset", Value: q.Hidden}})
+	}
+	joined := bson.M{}
+	if q.Department != "" {
+		joined["department"] = q.Department
+	}
+	if q.Language != "" {
+		joined["languages"] = q.Language
+	}
+	if len(joined) > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: joined}})
+	}
+	sort := q.Sort
+	if len(sort) == 0 && q.Limit > 0 {
+		sort = bson.D{{Key: "emp_id", Value: 1}}
+	}
+	if len(sort) > 0 {
+		// emp_id breaks ties so pages are stable
+		if !slices.ContainsFunc(sort, func(e bson.E) bool { return e.Key == "emp_id" }) {
+			sort = append(slices.Clone(sort), bson.E{Key: "emp_id", Value: 1})
+		}
+		pipeline = append(pipeline, bson.D{{Key: "$sort", Value: sort}})
+	}
+	if len(q.Fields) > 0 {
+		project := bson.D{{Key: "_id", Value: 0}}
+		for _, f := range q.Fields {
+			project = append(project, bson.E{Key: f, Value: 1})
+		}
+		pipeline = append(pipeline, bson.D{{Key: "$project", Value: project}})
+	}
+	return pipeline
+}
+
+// List runs listPipeline, with a PageStage when q has a limit
+func (s mongoEmployees) List(ctx context.Context, q models.EmployeeQuery) ([]models.Row, int, error) {
+	pipeline := listPipeline(q)
+	if q.Limit > 0 {
+		pipeline = append(pipeline, PageStage(q.Page, q.Limit))
+	}
+	var raws []bson.Raw
+	total := 0
+	err := s.env.Retry(ctx, "list employees", true, func() error {
+		cur, err := s.coll(ctx, "Employee").Aggregate(ctx, pipeline)
+		if err != nil {
+			return err
+		}
+		defer cur.Close(ctx)
+		if q.Limit == 0 {
+			raws = nil
+			err := cur.All(ctx, &raws)
+			total = len(raws)
+			return err
+		}
+		var out []PageResult
+		if err := cur.All(ctx, &out); err != nil {
+			return err
+		}
+		raws, total = nil, 0
+		if len(out) > 0 {
+			raws, total = out[0].Items, out[0].Count()
+		}
+		return nil
+	})
+	return toRows(raws), total, err
+}
+
+// Stream yields the rows of listPipeline as the cursor returns them
+func (s mongoEmployees) Stream(ctx context.Context, q models.EmployeeQuery) iter.Seq2[models.Row, error] {
+	return func(yield func(models.Row, error) bool) {
+		// a sort over the whole collection may not fit the in-memory sort limit
+		cur, err := s.coll(ctx, "Employee").Aggregate(ctx, listPipeline(q), options.Aggregate().SetAllowDiskUse(true))
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer cur.Close(ctx)
+		for cur.Next(ctx) {
+			if !yield(models.Row(cur.Current), nil) {
+				return
+			}
+		}
+		if err := cur.Err(); err != nil {
+			yield(nil, err)
+		}
+	}
+}
+
+func (s mongoEmployees) Create(ctx context.Context, e models.NewEmployee, actor string) error {
+	details := bson.M{
+		"emp_name":   e.EmpName,
+		"department": e.Department,
+		"languages":  e.Languages,
+	}
+	return s.auditChange(ctx, "create", e.EmpID, actor, details, func() error {
+		emp := bson.M{"emp_id": e.EmpID, "emp_name": e.EmpName, "created_at": time.Now().UTC()}
+		if e.ManagerID != 0 {
+			emp["manager_id"] = e.ManagerID
+		}
+		if len(e.CustomFields) > 0 {
+			emp["custom_fields"] = e.CustomFields
+		}
+		if err := s.env.Retry(ctx, "insert employee", false, func() error {
+			_, err := s.coll(ctx, "Employee").InsertOne(ctx, emp)
+			return err
+		}); err != nil {
+			return fmt.Errorf("insert employee: %w", err)
+		}
+		if err := s.SetDepartment(ctx, e.EmpID, e.Department); err != nil {
+			return fmt.Errorf("insert department: %w", err)
+		}
+		languages := e.Languages
+		if len(languages) == 0 {
+			languages = []string{""}
+		}
+		if err := s.ReplaceLanguages(ctx, e.EmpID, languages); err != nil {
+			return fmt.Errorf("insert developers: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s mongoEmployees) Exists(ctx context.Context, empId int) (bool, error) {
+	var n int64
+	err := s.env.Retry(ctx, "employee exists", true, func() (err error) {
+		n, err = s.coll(ctx, "Employee").CountDocuments(ctx, bson.M{"emp_id": empId})
+		return err
+	})
+	return n > 0, err
+}
+
+// Version counts documents written before versioning as version 0
+func (s mongoEmployees) Version(ctx context.Context, empId int) (int, error) {
+	var emp struct {
+		Version int `bson:"version"`
+	}
+	err := s.env.Retry(ctx, "employee version", true, func() error {
+		return s.coll(ctx, "Employee").FindOne(ctx, Live(bson.M{"emp_id": empId}),
+			options.FindOne().SetProjection(bson.M{"version": 1})).Decode(&emp)
+	})
+	return emp.Version, noDocument(err)
+}
+
+// Managers follows manager_id with $graphLookup, over live employees only
+func (s mongoEmployees) Managers(ctx context.Context, empId int) ([]int, error) {
+	cur, err := s.coll(ctx, "Employee").Aggregate(ctx, mongo.Pipeline{
+		bson.D{{Key: "$match", Value: Live(bson.M{"emp_id": empId})}},
+		bson.D{{Key: "$graphLookup", Value: bson.D{
+			{Key: "from", Value: "Employee"},
+			{Key: "startWith", Value: "$manager_id"},
+			{Key: "connectFromField", Value: "manager_id"},
+			{Key: "connectToField", Value: "emp_id"},
+			{Key: "as", Value: "chain"},
+			{Key: "restrictSearchWithMatch", Value: Live(bson.M{})},
+		}}},
+		bson.D{{Key: "$project", Value: bson.M{"_id": 0, "chain": "$chain.emp_id"}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	var out []struct {
+		Chain []int `bson:"chain"`
+	}
+	if err := cur.All(ctx, &out); err != nil {
+		return nil, err
+	}
+	if len(out) == 0 {
+		return nil, models.ErrNotFound
+	}
+	return out[0].Chain, nil
+}
+
+func (s mongoEmployees) CreateMany(ctx context.Context, list []models.NewEmployee, actor, source string) error {
+	deptIDs := map[string]int{}
+	for _, e := range list {
+		if _, ok := deptIDs[e.Department]; !ok {
+			id, err := s.DepartmentID(ctx, e.Department)
+			if err != nil {
+				return fmt.Errorf("resolve department %q: %w", e.Department, err)
+			}
+			deptIDs[e.Department] = id
+		}
+	}
+	now := time.Now().UTC()
+	var emps, departments, developers []interface{}
+	for _, e := range list {
+		emp := bson.M{"emp_id": e.EmpID, "emp_name": e.EmpName, "created_at": now}
+		if e.ManagerID != 0 {
+			emp["manager_id"] = e.ManagerID
+		}
+		if len(e.CustomFields) > 0 {
+			emp["custom_fields"] = e.CustomFields
+		}
+		emps = append(emps, emp)
+		departments = append(departments, bson.M{"emp_id": e.EmpID, "dept_id": deptIDs[e.Department]})
+		languages := e.Languages
+		if len(languages) == 0 {
+			languages = []string{""}
+		}
+		for pos, l := range languages {
+			developers = append(developers, bson.M{"emp_id": e.EmpID, "language": l, "position": pos})
+		}
+	}
+	for _, step := range []struct {
+		name string
+		docs []interface{}
+	}{
+		{"Employee", emps},
+		{"Department", departments},
+		{"Developers", developers},
+	} {
+		if _, err := s.coll(ctx, step.name).InsertMany(ctx, step.docs); err != nil {
+			return fmt.Errorf("insert %s: %w", strings.ToLower(step.name), err)
+		}
+	}
+	return s.env.AuditCreated(ctx, list, actor, source)
+}
+
+func (s mongoEmployees) Update(ctx context.Context, empId int, c models.EmployeeChange, actor string) error {
+	before := s.env.Snapshot(ctx, empId)
+	set := bson.M{}
+	unset := bson.M{}
+	for name, v := range c.CustomFields {
+		set["custom_fields."+name] = v
+	}
+	for _, name := range c.ClearFields {
+		unset["custom_fields."+name] = ""
+	}
+	if c.EmpName != nil {
+		set["emp_name"] = *c.EmpName
+	}
+	switch {
+	case c.ManagerID == nil:
+	case *c.ManagerID == 0:
+		unset["manager_id"] = ""
+	default:
+		set["manager_id"] = *c.ManagerID
+	}
+	update := bson.M{"$set": set}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	filter := Live(bson.M{"emp_id": empId})
+	if c.Version != nil {
+		if *c.Version == 0 {
+			filter["version"] = bson.M{"$in": bson.A{0, nil}}
+		} else {
+			filter["version"] = *c.Version
+		}
+	}
+	var res *mongo.UpdateResult
+	err := s.env.Retry(ctx, "update employee", false, func() (err error) {
+		res, err = s.coll(ctx, "Employee").UpdateOne(ctx, filter, BumpVersion(update))
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("update employee: %w", err)
+	}
+	if res.MatchedCount == 0 {
+		if _, err := s.Version(ctx, empId); err != nil {
+			return err
+		}
+		return models.ErrVersionConflict
+	}
+	if c.Department != nil {
+		if err := s.SetDepartment(ctx, empId, *c.Department); err != nil {
+			return fmt.Errorf("update department: %w", err)
+		}
+	}
+	if c.Languages != nil {
+		if err := s.ReplaceLanguages(ctx, empId, c.Languages); err != nil {
+			return fmt.Errorf("update developers: %w", err)
+		}
+	}
+	// audited like the request body: cleared custom fields show up as null
+	var custom map[string]interface{}
+	if len(c.CustomFields) > 0 || len(c.ClearFields) > 0 {
+		custom = map[string]interface{}{}
+		for name, v := range c.CustomFields {
+			custom[name] = v
+		}
+		for _, name := range c.ClearFields {
+			custom[name] = nil
+		}
+	}
+	return s.env.Audit(ctx, "update", empId, actor, models.EmployeePayload{
+		EmpName:      c.EmpName,
+		Department:   c.Department,
+		Languages:    c.Languages,
+		ManagerID:    c.ManagerID,
+		CustomFields: custom,
+	}, before, s.env.Snapshot(ctx, empId))
+}
+
+func (s mongoEmployees) Terminate(ctx context.Context, empId int, t models.Termination, actor string) error {
+	// only match employees that are not terminated yet
+	filter := Live(bson.M{"emp_id": empId, "status": bson.M{"$ne": "terminated"}})
+	update := bson.M{"$set": bson.M{
+		"status": "terminated",
+		"termination": bson.M{
+			"end_date":    t.EndDate,
+			"reason":      t.Reason,
+			"note":        t.Note,
+			"recorded_at": time.Now().UTC(),
+		},
+	}}
+	before := s.env.Snapshot(ctx, empId)
+	var res *mongo.UpdateResult
+	err := s.env.Retry(ctx, "terminate employee", false, func() (err error) {
+		res, err = s.coll(ctx, "Employee").UpdateOne(ctx, filter, BumpVersion(update))
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("terminate employee: %w", err)
+	}
+	if res.MatchedCount == 0 {
+		if _, err := s.Version(ctx, empId); err != nil {
+			return err
+		}
+		return models.ErrAlreadyTerminated
+	}
+	return s.env.Audit(ctx, "terminate", empId, actor, bson.M{"end_date": t.EndDate, "reason": t.Reason},
+		before, s.env.Snapshot(ctx, empId))
+}
+
+func (s mongoEmployees) SoftDelete(ctx context.Context, empId int, actor string) (int64, error) {
+	before := s.env.Snapshot(ctx, empId)
+	var res *mongo.UpdateResult
+	err := s.env.Retry(ctx, "delete employee", false, func() (err error) {
+		res, err = s.coll(ctx, "Employee").UpdateOne(ctx, Live(bson.M{"emp_id": empId}), bson.M{"$set": bson.M{
+			"deleted_at": time.Now().UTC(),
+			"deleted_by": actor,
+		}})
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	if res.ModifiedCount > 0 {
+		if err := s.env.Audit(ctx, "delete", empId, actor, nil, before, nil); err != nil {
+			return 0, err
+		}
+	}
+	return res.ModifiedCount, nil
+}
+
+func (s mongoEmployees) Purge(ctx context.Context, empId int) error {
+	var emp struct {
+		Photo *struct {
+			Key string `bson:"key"`
+		} `bson:"photo"`
+	}
+	if err := s.coll(ctx, "Employee").FindOne(ctx, bson.M{"emp_id": empId}).Decode(&emp); err == nil && emp.Photo != nil {
+		if err := s.env.DeletePhoto(ctx, emp.Photo.Key); err != nil {
+			return err
+		}
+	}
+	for _, name := range append([]string{"Employee", "Department", "Developers", "EmployeeHistory"}, RelatedCollections...) {
+		if err := s.env.Retry(ctx, "purge "+strings.ToLower(name), true, func() error {
+			_, err := s.coll(ctx, name).DeleteMany(ctx, bson.M{"emp_id": empId})
+			return err
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
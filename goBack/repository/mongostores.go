@@ -0,0 +1,892 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/karthikeyan-meenachisundaram/goBack/models"
+	"github.com/karthikeyan-meenachisundaram/goBack/service"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mongoTransfers keeps the transfer history in the Transfers collection
+type mongoTransfers struct{ *Mongo }
+
+// moveDepartment sets the employee's department, appends a Transfers entry and audits it
+func (m *Mongo) moveDepartment(ctx context.Context, t models.Transfer) error {
+	details := bson.M{
+		"from_department": t.FromDepartment,
+		"to_department":   t.ToDepartment,
+		"effective_date":  t.EffectiveDate,
+	}
+	return m.auditChange(ctx, "transfer", t.EmpID, t.RecordedBy, details, func() error {
+		if err := m.SetDepartment(ctx, t.EmpID, t.ToDepartment); err != nil {
+			return err
+		}
+		if err := m.touchEmployee(ctx, t.EmpID); err != nil {
+			return err
+		}
+		_, err := m.coll(ctx, "Transfers").InsertOne(ctx, t)
+		return err
+	})
+}
+
+func (s mongoTransfers) Transfer(ctx context.Context, t models.Transfer) error {
+	return s.env.Transaction(ctx, func(sc mongo.SessionContext) error {
+		return s.moveDepartment(sc, t)
+	})
+}
+
+func (s mongoTransfers) History(ctx context.Context, empId int) ([]models.Transfer, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "effective_date", Value: -1}, {Key: "recorded_at", Value: -1}})
+	cur, err := s.coll(ctx, "Transfers").Find(ctx, bson.M{"emp_id": empId}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	history := []models.Transfer{}
+	if err := cur.All(ctx, &history); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// mongoDepartments keeps the departments in the Departments collection and the
+// memberships in Department
+type mongoDepartments struct{ *Mongo }
+
+// departmentByName finds the department called name, ignoring case like the unique
+// index on Departments names and the employee payload checks do
+func (m *Mongo) departmentByName(ctx context.Context, name string) (models.Department, error) {
+	var d models.Department
+	err := m.coll(ctx, "Departments").FindOne(ctx, bson.M{"name": name}, options.FindOne().SetCollation(CaseInsensitive)).Decode(&d)
+	return d, noDocument(err)
+}
+
+// insertDepartment allocates a dept_id from the Counters collection and stores a new
+// department
+func (m *Mongo) insertDepartment(ctx context.Context, name string) (models.Department, error) {
+	var counter struct {
+		Seq int `bson:"seq"`
+	}
+	err := m.coll(ctx, "Counters").FindOneAndUpdate(ctx,
+		bson.M{"_id": "dept_id"},
+		bson.M{"$inc": bson.M{"seq": 1}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&counter)
+	if err != nil {
+		return models.Department{}, err
+	}
+	d := models.Department{DeptID: counter.Seq, Name: name, CreatedAt: time.Now().UTC()}
+	_, err = m.coll(ctx, "Departments").InsertOne(ctx, bson.M{"dept_id": d.DeptID, "name": d.Name, "created_at": d.CreatedAt})
+	return d, err
+}
+
+func (s mongoDepartments) List(ctx context.Context) ([]models.Department, error) {
+	cur, err := s.coll(ctx, "Departments").Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	list := []models.Department{}
+	if err := cur.All(ctx, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (s mongoDepartments) Get(ctx context.Context, deptID int) (models.Department, error) {
+	var d models.Department
+	err := s.coll(ctx, "Departments").FindOne(ctx, bson.M{"dept_id": deptID}).Decode(&d)
+	return d, noDocument(err)
+}
+
+func (s mongoDepartments) ByName(ctx context.Context, name string) (models.Department, error) {
+	return s.departmentByName(ctx, name)
+}
+
+func (s mongoDepartments) Create(ctx context.Context, name string) (models.Department, error) {
+	d, err := s.insertDepartment(ctx, name)
+	if mongo.IsDuplicateKeyError(err) {
+		return d, models.ErrDuplicate
+	}
+	return d, err
+}
+
+func (s mongoDepartments) Rename(ctx context.Context, deptID int, name string) error {
+	_, err := s.coll(ctx, "Departments").UpdateOne(ctx, bson.M{"dept_id": deptID}, bson.M{"$set": bson.M{"name": name}})
+	if mongo.IsDuplicateKeyError(err) {
+		return models.ErrDuplicate
+	}
+	return err
+}
+
+func (s mongoDepartments) Members(ctx context.Context, deptID int) (int64, error) {
+	return s.coll(ctx, "Department").CountDocuments(ctx, bson.M{"dept_id": deptID})
+}
+
+func (s mongoDepartments) Delete(ctx context.Context, deptID int) error {
+	_, err := s.coll(ctx, "Departments").DeleteOne(ctx, bson.M{"dept_id": deptID})
+	return err
+}
+
+// Reassign moves the members in one transaction, through moveDepartment like a transfer
+func (s mongoDepartments) Reassign(ctx context.Context, dept models.Department, empIDs []int, t models.Transfer) (models.Reassignment, error) {
+	filter := bson.M{"dept_id": dept.DeptID}
+	if len(empIDs) > 0 {
+		filter["emp_id"] = bson.M{"$in": empIDs}
+	}
+	var out models.Reassignment
+	err := s.env.Transaction(ctx, func(sc mongo.SessionContext) error {
+		out = models.Reassignment{Moved: []int{}, Skipped: []int{}}
+		cur, err := s.coll(sc, "Department").Find(sc, filter)
+		if err != nil {
+			return err
+		}
+		var members []struct {
+			EmpID int `bson:"emp_id"`
+		}
+		if err := cur.All(sc, &members); err != nil {
+			return err
+		}
+		ids := make([]int, 0, len(members))
+		for _, m := range members {
+			ids = append(ids, m.EmpID)
+		}
+
+		// terminated and deleted employees stay where they were
+		terminated := map[int]bool{}
+		cur, err = s.coll(sc, "Employee").Find(sc, bson.M{"emp_id": bson.M{"$in": ids}, "$or": bson.A{
+			bson.M{"status": "terminated"},
+			bson.M{"deleted_at": bson.M{"$exists": true}},
+		}})
+		if err != nil {
+			return err
+		}
+		var gone []struct {
+			EmpID int `bson:"emp_id"`
+		}
+		if err := cur.All(sc, &gone); err != nil {
+			return err
+		}
+		for _, g := range gone {
+			terminated[g.EmpID] = true
+		}
+
+		for _, id := range ids {
+			if terminated[id] {
+				out.Skipped = append(out.Skipped, id)
+				continue
+			}
+			t.EmpID = id
+			if err := s.moveDepartment(sc, t); err != nil {
+				return err
+			}
+			out.Moved = append(out.Moved, id)
+		}
+		return nil
+	})
+	return out, err
+}
+
+// mongoReports aggregates the reports from the Employee collection
+type mongoReports struct{ *Mongo }
+
+// statsSnapshotID is the StatsSnapshots document holding the default dashboard
+const statsSnapshotID = "default"
+
+// Stats aggregates the dashboard in one $facet over the live employees
+func (s mongoReports) Stats(ctx context.Context, days, recent int) (models.EmployeeStats, error) {
+	now := time.Now().UTC()
+	since := now.AddDate(0, 0, -days)
+
+	current := bson.M{"$match": bson.M{"status": bson.M{"$ne": "terminated"}}}
+	byCount := bson.M{"$sort": bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}}
+	pipeline := mongo.Pipeline{
+		bson.D{{Key: "$match", Value: Live(bson.M{})}},
+		DepartmentLookup(),
+		bson.D{{Key: "$lookup", Value: bson.D{
+			{Key: "from", Value: "Developers"},
+			{Key: "localField", Value: "emp_id"},
+			{Key: "foreignField", Value: "emp_id"},
+			{Key: "as", Value: "languages"},
+		}}},
+		bson.D{{Key: "$project", Value: bson.D{
+			{Key: "emp_id", Value: 1},
+			{Key: "emp_name", Value: 1},
+			{Key: "status", Value: bson.M{"$ifNull": bson.A{"$status", "active"}}},
+			{Key: "department", Value: bson.M{"$ifNull": bson.A{bson.M{"$arrayElemAt": bson.A{"$departments.department_name", 0}}, ""}}},
+			{Key: "languages", Value: "$languages.language"},
+			{Key: "hired_at", Value: bson.M{"$ifNull": bson.A{"$created_at", bson.M{"$toDate": "$_id"}}}},
+		}}},
+		bson.D{{Key: "$facet", Value: bson.M{
+			"status": bson.A{
+				bson.M{"$group": bson.M{"_id": "$status", "count": bson.M{"$sum": 1}}},
+			},
+			"departments": bson.A{
+				current,
+				bson.M{"$group": bson.M{"_id": "$department", "count": bson.M{"$sum": 1}}},
+				byCount,
+			},
+			"languages": bson.A{
+				current,
+				bson.M{"$unwind": "$languages"},
+				bson.M{"$match": bson.M{"languages": bson.M{"$ne": ""}}},
+				bson.M{"$group": bson.M{"_id": "$languages", "count": bson.M{"$sum": 1}}},
+				byCount,
+			},
+			"hired": bson.A{
+				bson.M{"$match": bson.M{"hired_at": bson.M{"$gte": since}}},
+				bson.M{"$count": "n"},
+			},
+			"recent": bson.A{
+				bson.M{"$sort": bson.D{{Key: "hired_at", Value: -1}, {Key: "emp_id", Value: -1}}},
+				bson.M{"$limit": recent},
+			},
+		}}},
+	}
+
+	cur, err := s.coll(ctx, "Employee").Aggregate(ctx, pipeline)
+	if err != nil {
+		return models.EmployeeStats{}, err
+	}
+	defer cur.Close(ctx)
+	var out []struct {
+		Status      []models.StatCount  `bson:"status"`
+		Departments []models.StatCount  `bson:"departments"`
+		Languages   []models.StatCount  `bson:"languages"`
+		Hired       []struct{ N int }   `bson:"hired"`
+		Recent      []models.RecentHire `bson:"recent"`
+	}
+	if err := cur.All(ctx, &out); err != nil {
+		return models.EmployeeStats{}, err
+	}
+
+	stats := models.EmployeeStats{ByDepartment: []models.StatCount{}, ByLanguage: []models.StatCount{}, RecentHires: []models.RecentHire{}, Since: since, ComputedAt: now}
+	stats.Headcount.ByStatus = map[string]int{"active": 0, "inactive": 0, "terminated": 0}
+	if len(out) > 0 {
+		f := out[0]
+		for _, s := range f.Status {
+			stats.Headcount.ByStatus[s.Name] = s.Count
+			if s.Name != "terminated" {
+				stats.Headcount.Total += s.Count
+			}
+		}
+		if f.Departments != nil {
+			stats.ByDepartment = f.Departments
+		}
+		if f.Languages != nil {
+			stats.ByLanguage = f.Languages
+		}
+		if len(f.Hired) > 0 {
+			stats.HiredSince = f.Hired[0].N
+		}
+		if f.Recent != nil {
+			stats.RecentHires = f.Recent
+		}
+	}
+
+	return stats, nil
+}
+
+// Snapshot reads the default dashboard from StatsSnapshots
+func (s mongoReports) Snapshot(ctx context.Context) (models.EmployeeStats, error) {
+	var snap struct {
+		Stats models.EmployeeStats `bson:"stats"`
+	}
+	err := s.coll(ctx, "StatsSnapshots").FindOne(ctx, bson.M{"_id": statsSnapshotID}).Decode(&snap)
+	return snap.Stats, noDocument(err)
+}
+
+// SaveSnapshot stores the default dashboard in StatsSnapshots
+func (s mongoReports) SaveSnapshot(ctx context.Context, stats models.EmployeeStats) error {
+	_, err := s.coll(ctx, "StatsSnapshots").ReplaceOne(ctx, bson.M{"_id": statsSnapshotID},
+		bson.M{"_id": statsSnapshotID, "stats": stats}, options.Replace().SetUpsert(true))
+	return err
+}
+
+// Attrition groups the terminated employees by $termination.end_date
+func (s mongoReports) Attrition(ctx context.Context, q models.AttritionQuery) ([]models.AttritionRow, error) {
+	endDate := "$termination.end_date"
+	var periodExpr interface{}
+	switch q.Period {
+	case "year":
+		periodExpr = bson.M{"$dateToString": bson.M{"format": "%Y", "date": endDate}}
+	case "quarter":
+		periodExpr = bson.M{"$concat": bson.A{
+			bson.M{"$dateToString": bson.M{"format": "%Y", "date": endDate}},
+			"-Q",
+			bson.M{"$toString": bson.M{"$ceil": bson.M{"$divide": bson.A{bson.M{"$month": endDate}, 3}}}},
+		}}
+	default:
+		periodExpr = bson.M{"$dateToString": bson.M{"format": "%Y-%m", "date": endDate}}
+	}
+
+	match := Live(bson.M{"status": "terminated"})
+	dateRange := bson.M{}
+	if q.From != nil {
+		dateRange["$gte"] = *q.From
+	}
+	if q.To != nil {
+		dateRange["$lte"] = *q.To
+	}
+	if len(dateRange) > 0 {
+		match["termination.end_date"] = dateRange
+	}
+
+	pipeline := mongo.Pipeline{
+		bson.D{{Key: "$match", Value: match}},
+		DepartmentLookup(),
+		bson.D{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: bson.D{
+				{Key: "period", Value: periodExpr},
+				{Key: "department", Value: bson.M{"$arrayElemAt": bson.A{"$departments.department_name", 0}}},
+				{Key: "reason", Value: "$termination.reason"},
+			}},
+			{Key: "count", Value: bson.M{"$sum": 1}},
+		}}},
+		bson.D{{Key: "$project", Value: bson.D{
+			{Key: "_id", Value: 0},
+			{Key: "period", Value: "$_id.period"},
+			{Key: "department", Value: bson.M{"$ifNull": bson.A{"$_id.department", ""}}},
+			{Key: "reason", Value: "$_id.reason"},
+			{Key: "count", Value: 1},
+		}}},
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "period", Value: 1}, {Key: "department", Value: 1}, {Key: "reason", Value: 1}}}},
+	}
+
+	cur, err := s.coll(ctx, "Employee").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	rows := []models.AttritionRow{}
+	if err := cur.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// mongoMerges merges employees across the Employee collection and the per-employee
+// collections
+type mongoMerges struct{ *Mongo }
+
+func (s mongoMerges) Candidates(ctx context.Context) ([]models.DuplicateCandidate, error) {
+	cur, err := s.coll(ctx, "Employee").Aggregate(ctx, mongo.Pipeline{
+		bson.D{{Key: "$match", Value: Live(bson.M{})}},
+		DepartmentLookup(),
+		bson.D{{Key: "$project", Value: bson.D{
+			{Key: "_id", Value: 0},
+			{Key: "emp_id", Value: 1},
+			{Key: "emp_name", Value: 1},
+			{Key: "department", Value: bson.D{
+				{Key: "$arrayElemAt", Value: bson.A{"$departments.department_name", 0}},
+			}},
+		}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	people := []models.DuplicateCandidate{}
+	if err := cur.All(ctx, &people); err != nil {
+		return nil, err
+	}
+	return people, nil
+}
+
+// Merge runs mergeEmployees in a transaction
+func (s mongoMerges) Merge(ctx context.Context, primary, duplicate int, actor string) (models.Merge, error) {
+	var out models.Merge
+	err := s.env.Transaction(ctx, func(sc mongo.SessionContext) error {
+		var err error
+		out, err = s.mergeEmployees(sc, primary, duplicate, actor)
+		return err
+	})
+	return out, noDocument(err)
+}
+
+// mergeEmployees folds duplicate into primary and returns the fields taken from the
+// duplicate, plus the key of the duplicate's photo blob when the primary kept its own
+// photo; the caller deletes that blob once the merge is committed
+func (m *Mongo) mergeEmployees(ctx context.Context, primary, duplicate int, actor string) (models.Merge, error) {
+	var p, d bson.M
+	if err := m.coll(ctx, "Employee").FindOne(ctx, Live(bson.M{"emp_id": primary})).Decode(&p); err != nil {
+		return models.Merge{}, err
+	}
+	if err := m.coll(ctx, "Employee").FindOne(ctx, Live(bson.M{"emp_id": duplicate})).Decode(&d); err != nil {
+		return models.Merge{}, err
+	}
+
+	before := m.env.Snapshot(ctx, primary)
+
+	// Employee fields (see service.PlanMerge); the version moves on either way, since the
+	// related records below change
+	plan := service.PlanMerge(p, d)
+	merged := plan.Fields
+	if _, err := m.coll(ctx, "Employee").UpdateOne(ctx, bson.M{"emp_id": primary}, BumpVersion(bson.M{"$set": plan.Set})); err != nil {
+		return models.Merge{}, err
+	}
+
+	// the duplicate's reports report to the primary now, who can't be their own manager
+	if _, err := m.coll(ctx, "Employee").UpdateMany(ctx, Live(bson.M{"manager_id": duplicate, "emp_id": bson.M{"$ne": primary}}),
+		BumpVersion(bson.M{"$set": bson.M{"manager_id": primary}})); err != nil {
+		return models.Merge{}, err
+	}
+	if _, err := m.coll(ctx, "Employee").UpdateOne(ctx, bson.M{"emp_id": primary, "manager_id": bson.M{"$in": bson.A{primary, duplicate}}},
+		bson.M{"$unset": bson.M{"manager_id": ""}}); err != nil {
+		return models.Merge{}, err
+	}
+
+	// Department / Developers: keep the primary's, adopt the duplicate's if the primary has none
+	for _, c := range []struct{ name, field string }{{"Department", "department_name"}, {"Developers", "language"}} {
+		n, err := m.coll(ctx, c.name).CountDocuments(ctx, bson.M{"emp_id": primary})
+		if err != nil {
+			return models.Merge{}, err
+		}
+		if n == 0 {
+			res, err := m.coll(ctx, c.name).UpdateMany(ctx, bson.M{"emp_id": duplicate}, bson.M{"$set": bson.M{"emp_id": primary}})
+			if err != nil {
+				return models.Merge{}, err
+			}
+			if res.ModifiedCount > 0 {
+				merged = append(merged, c.field)
+			}
+			continue
+		}
+		if _, err := m.coll(ctx, c.name).DeleteMany(ctx, bson.M{"emp_id": duplicate}); err != nil {
+			return models.Merge{}, err
+		}
+	}
+
+	for _, name := range RelatedCollections {
+		if _, err := m.coll(ctx, name).UpdateMany(ctx, bson.M{"emp_id": duplicate}, bson.M{"$set": bson.M{"emp_id": primary}}); err != nil {
+			return models.Merge{}, err
+		}
+	}
+
+	// the duplicate's revisions stay in the primary's history, each still carrying the
+	// duplicate's record as it was
+	if _, err := m.coll(ctx, "EmployeeHistory").UpdateMany(ctx, bson.M{"emp_id": duplicate}, bson.M{"$set": bson.M{"emp_id": primary}}); err != nil {
+		return models.Merge{}, err
+	}
+	// the duplicate goes to the trash like a deleted employee, without the photo the
+	// primary took over or the caller deletes, so purging it later leaves that alone
+	if _, err := m.coll(ctx, "Employee").UpdateOne(ctx, Live(bson.M{"emp_id": duplicate}), bson.M{
+		"$set":   bson.M{"deleted_at": time.Now().UTC(), "deleted_by": actor},
+		"$unset": bson.M{"photo": ""},
+	}); err != nil {
+		return models.Merge{}, err
+	}
+	if err := m.env.Audit(ctx, "delete", duplicate, actor, bson.M{"merged_into": primary}, nil, nil); err != nil {
+		return models.Merge{}, err
+	}
+
+	delete(d, "_id")
+	if err := m.env.Audit(ctx, "merge", primary, actor, bson.M{
+		"duplicate_id":  duplicate,
+		"merged_fields": merged,
+		"duplicate":     d,
+	}, before, m.env.Snapshot(ctx, primary)); err != nil {
+		return models.Merge{}, err
+	}
+	return models.Merge{Fields: merged, OrphanPhoto: plan.OrphanPhoto}, nil
+}
+
+// mongoSearch scores the employees in an aggregation over the Employee collection
+type mongoSearch struct{ *Mongo }
+
+// maxTextMatches caps the stemmed name matches fed into the ranking
+const maxTextMatches = 500
+
+// matchScore scores one string expression against the query:
+// exact 1, prefix 0.75, word prefix 0.6, substring 0.5, otherwise 0
+func matchScore(input interface{}, q string) bson.M {
+	re := func(pattern string) bson.M {
+		return bson.M{"$regexMatch": bson.M{"input": bson.M{"$ifNull": bson.A{input, ""}}, "regex": pattern, "options": "i"}}
+	}
+	return bson.M{"$switch": bson.M{
+		"branches": bson.A{
+			bson.M{"case": re("^" + q + "$"), "then": 1.0},
+			bson.M{"case": re("^" + q), "then": 0.75},
+			bson.M{"case": re(`\b` + q), "then": 0.6},
+			bson.M{"case": re(q), "then": 0.5},
+		},
+		"default": 0.0,
+	}}
+}
+
+// arrayMatchScore is the best matchScore over the elements of an array expression
+func arrayMatchScore(array interface{}, q string) bson.M {
+	return bson.M{"$max": bson.A{0.0, bson.M{"$max": bson.M{"$map": bson.M{
+		"input": bson.M{"$ifNull": bson.A{array, bson.A{}}},
+		"as":    "v",
+		"in":    matchScore("$$v", q),
+	}}}}}
+}
+
+// textMatches returns the live employees whose name matches q through the text index
+func (s mongoSearch) textMatches(ctx context.Context, q string) ([]int, error) {
+	opts := options.Find().
+		SetProjection(bson.M{"emp_id": 1, "score": bson.M{"$meta": "textScore"}}).
+		SetSort(bson.M{"score": bson.M{"$meta": "textScore"}}).
+		SetLimit(maxTextMatches)
+	cur, err := s.coll(ctx, "Employee").Find(ctx, Live(bson.M{"$text": bson.M{"$search": q}}), opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	var docs []struct {
+		EmpID int `bson:"emp_id"`
+	}
+	if err := cur.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	ids := make([]int, 0, len(docs))
+	for _, d := range docs {
+		ids = append(ids, d.EmpID)
+	}
+	return ids, nil
+}
+
+// fieldMatches returns the employees whose department, one of whose languages or one of
+// whose tags contains one of words, for the fields weighted above 0. Each of these fields
+// has few distinct values: they are read from an index and matched here, and the
+// employees holding the matching ones are then found through an index too.
+func (s mongoSearch) fieldMatches(ctx context.Context, words []string, weights map[string]float64) (bson.A, error) {
+	contains := func(v string) bool {
+		for _, word := range words {
+			if strings.Contains(strings.ToLower(v), strings.ToLower(word)) {
+				return true
+			}
+		}
+		return false
+	}
+	matching := func(values []interface{}) bson.A {
+		found := bson.A{}
+		for _, v := range values {
+			if str, _ := v.(string); contains(str) {
+				found = append(found, str)
+			}
+		}
+		return found
+	}
+	ids := bson.A{}
+	holders := func(collection, field string, values bson.A) error {
+		if len(values) == 0 {
+			return nil
+		}
+		found, err := s.coll(ctx, collection).Distinct(ctx, "emp_id", bson.M{field: bson.M{"$in": values}})
+		ids = append(ids, found...)
+		return err
+	}
+
+	if weights["department"] > 0 {
+		depts, err := s.Departments().List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		deptIDs := bson.A{}
+		for _, d := range depts {
+			if contains(d.Name) {
+				deptIDs = append(deptIDs, d.DeptID)
+			}
+		}
+		if err := holders("Department", "dept_id", deptIDs); err != nil {
+			return nil, err
+		}
+	}
+	for _, f := range []struct{ weight, collection, field string }{{"language", "Developers", "language"}, {"tags", "Employee", "tags"}} {
+		if weights[f.weight] <= 0 {
+			continue
+		}
+		values, err := s.coll(ctx, f.collection).Distinct(ctx, f.field, bson.M{})
+		if err != nil {
+			return nil, err
+		}
+		if err := holders(f.collection, f.field, matching(values)); err != nil {
+			return nil, err
+		}
+	}
+	return ids, nil
+}
+
+// Search scores the candidates fieldMatches and the text index turn up with matchScore
+func (s mongoSearch) Search(ctx context.Context, sq models.SearchQuery) ([]models.Row, int, error) {
+	words, weights := sq.Words, sq.Weights
+	terms := bson.A{}
+	for _, word := range words {
+		quoted := regexp.QuoteMeta(word)
+		fieldScores := map[string]bson.M{
+			"emp_name":   matchScore("$emp_name", quoted),
+			"department": matchScore("$department", quoted),
+			"language":   arrayMatchScore("$languages", quoted),
+			"tags":       arrayMatchScore("$tags", quoted),
+		}
+		for f, expr := range fieldScores {
+			if weights[f] > 0 {
+				terms = append(terms, bson.M{"$multiply": bson.A{weights[f], expr}})
+			}
+		}
+	}
+	candidates, err := s.fieldMatches(ctx, words, weights)
+	if err != nil {
+		return nil, 0, err
+	}
+	if weights["emp_name"] > 0 {
+		// without the text index the other fields still find employees
+		stemmed, err := s.textMatches(ctx, sq.Query)
+		if err != nil {
+			slog.WarnContext(ctx, "search: text index query failed", "err", err)
+		}
+		if len(stemmed) > 0 {
+			terms = append(terms, bson.M{"$cond": bson.A{bson.M{"$in": bson.A{"$emp_id", stemmed}}, weights["emp_name"] * 0.6, 0.0}})
+		}
+		for _, id := range stemmed {
+			candidates = append(candidates, id)
+		}
+	}
+
+	pipeline := mongo.Pipeline{
+		// the emp_id index narrows the scan to the candidates before anything is joined
+		bson.D{{Key: "$match", Value: Live(bson.M{"emp_id": bson.M{"$in": candidates}})}},
+		DepartmentLookup(),
+		bson.D{{Key: "$lookup", Value: bson.D{
+			{Key: "from", Value: "Developers"},
+			{Key: "localField", Value: "emp_id"},
+			{Key: "foreignField", Value: "emp_id"},
+			{Key: "pipeline", Value: bson.A{bson.D{{Key: "$sort", Value: bson.D{{Key: "position", Value: 1}}}}}},
+			{Key: "as", Value: "languages"},
+		}}},
+		bson.D{{Key: "$project", Value: bson.D{
+			{Key: "_id", Value: 0},
+			{Key: "emp_id", Value: 1},
+			{Key: "emp_name", Value: 1},
+			{Key: "department", Value: bson.M{"$arrayElemAt": bson.A{"$departments.department_name", 0}}},
+			{Key: "language", Value: bson.M{"$arrayElemAt": bson.A{"$languages.language", 0}}},
+			{Key: "languages", Value: "$languages.language"},
+			{Key: "status", Value: bson.M{"$ifNull": bson.A{"$status", "active"}}},
+			{Key: "tags", Value: 1},
+			{Key: "photo_url", Value: PhotoURLExpr()},
+		}}},
+		bson.D{{Key: "$addFields", Value: bson.M{"score": bson.M{"$add": terms}}}},
+		bson.D{{Key: "$match", Value: bson.M{"score": bson.M{"$gt": 0}}}},
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "score", Value: -1}, {Key: "emp_name", Value: 1}}}},
+		PageStage(sq.Page, sq.Limit),
+	}
+
+	cur, err := s.coll(ctx, "Employee").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cur.Close(ctx)
+	var out []PageResult
+	if err := cur.All(ctx, &out); err != nil {
+		return nil, 0, err
+	}
+	if len(out) == 0 {
+		return nil, 0, nil
+	}
+	return toRows(out[0].Items), out[0].Count(), nil
+}
+
+// mongoChangeRequests keeps the change requests in the ChangeRequests collection, the
+// hex of their ObjectID being their ID
+type mongoChangeRequests struct{ *Mongo }
+
+// mongoChangeRequest is a change request as stored
+type mongoChangeRequest struct {
+	ID                   primitive.ObjectID `bson:"_id,omitempty"`
+	models.ChangeRequest `bson:",inline"`
+}
+
+// changeRequest is the stored request with its ID filled in
+func (d mongoChangeRequest) changeRequest() models.ChangeRequest {
+	cr := d.ChangeRequest
+	cr.ID = d.ID.Hex()
+	return cr
+}
+
+// Submit inserts cr and audits the request
+func (s mongoChangeRequests) Submit(ctx context.Context, cr *models.ChangeRequest) error {
+	res, err := s.coll(ctx, "ChangeRequests").InsertOne(ctx, mongoChangeRequest{ChangeRequest: *cr})
+	if err != nil {
+		return err
+	}
+	oid, _ := res.InsertedID.(primitive.ObjectID)
+	cr.ID = oid.Hex()
+	details := bson.M{"approval_id": oid, "kind": cr.Kind, "payload": cr.Payload}
+	switch {
+	case cr.Termination != nil:
+		details["termination"] = cr.Termination
+	case cr.Transfer != nil:
+		details["transfer"] = cr.Transfer
+	case cr.Tags != nil:
+		details["tags"] = cr.Tags
+	case cr.Photo != nil:
+		details["photo"] = cr.Photo
+	case cr.Merge != nil:
+		details["merge"] = cr.Merge
+	}
+	_ = s.env.Audit(ctx, "change_requested", cr.EmpID, cr.RequestedBy, details, nil, nil)
+	return nil
+}
+
+func (s mongoChangeRequests) List(ctx context.Context, status, requestedBy string) ([]models.ChangeRequest, error) {
+	filter := bson.M{}
+	if status != "" {
+		filter["status"] = status
+	}
+	if requestedBy != "" {
+		filter["requested_by"] = requestedBy
+	}
+	cur, err := s.coll(ctx, "ChangeRequests").Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "requested_at", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	var docs []mongoChangeRequest
+	if err := cur.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	list := make([]models.ChangeRequest, 0, len(docs))
+	for _, d := range docs {
+		list = append(list, d.changeRequest())
+	}
+	return list, nil
+}
+
+func (s mongoChangeRequests) Get(ctx context.Context, id string) (models.ChangeRequest, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return models.ChangeRequest{}, models.ErrNotFound
+	}
+	var d mongoChangeRequest
+	if err := s.coll(ctx, "ChangeRequests").FindOne(ctx, bson.M{"_id": oid}).Decode(&d); err != nil {
+		return models.ChangeRequest{}, noDocument(err)
+	}
+	return d.changeRequest(), nil
+}
+
+// Decide runs decideChangeRequest in a transaction
+func (s mongoChangeRequests) Decide(ctx context.Context, id string, approve bool, approver, comment string, apply func(context.Context, models.ChangeRequest) error) (models.ChangeRequest, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return models.ChangeRequest{}, models.ErrNotFound
+	}
+	var cr models.ChangeRequest
+	err = s.env.Transaction(ctx, func(sc mongo.SessionContext) error {
+		return s.decideChangeRequest(sc, oid, approve, approver, comment, apply, &cr)
+	})
+	return cr, noDocument(err)
+}
+
+// decideChangeRequest approves (applying the change) or rejects a pending request and audits the decision
+func (s mongoChangeRequests) decideChangeRequest(ctx context.Context, id primitive.ObjectID, approve bool, approver, comment string, apply func(context.Context, models.ChangeRequest) error, out *models.ChangeRequest) error {
+	status := "rejected"
+	if approve {
+		status = "approved"
+	}
+	now := time.Now().UTC()
+
+	var d mongoChangeRequest
+	if err := s.coll(ctx, "ChangeRequests").FindOne(ctx, bson.M{"_id": id}).Decode(&d); err != nil {
+		return err
+	}
+	cr := d.changeRequest()
+	if cr.Status != "pending" {
+		return models.ErrChangeNotPending
+	}
+
+	if approve {
+		if err := apply(ctx, cr); err != nil {
+			return err
+		}
+	}
+
+	res, err := s.coll(ctx, "ChangeRequests").UpdateOne(ctx, bson.M{"_id": id, "status": "pending"}, bson.M{"$set": bson.M{
+		"status":     status,
+		"decided_by": approver,
+		"decided_at": now,
+		"comment":    comment,
+	}})
+	if err != nil {
+		return err
+	}
+	if res.ModifiedCount == 0 {
+		return models.ErrChangeNotPending
+	}
+	if err := s.env.Audit(ctx, "change_"+status, cr.EmpID, approver, bson.M{
+		"approval_id":  id,
+		"kind":         cr.Kind,
+		"requested_by": cr.RequestedBy,
+		"comment":      comment,
+	}, nil, nil); err != nil {
+		return err
+	}
+
+	cr.Status, cr.DecidedBy, cr.DecidedAt, cr.Comment = status, approver, &now, comment
+	*out = cr
+	return nil
+}
+
+// mongoMetadata reads the custom fields, reference lists and saved searches from their
+// collections
+type mongoMetadata struct{ *Mongo }
+
+// referenceCollections are the collections of the reference lists by their name
+var referenceCollections = map[string]string{"departments": "Departments", "languages": "Languages"}
+
+func (s mongoMetadata) CustomFields(ctx context.Context) ([]models.CustomField, error) {
+	cur, err := s.coll(ctx, "CustomFields").Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	fields := []models.CustomField{}
+	if err := cur.All(ctx, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// ReferenceNames reads every entry of the list, the deactivated ones too
+func (s mongoMetadata) ReferenceNames(ctx context.Context, list string) (map[string]bool, error) {
+	name, ok := referenceCollections[list]
+	if !ok {
+		return nil, fmt.Errorf("unknown reference list %q", list)
+	}
+	cur, err := s.coll(ctx, name).Find(ctx, bson.M{}, options.Find().SetProjection(bson.M{"name": 1, "inactive": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	var entries []struct {
+		Name     string `bson:"name"`
+		Inactive bool   `bson:"inactive"`
+	}
+	if err := cur.All(ctx, &entries); err != nil || len(entries) == 0 {
+		return nil, err
+	}
+	names := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		names[strings.ToLower(e.Name)] = !e.Inactive
+	}
+	return names, nil
+}
+
+// SavedSearch looks up a search by name: the caller's own first, then a shared one
+func (s mongoMetadata) SavedSearch(ctx context.Context, name, user string) (models.SavedSearch, error) {
+	var saved models.SavedSearch
+	err := s.coll(ctx, "SavedSearches").FindOne(ctx, bson.M{"name": name, "owner": user}).Decode(&saved)
+	if err == mongo.ErrNoDocuments {
+		err = s.coll(ctx, "SavedSearches").FindOne(ctx, bson.M{"name": name, "shared": true}).Decode(&saved)
+	}
+	return saved, noDocument(err)
+}
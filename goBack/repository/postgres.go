@@ -0,0 +1,618 @@
+package repository
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"iter"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/karthikeyan-meenachisundaram/goBack/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// migrations are the schema of the Postgres store, applied in file name order by Migrate
+//
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// migrationLock is the advisory lock instances hold while migrating, so that two starting
+// together don't both apply a migration
+const migrationLock = 0x676f4261636b // "goBack"
+
+// Postgres is an EmployeeStore on PostgreSQL, for where MongoDB isn't available. The
+// employees, their departments and their languages have a table each (see migrations),
+// joined into rows shaped like the Mongo store's; mutations are recorded in audit_log.
+// Like the in-memory store it keeps no history or tags.
+type Postgres struct {
+	pool *pgxpool.Pool
+}
+
+// NewPostgres returns the store on pool; Migrate creates its tables
+func NewPostgres(pool *pgxpool.Pool) *Postgres {
+	return &Postgres{pool: pool}
+}
+
+// querier runs statements on the pool or in a transaction
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// Migrate applies the migrations not applied yet, each in its own transaction, and
+// records them in schema_migrations
+func (s *Postgres) Migrate(ctx context.Context) error {
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+	if _, err := conn.Exec(ctx, "SELECT pg_advisory_lock($1)", migrationLock); err != nil {
+		return fmt.Errorf("lock migrations: %w", err)
+	}
+	defer conn.Exec(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock($1)", migrationLock)
+
+	if _, err := conn.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    integer PRIMARY KEY,
+		applied_at timestamptz NOT NULL DEFAULT now()
+	)`); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+	files, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		name := path.Base(f)
+		prefix, _, _ := strings.Cut(name, "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return fmt.Errorf("migration %s: the name must start with its version", name)
+		}
+		sql, err := migrations.ReadFile(f)
+		if err != nil {
+			return err
+		}
+		err = pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+			var applied bool
+			if err := tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)", version).Scan(&applied); err != nil || applied {
+				return err
+			}
+			// without arguments the file runs as one multi-statement query
+			if _, err := tx.Exec(ctx, string(sql)); err != nil {
+				return err
+			}
+			_, err := tx.Exec(ctx, "INSERT INTO schema_migrations (version) VALUES ($1)", version)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("migration %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Ping checks that the database answers
+func (s *Postgres) Ping(ctx context.Context) error {
+	return s.pool.Ping(ctx)
+}
+
+// sqlArgs collects the arguments of a statement as it is built
+type sqlArgs []any
+
+// add appends v and returns its placeholder
+func (a *sqlArgs) add(v any) string {
+	*a = append(*a, v)
+	return "$" + strconv.Itoa(len(*a))
+}
+
+// listFrom joins the employees to their department; the languages are joined by
+// listQuery, which groups them per employee
+const listFrom = `FROM employees e
+	LEFT JOIN departments d ON d.dept_id = e.dept_id`
+
+// listWhere is the condition selecting the live employees q filters for, and empId
+// alone unless it is 0. There are no tags, so a tag filter selects nobody.
+func listWhere(q models.EmployeeQuery, empId int, a *sqlArgs) (string, error) {
+	conds := []string{"e.deleted_at IS NULL"}
+	if empId != 0 {
+		conds = append(conds, "e.emp_id = "+a.add(empId))
+	}
+	switch q.Status {
+	case "":
+	case "active":
+		conds = append(conds, "e.status NOT IN ('inactive', 'terminated')")
+	default:
+		conds = append(conds, "e.status = "+a.add(q.Status))
+	}
+	if q.Department != "" {
+		conds = append(conds, "d.name = "+a.add(q.Department))
+	}
+	if q.Language != "" {
+		conds = append(conds, "EXISTS (SELECT 1 FROM employee_languages f WHERE f.emp_id = e.emp_id AND f.language = "+a.add(q.Language)+")")
+	}
+	if len(q.Tags) > 0 {
+		conds = append(conds, "false")
+	}
+	if len(q.CustomFields) > 0 {
+		b, err := json.Marshal(q.CustomFields)
+		if err != nil {
+			return "", fmt.Errorf("custom field filter: %w", err)
+		}
+		// jsonb compares numbers by value, so 3 matches a stored 3.0
+		conds = append(conds, "e.custom_fields @> "+a.add(string(b))+"::jsonb")
+	}
+	return strings.Join(conds, " AND "), nil
+}
+
+// sortColumn is the expression a row field sorts on, "" for fields the rows never carry.
+// Text sorts byte-wise, as MongoDB does.
+func sortColumn(field string, a *sqlArgs) string {
+	switch field {
+	case "emp_id", "version", "manager_id", "updated_at":
+		return "e." + field
+	case "emp_name", "status":
+		return "e." + field + ` COLLATE "C"`
+	case "department":
+		return `d.name COLLATE "C"`
+	case "language":
+		return `(array_agg(l.language ORDER BY l.position))[1] COLLATE "C"`
+	case "termination":
+		return "e.termination_end_date"
+	}
+	if name, ok := strings.CutPrefix(field, "custom_fields."); ok {
+		return "e.custom_fields -> " + a.add(name) + "::text"
+	}
+	return ""
+}
+
+// listQuery selects the rows q asks for, and only empId's unless it is 0, in q's order
+// and with its page. Each employee is grouped with its languages, in their order.
+func listQuery(q models.EmployeeQuery, empId int) (string, []any, error) {
+	var a sqlArgs
+	where, err := listWhere(q, empId, &a)
+	if err != nil {
+		return "", nil, err
+	}
+	var order []string
+	for _, k := range q.Sort {
+		col := sortColumn(k.Key, &a)
+		if col == "" {
+			continue
+		}
+		// missing values first, as MongoDB sorts them
+		if dir, _ := k.Value.(int); dir < 0 {
+			order = append(order, col+" DESC NULLS LAST")
+		} else {
+			order = append(order, col+" ASC NULLS FIRST")
+		}
+	}
+	order = append(order, "e.emp_id")
+
+	sql := `SELECT e.emp_id, e.emp_name, d.name, e.status, e.termination_end_date, e.termination_reason,
+		e.termination_note, e.terminated_at, e.custom_fields, e.manager_id, e.version, e.updated_at,
+		coalesce(array_agg(l.language ORDER BY l.position) FILTER (WHERE l.emp_id IS NOT NULL), '{}')
+	` + listFrom + `
+	LEFT JOIN employee_languages l ON l.emp_id = e.emp_id
+	WHERE ` + where + `
+	GROUP BY e.emp_id, d.name
+	ORDER BY ` + strings.Join(order, ", ")
+	if q.Limit > 0 {
+		sql += " LIMIT " + a.add(q.Limit) + " OFFSET " + a.add((q.Page-1)*q.Limit)
+	}
+	return sql, a, nil
+}
+
+// scanRow reads a row of listQuery into the shape the in-memory store gives its rows,
+// minus the hidden custom fields and the fields q leaves out
+func scanRow(r pgx.Row, q models.EmployeeQuery) (models.Row, error) {
+	var (
+		e                                memoryEmployee
+		empId                            int
+		department, reason, note         *string
+		managerID                        *int
+		endDate, terminatedAt, updatedAt *time.Time
+		custom                           []byte
+	)
+	err := r.Scan(&empId, &e.empName, &department, &e.status, &endDate, &reason, &note, &terminatedAt,
+		&custom, &managerID, &e.version, &updatedAt, &e.languages)
+	if err != nil {
+		return nil, err
+	}
+	if department != nil {
+		e.department = *department
+	}
+	if managerID != nil {
+		e.managerID = *managerID
+	}
+	if terminatedAt != nil {
+		termination := bson.M{"reason": "", "note": "", "recorded_at": terminatedAt.UTC()}
+		if endDate != nil {
+			termination["end_date"] = endDate.UTC()
+		}
+		if reason != nil {
+			termination["reason"] = *reason
+		}
+		if note != nil {
+			termination["note"] = *note
+		}
+		e.termination = termination
+	}
+	if updatedAt != nil {
+		t := updatedAt.UTC()
+		e.updatedAt = &t
+	}
+	if err := json.Unmarshal(custom, &e.customFields); err != nil {
+		return nil, fmt.Errorf("custom_fields of %d: %w", empId, err)
+	}
+	return bson.Marshal(project(e.row(empId, q.Hidden), q.Fields))
+}
+
+// notFound turns a missing row into models.ErrNotFound
+func notFound(err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.ErrNotFound
+	}
+	return err
+}
+
+// nullID is id, or NULL when it is 0
+func nullID(id int) *int {
+	if id == 0 {
+		return nil
+	}
+	return &id
+}
+
+func (s *Postgres) List(ctx context.Context, q models.EmployeeQuery) ([]models.Row, int, error) {
+	sql, args, err := listQuery(q, 0)
+	if err != nil {
+		return nil, 0, err
+	}
+	rows, err := s.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	raws := []models.Row{}
+	for rows.Next() {
+		raw, err := scanRow(rows, q)
+		if err != nil {
+			return nil, 0, err
+		}
+		raws = append(raws, raw)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	if q.Limit == 0 {
+		return raws, len(raws), nil
+	}
+
+	// the page may be past the last one, so the total is counted apart
+	var a sqlArgs
+	where, err := listWhere(q, 0, &a)
+	if err != nil {
+		return nil, 0, err
+	}
+	var total int
+	if err := s.pool.QueryRow(ctx, "SELECT count(*) "+listFrom+" WHERE "+where, a...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	return raws, total, nil
+}
+
+func (s *Postgres) Stream(ctx context.Context, q models.EmployeeQuery) iter.Seq2[models.Row, error] {
+	return func(yield func(models.Row, error) bool) {
+		q.Page, q.Limit = 0, 0
+		sql, args, err := listQuery(q, 0)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		rows, err := s.pool.Query(ctx, sql, args...)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer rows.Close()
+		for rows.Next() {
+			raw, err := scanRow(rows, q)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if !yield(raw, nil) {
+				return
+			}
+		}
+		if err := rows.Err(); err != nil {
+			yield(nil, err)
+		}
+	}
+}
+
+// NextIDs leaves the counter alone when it has fewer than n ids left
+func (s *Postgres) NextIDs(ctx context.Context, n int) (int, error) {
+	var last int
+	err := s.pool.QueryRow(ctx, "UPDATE id_counter SET seq = seq + $1 WHERE seq <= $2 RETURNING seq", n, models.MaxEmpID-n).Scan(&last)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, models.ErrIDOutOfRange
+	}
+	return last - n + 1, err
+}
+
+func (s *Postgres) ReserveID(ctx context.Context, empId int) error {
+	if empId > models.MaxEmpID {
+		return models.ErrIDOutOfRange
+	}
+	_, err := s.pool.Exec(ctx, "UPDATE id_counter SET seq = greatest(seq, $1)", empId)
+	return err
+}
+
+func (s *Postgres) LastID(ctx context.Context) (int, error) {
+	var last int
+	err := s.pool.QueryRow(ctx, "SELECT coalesce(max(emp_id), 0) FROM employees").Scan(&last)
+	return last, err
+}
+
+func (s *Postgres) Exists(ctx context.Context, empId int) (bool, error) {
+	var ok bool
+	err := s.pool.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM employees WHERE emp_id = $1)", empId).Scan(&ok)
+	return ok, err
+}
+
+func (s *Postgres) Version(ctx context.Context, empId int) (int, error) {
+	var version int
+	err := s.pool.QueryRow(ctx, "SELECT version FROM employees WHERE emp_id = $1 AND deleted_at IS NULL", empId).Scan(&version)
+	return version, notFound(err)
+}
+
+// Managers follows manager_id one live employee at a time, stopping at a cycle
+func (s *Postgres) Managers(ctx context.Context, empId int) ([]int, error) {
+	manager := func(id int) (*int, error) {
+		var m *int
+		err := s.pool.QueryRow(ctx, "SELECT manager_id FROM employees WHERE emp_id = $1 AND deleted_at IS NULL", id).Scan(&m)
+		return m, err
+	}
+	m, err := manager(empId)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	chain := []int{}
+	for m != nil && !slices.Contains(chain, *m) {
+		next, err := manager(*m)
+		if errors.Is(err, pgx.ErrNoRows) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, *m)
+		m = next
+	}
+	return chain, nil
+}
+
+func (s *Postgres) Get(ctx context.Context, empId int, hidden []string) (models.Row, error) {
+	q := models.EmployeeQuery{Hidden: hidden}
+	sql, args, err := listQuery(q, empId)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := scanRow(s.pool.QueryRow(ctx, sql, args...), q)
+	return raw, notFound(err)
+}
+
+// departmentID is the id of the department called name, ignoring case, created when there
+// is none; nil for no department
+func departmentID(ctx context.Context, q querier, name string) (*int, error) {
+	if name == "" {
+		return nil, nil
+	}
+	if _, err := q.Exec(ctx, "INSERT INTO departments (name) VALUES ($1) ON CONFLICT ((lower(name))) DO NOTHING", name); err != nil {
+		return nil, fmt.Errorf("insert department: %w", err)
+	}
+	var id int
+	if err := q.QueryRow(ctx, "SELECT dept_id FROM departments WHERE lower(name) = lower($1)", name).Scan(&id); err != nil {
+		return nil, fmt.Errorf("find department: %w", err)
+	}
+	return &id, nil
+}
+
+// audit records an entry in audit_log
+func audit(ctx context.Context, q querier, action string, empId int, actor string, details any) error {
+	b, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+	_, err = q.Exec(ctx, "INSERT INTO audit_log (action, emp_id, actor, details) VALUES ($1, $2, $3, $4)", action, empId, actor, string(b))
+	if err != nil {
+		return fmt.Errorf("insert audit entry: %w", err)
+	}
+	return nil
+}
+
+func (s *Postgres) Create(ctx context.Context, e models.NewEmployee, actor string) error {
+	return s.CreateMany(ctx, []models.NewEmployee{e}, actor, "")
+}
+
+// CreateMany adds all of list or, when an emp_id is taken, none. The rows go in one batch
+// once the departments are resolved.
+func (s *Postgres) CreateMany(ctx context.Context, list []models.NewEmployee, actor, source string) error {
+	return pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		deptIDs := map[string]*int{}
+		for _, e := range list {
+			if _, ok := deptIDs[e.Department]; !ok {
+				id, err := departmentID(ctx, tx, e.Department)
+				if err != nil {
+					return fmt.Errorf("resolve department %q: %w", e.Department, err)
+				}
+				deptIDs[e.Department] = id
+			}
+		}
+
+		var batch pgx.Batch
+		for _, e := range list {
+			custom := e.CustomFields
+			if custom == nil {
+				custom = bson.M{}
+			}
+			b, err := json.Marshal(custom)
+			if err != nil {
+				return fmt.Errorf("custom_fields of %d: %w", e.EmpID, err)
+			}
+			batch.Queue("INSERT INTO employees (emp_id, emp_name, dept_id, manager_id, custom_fields) VALUES ($1, $2, $3, $4, $5)",
+				e.EmpID, e.EmpName, deptIDs[e.Department], nullID(e.ManagerID), string(b))
+			// like the Mongo store, an employee without languages gets an empty one
+			languages := e.Languages
+			if len(languages) == 0 {
+				languages = []string{""}
+			}
+			for pos, l := range languages {
+				batch.Queue("INSERT INTO employee_languages (emp_id, position, language) VALUES ($1, $2, $3)", e.EmpID, pos, l)
+			}
+			details := map[string]any{"emp_name": e.EmpName, "department": e.Department, "languages": e.Languages}
+			if source != "" {
+				details["source"] = source
+			}
+			b, err = json.Marshal(details)
+			if err != nil {
+				return err
+			}
+			batch.Queue("INSERT INTO audit_log (action, emp_id, actor, details) VALUES ('create', $1, $2, $3)", e.EmpID, actor, string(b))
+		}
+		if err := tx.SendBatch(ctx, &batch).Close(); err != nil {
+			return fmt.Errorf("insert employees: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *Postgres) Update(ctx context.Context, empId int, c models.EmployeeChange, actor string) error {
+	return pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		var version int
+		err := tx.QueryRow(ctx, "SELECT version FROM employees WHERE emp_id = $1 AND deleted_at IS NULL FOR UPDATE", empId).Scan(&version)
+		if err != nil {
+			return notFound(err)
+		}
+		if c.Version != nil && *c.Version != version {
+			return models.ErrVersionConflict
+		}
+
+		var a sqlArgs
+		set := []string{"version = version + 1", "updated_at = now()"}
+		if c.EmpName != nil {
+			set = append(set, "emp_name = "+a.add(*c.EmpName))
+		}
+		if c.Department != nil {
+			id, err := departmentID(ctx, tx, *c.Department)
+			if err != nil {
+				return err
+			}
+			set = append(set, "dept_id = "+a.add(id))
+		}
+		if c.ManagerID != nil {
+			set = append(set, "manager_id = "+a.add(nullID(*c.ManagerID)))
+		}
+		custom := "custom_fields"
+		if len(c.CustomFields) > 0 {
+			b, err := json.Marshal(c.CustomFields)
+			if err != nil {
+				return fmt.Errorf("custom_fields: %w", err)
+			}
+			custom = "(" + custom + " || " + a.add(string(b)) + "::jsonb)"
+		}
+		if len(c.ClearFields) > 0 {
+			custom += " - " + a.add(c.ClearFields) + "::text[]"
+		}
+		if custom != "custom_fields" {
+			set = append(set, "custom_fields = "+custom)
+		}
+		if _, err := tx.Exec(ctx, "UPDATE employees SET "+strings.Join(set, ", ")+" WHERE emp_id = "+a.add(empId), a...); err != nil {
+			return fmt.Errorf("update employee: %w", err)
+		}
+
+		if c.Languages != nil {
+			if _, err := tx.Exec(ctx, "DELETE FROM employee_languages WHERE emp_id = $1", empId); err != nil {
+				return fmt.Errorf("update languages: %w", err)
+			}
+			for pos, l := range c.Languages {
+				if _, err := tx.Exec(ctx, "INSERT INTO employee_languages (emp_id, position, language) VALUES ($1, $2, $3)", empId, pos, l); err != nil {
+					return fmt.Errorf("update languages: %w", err)
+				}
+			}
+		}
+
+		// audited like the request body: cleared custom fields show up as null
+		var customDetails map[string]interface{}
+		if len(c.CustomFields) > 0 || len(c.ClearFields) > 0 {
+			customDetails = map[string]interface{}{}
+			for name, v := range c.CustomFields {
+				customDetails[name] = v
+			}
+			for _, name := range c.ClearFields {
+				customDetails[name] = nil
+			}
+		}
+		return audit(ctx, tx, "update", empId, actor, models.EmployeePayload{
+			EmpName:      c.EmpName,
+			Department:   c.Department,
+			Languages:    c.Languages,
+			ManagerID:    c.ManagerID,
+			CustomFields: customDetails,
+		})
+	})
+}
+
+func (s *Postgres) Terminate(ctx context.Context, empId int, t models.Termination, actor string) error {
+	return pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		var status string
+		err := tx.QueryRow(ctx, "SELECT status FROM employees WHERE emp_id = $1 AND deleted_at IS NULL FOR UPDATE", empId).Scan(&status)
+		if err != nil {
+			return notFound(err)
+		}
+		if status == "terminated" {
+			return models.ErrAlreadyTerminated
+		}
+		_, err = tx.Exec(ctx, `UPDATE employees SET status = 'terminated', termination_end_date = $2, termination_reason = $3,
+			termination_note = $4, terminated_at = now(), version = version + 1, updated_at = now()
+			WHERE emp_id = $1`, empId, t.EndDate, t.Reason, t.Note)
+		if err != nil {
+			return fmt.Errorf("terminate employee: %w", err)
+		}
+		return audit(ctx, tx, "terminate", empId, actor, map[string]any{"end_date": t.EndDate, "reason": t.Reason})
+	})
+}
+
+func (s *Postgres) SoftDelete(ctx context.Context, empId int, actor string) (int64, error) {
+	var n int64
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, "UPDATE employees SET deleted_at = now(), deleted_by = $2 WHERE emp_id = $1 AND deleted_at IS NULL", empId, actor)
+		if err != nil {
+			return err
+		}
+		if n = tag.RowsAffected(); n == 0 {
+			return nil
+		}
+		return audit(ctx, tx, "delete", empId, actor, nil)
+	})
+	return n, err
+}
+
+// Purge removes the employee and, through the foreign key, its languages. The audit log
+// keeps its entries.
+func (s *Postgres) Purge(ctx context.Context, empId int) error {
+	_, err := s.pool.Exec(ctx, "DELETE FROM employees WHERE emp_id = $1", empId)
+	return err
+}
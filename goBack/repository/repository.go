@@ -0,0 +1,135 @@
+// Package repository declares the stores the HTTP handlers read and write through and
+// implements them on MongoDB (see Mongo), on PostgreSQL and in memory.
+package repository
+
+import (
+	"context"
+	"iter"
+
+	"github.com/karthikeyan-meenachisundaram/goBack/models"
+)
+
+// EmployeeStore is the persistence behind the employee CRUD handlers. Mutations record
+// their own audit entries.
+type EmployeeStore interface {
+	// NextIDs allocates n consecutive emp_ids and returns the first;
+	// models.ErrIDOutOfRange when the last would pass models.MaxEmpID
+	NextIDs(ctx context.Context, n int) (int, error)
+	// ReserveID moves the id counter past an emp_id chosen by the caller;
+	// models.ErrIDOutOfRange when it is above models.MaxEmpID
+	ReserveID(ctx context.Context, empId int) error
+	// LastID is the highest emp_id in use, 0 when there are no employees
+	LastID(ctx context.Context) (int, error)
+	// Exists reports whether empId is in use, by a live or a deleted employee
+	Exists(ctx context.Context, empId int) (bool, error)
+	// Version is the version of a live employee; models.ErrNotFound when there is none
+	Version(ctx context.Context, empId int) (int, error)
+	// Managers are the managers above a live employee, in no particular order;
+	// models.ErrNotFound when there is none
+	Managers(ctx context.Context, empId int) ([]int, error)
+	// Get returns a live employee shaped like a list row, minus the hidden custom
+	// fields; models.ErrNotFound when there is none
+	Get(ctx context.Context, empId int, hidden []string) (models.Row, error)
+	// List returns the page of live employees' rows q selects and how many it selects
+	// in all
+	List(ctx context.Context, q models.EmployeeQuery) ([]models.Row, int, error)
+	// Stream yields every row q selects, its page aside, without holding them all; a
+	// row is only valid until the next one. A failure is yielded last.
+	Stream(ctx context.Context, q models.EmployeeQuery) iter.Seq2[models.Row, error]
+	Create(ctx context.Context, e models.NewEmployee, actor string) error
+	// CreateMany writes a batch of employees with one InsertMany per collection; source
+	// ("import", "batch") is noted in their audit entries
+	CreateMany(ctx context.Context, list []models.NewEmployee, actor, source string) error
+	Update(ctx context.Context, empId int, c models.EmployeeChange, actor string) error
+	// Terminate marks a live employee as terminated, keeping the record;
+	// models.ErrAlreadyTerminated when it already is
+	Terminate(ctx context.Context, empId int, t models.Termination, actor string) error
+	// SoftDelete marks a live employee as deleted and returns how many were marked
+	SoftDelete(ctx context.Context, empId int, actor string) (int64, error)
+	// Purge permanently removes an employee and all related records
+	Purge(ctx context.Context, empId int) error
+}
+
+// TransferStore keeps the department transfer history
+type TransferStore interface {
+	// Transfer moves t.EmpID into t.ToDepartment and records t, all or nothing
+	Transfer(ctx context.Context, t models.Transfer) error
+	// History is the transfer history of one employee, newest first
+	History(ctx context.Context, empId int) ([]models.Transfer, error)
+}
+
+// DepartmentStore keeps the departments employees belong to
+type DepartmentStore interface {
+	// List returns every department by name
+	List(ctx context.Context) ([]models.Department, error)
+	// Get returns the department with deptID; models.ErrNotFound when there is none
+	Get(ctx context.Context, deptID int) (models.Department, error)
+	// ByName returns the department called name, ignoring case; models.ErrNotFound when
+	// there is none
+	ByName(ctx context.Context, name string) (models.Department, error)
+	// Create adds a department; models.ErrDuplicate when the name is taken
+	Create(ctx context.Context, name string) (models.Department, error)
+	// Rename renames a department; models.ErrDuplicate when the name is taken
+	Rename(ctx context.Context, deptID int, name string) error
+	// Members counts the employees in a department, deleted ones included
+	Members(ctx context.Context, deptID int) (int64, error)
+	Delete(ctx context.Context, deptID int) error
+	// Reassign transfers the members of dept (only those in empIDs when given) to
+	// t.ToDepartment, recording t for each, all or nothing. Terminated and deleted members
+	// are skipped.
+	Reassign(ctx context.Context, dept models.Department, empIDs []int, t models.Transfer) (models.Reassignment, error)
+}
+
+// ReportStore computes the reports over all employees
+type ReportStore interface {
+	// Stats is the dashboard for the last days and the recent newest hires
+	Stats(ctx context.Context, days, recent int) (models.EmployeeStats, error)
+	// Snapshot is the saved default dashboard; models.ErrNotFound when there is none
+	Snapshot(ctx context.Context) (models.EmployeeStats, error)
+	SaveSnapshot(ctx context.Context, stats models.EmployeeStats) error
+	// Attrition counts terminations by period, department and reason, in that order
+	Attrition(ctx context.Context, q models.AttritionQuery) ([]models.AttritionRow, error)
+}
+
+// MergeStore finds and merges duplicate employees
+type MergeStore interface {
+	// Candidates are the live employees duplicate detection compares
+	Candidates(ctx context.Context) ([]models.DuplicateCandidate, error)
+	// Merge folds duplicate into primary, all or nothing, and soft-deletes it like
+	// EmployeeStore.SoftDelete; models.ErrNotFound when either is not a live employee
+	Merge(ctx context.Context, primary, duplicate int, actor string) (models.Merge, error)
+}
+
+// SearchStore ranks employees against a free-text query
+type SearchStore interface {
+	// Search returns the page of matching live employees asked for, best first, each
+	// shaped like a sparse list row with its score, and the number of matches
+	Search(ctx context.Context, q models.SearchQuery) ([]models.Row, int, error)
+}
+
+// ChangeRequestStore keeps the edits and deletes waiting for an approver
+type ChangeRequestStore interface {
+	// Submit stores cr and sets its ID
+	Submit(ctx context.Context, cr *models.ChangeRequest) error
+	// List returns the requests with status, of requestedBy, newest first; "" matches any
+	List(ctx context.Context, status, requestedBy string) ([]models.ChangeRequest, error)
+	// Get returns one request; models.ErrNotFound when there is none, or id isn't one the
+	// store hands out
+	Get(ctx context.Context, id string) (models.ChangeRequest, error)
+	// Decide approves or rejects a pending request, all or nothing; an approval runs apply
+	// first. models.ErrChangeNotPending when it was decided before.
+	Decide(ctx context.Context, id string, approve bool, approver, comment string, apply func(context.Context, models.ChangeRequest) error) (models.ChangeRequest, error)
+}
+
+// MetadataStore reads the definitions employee payloads and list filters are checked
+// against
+type MetadataStore interface {
+	// CustomFields are the custom field definitions, by name
+	CustomFields(ctx context.Context) ([]models.CustomField, error)
+	// ReferenceNames maps the lower-cased names of the "departments" or "languages"
+	// reference list to whether they are active; nil when the list has no entries
+	ReferenceNames(ctx context.Context, list string) (map[string]bool, error)
+	// SavedSearch is user's saved search called name, else a shared one;
+	// models.ErrNotFound when there is neither
+	SavedSearch(ctx context.Context, name, user string) (models.SavedSearch, error)
+}
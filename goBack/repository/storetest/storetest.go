@@ -0,0 +1,407 @@
+// Package storetest checks that an EmployeeStore behaves the way the handlers expect, so
+// the in-memory, Postgres and Mongo stores can be held to the same tests.
+package storetest
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/karthikeyan-meenachisundaram/goBack/models"
+	"github.com/karthikeyan-meenachisundaram/goBack/repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Run runs the tests every EmployeeStore passes, each on an empty store from open
+func Run(t *testing.T, open func(t *testing.T) repository.EmployeeStore) {
+	tests := []struct {
+		name string
+		fn   func(*testing.T, repository.EmployeeStore)
+	}{
+		{"IDs", testIDs},
+		{"CreateAndGet", testCreateAndGet},
+		{"Update", testUpdate},
+		{"Managers", testManagers},
+		{"TerminateDeletePurge", testTerminateDeletePurge},
+		{"List", testList},
+		{"ListFields", testListFields},
+		{"Stream", testStream},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) { tt.fn(t, open(t)) })
+	}
+}
+
+// RunAtomicBatches checks that CreateMany adds all of a batch or none, which the stores
+// with transactions promise
+func RunAtomicBatches(t *testing.T, open func(t *testing.T) repository.EmployeeStore) {
+	ctx := context.Background()
+	s := open(t)
+	if err := s.Create(ctx, models.NewEmployee{EmpID: 2, EmpName: "Ravi"}, "tester"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CreateMany(ctx, []models.NewEmployee{{EmpID: 1, EmpName: "Asha"}, {EmpID: 2, EmpName: "Again"}}, "tester", "import"); err == nil {
+		t.Error("a batch with a taken emp_id succeeded")
+	}
+	if err := s.CreateMany(ctx, []models.NewEmployee{{EmpID: 3, EmpName: "C"}, {EmpID: 3, EmpName: "D"}}, "tester", "import"); err == nil {
+		t.Error("a batch repeating an emp_id succeeded")
+	}
+	for _, id := range []int{1, 3} {
+		if ok, _ := s.Exists(ctx, id); ok {
+			t.Errorf("a failed batch left employee %d behind", id)
+		}
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
+
+// intValue is a row's number as an int, whichever BSON integer it was stored as
+func intValue(v interface{}) int {
+	switch n := v.(type) {
+	case int32:
+		return int(n)
+	case int64:
+		return int(n)
+	case float64:
+		return int(n)
+	}
+	return -1
+}
+
+// row decodes a stored employee the way the handlers read list rows
+func row(t *testing.T, s repository.EmployeeStore, empId int) bson.M {
+	t.Helper()
+	raw, err := s.Get(context.Background(), empId, nil)
+	if err != nil {
+		t.Fatalf("Get(%d): %v", empId, err)
+	}
+	var m bson.M
+	if err := bson.Unmarshal(raw, &m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func testIDs(t *testing.T, s repository.EmployeeStore) {
+	ctx := context.Background()
+	if last, _ := s.LastID(ctx); last != 0 {
+		t.Errorf("LastID without employees = %d, want 0", last)
+	}
+	if first, err := s.NextIDs(ctx, 3); err != nil || first != 1 {
+		t.Errorf("first id = %d, %v; want 1", first, err)
+	}
+	if err := s.ReserveID(ctx, 10); err != nil {
+		t.Fatal(err)
+	}
+	if next, _ := s.NextIDs(ctx, 1); next != 11 {
+		t.Errorf("id after reserving 10 = %d, want 11", next)
+	}
+	// reserving below the counter doesn't move it back
+	_ = s.ReserveID(ctx, 2)
+	if next, _ := s.NextIDs(ctx, 1); next != 12 {
+		t.Errorf("id = %d, want 12", next)
+	}
+
+	// the counter stops at the largest emp_id instead of running past it
+	if err := s.ReserveID(ctx, models.MaxEmpID+1); !errors.Is(err, models.ErrIDOutOfRange) {
+		t.Errorf("reserving past the largest emp_id = %v, want ErrIDOutOfRange", err)
+	}
+	if err := s.ReserveID(ctx, models.MaxEmpID-2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.NextIDs(ctx, 3); !errors.Is(err, models.ErrIDOutOfRange) {
+		t.Errorf("3 ids with 2 left = %v, want ErrIDOutOfRange", err)
+	}
+	if next, err := s.NextIDs(ctx, 2); err != nil || next != models.MaxEmpID-1 {
+		t.Errorf("last 2 ids = %d, %v; want %d", next, err, models.MaxEmpID-1)
+	}
+	if _, err := s.NextIDs(ctx, 1); !errors.Is(err, models.ErrIDOutOfRange) {
+		t.Errorf("id after the largest = %v, want ErrIDOutOfRange", err)
+	}
+}
+
+func testCreateAndGet(t *testing.T, s repository.EmployeeStore) {
+	ctx := context.Background()
+	err := s.CreateMany(ctx, []models.NewEmployee{
+		{EmpID: 1, EmpName: "Asha", Department: "Engg", Languages: []string{"Go", "Rust"}, CustomFields: bson.M{"shirt": "M", "salary": 10.0}},
+		{EmpID: 2, EmpName: "Ravi", Department: "Sales", ManagerID: 1},
+	}, "tester", "batch")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	asha := row(t, s, 1)
+	if asha["emp_name"] != "Asha" || asha["department"] != "Engg" || asha["language"] != "Go" || asha["status"] != "active" {
+		t.Errorf("row = %v", asha)
+	}
+	if langs, _ := asha["languages"].(bson.A); !slices.Equal(langs, bson.A{"Go", "Rust"}) {
+		t.Errorf("languages = %v", asha["languages"])
+	}
+	if v := intValue(asha["version"]); v != 0 {
+		t.Errorf("version of a new employee = %v", asha["version"])
+	}
+	ravi := row(t, s, 2)
+	if intValue(ravi["manager_id"]) != 1 {
+		t.Errorf("manager_id = %v, want 1", ravi["manager_id"])
+	}
+	// an employee without languages gets an empty one, as the Mongo store has always done
+	if langs, _ := ravi["languages"].(bson.A); !slices.Equal(langs, bson.A{""}) {
+		t.Errorf("languages = %v, want one empty language", langs)
+	}
+
+	raw, err := s.Get(ctx, 1, []string{"custom_fields.salary"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if custom := bson.Raw(raw).Lookup("custom_fields").Document(); custom.Lookup("salary").Value != nil || custom.Lookup("shirt").StringValue() != "M" {
+		t.Errorf("custom fields with salary hidden = %v", custom)
+	}
+
+	if err := s.Create(ctx, models.NewEmployee{EmpID: 2, EmpName: "Again"}, "tester"); err == nil {
+		t.Error("creating a taken emp_id succeeded")
+	}
+	if ok, _ := s.Exists(ctx, 2); !ok {
+		t.Error("Exists(2) = false")
+	}
+	if last, _ := s.LastID(ctx); last != 2 {
+		t.Errorf("LastID = %d, want 2", last)
+	}
+	if _, err := s.Get(ctx, 99, nil); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("Get(99) = %v, want ErrNotFound", err)
+	}
+}
+
+func testUpdate(t *testing.T, s repository.EmployeeStore) {
+	ctx := context.Background()
+	if err := s.Create(ctx, models.NewEmployee{EmpID: 1, EmpName: "Asha", Department: "Engg", ManagerID: 7, CustomFields: bson.M{"shirt": "M"}}, "tester"); err != nil {
+		t.Fatal(err)
+	}
+
+	err := s.Update(ctx, 1, models.EmployeeChange{
+		Version:      ptr(0),
+		EmpName:      ptr("Asha Rao"),
+		Department:   ptr("Sales"),
+		Languages:    []string{"Python"},
+		ManagerID:    ptr(0),
+		CustomFields: bson.M{"desk": "4B"},
+		ClearFields:  []string{"shirt"},
+	}, "tester")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := row(t, s, 1)
+	if m["emp_name"] != "Asha Rao" || m["department"] != "Sales" || m["language"] != "Python" || intValue(m["version"]) != 1 {
+		t.Errorf("row = %v", m)
+	}
+	if m["manager_id"] != nil {
+		t.Errorf("manager_id = %v after removing the manager", m["manager_id"])
+	}
+	if m["updated_at"] == nil {
+		t.Error("updated_at not set")
+	}
+	if custom, _ := m["custom_fields"].(bson.M); custom["desk"] != "4B" || custom["shirt"] != nil {
+		t.Errorf("custom fields = %v", custom)
+	}
+
+	// the change was based on version 0, which is gone now
+	if err := s.Update(ctx, 1, models.EmployeeChange{Version: ptr(0), EmpName: ptr("Late")}, "tester"); !errors.Is(err, models.ErrVersionConflict) {
+		t.Errorf("stale update = %v, want ErrVersionConflict", err)
+	}
+	if err := s.Update(ctx, 9, models.EmployeeChange{EmpName: ptr("Nobody")}, "tester"); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("update of a missing employee = %v, want ErrNotFound", err)
+	}
+	if v, _ := s.Version(ctx, 1); v != 1 {
+		t.Errorf("version = %d, want 1", v)
+	}
+	if _, err := s.Version(ctx, 9); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("Version(9) = %v, want ErrNotFound", err)
+	}
+}
+
+func testManagers(t *testing.T, s repository.EmployeeStore) {
+	ctx := context.Background()
+	if err := s.CreateMany(ctx, []models.NewEmployee{
+		{EmpID: 1, EmpName: "CEO"},
+		{EmpID: 2, EmpName: "VP", ManagerID: 1},
+		{EmpID: 3, EmpName: "Dev", ManagerID: 2},
+	}, "tester", ""); err != nil {
+		t.Fatal(err)
+	}
+	chain, err := s.Managers(ctx, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(chain)
+	if !slices.Equal(chain, []int{1, 2}) {
+		t.Errorf("managers of 3 = %v, want [1 2]", chain)
+	}
+	if chain, _ := s.Managers(ctx, 1); len(chain) != 0 {
+		t.Errorf("managers of 1 = %v, want none", chain)
+	}
+	// a deleted manager ends the chain
+	_, _ = s.SoftDelete(ctx, 2, "tester")
+	if chain, _ := s.Managers(ctx, 3); len(chain) != 0 {
+		t.Errorf("managers of 3 after deleting 2 = %v, want none", chain)
+	}
+	if _, err := s.Managers(ctx, 2); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("managers of a deleted employee = %v, want ErrNotFound", err)
+	}
+}
+
+func testTerminateDeletePurge(t *testing.T, s repository.EmployeeStore) {
+	ctx := context.Background()
+	if err := s.CreateMany(ctx, []models.NewEmployee{{EmpID: 1, EmpName: "Asha"}, {EmpID: 2, EmpName: "Ravi"}}, "tester", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	end := time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
+	if err := s.Terminate(ctx, 1, models.Termination{EndDate: end, Reason: "layoff"}, "tester"); err != nil {
+		t.Fatal(err)
+	}
+	m := row(t, s, 1)
+	if m["status"] != "terminated" || intValue(m["version"]) != 1 {
+		t.Errorf("row = %v", m)
+	}
+	term, _ := m["termination"].(bson.M)
+	if endDate, _ := term["end_date"].(primitive.DateTime); term["reason"] != "layoff" || !endDate.Time().Equal(end) {
+		t.Errorf("termination = %v", m["termination"])
+	}
+	if err := s.Terminate(ctx, 1, models.Termination{EndDate: end, Reason: "layoff"}, "tester"); !errors.Is(err, models.ErrAlreadyTerminated) {
+		t.Errorf("second termination = %v, want ErrAlreadyTerminated", err)
+	}
+	if err := s.Terminate(ctx, 9, models.Termination{EndDate: end}, "tester"); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("termination of a missing employee = %v, want ErrNotFound", err)
+	}
+
+	if n, _ := s.SoftDelete(ctx, 2, "tester"); n != 1 {
+		t.Errorf("deleted %d, want 1", n)
+	}
+	if n, _ := s.SoftDelete(ctx, 2, "tester"); n != 0 {
+		t.Errorf("deleted again %d, want 0", n)
+	}
+	if _, err := s.Get(ctx, 2, nil); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("Get of a deleted employee = %v, want ErrNotFound", err)
+	}
+	// the id stays taken until the employee is purged
+	if ok, _ := s.Exists(ctx, 2); !ok {
+		t.Error("a deleted employee's id is free")
+	}
+	if last, _ := s.LastID(ctx); last != 2 {
+		t.Errorf("LastID = %d, want the deleted employee's 2", last)
+	}
+	if err := s.Purge(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := s.Exists(ctx, 2); ok {
+		t.Error("a purged employee's id is taken")
+	}
+}
+
+// listIDs lists with q and returns the emp_ids of the rows and the total
+func listIDs(t *testing.T, s repository.EmployeeStore, q models.EmployeeQuery) ([]int, int) {
+	t.Helper()
+	raws, total, err := s.List(context.Background(), q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []int{}
+	for _, raw := range raws {
+		ids = append(ids, int(bson.Raw(raw).Lookup("emp_id").Int32()))
+	}
+	return ids, total
+}
+
+func testList(t *testing.T, s repository.EmployeeStore) {
+	ctx := context.Background()
+	if err := s.CreateMany(ctx, []models.NewEmployee{
+		{EmpID: 3, EmpName: "Meena", Department: "Engg", Languages: []string{"Python"}, CustomFields: bson.M{"level": 2.0}},
+		{EmpID: 1, EmpName: "Ravi", Department: "Sales", Languages: []string{"Go"}, CustomFields: bson.M{"level": 3.0}},
+		{EmpID: 2, EmpName: "Asha", Department: "Engg", Languages: []string{"Rust", "Go"}, CustomFields: bson.M{"level": 3.0, "shirt": "M"}},
+		{EmpID: 4, EmpName: "Gone", Department: "Engg"},
+	}, "tester", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Terminate(ctx, 3, models.Termination{Reason: "layoff"}, "tester"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SoftDelete(ctx, 4, "tester"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		q     models.EmployeeQuery
+		want  []int
+		total int
+	}{
+		{"all live in emp_id order", models.EmployeeQuery{}, []int{1, 2, 3}, 3},
+		{"active", models.EmployeeQuery{Status: "active"}, []int{1, 2}, 2},
+		{"terminated", models.EmployeeQuery{Status: "terminated"}, []int{3}, 1},
+		{"department", models.EmployeeQuery{Department: "Engg"}, []int{2, 3}, 2},
+		{"any language", models.EmployeeQuery{Language: "Go"}, []int{1, 2}, 2},
+		{"custom field", models.EmployeeQuery{CustomFields: map[string]interface{}{"level": 3.0, "shirt": "M"}}, []int{2}, 1},
+		{"sort by name", models.EmployeeQuery{Sort: bson.D{{Key: "emp_name", Value: 1}}}, []int{2, 3, 1}, 3},
+		{"sort by department, then name", models.EmployeeQuery{Sort: bson.D{{Key: "department", Value: -1}, {Key: "emp_name", Value: 1}}}, []int{1, 2, 3}, 3},
+		{"sort ties on emp_id", models.EmployeeQuery{Sort: bson.D{{Key: "custom_fields.level", Value: -1}}}, []int{1, 2, 3}, 3},
+		{"page", models.EmployeeQuery{Sort: bson.D{{Key: "emp_name", Value: -1}}, Page: 2, Limit: 2}, []int{2}, 3},
+		{"past the last page", models.EmployeeQuery{Page: 3, Limit: 2}, []int{}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids, total := listIDs(t, s, tt.q)
+			if !slices.Equal(ids, tt.want) || total != tt.total {
+				t.Errorf("List = %v (total %d), want %v (total %d)", ids, total, tt.want, tt.total)
+			}
+		})
+	}
+}
+
+func testListFields(t *testing.T, s repository.EmployeeStore) {
+	ctx := context.Background()
+	if err := s.Create(ctx, models.NewEmployee{EmpID: 1, EmpName: "Asha", Department: "Engg", CustomFields: bson.M{"salary": 10.0, "shirt": "M"}}, "tester"); err != nil {
+		t.Fatal(err)
+	}
+
+	raws, _, err := s.List(ctx, models.EmployeeQuery{Fields: []string{"emp_id", "emp_name", "custom_fields"}, Hidden: []string{"custom_fields.salary"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(raws) != 1 {
+		t.Fatalf("listed %d rows, want 1", len(raws))
+	}
+	var got bson.M
+	if err := bson.Unmarshal(raws[0], &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 || got["emp_name"] != "Asha" || got["department"] != nil {
+		t.Errorf("row = %v, want emp_id, emp_name and custom_fields only", got)
+	}
+	if cf, _ := got["custom_fields"].(bson.M); cf["salary"] != nil || cf["shirt"] != "M" {
+		t.Errorf("custom_fields = %v, want the hidden salary left out", got["custom_fields"])
+	}
+}
+
+func testStream(t *testing.T, s repository.EmployeeStore) {
+	ctx := context.Background()
+	if err := s.CreateMany(ctx, []models.NewEmployee{{EmpID: 1, EmpName: "Asha"}, {EmpID: 2, EmpName: "Ravi"}, {EmpID: 3, EmpName: "Meena"}}, "tester", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	// the page is ignored
+	for raw, err := range s.Stream(ctx, models.EmployeeQuery{Sort: bson.D{{Key: "emp_name", Value: 1}}, Page: 1, Limit: 1}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, bson.Raw(raw).Lookup("emp_name").StringValue())
+		if len(names) == 2 {
+			break
+		}
+	}
+	if !slices.Equal(names, []string{"Asha", "Meena"}) {
+		t.Errorf("streamed %v", names)
+	}
+}
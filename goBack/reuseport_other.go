@@ -0,0 +1,13 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd)
+
+package main
+
+import "syscall"
+
+// reusePortSupported is false on platforms without SO_REUSEPORT
+const reusePortSupported = false
+
+// reusePort is a no-op where SO_REUSEPORT is unavailable
+func reusePort(network, address string, c syscall.RawConn) error {
+	return nil
+}
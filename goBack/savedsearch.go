@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/karthikeyan-meenachisundaram/goBack/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// listColumns are the fields of the employee list that can be sorted on or selected
+var listColumns = map[string]bool{
+	"emp_id":        true,
+	"emp_name":      true,
+	"department":    true,
+	"language":      true,
+	"status":        true,
+	"termination":   true,
+	"custom_fields": true,
+	"tags":          true,
+	"languages":     true,
+	"photo_url":     true,
+	"version":       true,
+	"updated_at":    true,
+}
+
+// parseSort turns "emp_name,-emp_id" into a $sort document
+func parseSort(s string) (bson.D, error) {
+	sort := bson.D{}
+	for _, f := range strings.Split(s, ",") {
+		dir := 1
+		if strings.HasPrefix(f, "-") {
+			dir, f = -1, f[1:]
+		}
+		if !listColumns[f] && !strings.HasPrefix(f, "custom_fields.") {
+			return nil, fmt.Errorf("cannot sort on %q", f)
+		}
+		sort = append(sort, bson.E{Key: f, Value: dir})
+	}
+	return sort, nil
+}
+
+// parseColumns turns "emp_id,emp_name" into the fields to keep, emp_id first (it always
+// is kept)
+func parseColumns(s string) ([]string, error) {
+	fields := []string{"emp_id"}
+	for _, c := range strings.Split(s, ",") {
+		c = strings.TrimSpace(c)
+		if !listColumns[c] {
+			return nil, fmt.Errorf("unknown column %q", c)
+		}
+		if !slices.Contains(fields, c) {
+			fields = append(fields, c)
+		}
+	}
+	return fields, nil
+}
+
+// checkSavedSearch validates the stored filter, sort and columns
+func checkSavedSearch(s *models.SavedSearch) error {
+	if s.Name == "" || len(s.Name) > 100 {
+		return fmt.Errorf("name is required (max 100 characters)")
+	}
+	for k := range s.Query {
+		if k != "status" && k != "tag" && k != "department" && k != "language" && !strings.HasPrefix(k, "cf.") {
+			return fmt.Errorf("unsupported filter %q", k)
+		}
+	}
+	if s.Sort != "" {
+		if _, err := parseSort(s.Sort); err != nil {
+			return err
+		}
+	}
+	if s.Columns != "" {
+		if _, err := parseColumns(s.Columns); err != nil {
+			return err
+		}
+	}
+	if s.Query == nil {
+		s.Query = map[string][]string{}
+	}
+	return nil
+}
+
+// ---------------- Handlers ----------------
+
+// savedSearchesHandler handles GET (list own + shared) and POST (create) on /api/saved-searches
+func savedSearchesHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := actorFromRequest(r)
+
+	switch r.Method {
+	case http.MethodGet:
+		filter := bson.M{"$or": bson.A{bson.M{"owner": user}, bson.M{"shared": true}}}
+		cur, err := coll(ctx, "SavedSearches").Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+		if err != nil {
+			storeError(w, "find saved searches", err)
+			return
+		}
+		defer cur.Close(ctx)
+		list := []models.SavedSearch{}
+		if err := cur.All(ctx, &list); err != nil {
+			storeError(w, "cursor all", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(list)
+	case http.MethodPost:
+		var s models.SavedSearch
+		if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+			httpError(w, "invalid input: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := checkSavedSearch(&s); err != nil {
+			httpError(w, "invalid saved search: "+err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		s.Owner = user
+		s.CreatedAt = time.Now().UTC()
+		s.UpdatedAt = s.CreatedAt
+		res, err := coll(ctx, "SavedSearches").UpdateOne(ctx, bson.M{"name": s.Name, "owner": user}, bson.M{"$setOnInsert": s}, options.Update().SetUpsert(true))
+		if err != nil {
+			storeError(w, "insert saved search", err)
+			return
+		}
+		if res.UpsertedCount == 0 {
+			httpError(w, "saved search already exists: "+s.Name, http.StatusConflict)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(s)
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// savedSearchByNameHandler handles GET, PUT and DELETE on /api/saved-searches/{name}.
+// Only the owner can change or delete a saved search.
+func savedSearchByNameHandler(w http.ResponseWriter, r *http.Request) {
+	name, err := url.PathUnescape(strings.TrimPrefix(r.URL.EscapedPath(), "/api/saved-searches/"))
+	if err != nil || name == "" {
+		httpError(w, "name required in path", http.StatusBadRequest)
+		return
+	}
+	ctx := r.Context()
+	user := actorFromRequest(r)
+
+	switch r.Method {
+	case http.MethodGet:
+		s, err := definitions.SavedSearch(ctx, name, user)
+		if err != nil {
+			if errors.Is(err, models.ErrNotFound) {
+				httpError(w, "saved search not found", http.StatusNotFound)
+				return
+			}
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(s)
+	case http.MethodPut:
+		var s models.SavedSearch
+		if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+			httpError(w, "invalid input: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.Name = name
+		if err := checkSavedSearch(&s); err != nil {
+			httpError(w, "invalid saved search: "+err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		update := bson.M{"$set": bson.M{
+			"shared":     s.Shared,
+			"query":      s.Query,
+			"sort":       s.Sort,
+			"columns":    s.Columns,
+			"updated_at": time.Now().UTC(),
+		}}
+		err := coll(ctx, "SavedSearches").FindOneAndUpdate(ctx, bson.M{"name": name, "owner": user}, update,
+			options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&s)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				httpError(w, "saved search not found", http.StatusNotFound)
+				return
+			}
+			storeError(w, "update saved search", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(s)
+	case http.MethodDelete:
+		res, err := coll(ctx, "SavedSearches").DeleteOne(ctx, bson.M{"name": name, "owner": user})
+		if err != nil {
+			storeError(w, "delete saved search", err)
+			return
+		}
+		if res.DeletedCount == 0 {
+			httpError(w, "saved search not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(bson.M{"message": "Saved search deleted successfully"})
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
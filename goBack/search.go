@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/karthikeyan-meenachisundaram/goBack/models"
+	"github.com/karthikeyan-meenachisundaram/goBack/service"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// defaultSearchWeights rank name matches above department and language matches;
+// search.weights overrides them per field
+var defaultSearchWeights = map[string]float64{
+	"emp_name":   3,
+	"department": 2,
+	"language":   1,
+	"tags":       1,
+}
+
+// searchHandler handles GET /api/employees/search?q=&page=&limit=, best matches first.
+// Every word of q is scored against each field and the scores add up, so "asha engg"
+// ranks Asha from Engg first; stemmed name matches from the text index count as a word
+// prefix match. Each result carries its relevance score. Only the employees the text
+// index or fieldMatches turn up are scored, so a name matches on whole (stemmed) words.
+func searchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		httpError(w, "q is required", http.StatusBadRequest)
+		return
+	}
+	page, limit, err := parsePage(r.URL.Query())
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	weights := cfg.Search.Weights
+
+	raws, total, err := searches.Search(r.Context(), models.SearchQuery{
+		Query:   q,
+		Words:   service.SearchWords(q),
+		Weights: weights,
+		Page:    page,
+		Limit:   limit,
+	})
+	if err != nil {
+		storeError(w, "search", err)
+		return
+	}
+	// always sparse so the score survives decoding
+	items, err := employeeRows(raws, true, apiVersion(r) == 2)
+	if err != nil {
+		storeError(w, "decode", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(bson.M{"query": q, "weights": weights, "items": items, "page": page, "limit": limit, "total": total})
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/karthikeyan-meenachisundaram/goBack/models"
+)
+
+func TestSearchHandler(t *testing.T) {
+	s := useTestStores(t)
+	s.addEmployees(t,
+		models.NewEmployee{EmpID: 1, EmpName: "Asha Rao", Department: "Engg"},
+		models.NewEmployee{EmpID: 2, EmpName: "Ravi Kumar", Department: "Ops"},
+	)
+
+	w := asAdmin(t, searchHandler, http.MethodGet, "/api/employees/search?q=asha+engg&limit=5", "")
+	expectStatus(t, w, http.StatusOK)
+	q := s.searches.query
+	if q.Query != "asha engg" || !slices.Equal(q.Words, []string{"asha", "engg"}) || q.Page != 1 || q.Limit != 5 {
+		t.Errorf("query = %+v", q)
+	}
+	if q.Weights["emp_name"] != defaultSearchWeights["emp_name"] {
+		t.Errorf("weights = %v", q.Weights)
+	}
+	out := decode(t, w)
+	items := out["items"].([]interface{})
+	if out["total"] != 1.0 || len(items) != 1 || items[0].(map[string]interface{})["score"] != 1.0 {
+		t.Errorf("result = %v", out)
+	}
+
+	w = asAdmin(t, searchHandler, http.MethodGet, "/api/employees/search?q=+", "")
+	expectStatus(t, w, http.StatusBadRequest)
+	w = asAdmin(t, searchHandler, http.MethodGet, "/api/employees/search?q=asha&page=0", "")
+	expectStatus(t, w, http.StatusBadRequest)
+}
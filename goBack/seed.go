@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"time"
+
+	"github.com/karthikeyan-meenachisundaram/goBack/models"
+)
+
+// seedActor is who seeded employees show up as in the audit log
+const seedActor = "seed"
+
+// sample values for generated employees
+var (
+	seedFirstNames = []string{
+		"Asha", "Bruno", "Chen", "Divya", "Elena", "Farid", "Grace", "Hiroshi", "Ines", "Jamal",
+		"Karthik", "Lena", "Mateo", "Nadia", "Omar", "Priya", "Quinn", "Rosa", "Sanjay", "Tomas",
+		"Uma", "Victor", "Wei", "Ximena", "Yusuf", "Zara",
+	}
+	seedLastNames = []string{
+		"Anand", "Becker", "Costa", "Dubois", "Eriksen", "Fernandes", "Garcia", "Hassan", "Ito",
+		"Jensen", "Kowalski", "Lopez", "Menon", "Nakamura", "Okafor", "Patel", "Rossi", "Schmidt",
+		"Tanaka", "Usman", "Varga", "Wong", "Yilmaz", "Zhang",
+	}
+	seedDepartments = []string{"Engineering", "Sales", "Marketing", "Finance", "HR", "Support", "Operations", "Design"}
+	seedLanguages   = []string{"Go", "Python", "JavaScript", "TypeScript", "Java", "Rust", "C#", "Kotlin", "Ruby", "SQL"}
+)
+
+// seedCommand runs "goback seed": it adds the employees of a fixture file (-file, a JSON
+// array of create payloads as POST /api/employees/batch takes them) and/or -count
+// generated ones. They are written through the employee store like an import, so ids,
+// departments and audit entries look the same; nothing is written if any entry is invalid.
+func seedCommand(args []string) int {
+	fs := flag.NewFlagSet("seed", flag.ContinueOnError)
+	configPath := fs.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML or JSON config file")
+	file := fs.String("file", "", "JSON fixture file with an array of employees")
+	count := fs.Int("count", 0, "number of generated employees to add")
+	seed := fs.Uint64("seed", 0, "random seed for generated employees, for repeatable data (0 picks one)")
+	dryRun := fs.Bool("dry-run", false, "validate the employees without writing them")
+	org := fs.String("org", defaultOrg, "organization to seed")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: goback seed [-config file] [-org id] [-file seed.json] [-count n] [-seed n] [-dry-run]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *file == "" && *count <= 0 {
+		fmt.Fprintln(os.Stderr, "seed: nothing to do, pass -file and/or -count")
+		fs.Usage()
+		return 2
+	}
+	var err error
+	if cfg, err = loadConfig(*configPath); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%s\n", err)
+		return 2
+	}
+	initLogger()
+
+	var payloads []models.EmployeePayload
+	if *file != "" {
+		if payloads, err = readSeedFile(*file); err != nil {
+			slog.Error("seed: read fixture", "file", *file, "err", err)
+			return 1
+		}
+	}
+	if *count > 0 {
+		if *seed == 0 {
+			*seed = rand.Uint64()
+		}
+		payloads = append(payloads, fakeEmployees(*count, *seed)...)
+		slog.Info("seed: generated employees", "count", *count, "seed", *seed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+	connectMongo(ctx)
+	defer func() { _ = client.Disconnect(context.Background()) }()
+	if ok, err := orgExists(ctx, *org); err != nil || !ok {
+		slog.Error("seed: unknown organization", "org", *org, "err", err)
+		return 1
+	}
+	ctx = withOrg(ctx, *org)
+	initIDCounter(ctx)
+	ensureIndexes(ctx)
+
+	defs, err := loadCustomFields(ctx)
+	if err != nil {
+		slog.Error("seed: find custom fields", "err", err)
+		return 1
+	}
+	list := make([]models.NewEmployee, 0, len(payloads))
+	invalid := 0
+	for i := range payloads {
+		// a seed brings its own departments and languages, so the managed lists don't apply
+		emp, errs := newEmployee(defs, referenceData{}, &payloads[i])
+		if len(errs) > 0 {
+			slog.Error("seed: invalid employee", "index", i, "errors", errs)
+			invalid++
+			continue
+		}
+		list = append(list, emp)
+	}
+	if invalid > 0 {
+		slog.Error("seed: nothing written", "invalid", invalid, "total", len(payloads))
+		return 1
+	}
+	if *dryRun {
+		slog.Info("seed: dry run, all employees are valid", "total", len(list))
+		return 0
+	}
+	if err := insertSeed(ctx, list); err != nil {
+		slog.Error("seed: write employees", "err", err)
+		return 1
+	}
+	slog.Info("seeded employees", "total", len(list))
+	return 0
+}
+
+// readSeedFile reads a fixture: a JSON array of employees in either payload shape. An
+// emp_id is kept (and the id counter moved past it); employees without one get the next ids.
+func readSeedFile(name string) ([]models.EmployeePayload, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var payloads []models.EmployeePayload
+	if err := json.Unmarshal(b, &payloads); err != nil {
+		return nil, fmt.Errorf("expected a JSON array of employees: %w", err)
+	}
+	return payloads, nil
+}
+
+// fakeEmployees generates n employees from the sample names, departments and languages;
+// the same seed gives the same employees
+func fakeEmployees(n int, seed uint64) []models.EmployeePayload {
+	rng := rand.New(rand.NewPCG(seed, seed))
+	departments, languages := seedDepartments, seedLanguages
+	pick := func(from []string) string { return from[rng.IntN(len(from))] }
+
+	out := make([]models.EmployeePayload, 0, n)
+	for range n {
+		name := pick(seedFirstNames) + " " + pick(seedLastNames)
+		department := pick(departments)
+		// one to three distinct languages, the first being the primary one
+		langs := make([]string, 0, 3)
+		for _, i := range rng.Perm(len(languages))[:min(1+rng.IntN(3), len(languages))] {
+			langs = append(langs, languages[i])
+		}
+		out = append(out, models.EmployeePayload{EmpName: &name, Department: &department, Languages: langs})
+	}
+	return out
+}
+
+// insertSeed writes validated employees in import-sized batches, allocating ids for the
+// ones that have none
+func insertSeed(ctx context.Context, list []models.NewEmployee) error {
+	last := 0
+	for _, e := range list {
+		last = max(last, e.EmpID)
+	}
+	if last > 0 {
+		if err := employees.ReserveID(ctx, last); err != nil {
+			return fmt.Errorf("reserve ids: %w", err)
+		}
+	}
+	for start := 0; start < len(list); start += importBatchSize {
+		batch := list[start:min(start+importBatchSize, len(list))]
+		missing := 0
+		for _, e := range batch {
+			if e.EmpID == 0 {
+				missing++
+			}
+		}
+		if missing > 0 {
+			next, err := employees.NextIDs(ctx, missing)
+			if err != nil {
+				return fmt.Errorf("allocate ids: %w", err)
+			}
+			for i := range batch {
+				if batch[i].EmpID == 0 {
+					batch[i].EmpID = next
+					next++
+				}
+			}
+		}
+		if err := employees.CreateMany(ctx, batch, seedActor, "seed"); err != nil {
+			return err
+		}
+		slog.Info("seed: wrote batch", "employees", start+len(batch), "total", len(list))
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// drainTimeout bounds how long a stopping instance waits for in-flight requests
+// (server.shutdown_timeout)
+func drainTimeout() time.Duration {
+	return time.Duration(cfg.Server.ShutdownTimeout)
+}
+
+// listen opens the server socket. With server.reuse_port the socket is opened with
+// SO_REUSEPORT so a new instance can bind the same port while the old one still runs:
+//
+//	REUSEPORT=true ./goBack &     # start the new binary / config
+//	kill -TERM <old pid>          # old instance stops accepting and drains
+func listen(addr string) (net.Listener, error) {
+	if !cfg.Server.ReusePort {
+		return net.Listen("tcp", addr)
+	}
+	if !reusePortSupported {
+		slog.Warn("server.reuse_port is not supported on this platform, listening normally")
+		return net.Listen("tcp", addr)
+	}
+	lc := net.ListenConfig{Control: reusePort}
+	return lc.Listen(context.Background(), "tcp", addr)
+}
+
+// serve runs handler on addr until SIGINT/SIGTERM, then stops accepting connections
+// and waits up to drainTimeout for in-flight requests to finish. It returns nil after a
+// clean drain so the caller can release the database connection. With tls configured
+// addr speaks HTTPS, and tls.redirect_addr sends plain HTTP there.
+func serve(addr string, handler http.Handler) error {
+	tlsConfig, redirect, err := serverTLS(http.HandlerFunc(redirectToHTTPS))
+	if err != nil {
+		return err
+	}
+	ln, err := listen(addr)
+	if err != nil {
+		return err
+	}
+	// request contexts are cancelled on shutdown so SSE streams end instead of holding the drain
+	base, cancelBase := context.WithCancel(context.Background())
+	srv := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: time.Duration(cfg.Server.ReadHeaderTimeout),
+		BaseContext:       func(net.Listener) context.Context { return base },
+		TLSConfig:         tlsConfig,
+	}
+	srv.RegisterOnShutdown(cancelBase)
+
+	stopRedirect := func(context.Context) {}
+	if tlsConfig != nil {
+		srv.Handler = hsts(handler)
+		if stopRedirect, err = startRedirect(redirect); err != nil {
+			ln.Close()
+			return err
+		}
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		if tlsConfig != nil {
+			errc <- srv.ServeTLS(ln, "", "") // certificates come from tlsConfig
+			return
+		}
+		errc <- srv.Serve(ln)
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case err := <-errc:
+		return err
+	case sig := <-stop:
+		slog.Info("draining connections", "signal", sig.String(), "timeout", drainTimeout().String())
+	}
+	draining.Store(true)
+	signal.Stop(stop) // a second signal exits immediately
+
+	ctx, cancel := context.WithTimeout(context.Background(), drainTimeout())
+	defer cancel()
+	stopRedirect(ctx)
+	if err := srv.Shutdown(ctx); err != nil {
+		return err
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	slog.Info("server stopped")
+	return nil
+}
@@ -0,0 +1,140 @@
+// Package service holds the business rules of the employee API that don't depend on
+// HTTP or on a store: duplicate detection, what a merge takes over, and the checks on
+// terminations, transfers and reports.
+package service
+
+import (
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/karthikeyan-meenachisundaram/goBack/models"
+	"golang.org/x/text/unicode/norm"
+)
+
+// nameKey normalizes a name for duplicate matching: accents, punctuation and case
+// dropped and the words sorted, so "Smith, Jöhn" reads like "John Smith"
+func nameKey(name string) []string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(name) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(unicode.ToLower(r))
+		default:
+			b.WriteRune(' ')
+		}
+	}
+	words := strings.Fields(b.String())
+	slices.Sort(words)
+	return words
+}
+
+// soundex is the American Soundex code of a word, which spelling variants of a name
+// ("jon", "john") share
+func soundex(word string) string {
+	codes := map[rune]rune{
+		'b': '1', 'f': '1', 'p': '1', 'v': '1',
+		'c': '2', 'g': '2', 'j': '2', 'k': '2', 'q': '2', 's': '2', 'x': '2', 'z': '2',
+		'd': '3', 't': '3', 'l': '4', 'm': '5', 'n': '5', 'r': '6',
+	}
+	code := []rune{}
+	var last rune
+	for i, r := range word {
+		c := codes[r]
+		if i == 0 {
+			code = append(code, unicode.ToUpper(r))
+		} else if c != 0 && c != last {
+			code = append(code, c)
+		}
+		// h and w don't separate equal codes, vowels do
+		if r != 'h' && r != 'w' {
+			last = c
+		}
+		if len(code) == 4 {
+			break
+		}
+	}
+	for len(code) < 4 {
+		code = append(code, '0')
+	}
+	return string(code)
+}
+
+// levenshtein is the number of single-rune edits between a and b
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+// Duplicates pairs the people whose names score at least minScore, most alike first.
+// Only names sharing the Soundex code of a word are compared, so the work stays near
+// linear in the number of people.
+func Duplicates(people []models.DuplicateCandidate, minScore float64) []models.DuplicatePair {
+	keys := make([]string, len(people))
+	blocks := map[string][]int{}
+	for i := range people {
+		words := nameKey(people[i].EmpName)
+		keys[i] = strings.Join(words, " ")
+		for _, word := range words {
+			code := soundex(word)
+			if n := len(blocks[code]); n == 0 || blocks[code][n-1] != i {
+				blocks[code] = append(blocks[code], i)
+			}
+		}
+	}
+	seen := map[[2]int]bool{}
+	pairs := []models.DuplicatePair{}
+	for _, block := range blocks {
+		for x, i := range block {
+			for _, j := range block[x+1:] {
+				if seen[[2]int{i, j}] {
+					continue
+				}
+				seen[[2]int{i, j}] = true
+				a, b := keys[i], keys[j]
+				score := 1 - float64(levenshtein(a, b))/float64(max(len([]rune(a)), len([]rune(b))))
+				if score < minScore {
+					continue
+				}
+				pair := models.DuplicatePair{Score: math.Round(score*100) / 100, Reason: "similar_name", Employees: [2]models.DuplicateCandidate{people[i], people[j]}}
+				if a == b {
+					pair.Reason = "same_name"
+				}
+				if people[i].EmpID > people[j].EmpID {
+					pair.Employees = [2]models.DuplicateCandidate{people[j], people[i]}
+				}
+				pairs = append(pairs, pair)
+			}
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		p, q := pairs[i], pairs[j]
+		if p.Score != q.Score {
+			return p.Score > q.Score
+		}
+		if p.Employees[0].EmpID != q.Employees[0].EmpID {
+			return p.Employees[0].EmpID < q.Employees[0].EmpID
+		}
+		return p.Employees[1].EmpID < q.Employees[1].EmpID
+	})
+	return pairs
+}
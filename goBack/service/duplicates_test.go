@@ -0,0 +1,91 @@
+package service
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/karthikeyan-meenachisundaram/goBack/models"
+)
+
+func TestNameKey(t *testing.T) {
+	for name, want := range map[string][]string{
+		"John Smith":    {"john", "smith"},
+		"Smith, Jöhn":   {"john", "smith"},
+		"  O'Brien  ":   {"brien", "o"},
+		"ÉLODIE dupont": {"dupont", "elodie"},
+		"":              nil,
+	} {
+		if got := nameKey(name); !slices.Equal(got, want) {
+			t.Errorf("nameKey(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestSoundex(t *testing.T) {
+	for word, want := range map[string]string{
+		"robert":   "R163",
+		"rupert":   "R163",
+		"ashcraft": "A261",
+		"tymczak":  "T522",
+		"pfister":  "P236",
+		"jon":      "J500",
+		"john":     "J500",
+		"a":        "A000",
+	} {
+		if got := soundex(word); got != want {
+			t.Errorf("soundex(%q) = %q, want %q", word, got, want)
+		}
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	for _, c := range []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"kitten", "sitting", 3},
+		{"jon smith", "john smith", 1},
+		{"jöhn", "john", 1},
+	} {
+		if got := levenshtein(c.a, c.b); got != c.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestDuplicates(t *testing.T) {
+	people := []models.DuplicateCandidate{
+		{EmpID: 7, EmpName: "Smith, John"},
+		{EmpID: 3, EmpName: "John Smith"},
+		{EmpID: 4, EmpName: "Jon Smith"},
+		{EmpID: 5, EmpName: "Asha Rao"},
+	}
+	pairs := Duplicates(people, 0.85)
+	if len(pairs) != 3 {
+		t.Fatalf("got %d pairs, want 3: %+v", len(pairs), pairs)
+	}
+	first := pairs[0]
+	if first.Reason != "same_name" || first.Score != 1 {
+		t.Errorf("first pair = %s %v, want same_name 1", first.Reason, first.Score)
+	}
+	if first.Employees[0].EmpID != 3 || first.Employees[1].EmpID != 7 {
+		t.Errorf("first pair = %d, %d, want the lower emp_id first: 3, 7", first.Employees[0].EmpID, first.Employees[1].EmpID)
+	}
+	for _, p := range pairs[1:] {
+		if p.Reason != "similar_name" || p.Score >= 1 || p.Score < 0.85 {
+			t.Errorf("pair %d/%d = %s %v, want similar_name in [0.85, 1)", p.Employees[0].EmpID, p.Employees[1].EmpID, p.Reason, p.Score)
+		}
+		if p.Employees[0].EmpID == 5 || p.Employees[1].EmpID == 5 {
+			t.Errorf("Asha Rao paired with %+v", p)
+		}
+	}
+
+	if pairs := Duplicates(people, 1); len(pairs) != 1 {
+		t.Errorf("min score 1: got %d pairs, want only the same name", len(pairs))
+	}
+	if pairs := Duplicates(nil, 0.5); pairs == nil || len(pairs) != 0 {
+		t.Errorf("no people: got %#v, want an empty list", pairs)
+	}
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"slices"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// mergeSkipFields are the employee fields a merge never copies from the duplicate: its
+// identity and bookkeeping, its lifecycle state, and the fields merged on their own below
+var mergeSkipFields = map[string]bool{
+	"_id": true, "emp_id": true, "version": true, "updated_at": true,
+	"status": true, "termination": true, "deleted_at": true, "deleted_by": true,
+	"photo": true, "tags": true, "custom_fields": true,
+}
+
+// blank reports whether an employee field value counts as unset
+func blank(v interface{}) bool {
+	return v == nil || v == ""
+}
+
+// MergePlan is what merging a duplicate employee document into the primary one changes
+// on the primary
+type MergePlan struct {
+	Set    bson.M   // the fields to set, custom fields as custom_fields.<name>
+	Fields []string // the names of the fields in Set, sorted
+	// OrphanPhoto is the key of the duplicate's photo blob when the primary keeps its own
+	OrphanPhoto string
+}
+
+// PlanMerge works out what the primary takes over from the duplicate: the fields it has
+// blank, the union of both tag lists, the custom fields it has blank key by key, and the
+// duplicate's photo if it has none. Lifecycle state (status, termination, deletion) stays
+// the primary's.
+func PlanMerge(primary, duplicate bson.M) MergePlan {
+	plan := MergePlan{Set: bson.M{}, Fields: []string{}}
+	for k, v := range duplicate {
+		if mergeSkipFields[k] || blank(v) {
+			continue
+		}
+		if cur, ok := primary[k]; !ok || blank(cur) {
+			plan.Set[k] = v
+			plan.Fields = append(plan.Fields, k)
+		}
+	}
+	if dt, _ := duplicate["tags"].(bson.A); len(dt) > 0 {
+		pt, _ := primary["tags"].(bson.A)
+		tags := slices.Clone(pt)
+		for _, t := range dt {
+			if !slices.Contains(tags, t) {
+				tags = append(tags, t)
+			}
+		}
+		if len(tags) > len(pt) {
+			plan.Set["tags"] = tags
+			plan.Fields = append(plan.Fields, "tags")
+		}
+	}
+	if dc, _ := duplicate["custom_fields"].(bson.M); len(dc) > 0 {
+		pc, _ := primary["custom_fields"].(bson.M)
+		for k, v := range dc {
+			if cur, ok := pc[k]; !blank(v) && (!ok || blank(cur)) {
+				plan.Set["custom_fields."+k] = v
+				plan.Fields = append(plan.Fields, "custom_fields."+k)
+			}
+		}
+	}
+	// the duplicate's photo replaces none of the primary's; a second one is dropped
+	if dp, _ := duplicate["photo"].(bson.M); dp != nil {
+		if pp, _ := primary["photo"].(bson.M); pp == nil {
+			plan.Set["photo"] = dp
+			plan.Fields = append(plan.Fields, "photo")
+		} else {
+			plan.OrphanPhoto, _ = dp["key"].(string)
+		}
+	}
+	sort.Strings(plan.Fields)
+	return plan
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"slices"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestPlanMergeFillsBlanks(t *testing.T) {
+	primary := bson.M{"emp_id": 1, "emp_name": "John Smith", "manager_id": ""}
+	duplicate := bson.M{"emp_id": 2, "emp_name": "Jon Smith", "manager_id": 9, "version": 4, "created_at": "x"}
+	plan := PlanMerge(primary, duplicate)
+	if want := []string{"created_at", "manager_id"}; !slices.Equal(plan.Fields, want) {
+		t.Errorf("fields = %q, want %q", plan.Fields, want)
+	}
+	if plan.Set["manager_id"] != 9 || plan.Set["created_at"] != "x" {
+		t.Errorf("set = %v", plan.Set)
+	}
+	if _, ok := plan.Set["emp_name"]; ok {
+		t.Error("the primary's name was overwritten")
+	}
+}
+
+func TestPlanMergeKeepsLifecycle(t *testing.T) {
+	// active employees have no stored status
+	primary := bson.M{"emp_id": 1}
+	duplicate := bson.M{
+		"emp_id":      2,
+		"status":      "terminated",
+		"termination": bson.M{"reason": "layoff"},
+		"deleted_at":  "then",
+		"deleted_by":  "someone",
+		"updated_at":  "now",
+	}
+	plan := PlanMerge(primary, duplicate)
+	if len(plan.Set) != 0 || len(plan.Fields) != 0 {
+		t.Errorf("plan = %+v, want nothing taken over", plan)
+	}
+}
+
+func TestPlanMergeTagsAndCustomFields(t *testing.T) {
+	primary := bson.M{
+		"tags":          bson.A{"remote", "mentor"},
+		"custom_fields": bson.M{"shirt": "M", "desk": ""},
+	}
+	duplicate := bson.M{
+		"tags":          bson.A{"mentor", "oncall"},
+		"custom_fields": bson.M{"shirt": "L", "desk": "4B", "badge": "123", "empty": ""},
+	}
+	plan := PlanMerge(primary, duplicate)
+	if tags, _ := plan.Set["tags"].(bson.A); !slices.Equal(tags, bson.A{"remote", "mentor", "oncall"}) {
+		t.Errorf("tags = %v", plan.Set["tags"])
+	}
+	if plan.Set["custom_fields.desk"] != "4B" || plan.Set["custom_fields.badge"] != "123" {
+		t.Errorf("custom fields = %v", plan.Set)
+	}
+	for _, k := range []string{"custom_fields.shirt", "custom_fields.empty", "custom_fields"} {
+		if _, ok := plan.Set[k]; ok {
+			t.Errorf("%s was set", k)
+		}
+	}
+	if want := []string{"custom_fields.badge", "custom_fields.desk", "tags"}; !slices.Equal(plan.Fields, want) {
+		t.Errorf("fields = %q, want %q", plan.Fields, want)
+	}
+
+	// tags the primary has all of change nothing
+	if plan := PlanMerge(primary, bson.M{"tags": bson.A{"remote"}}); len(plan.Set) != 0 {
+		t.Errorf("set = %v, want nothing", plan.Set)
+	}
+}
+
+func TestPlanMergePhoto(t *testing.T) {
+	photo := bson.M{"key": "emp/2.jpg"}
+	plan := PlanMerge(bson.M{}, bson.M{"photo": photo})
+	if plan.Set["photo"] == nil || plan.OrphanPhoto != "" {
+		t.Errorf("primary without a photo: plan = %+v, want the duplicate's photo adopted", plan)
+	}
+	plan = PlanMerge(bson.M{"photo": bson.M{"key": "emp/1.jpg"}}, bson.M{"photo": photo})
+	if _, ok := plan.Set["photo"]; ok || plan.OrphanPhoto != "emp/2.jpg" {
+		t.Errorf("primary with a photo: plan = %+v, want the duplicate's photo orphaned", plan)
+	}
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/karthikeyan-meenachisundaram/goBack/models"
+)
+
+// dateLayout is how the API takes dates
+const dateLayout = "2006-01-02"
+
+// MaxSearchTerms caps how many words of a search query are scored
+const MaxSearchTerms = 5
+
+// TerminationReasons are the accepted reason codes for a termination
+var TerminationReasons = map[string]bool{
+	"resignation":  true,
+	"dismissal":    true,
+	"layoff":       true,
+	"retirement":   true,
+	"contract_end": true,
+	"other":        true,
+}
+
+// ErrSameDepartment is returned for a transfer into the department the employee is in
+var ErrSameDepartment = errors.New("employee is already in that department")
+
+// NewTermination validates a termination: endDate as YYYY-MM-DD and one of the
+// TerminationReasons
+func NewTermination(endDate, reason, note string) (models.Termination, error) {
+	end, err := time.Parse(dateLayout, endDate)
+	if err != nil {
+		return models.Termination{}, errors.New("invalid end_date, expected YYYY-MM-DD")
+	}
+	if !TerminationReasons[reason] {
+		return models.Termination{}, errors.New("invalid reason: " + reason)
+	}
+	return models.Termination{EndDate: end, Reason: reason, Note: note}, nil
+}
+
+// EffectiveDate parses the effective date of a transfer, YYYY-MM-DD; today (UTC) when
+// none is given
+func EffectiveDate(v string) (time.Time, error) {
+	if v == "" {
+		return time.Now().UTC().Truncate(24 * time.Hour), nil
+	}
+	t, err := time.Parse(dateLayout, v)
+	if err != nil {
+		return time.Time{}, errors.New("invalid effective_date, expected YYYY-MM-DD")
+	}
+	return t, nil
+}
+
+// CheckTransfer reports whether an employee with status in department from can be
+// transferred to department to: models.ErrTerminated or ErrSameDepartment when not
+func CheckTransfer(status, from, to string) error {
+	switch {
+	case status == "terminated":
+		return models.ErrTerminated
+	case from == to:
+		return ErrSameDepartment
+	}
+	return nil
+}
+
+// NewAttritionQuery validates the attrition report parameters: period month (the
+// default), quarter or year, and optional from and to dates as YYYY-MM-DD
+func NewAttritionQuery(period, from, to string) (models.AttritionQuery, error) {
+	if period == "" {
+		period = "month"
+	}
+	switch period {
+	case "month", "quarter", "year":
+	default:
+		return models.AttritionQuery{}, errors.New("invalid period: " + period)
+	}
+	q := models.AttritionQuery{Period: period}
+	for _, p := range []struct {
+		name, value string
+		date        **time.Time
+	}{{"from", from, &q.From}, {"to", to, &q.To}} {
+		if p.value == "" {
+			continue
+		}
+		t, err := time.Parse(dateLayout, p.value)
+		if err != nil {
+			return models.AttritionQuery{}, errors.New("invalid " + p.name + ", expected YYYY-MM-DD")
+		}
+		*p.date = &t
+	}
+	return q, nil
+}
+
+// SearchWords are the words of a search query that are scored, at most MaxSearchTerms
+func SearchWords(q string) []string {
+	words := strings.Fields(q)
+	if len(words) > MaxSearchTerms {
+		words = words[:MaxSearchTerms]
+	}
+	return words
+}
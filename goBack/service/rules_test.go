@@ -0,0 +1,91 @@
+package service
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/karthikeyan-meenachisundaram/goBack/models"
+)
+
+func TestNewTermination(t *testing.T) {
+	term, err := NewTermination("2024-03-31", "layoff", "restructuring")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC); !term.EndDate.Equal(want) || term.Reason != "layoff" || term.Note != "restructuring" {
+		t.Errorf("termination = %+v", term)
+	}
+	for _, c := range []struct{ endDate, reason, want string }{
+		{"31/03/2024", "layoff", "invalid end_date, expected YYYY-MM-DD"},
+		{"", "layoff", "invalid end_date, expected YYYY-MM-DD"},
+		{"2024-03-31", "bored", "invalid reason: bored"},
+		{"2024-03-31", "", "invalid reason: "},
+	} {
+		if _, err := NewTermination(c.endDate, c.reason, ""); err == nil || err.Error() != c.want {
+			t.Errorf("NewTermination(%q, %q) = %v, want %q", c.endDate, c.reason, err, c.want)
+		}
+	}
+}
+
+func TestEffectiveDate(t *testing.T) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	if got, err := EffectiveDate(""); err != nil || !got.Equal(today) {
+		t.Errorf("EffectiveDate(\"\") = %v, %v, want today %v", got, err, today)
+	}
+	if got, err := EffectiveDate("2024-07-01"); err != nil || got.Format("2006-01-02") != "2024-07-01" {
+		t.Errorf("EffectiveDate(2024-07-01) = %v, %v", got, err)
+	}
+	if _, err := EffectiveDate("July 1st"); err == nil {
+		t.Error("EffectiveDate(July 1st) succeeded")
+	}
+}
+
+func TestCheckTransfer(t *testing.T) {
+	for _, c := range []struct {
+		status, from, to string
+		want             error
+	}{
+		{"active", "Engg", "Sales", nil},
+		{"inactive", "", "Sales", nil},
+		{"terminated", "Engg", "Sales", models.ErrTerminated},
+		{"active", "Engg", "Engg", ErrSameDepartment},
+	} {
+		if err := CheckTransfer(c.status, c.from, c.to); !errors.Is(err, c.want) {
+			t.Errorf("CheckTransfer(%q, %q, %q) = %v, want %v", c.status, c.from, c.to, err, c.want)
+		}
+	}
+}
+
+func TestNewAttritionQuery(t *testing.T) {
+	q, err := NewAttritionQuery("", "", "")
+	if err != nil || q.Period != "month" || q.From != nil || q.To != nil {
+		t.Errorf("defaults = %+v, %v", q, err)
+	}
+	q, err = NewAttritionQuery("quarter", "2024-01-01", "2024-12-31")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Period != "quarter" || q.From == nil || q.From.Format("2006-01-02") != "2024-01-01" || q.To == nil || q.To.Format("2006-01-02") != "2024-12-31" {
+		t.Errorf("query = %+v", q)
+	}
+	for _, c := range []struct{ period, from, to, want string }{
+		{"week", "", "", "invalid period: week"},
+		{"year", "2024", "", "invalid from, expected YYYY-MM-DD"},
+		{"year", "", "tomorrow", "invalid to, expected YYYY-MM-DD"},
+	} {
+		if _, err := NewAttritionQuery(c.period, c.from, c.to); err == nil || err.Error() != c.want {
+			t.Errorf("NewAttritionQuery(%q, %q, %q) = %v, want %q", c.period, c.from, c.to, err, c.want)
+		}
+	}
+}
+
+func TestSearchWords(t *testing.T) {
+	if got := SearchWords("  asha   engg "); !slices.Equal(got, []string{"asha", "engg"}) {
+		t.Errorf("got %q", got)
+	}
+	if got := SearchWords("a b c d e f g"); len(got) != MaxSearchTerms {
+		t.Errorf("got %d words, want %d", len(got), MaxSearchTerms)
+	}
+}
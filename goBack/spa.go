@@ -0,0 +1,95 @@
+package main
+
+import (
+	"embed"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+)
+
+// frontendFiles is the Vue build (vueFront: npm run build writes to frontend/dist).
+// all: keeps the files Vite names with a leading underscore.
+//
+//go:embed all:frontend
+var frontendFiles embed.FS
+
+// defaultDevProxy is where -dev sends the frontend: Vite's dev server on its default port
+const defaultDevProxy = "http://localhost:5173"
+
+// frontendHandler serves the SPA: from its dev server when server.dev_proxy is set,
+// otherwise the files of frontendFS
+func frontendHandler() http.Handler {
+	if target := cfg.Server.DevProxy; target != "" {
+		return devProxy(target)
+	}
+	return spaHandler(frontendFS())
+}
+
+// devProxy forwards every request outside the API to the frontend dev server at target,
+// hot-reload websocket included, so the app and the API share an origin while developing
+// (no CORS) and the dev server answers the Vue router's history URLs with index.html
+func devProxy(target string) http.Handler {
+	u, _ := url.Parse(target) // checked by Config.validate
+	slog.Info("proxying the frontend to its dev server", "url", target)
+	return &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(u)
+			pr.SetXForwarded()
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			slog.Warn("dev proxy", "url", target, "path", r.URL.Path, "err", err)
+			http.Error(w, "frontend dev server at "+target+" is not reachable (npm run dev in vueFront)", http.StatusBadGateway)
+		},
+	}
+}
+
+// frontendFS is where the SPA is served from: server.static_dir on disk when set (for
+// development, so a rebuild needs no restart), the build embedded in the binary otherwise
+func frontendFS() fs.FS {
+	if dir := cfg.Server.StaticDir; dir != "" {
+		slog.Info("serving the frontend from disk", "dir", dir)
+		return os.DirFS(dir)
+	}
+	dist, _ := fs.Sub(frontendFiles, "frontend/dist")
+	if _, err := fs.Stat(dist, "index.html"); err != nil {
+		slog.Warn("this binary was built without the frontend (run npm run build in vueFront first)")
+	}
+	return dist
+}
+
+// spaHandler serves the files of fsys and index.html for every other path, so the Vue
+// router's history URLs load the app. Vite's hashed /assets/ files never change and are
+// cached for a year; index.html and the rest are revalidated on every load.
+func spaHandler(fsys fs.FS) http.Handler {
+	files := http.FileServerFS(fsys)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(path.Clean(r.URL.Path), "/")
+		if info, err := fs.Stat(fsys, name); err == nil && !info.IsDir() && name != "index.html" {
+			if strings.HasPrefix(name, "assets/") {
+				w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+			} else {
+				w.Header().Set("Cache-Control", "no-cache")
+			}
+			files.ServeHTTP(w, r)
+			return
+		}
+		if strings.HasPrefix(name, "assets/") {
+			// a missing asset is a stale page asking for an old build, not a route
+			http.NotFound(w, r)
+			return
+		}
+		index, err := fs.ReadFile(fsys, "index.html")
+		if err != nil {
+			http.Error(w, "frontend not built", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-cache")
+		_, _ = w.Write(index)
+	})
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+const (
+	// statsDays and statsRecent are the dashboard defaults, the view kept as a snapshot
+	statsDays   = 30
+	statsRecent = 10
+)
+
+// statsHandler handles GET /api/employees/stats?days=30&recent=10: headcount by status,
+// employees per department and per language (terminated employees excluded), the number
+// hired in the last days and the newest hires. Employees created before created_at was
+// stored count from their document's creation time. With jobs.stats_interval set, the
+// default view is a snapshot the jobs rebuild, used until the next employee change.
+func statsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	days, recent := statsDays, statsRecent
+	if v := q.Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 3650 {
+			httpError(w, "days must be between 1 and 3650", http.StatusBadRequest)
+			return
+		}
+		days = n
+	}
+	if v := q.Get("recent"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 100 {
+			httpError(w, "recent must be between 1 and 100", http.StatusBadRequest)
+			return
+		}
+		recent = n
+	}
+
+	ctx := r.Context()
+
+	// the default dashboard comes from the snapshot while no employee changed since
+	dashboard := days == statsDays && recent == statsRecent && cfg.Jobs.StatsInterval > 0
+	if dashboard {
+		if stats, err := reports.Snapshot(ctx); err == nil && stats.ComputedAt.After(lastEmployeeChange()) {
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(stats)
+			return
+		}
+	}
+	stats, err := reports.Stats(ctx, days, recent)
+	if err != nil {
+		storeError(w, "aggregate", err)
+		return
+	}
+	if dashboard {
+		if err := reports.SaveSnapshot(ctx, stats); err != nil {
+			logFor(r.Context()).Warn("stats: save snapshot", "err", err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(stats)
+}
+
+// runStatsRebuildJob recomputes the default dashboard snapshot (jobs.stats_interval)
+func runStatsRebuildJob(ctx context.Context, _ Job) (string, error) {
+	stats, err := reports.Stats(ctx, statsDays, statsRecent)
+	if err != nil {
+		return "", err
+	}
+	if err := reports.SaveSnapshot(ctx, stats); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("headcount %d", stats.Headcount.Total), nil
+}
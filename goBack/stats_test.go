@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/karthikeyan-meenachisundaram/goBack/models"
+)
+
+func TestStatsHandlerSnapshot(t *testing.T) {
+	s := useTestStores(t)
+	cfg.Jobs.StatsInterval = Duration(time.Hour)
+	s.reports.stats = models.EmployeeStats{HiredSince: 4}
+
+	// nothing to reuse yet: computed and kept
+	w := asAdmin(t, statsHandler, http.MethodGet, "/api/employees/stats", "")
+	expectStatus(t, w, http.StatusOK)
+	if s.reports.computed != 1 || s.reports.snapshot == nil {
+		t.Fatalf("computed %d times, snapshot %v", s.reports.computed, s.reports.snapshot)
+	}
+	w = asAdmin(t, statsHandler, http.MethodGet, "/api/employees/stats", "")
+	expectStatus(t, w, http.StatusOK)
+	if s.reports.computed != 1 {
+		t.Errorf("a fresh snapshot was recomputed")
+	}
+
+	// stale after an employee change
+	invalidateEmployeeLists()
+	w = asAdmin(t, statsHandler, http.MethodGet, "/api/employees/stats", "")
+	expectStatus(t, w, http.StatusOK)
+	if s.reports.computed != 2 {
+		t.Errorf("a stale snapshot was used")
+	}
+
+	// other views are never kept
+	w = asAdmin(t, statsHandler, http.MethodGet, "/api/employees/stats?days=7", "")
+	expectStatus(t, w, http.StatusOK)
+	if s.reports.computed != 3 {
+		t.Errorf("computed %d times, want 3", s.reports.computed)
+	}
+	w = asAdmin(t, statsHandler, http.MethodGet, "/api/employees/stats?recent=500", "")
+	expectStatus(t, w, http.StatusBadRequest)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/karthikeyan-meenachisundaram/goBack/models"
+	"github.com/karthikeyan-meenachisundaram/goBack/repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// mongoStores are the stores on MongoDB, in the database of the request's organization
+var mongoStores = repository.NewMongo(mongoEnv{})
+
+// The stores the handlers use; serveMemory swaps in in-memory employees and definitions
+var (
+	employees      = mongoStores.Employees()
+	transfers      = mongoStores.Transfers()
+	departments    = mongoStores.Departments()
+	reports        = mongoStores.Reports()
+	merges         = mongoStores.Merges()
+	searches       = mongoStores.Searches()
+	changeRequests = mongoStores.ChangeRequests()
+	definitions    = mongoStores.Metadata()
+)
+
+// mongoEnv runs the Mongo stores on the connection, retry policy, audit log and photo
+// store of this server
+type mongoEnv struct{}
+
+func (mongoEnv) Collection(ctx context.Context, name string) *mongo.Collection {
+	return coll(ctx, name)
+}
+
+func (mongoEnv) Retry(ctx context.Context, what string, idempotent bool, op func() error) error {
+	return retry(ctx, what, idempotent, op)
+}
+
+func (mongoEnv) Transaction(ctx context.Context, fn func(sc mongo.SessionContext) error) error {
+	return withTransaction(ctx, fn)
+}
+
+func (mongoEnv) Snapshot(ctx context.Context, empId int) bson.M {
+	return employeeSnapshot(ctx, empId)
+}
+
+func (mongoEnv) Audit(ctx context.Context, action string, empId int, actor string, details interface{}, before, after bson.M) error {
+	return recordAuditDiff(ctx, action, empId, actor, details, before, after)
+}
+
+// AuditCreated builds the entries and revisions recordAuditDiff would for each employee
+func (mongoEnv) AuditCreated(ctx context.Context, list []models.NewEmployee, actor, source string) error {
+	now := time.Now().UTC()
+	var audit, history []interface{}
+	for _, e := range list {
+		languages := e.Languages
+		if len(languages) == 0 {
+			languages = []string{""}
+		}
+		after := bson.M{"emp_name": e.EmpName, "department": e.Department, "languages": languages, "status": "active"}
+		if e.ManagerID != 0 {
+			after["manager_id"] = e.ManagerID
+		}
+		for k, v := range e.CustomFields {
+			after["custom_fields."+k] = v
+		}
+		changes := diffSnapshots(nil, after)
+		audit = append(audit, AuditEntry{
+			Action:    "create",
+			EmpID:     e.EmpID,
+			Actor:     actor,
+			Timestamp: now,
+			Details:   bson.M{"emp_name": e.EmpName, "department": e.Department, "languages": e.Languages, "source": source},
+			Changes:   changes,
+		})
+		history = append(history, newRevision("create", e.EmpID, 0, actor, now, after, changes))
+	}
+	for _, step := range []struct {
+		name string
+		docs []interface{}
+	}{
+		{"AuditLog", audit},
+		{"EmployeeHistory", history},
+	} {
+		if _, err := coll(ctx, step.name).InsertMany(ctx, step.docs); err != nil {
+			return fmt.Errorf("insert %s: %w", strings.ToLower(step.name), err)
+		}
+	}
+	return nil
+}
+
+func (mongoEnv) DeletePhoto(ctx context.Context, key string) error {
+	return photos.Delete(ctx, key)
+}
+
+// nextID allocates a single emp_id
+func nextID(ctx context.Context) (int, error) {
+	return employees.NextIDs(ctx, 1)
+}
@@ -0,0 +1,461 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/karthikeyan-meenachisundaram/goBack/models"
+	"github.com/karthikeyan-meenachisundaram/goBack/repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// The handler tests run on the in-memory employee store and on the fakes below for the
+// other stores, so none of them needs a database.
+
+// testStores are the stores a handler test runs on
+type testStores struct {
+	employees      *repository.Memory
+	definitions    *repository.MemoryMetadata
+	transfers      *fakeTransferStore
+	departments    *fakeDepartmentStore
+	reports        *fakeReportStore
+	merges         *fakeMergeStore
+	searches       *fakeSearchStore
+	changeRequests *fakeChangeRequestStore
+	accounts       memoryAccounts
+	apiKeys        *memoryAPIKeys
+	notifications  *fakeNotificationStore
+	idempotency    *fakeIdempotencyStore
+}
+
+// useTestStores points the package stores at empty fakes and the default config for the
+// rest of the test
+func useTestStores(t *testing.T) *testStores {
+	t.Helper()
+	savedCfg := cfg
+	saved := []interface{}{employees, transfers, departments, reports, merges, searches, changeRequests, definitions, accounts, apiKeys, notifications, idempotencyKeys}
+	// lists cached by an earlier test came from other stores
+	invalidateEmployeeLists()
+	t.Cleanup(func() {
+		invalidateEmployeeLists()
+		cfg = savedCfg
+		employees = saved[0].(repository.EmployeeStore)
+		transfers = saved[1].(repository.TransferStore)
+		departments = saved[2].(repository.DepartmentStore)
+		reports = saved[3].(repository.ReportStore)
+		merges = saved[4].(repository.MergeStore)
+		searches = saved[5].(repository.SearchStore)
+		changeRequests = saved[6].(repository.ChangeRequestStore)
+		definitions = saved[7].(repository.MetadataStore)
+		accounts = saved[8].(accountStore)
+		apiKeys = saved[9].(apiKeyStore)
+		notifications = saved[10].(notificationStore)
+		idempotencyKeys = saved[11].(idempotencyStore)
+	})
+
+	cfg = defaultConfig()
+	s := &testStores{
+		employees:      repository.NewMemory(),
+		definitions:    &repository.MemoryMetadata{},
+		transfers:      &fakeTransferStore{},
+		departments:    &fakeDepartmentStore{},
+		reports:        &fakeReportStore{},
+		merges:         &fakeMergeStore{},
+		searches:       &fakeSearchStore{},
+		changeRequests: &fakeChangeRequestStore{},
+		accounts:       memoryAccounts{},
+		apiKeys:        &memoryAPIKeys{},
+		notifications:  &fakeNotificationStore{},
+		idempotency:    &fakeIdempotencyStore{},
+	}
+	employees, transfers, departments, reports = s.employees, s.transfers, s.departments, s.reports
+	merges, searches, changeRequests, definitions = s.merges, s.searches, s.changeRequests, s.definitions
+	accounts, apiKeys, notifications, idempotencyKeys = s.accounts, s.apiKeys, s.notifications, s.idempotency
+	return s
+}
+
+// addEmployees creates employees in the in-memory store
+func (s *testStores) addEmployees(t *testing.T, list ...models.NewEmployee) {
+	t.Helper()
+	if err := s.employees.CreateMany(context.Background(), list, "tester", ""); err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range list {
+		_ = s.employees.ReserveID(context.Background(), e.EmpID)
+	}
+}
+
+// request is a request from user, an admin when role is "admin"
+func request(method, target, body, user, role string) *http.Request {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	claims := &tokenClaims{Type: "access", Role: role, Org: defaultOrg, RegisteredClaims: jwt.RegisteredClaims{Subject: user}}
+	return r.WithContext(withOrg(context.WithValue(r.Context(), userKey, claims), defaultOrg))
+}
+
+// record runs handler on r and returns the recorded response
+func record(handler http.HandlerFunc, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+// call runs handler on a request from user
+func call(t *testing.T, handler http.HandlerFunc, method, target, body, user, role string) *httptest.ResponseRecorder {
+	t.Helper()
+	return record(handler, request(method, target, body, user, role))
+}
+
+// asAdmin is call by an admin
+func asAdmin(t *testing.T, handler http.HandlerFunc, method, target, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	return call(t, handler, method, target, body, "admin", "admin")
+}
+
+// decode reads a JSON response body into a generic value
+func decode(t *testing.T, w *httptest.ResponseRecorder) map[string]interface{} {
+	t.Helper()
+	var out map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode %q: %v", w.Body.String(), err)
+	}
+	return out
+}
+
+// expectStatus fails the test unless w answered status
+func expectStatus(t *testing.T, w *httptest.ResponseRecorder, status int) {
+	t.Helper()
+	if w.Code != status {
+		t.Fatalf("status = %d, want %d; body %s", w.Code, status, w.Body.String())
+	}
+}
+
+// errorMessage is the message of an error envelope
+func errorMessage(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	e, _ := decode(t, w)["error"].(map[string]interface{})
+	msg, _ := e["message"].(string)
+	return msg
+}
+
+// fakeTransferStore records transfers; it moves the employee in the in-memory store
+// through employees.Update like the Mongo store's SetDepartment does
+type fakeTransferStore struct {
+	recorded []models.Transfer
+}
+
+func (f *fakeTransferStore) Transfer(ctx context.Context, t models.Transfer) error {
+	if err := employees.Update(ctx, t.EmpID, models.EmployeeChange{Department: &t.ToDepartment}, t.RecordedBy); err != nil {
+		return err
+	}
+	f.recorded = append(f.recorded, t)
+	return nil
+}
+
+func (f *fakeTransferStore) History(ctx context.Context, empId int) ([]models.Transfer, error) {
+	history := []models.Transfer{}
+	for _, t := range slices.Backward(f.recorded) {
+		if t.EmpID == empId {
+			history = append(history, t)
+		}
+	}
+	return history, nil
+}
+
+// fakeDepartmentStore keeps departments in a slice; members counts how many employees
+// each dept_id has
+type fakeDepartmentStore struct {
+	list       []models.Department
+	members    map[int]int64
+	reassigned models.Reassignment
+}
+
+func (f *fakeDepartmentStore) List(ctx context.Context) ([]models.Department, error) {
+	return append([]models.Department{}, f.list...), nil
+}
+
+func (f *fakeDepartmentStore) Get(ctx context.Context, deptID int) (models.Department, error) {
+	for _, d := range f.list {
+		if d.DeptID == deptID {
+			return d, nil
+		}
+	}
+	return models.Department{}, models.ErrNotFound
+}
+
+func (f *fakeDepartmentStore) ByName(ctx context.Context, name string) (models.Department, error) {
+	for _, d := range f.list {
+		if strings.EqualFold(d.Name, name) {
+			return d, nil
+		}
+	}
+	return models.Department{}, models.ErrNotFound
+}
+
+func (f *fakeDepartmentStore) Create(ctx context.Context, name string) (models.Department, error) {
+	if _, err := f.ByName(ctx, name); err == nil {
+		return models.Department{}, models.ErrDuplicate
+	}
+	d := models.Department{DeptID: len(f.list) + 1, Name: name, CreatedAt: time.Now().UTC()}
+	f.list = append(f.list, d)
+	return d, nil
+}
+
+func (f *fakeDepartmentStore) Rename(ctx context.Context, deptID int, name string) error {
+	if d, err := f.ByName(ctx, name); err == nil && d.DeptID != deptID {
+		return models.ErrDuplicate
+	}
+	for i := range f.list {
+		if f.list[i].DeptID == deptID {
+			f.list[i].Name = name
+		}
+	}
+	return nil
+}
+
+func (f *fakeDepartmentStore) Members(ctx context.Context, deptID int) (int64, error) {
+	return f.members[deptID], nil
+}
+
+func (f *fakeDepartmentStore) Delete(ctx context.Context, deptID int) error {
+	f.list = slices.DeleteFunc(f.list, func(d models.Department) bool { return d.DeptID == deptID })
+	return nil
+}
+
+// Reassign moves the employees of the in-memory store whose department is dept, skipping
+// terminated ones
+func (f *fakeDepartmentStore) Reassign(ctx context.Context, dept models.Department, empIDs []int, t models.Transfer) (models.Reassignment, error) {
+	out := models.Reassignment{Moved: []int{}, Skipped: []int{}}
+	last, _ := employees.LastID(ctx)
+	for id := 1; id <= last; id++ {
+		if len(empIDs) > 0 && !slices.Contains(empIDs, id) {
+			continue
+		}
+		raw, err := employees.Get(ctx, id, nil)
+		if err != nil {
+			continue
+		}
+		if bson.Raw(raw).Lookup("department").StringValue() != dept.Name {
+			continue
+		}
+		if bson.Raw(raw).Lookup("status").StringValue() == "terminated" {
+			out.Skipped = append(out.Skipped, id)
+			continue
+		}
+		if err := employees.Update(ctx, id, models.EmployeeChange{Department: &t.ToDepartment}, t.RecordedBy); err != nil {
+			return out, err
+		}
+		out.Moved = append(out.Moved, id)
+	}
+	f.reassigned = out
+	return out, nil
+}
+
+// fakeReportStore answers with canned reports and counts the computed ones
+type fakeReportStore struct {
+	stats     models.EmployeeStats
+	snapshot  *models.EmployeeStats
+	computed  int
+	attrition []models.AttritionRow
+	query     models.AttritionQuery
+}
+
+func (f *fakeReportStore) Stats(ctx context.Context, days, recent int) (models.EmployeeStats, error) {
+	f.computed++
+	stats := f.stats
+	stats.ComputedAt = time.Now().UTC()
+	return stats, nil
+}
+
+func (f *fakeReportStore) Snapshot(ctx context.Context) (models.EmployeeStats, error) {
+	if f.snapshot == nil {
+		return models.EmployeeStats{}, models.ErrNotFound
+	}
+	return *f.snapshot, nil
+}
+
+func (f *fakeReportStore) SaveSnapshot(ctx context.Context, stats models.EmployeeStats) error {
+	f.snapshot = &stats
+	return nil
+}
+
+func (f *fakeReportStore) Attrition(ctx context.Context, q models.AttritionQuery) ([]models.AttritionRow, error) {
+	f.query = q
+	return f.attrition, nil
+}
+
+// fakeMergeStore takes its candidates from the in-memory store and merges by
+// soft-deleting the duplicate
+type fakeMergeStore struct {
+	merged [][2]int
+	result models.Merge
+}
+
+func (f *fakeMergeStore) Candidates(ctx context.Context) ([]models.DuplicateCandidate, error) {
+	people := []models.DuplicateCandidate{}
+	last, _ := employees.LastID(ctx)
+	for id := 1; id <= last; id++ {
+		if raw, err := employees.Get(ctx, id, nil); err == nil {
+			people = append(people, models.DuplicateCandidate{EmpID: id, EmpName: bson.Raw(raw).Lookup("emp_name").StringValue()})
+		}
+	}
+	return people, nil
+}
+
+func (f *fakeMergeStore) Merge(ctx context.Context, primary, duplicate int, actor string) (models.Merge, error) {
+	for _, id := range []int{primary, duplicate} {
+		if _, err := employees.Version(ctx, id); err != nil {
+			return models.Merge{}, err
+		}
+	}
+	if _, err := employees.SoftDelete(ctx, duplicate, actor); err != nil {
+		return models.Merge{}, err
+	}
+	f.merged = append(f.merged, [2]int{primary, duplicate})
+	return f.result, nil
+}
+
+// fakeSearchStore remembers the query and finds the employees whose name contains one
+// of its words
+type fakeSearchStore struct {
+	query models.SearchQuery
+}
+
+func (f *fakeSearchStore) Search(ctx context.Context, q models.SearchQuery) ([]models.Row, int, error) {
+	f.query = q
+	var rows []models.Row
+	last, _ := employees.LastID(ctx)
+	for id := 1; id <= last; id++ {
+		raw, err := employees.Get(ctx, id, nil)
+		if err != nil {
+			continue
+		}
+		name := strings.ToLower(bson.Raw(raw).Lookup("emp_name").StringValue())
+		for _, word := range q.Words {
+			if strings.Contains(name, strings.ToLower(word)) {
+				row, _ := bson.Marshal(bson.M{"emp_id": id, "emp_name": bson.Raw(raw).Lookup("emp_name").StringValue(), "score": 1.0})
+				rows = append(rows, row)
+				break
+			}
+		}
+	}
+	total := len(rows)
+	start := min((q.Page-1)*q.Limit, total)
+	return rows[start:min(start+q.Limit, total)], total, nil
+}
+
+// fakeChangeRequestStore keeps change requests in a slice
+type fakeChangeRequestStore struct {
+	list []models.ChangeRequest
+}
+
+func (f *fakeChangeRequestStore) Submit(ctx context.Context, cr *models.ChangeRequest) error {
+	cr.ID = strconv.Itoa(len(f.list) + 1)
+	f.list = append(f.list, *cr)
+	return nil
+}
+
+func (f *fakeChangeRequestStore) List(ctx context.Context, status, requestedBy string) ([]models.ChangeRequest, error) {
+	list := []models.ChangeRequest{}
+	for _, cr := range slices.Backward(f.list) {
+		if (status == "" || cr.Status == status) && (requestedBy == "" || cr.RequestedBy == requestedBy) {
+			list = append(list, cr)
+		}
+	}
+	return list, nil
+}
+
+func (f *fakeChangeRequestStore) Get(ctx context.Context, id string) (models.ChangeRequest, error) {
+	for _, cr := range f.list {
+		if cr.ID == id {
+			return cr, nil
+		}
+	}
+	return models.ChangeRequest{}, models.ErrNotFound
+}
+
+func (f *fakeChangeRequestStore) Decide(ctx context.Context, id string, approve bool, approver, comment string, apply func(context.Context, models.ChangeRequest) error) (models.ChangeRequest, error) {
+	for i, cr := range f.list {
+		if cr.ID != id {
+			continue
+		}
+		if cr.Status != "pending" {
+			return models.ChangeRequest{}, models.ErrChangeNotPending
+		}
+		cr.Status = "rejected"
+		if approve {
+			if err := apply(ctx, cr); err != nil {
+				return models.ChangeRequest{}, err
+			}
+			cr.Status = "approved"
+		}
+		now := time.Now().UTC()
+		cr.DecidedBy, cr.DecidedAt, cr.Comment = approver, &now, comment
+		f.list[i] = cr
+		return cr, nil
+	}
+	return models.ChangeRequest{}, models.ErrNotFound
+}
+
+// fakeNotificationStore keeps notifications in insertion order
+type fakeNotificationStore struct {
+	list []Notification
+}
+
+func (f *fakeNotificationStore) Insert(ctx context.Context, n *Notification) error {
+	n.ID = primitive.NewObjectID()
+	f.list = append(f.list, *n)
+	return nil
+}
+
+// fakeIdempotencyStore keeps idempotency records by actor and key
+type fakeIdempotencyStore struct {
+	records map[[2]string]IdempotencyRecord
+}
+
+func (f *fakeIdempotencyStore) Begin(ctx context.Context, rec IdempotencyRecord) error {
+	if _, ok := f.records[[2]string{rec.Actor, rec.Key}]; ok {
+		return errIdempotencyKeyUsed
+	}
+	if f.records == nil {
+		f.records = map[[2]string]IdempotencyRecord{}
+	}
+	f.records[[2]string{rec.Actor, rec.Key}] = rec
+	return nil
+}
+
+func (f *fakeIdempotencyStore) Get(ctx context.Context, actor, key string) (IdempotencyRecord, error) {
+	rec, ok := f.records[[2]string{actor, key}]
+	if !ok {
+		return rec, models.ErrNotFound
+	}
+	return rec, nil
+}
+
+func (f *fakeIdempotencyStore) Finish(ctx context.Context, actor, key string, status int, contentType string, body []byte) error {
+	rec := f.records[[2]string{actor, key}]
+	rec.Done, rec.Status, rec.ContentType, rec.Body = true, status, contentType, body
+	f.records[[2]string{actor, key}] = rec
+	return nil
+}
+
+func (f *fakeIdempotencyStore) Release(ctx context.Context, actor, key string) error {
+	delete(f.records, [2]string{actor, key})
+	return nil
+}
+
+// empPath is the path of an employee sub-resource
+func empPath(empId int, rest string) string {
+	return "/api/employees/" + strconv.Itoa(empId) + rest
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/karthikeyan-meenachisundaram/goBack/models"
+	"github.com/karthikeyan-meenachisundaram/goBack/repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var tagPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{0,39}$`)
+
+// normalizeTag lower-cases and trims a tag so "On-Call " and "on-call" are the same
+func normalizeTag(t string) string {
+	return strings.ToLower(strings.TrimSpace(t))
+}
+
+// allowedTags returns the curated tag list from tags.allowed, nil if free-form
+func allowedTags() map[string]bool {
+	if len(cfg.Tags.Allowed) == 0 {
+		return nil
+	}
+	allowed := map[string]bool{}
+	for _, t := range cfg.Tags.Allowed {
+		if t = normalizeTag(t); t != "" {
+			allowed[t] = true
+		}
+	}
+	return allowed
+}
+
+// employeeTags handles POST /api/employees/{id}/tags and DELETE /api/employees/{id}/tags/{tag}
+func employeeTags(w http.ResponseWriter, r *http.Request, empId int, tag string) {
+	ctx := r.Context()
+
+	var change models.TagsChange
+	switch {
+	case r.Method == http.MethodPost && tag == "":
+		var input struct {
+			Tags []string `json:"tags"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			httpError(w, "invalid input: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(input.Tags) == 0 {
+			httpError(w, "tags is required", http.StatusUnprocessableEntity)
+			return
+		}
+		allowed := allowedTags()
+		tags := make([]string, 0, len(input.Tags))
+		for _, t := range input.Tags {
+			t = normalizeTag(t)
+			if !tagPattern.MatchString(t) {
+				httpError(w, "invalid tag: "+t, http.StatusUnprocessableEntity)
+				return
+			}
+			if allowed != nil && !allowed[t] {
+				httpError(w, "tag not allowed: "+t, http.StatusUnprocessableEntity)
+				return
+			}
+			tags = append(tags, t)
+		}
+		change.Add = tags
+	case r.Method == http.MethodDelete && tag != "":
+		change.Remove = normalizeTag(tag)
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// in approval mode non-admin tag changes wait for an approver
+	if approvalMode() && !isAdmin(r) {
+		submitChangeRequest(ctx, w, r, models.ChangeRequest{Kind: "tags", EmpID: empId, Tags: &change})
+		return
+	}
+
+	tags, err := changeTags(ctx, empId, change, actorFromRequest(r))
+	if err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			httpError(w, "employee not found", http.StatusNotFound)
+			return
+		}
+		storeError(w, "update tags", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(bson.M{"emp_id": empId, "tags": tags})
+}
+
+// changeTags adds or removes the tags of c on a live employee as actor and returns its
+// tags; models.ErrNotFound when there is no such employee
+func changeTags(ctx context.Context, empId int, c models.TagsChange, actor string) ([]string, error) {
+	update := bson.M{"$addToSet": bson.M{"tags": bson.M{"$each": c.Add}}}
+	details := bson.M{"added": c.Add}
+	if c.Remove != "" {
+		update = bson.M{"$pull": bson.M{"tags": c.Remove}}
+		details = bson.M{"removed": c.Remove}
+	}
+
+	var emp struct {
+		Tags []string `bson:"tags" json:"tags"`
+	}
+	err := auditChange(ctx, "tags", empId, actor, details, func() error {
+		return coll(ctx, "Employee").FindOneAndUpdate(ctx, repository.Live(bson.M{"emp_id": empId}), repository.BumpVersion(update),
+			options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&emp)
+	})
+	if err == mongo.ErrNoDocuments {
+		return nil, models.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if emp.Tags == nil {
+		emp.Tags = []string{}
+	}
+	return emp.Tags, nil
+}
+
+// tagsHandler handles GET /api/tags, returning each tag with the number of employees carrying it
+func tagsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	ctx := r.Context()
+
+	pipeline := mongo.Pipeline{
+		bson.D{{Key: "$match", Value: repository.Live(bson.M{})}},
+		bson.D{{Key: "$unwind", Value: "$tags"}},
+		bson.D{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$tags"},
+			{Key: "count", Value: bson.M{"$sum": 1}},
+		}}},
+		bson.D{{Key: "$project", Value: bson.D{
+			{Key: "_id", Value: 0},
+			{Key: "tag", Value: "$_id"},
+			{Key: "count", Value: 1},
+		}}},
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}, {Key: "tag", Value: 1}}}},
+	}
+	cur, err := coll(ctx, "Employee").Aggregate(ctx, pipeline)
+	if err != nil {
+		storeError(w, "aggregate", err)
+		return
+	}
+	defer cur.Close(ctx)
+
+	usage := []bson.M{}
+	if err := cur.All(ctx, &usage); err != nil {
+		storeError(w, "cursor all", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(usage)
+}
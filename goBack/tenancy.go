@@ -0,0 +1,324 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Every organization (tenant) has a database of its own: mongo.database for the default
+// organization, which is where the data of a single-tenant deployment already is, and
+// <mongo.database>_<org_id> for the others. Collections, indexes, counters (emp_id too)
+// and users are therefore per organization without a filter in every query. A request's
+// organization comes from its token, or for login from X-Org-ID; see tenant.
+
+const (
+	// defaultOrg is the organization of mongo.database; its admins manage organizations
+	defaultOrg         = "default"
+	maxOrgNameLength   = 100
+	orgHeader          = "X-Org-ID"
+	orgsCollectionName = "Organizations" // in the default organization's database
+)
+
+// orgIDPattern keeps org ids usable in database names (mongo.database plus the id stays
+// under MongoDB's 64 bytes for sensible database names)
+var orgIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,29}$`)
+
+// reservedOrgIDs match orgIDPattern but can't name a new organization: "default" is
+// the default organization, whose database setUpOrg would otherwise write its admin to
+var reservedOrgIDs = map[string]bool{defaultOrg: true}
+
+// Organization is a tenant in the Organizations collection
+type Organization struct {
+	ID        string    `bson:"_id" json:"org_id"`
+	Name      string    `bson:"name" json:"name"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+	CreatedBy string    `bson:"created_by,omitempty" json:"created_by,omitempty"`
+}
+
+// knownOrgs caches the organization ids this instance has seen; orgExists asks the
+// database about others, so organizations created on another instance work here too
+var knownOrgs = struct {
+	sync.RWMutex
+	ids map[string]bool
+}{ids: map[string]bool{defaultOrg: true}}
+
+// orgKey keys the organization in a context
+type orgKey struct{}
+
+// withOrg returns ctx acting for organization org
+func withOrg(ctx context.Context, org string) context.Context {
+	return context.WithValue(ctx, orgKey{}, org)
+}
+
+// orgOf is the organization ctx acts for, the default one unless withOrg said otherwise
+func orgOf(ctx context.Context) string {
+	if org, ok := ctx.Value(orgKey{}).(string); ok && org != "" {
+		return org
+	}
+	return defaultOrg
+}
+
+// orgDatabase is the name of an organization's database
+func orgDatabase(org string) string {
+	if org == defaultOrg {
+		return cfg.Mongo.Database
+	}
+	return cfg.Mongo.Database + "_" + org
+}
+
+// database is the database of the organization ctx acts for
+func database(ctx context.Context) *mongo.Database {
+	return client.Database(orgDatabase(orgOf(ctx)))
+}
+
+// coll is a collection of the organization ctx acts for
+func coll(ctx context.Context, name string) *mongo.Collection {
+	return database(ctx).Collection(name)
+}
+
+// orgsCollection is the Organizations collection, whatever organization ctx acts for
+func orgsCollection() *mongo.Collection {
+	return client.Database(orgDatabase(defaultOrg)).Collection(orgsCollectionName)
+}
+
+// orgOfClaims is the organization a token was issued for; tokens from before
+// organizations existed belong to the default one
+func orgOfClaims(c *tokenClaims) string {
+	if c.Org == "" {
+		return defaultOrg
+	}
+	return c.Org
+}
+
+// loadOrgs reads the organizations into knownOrgs
+func loadOrgs(ctx context.Context) error {
+	cur, err := orgsCollection().Find(ctx, bson.M{}, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return err
+	}
+	var list []Organization
+	if err := cur.All(ctx, &list); err != nil {
+		return err
+	}
+	knownOrgs.Lock()
+	defer knownOrgs.Unlock()
+	for _, o := range list {
+		knownOrgs.ids[o.ID] = true
+	}
+	return nil
+}
+
+// orgExists reports whether org is an organization; without MongoDB (the memory driver)
+// only the default one is
+func orgExists(ctx context.Context, org string) (bool, error) {
+	knownOrgs.RLock()
+	known := knownOrgs.ids[org]
+	knownOrgs.RUnlock()
+	if known || !orgIDPattern.MatchString(org) || client == nil {
+		return known, nil
+	}
+	err := orgsCollection().FindOne(ctx, bson.M{"_id": org}).Err()
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	knownOrgs.Lock()
+	knownOrgs.ids[org] = true
+	knownOrgs.Unlock()
+	return true, nil
+}
+
+// orgIDs lists the organizations this instance knows, the default one first
+func orgIDs() []string {
+	knownOrgs.RLock()
+	defer knownOrgs.RUnlock()
+	ids := make([]string, 0, len(knownOrgs.ids))
+	for id := range knownOrgs.ids {
+		if id != defaultOrg {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	return append([]string{defaultOrg}, ids...)
+}
+
+// initOrgs loads the organizations; without them only the default one is served
+func initOrgs(ctx context.Context) {
+	if err := loadOrgs(ctx); err != nil {
+		slog.Error("initOrgs: load organizations", "err", err)
+		return
+	}
+	if n := len(orgIDs()); n > 1 {
+		slog.Info("loaded organizations", "organizations", n)
+	}
+}
+
+// forEachOrg runs fn (one of the start-up steps) for every known organization
+func forEachOrg(ctx context.Context, fn func(ctx context.Context)) {
+	for _, org := range orgIDs() {
+		fn(withOrg(ctx, org))
+	}
+}
+
+// orgStarters are the per-organization background tasks (change stream watchers) that
+// a new organization needs as well; registered by startEventWatcher
+var orgStarters []func(org string)
+
+// setUpOrg prepares the database of a new organization: indexes and its first admin
+func setUpOrg(ctx context.Context, admin, password string) error {
+	ensureIndexes(ctx)
+	_, err := createUser(ctx, admin, password, "admin")
+	return err
+}
+
+// tenant puts the request's organization on its context: the token's for authenticated
+// requests (an X-Org-ID naming another one is refused), X-Org-ID or the default
+// organization for the public ones such as login
+func tenant(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		org := r.Header.Get(orgHeader)
+		if c, ok := r.Context().Value(userKey).(*tokenClaims); ok {
+			tokenOrg := orgOfClaims(c)
+			if org != "" && org != tokenOrg {
+				writeForbidden(w, fmt.Sprintf("the token belongs to organization %q", tokenOrg))
+				return
+			}
+			org = tokenOrg
+		}
+		if org == "" {
+			org = defaultOrg
+		}
+		ok, err := orgExists(r.Context(), org)
+		if err != nil {
+			storeError(w, "find organization", err)
+			return
+		}
+		if !ok {
+			httpError(w, fmt.Sprintf("unknown organization %q", org), http.StatusBadRequest)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(withOrg(r.Context(), org)))
+	})
+}
+
+// requirePlatformAdmin writes 403 and returns false unless the request is from an admin
+// of the default organization
+func requirePlatformAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if !isAdmin(r) || orgOf(r.Context()) != defaultOrg {
+		writeForbidden(w, "admin of the default organization required")
+		return false
+	}
+	return true
+}
+
+// ---------------- Handlers ----------------
+
+// orgsHandler handles GET and POST /api/admin/orgs (admins of the default organization).
+// POST {org_id, name, admin_username, admin_password} creates the organization with its
+// database and first admin account.
+func orgsHandler(w http.ResponseWriter, r *http.Request) {
+	if !requirePlatformAdmin(w, r) {
+		return
+	}
+	ctx := r.Context()
+
+	switch r.Method {
+	case http.MethodGet:
+		cur, err := orgsCollection().Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+		if err != nil {
+			storeError(w, "find organizations", err)
+			return
+		}
+		defer cur.Close(ctx)
+		list := []Organization{}
+		if err := cur.All(ctx, &list); err != nil {
+			storeError(w, "cursor all", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(list)
+
+	case http.MethodPost:
+		var input struct {
+			OrgID         string `json:"org_id"`
+			Name          string `json:"name"`
+			AdminUsername string `json:"admin_username"`
+			AdminPassword string `json:"admin_password"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			httpError(w, "invalid input: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		errs := map[string]string{}
+		if !orgIDPattern.MatchString(input.OrgID) {
+			errs["org_id"] = "must be 2 to 30 lower-case letters, digits or dashes, starting with a letter or digit"
+		} else if reservedOrgIDs[input.OrgID] {
+			errs["org_id"] = "is reserved"
+		}
+		input.Name = strings.TrimSpace(input.Name)
+		if input.Name == "" {
+			errs["name"] = "is required"
+		} else if utf8.RuneCountInString(input.Name) > maxOrgNameLength {
+			errs["name"] = fmt.Sprintf("must be at most %d characters", maxOrgNameLength)
+		}
+		// the same rules as /api/admin/users
+		if input.AdminUsername == "" || strings.Contains(input.AdminUsername, "/") {
+			errs["admin_username"] = "is required and must not contain /"
+		}
+		if len(input.AdminPassword) < 8 {
+			errs["admin_password"] = "must be at least 8 characters"
+		}
+		if len(errs) > 0 {
+			writeValidationErrors(w, errs)
+			return
+		}
+
+		o := Organization{ID: input.OrgID, Name: input.Name, CreatedAt: time.Now().UTC(), CreatedBy: actorFromRequest(r)}
+		if _, err := orgsCollection().InsertOne(ctx, o); err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				httpError(w, fmt.Sprintf("organization %q already exists", o.ID), http.StatusConflict)
+				return
+			}
+			storeError(w, "insert organization", err)
+			return
+		}
+		if err := setUpOrg(withOrg(ctx, o.ID), input.AdminUsername, input.AdminPassword); err != nil {
+			_, _ = orgsCollection().DeleteOne(ctx, bson.M{"_id": o.ID})
+			storeError(w, "set up organization", err)
+			return
+		}
+		knownOrgs.Lock()
+		knownOrgs.ids[o.ID] = true
+		knownOrgs.Unlock()
+		for _, start := range orgStarters {
+			start(o.ID)
+		}
+		logFor(ctx).Info("created organization", "org", o.ID, "actor", o.CreatedBy)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(o)
+
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	pb "github.com/karthikeyan-meenachisundaram/goBack/employeepb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+// useOrg makes org a known organization for the rest of the test
+func useOrg(t *testing.T, org string) {
+	t.Helper()
+	knownOrgs.Lock()
+	knownOrgs.ids[org] = true
+	knownOrgs.Unlock()
+	t.Cleanup(func() {
+		knownOrgs.Lock()
+		delete(knownOrgs.ids, org)
+		knownOrgs.Unlock()
+	})
+}
+
+// Requests act for the organization of their token, over HTTP and gRPC alike; asking for
+// another one is refused rather than honored.
+func TestTenantIsolation(t *testing.T) {
+	useBootstrapAccount(t)
+	useOrg(t, "acme")
+	token := func(org string) string {
+		token, err := issueToken(User{Username: "ann", Role: "admin"}, org, "access", time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	tests := []struct {
+		name, tokenOrg, header string
+		status                 int // over HTTP
+		code                   codes.Code
+		org                    string // acted for
+	}{
+		{"default", defaultOrg, "", http.StatusOK, codes.OK, defaultOrg},
+		{"acme", "acme", "", http.StatusOK, codes.OK, "acme"},
+		{"acme asking for acme", "acme", "acme", http.StatusOK, codes.OK, "acme"},
+		// gRPC has no organization header: the token's organization is the only one
+		{"acme asking for default", "acme", defaultOrg, http.StatusForbidden, codes.OK, "acme"},
+		{"default asking for acme", defaultOrg, "acme", http.StatusForbidden, codes.OK, defaultOrg},
+		{"token from before organizations", "", "", http.StatusOK, codes.OK, defaultOrg},
+		{"removed organization", "globex", "", http.StatusBadRequest, codes.InvalidArgument, ""},
+	}
+
+	var org string
+	capture := func(ctx context.Context) { org = orgOf(ctx) }
+	h := authenticate(tenant(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { capture(r.Context()) })))
+	g := grpcInterceptors{}
+	for _, tt := range tests {
+		org = ""
+		r := request(http.MethodGet, "/api/employees", "", "", "")
+		r.Header.Set("Authorization", "Bearer "+token(tt.tokenOrg))
+		if tt.header != "" {
+			r.Header.Set(orgHeader, tt.header)
+		}
+		w := record(h.ServeHTTP, r)
+		if w.Code != tt.status {
+			t.Errorf("HTTP %s: status = %d, want %d (%s)", tt.name, w.Code, tt.status, w.Body.String())
+		}
+		want := ""
+		if tt.status == http.StatusOK {
+			want = tt.org
+		}
+		if org != want {
+			t.Errorf("HTTP %s: acted for %q, want %q", tt.name, org, want)
+		}
+
+		org = ""
+		md := metadata.Pairs("authorization", "Bearer "+token(tt.tokenOrg))
+		if tt.header != "" {
+			md.Append("x-org-id", tt.header)
+		}
+		ctx := metadata.NewIncomingContext(context.Background(), md)
+		_, err := g.unary(ctx, nil, &grpc.UnaryServerInfo{FullMethod: pb.EmployeeService_Get_FullMethodName},
+			func(ctx context.Context, _ any) (any, error) { capture(ctx); return nil, nil })
+		expectCode(t, err, tt.code)
+		if org != tt.org {
+			t.Errorf("gRPC %s: acted for %q, want %q", tt.name, org, tt.org)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/karthikeyan-meenachisundaram/goBack/models"
+	"github.com/karthikeyan-meenachisundaram/goBack/service"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// terminateEmployee handles POST /api/employees/{id}/terminate.
+// Unlike delete, the employee record is kept and only marked as terminated.
+func terminateEmployee(w http.ResponseWriter, r *http.Request, empId int) {
+	if r.Method != http.MethodPost {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var input struct {
+		EndDate string `json:"end_date"` // YYYY-MM-DD
+		Reason  string `json:"reason"`
+		Note    string `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		httpError(w, "invalid input: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	t, err := service.NewTermination(input.EndDate, input.Reason, input.Note)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	ctx := r.Context()
+
+	// in approval mode non-admin terminations wait for an approver
+	if approvalMode() && !isAdmin(r) {
+		submitChangeRequest(ctx, w, r, models.ChangeRequest{Kind: "terminate", EmpID: empId,
+			Termination: &models.TerminationChange{EndDate: t.EndDate, Reason: t.Reason, Note: t.Note}})
+		return
+	}
+
+	switch err := employees.Terminate(ctx, empId, t, actorFromRequest(r)); {
+	case errors.Is(err, models.ErrNotFound):
+		httpError(w, "employee not found", http.StatusNotFound)
+		return
+	case errors.Is(err, models.ErrAlreadyTerminated):
+		httpError(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		storeError(w, "terminate employee", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(bson.M{"message": "Employee terminated successfully", "emp_id": empId})
+}
+
+// attritionReportHandler handles GET /api/reports/attrition.
+// Query params: period=month|quarter|year (default month), from/to (YYYY-MM-DD, on end_date).
+func attritionReportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	query, err := service.NewAttritionQuery(q.Get("period"), q.Get("from"), q.Get("to"))
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rows, err := reports.Attrition(r.Context(), query)
+	if err != nil {
+		storeError(w, "attrition report", err)
+		return
+	}
+	total := 0
+	for _, row := range rows {
+		total += row.Count
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(bson.M{"period": query.Period, "rows": rows, "total": total})
+}
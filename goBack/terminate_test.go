@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/karthikeyan-meenachisundaram/goBack/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestTerminateEmployee(t *testing.T) {
+	s := useTestStores(t)
+	s.addEmployees(t, models.NewEmployee{EmpID: 1, EmpName: "Asha", Department: "Engg"})
+	body := `{"end_date":"2026-03-31","reason":"resignation","note":"moving abroad"}`
+
+	w := asAdmin(t, empByIDHandler, http.MethodPost, empPath(1, "/terminate"), body)
+	expectStatus(t, w, http.StatusOK)
+	raw, _ := s.employees.Get(t.Context(), 1, nil)
+	if got := bson.Raw(raw).Lookup("status").StringValue(); got != "terminated" {
+		t.Errorf("status = %q, want terminated", got)
+	}
+
+	w = asAdmin(t, empByIDHandler, http.MethodPost, empPath(1, "/terminate"), body)
+	expectStatus(t, w, http.StatusConflict)
+	w = asAdmin(t, empByIDHandler, http.MethodPost, empPath(2, "/terminate"), body)
+	expectStatus(t, w, http.StatusNotFound)
+}
+
+func TestTerminateEmployeeValidation(t *testing.T) {
+	s := useTestStores(t)
+	s.addEmployees(t, models.NewEmployee{EmpID: 1, EmpName: "Asha", Department: "Engg"})
+
+	for _, body := range []string{
+		`{"end_date":"31/03/2026","reason":"resignation"}`,
+		`{"end_date":"2026-03-31","reason":"bored"}`,
+	} {
+		w := asAdmin(t, empByIDHandler, http.MethodPost, empPath(1, "/terminate"), body)
+		expectStatus(t, w, http.StatusUnprocessableEntity)
+	}
+	w := asAdmin(t, empByIDHandler, http.MethodGet, empPath(1, "/terminate"), "")
+	expectStatus(t, w, http.StatusMethodNotAllowed)
+}
+
+func TestAttritionReport(t *testing.T) {
+	s := useTestStores(t)
+	s.reports.attrition = []models.AttritionRow{
+		{Period: "2026-Q1", Department: "Engg", Reason: "resignation", Count: 2},
+		{Period: "2026-Q1", Department: "Ops", Reason: "layoff", Count: 3},
+	}
+
+	w := asAdmin(t, attritionReportHandler, http.MethodGet, "/api/reports/attrition?period=quarter&from=2026-01-01", "")
+	expectStatus(t, w, http.StatusOK)
+	out := decode(t, w)
+	if out["total"] != 5.0 || out["period"] != "quarter" {
+		t.Errorf("report = %v", out)
+	}
+	q := s.reports.query
+	if q.Period != "quarter" || q.From == nil || !q.From.Equal(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)) || q.To != nil {
+		t.Errorf("query = %+v", q)
+	}
+
+	w = asAdmin(t, attritionReportHandler, http.MethodGet, "/api/reports/attrition?period=week", "")
+	expectStatus(t, w, http.StatusBadRequest)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// tlsVersions are the accepted values of tls.min_version
+var tlsVersions = map[string]uint16{"1.2": tls.VersionTLS12, "1.3": tls.VersionTLS13}
+
+// serverTLS is the TLS configuration of the HTTPS listener, nil when tls is off. With
+// autocert it also returns the handler answering ACME http-01 challenges on the redirect
+// listener, wrapping next.
+func serverTLS(next http.Handler) (*tls.Config, http.Handler, error) {
+	t := cfg.TLS
+	if !t.enabled() {
+		return nil, next, nil
+	}
+	var tc *tls.Config
+	if len(t.AutocertDomains) > 0 {
+		m := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(t.AutocertDomains...),
+			Cache:      autocert.DirCache(t.AutocertDir),
+			Email:      t.AutocertEmail,
+		}
+		// GetCertificate, and acme-tls/1 so the challenge can also be answered on this listener
+		tc = m.TLSConfig()
+		next = m.HTTPHandler(next)
+	} else {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("tls key pair: %w", err)
+		}
+		tc = &tls.Config{Certificates: []tls.Certificate{cert}}
+	}
+	tc.MinVersion = tlsVersions[t.MinVersion]
+	tc.CurvePreferences = []tls.CurveID{tls.X25519, tls.CurveP256}
+	// forward-secret AEAD suites only; TLS 1.3 picks its own
+	tc.CipherSuites = []uint16{
+		tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+		tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+		tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+		tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+	}
+	return tc, next, nil
+}
+
+// hsts adds Strict-Transport-Security to every response (tls.hsts_max_age)
+func hsts(next http.Handler) http.Handler {
+	if cfg.TLS.HSTSMaxAge <= 0 {
+		return next
+	}
+	value := "max-age=" + strconv.Itoa(int(time.Duration(cfg.TLS.HSTSMaxAge).Seconds()))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Strict-Transport-Security", value)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// redirectToHTTPS sends plain HTTP requests to the same URL on the HTTPS listener
+func redirectToHTTPS(w http.ResponseWriter, r *http.Request) {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if _, port, _ := net.SplitHostPort(cfg.Server.Addr); port != "" && port != "443" {
+		host = net.JoinHostPort(host, port)
+	}
+	http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+}
+
+// startRedirect serves handler as plain HTTP on tls.redirect_addr (nothing when empty)
+// and returns the function that drains and stops it
+func startRedirect(handler http.Handler) (func(context.Context), error) {
+	addr := cfg.TLS.RedirectAddr
+	if addr == "" {
+		return func(context.Context) {}, nil
+	}
+	ln, err := listen(addr)
+	if err != nil {
+		return nil, err
+	}
+	srv := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: time.Duration(cfg.Server.ReadHeaderTimeout),
+	}
+	go func() {
+		if err := srv.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("redirect server", "err", err)
+		}
+	}()
+	slog.Info("redirecting to https", "addr", addr)
+
+	return func(ctx context.Context) {
+		if err := srv.Shutdown(ctx); err != nil {
+			slog.Error("redirect server shutdown", "err", err)
+		}
+	}, nil
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/event"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.39.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// With tracing.endpoint set, every /api request is a span (continuing the caller's trace
+// when it sends a traceparent) and every Mongo command it runs a child span, so a slow
+// response shows whether the time went to the aggregation or elsewhere. Spans are
+// batched to an OTLP collector.
+
+// tracer makes the Mongo spans; nil while tracing is off
+var tracer trace.Tracer
+
+// mongoSpans are the spans of the Mongo commands in flight, by the driver's request id
+var mongoSpans = struct {
+	sync.Mutex
+	spans map[int64]trace.Span
+}{spans: map[int64]trace.Span{}}
+
+// startTracing sets up the exporter, the tracer provider and W3C trace context
+// propagation from cfg.Tracing. The returned function flushes the spans still batched
+// and is a no-op while tracing is off.
+func startTracing(ctx context.Context) (func(context.Context), error) {
+	t := cfg.Tracing
+	if !t.enabled() {
+		return func(context.Context) {}, nil
+	}
+	var exporter sdktrace.SpanExporter
+	var err error
+	if t.Protocol == "http/protobuf" {
+		// unlike for gRPC, the endpoint is the base URL the traces path goes under
+		u, _ := url.Parse(t.Endpoint)
+		exporter, err = otlptracehttp.New(ctx,
+			otlptracehttp.WithEndpointURL(t.Endpoint),
+			otlptracehttp.WithURLPath(path.Join("/", u.Path, "v1/traces")),
+			otlptracehttp.WithHeaders(t.Headers))
+	} else {
+		exporter, err = otlptracegrpc.New(ctx,
+			otlptracegrpc.WithEndpointURL(t.Endpoint),
+			otlptracegrpc.WithHeaders(t.Headers))
+	}
+	if err != nil {
+		return nil, err
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(t.ServiceName)))
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(t.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		slog.Warn("tracing: export", "err", err)
+	}))
+	tracer = provider.Tracer("github.com/karthikeyan-meenachisundaram/goBack")
+	slog.Info("tracing to OTLP collector", "endpoint", t.Endpoint, "protocol", t.Protocol, "sample_ratio", t.SampleRatio)
+
+	return func(ctx context.Context) {
+		if err := provider.Shutdown(ctx); err != nil {
+			slog.Error("tracing: shutdown", "err", err)
+		}
+	}, nil
+}
+
+// traceRequests wraps next in a server span per /api request, named after the mux pattern
+// that serves it (as the metrics label it) rather than the raw path
+func traceRequests(mux *http.ServeMux, next http.Handler) http.Handler {
+	if !cfg.Tracing.enabled() {
+		return next
+	}
+	return otelhttp.NewHandler(next, "http",
+		otelhttp.WithFilter(func(r *http.Request) bool { return strings.HasPrefix(r.URL.Path, "/api/") }),
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.Method + " " + routeOf(mux, r)
+		}))
+}
+
+// startMongoSpan opens the span of a command, as a child of the request's span. Commands
+// outside a recorded trace (the job workers' polling, change streams, unsampled
+// requests) get none, so they don't each start a trace of their own.
+func startMongoSpan(ctx context.Context, e *event.CommandStartedEvent) {
+	if tracer == nil || !trace.SpanContextFromContext(ctx).IsSampled() {
+		return
+	}
+	name := e.CommandName
+	attrs := []attribute.KeyValue{
+		semconv.DBSystemNameMongoDB,
+		semconv.DBOperationName(e.CommandName),
+		semconv.DBNamespace(e.DatabaseName),
+	}
+	// the first field of a command is its name with the collection as value
+	if first, err := e.Command.IndexErr(0); err == nil {
+		if collection, ok := first.Value().StringValueOK(); ok {
+			name += " " + collection
+			attrs = append(attrs, semconv.DBCollectionName(collection))
+		}
+	}
+	// connection ids read host:port[-n]
+	addr, _, _ := strings.Cut(e.ConnectionID, "[")
+	if host, port, err := net.SplitHostPort(addr); err == nil {
+		attrs = append(attrs, semconv.ServerAddress(host))
+		if n, err := strconv.Atoi(port); err == nil {
+			attrs = append(attrs, semconv.ServerPort(n))
+		}
+	}
+	_, span := tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+	mongoSpans.Lock()
+	mongoSpans.spans[e.RequestID] = span
+	mongoSpans.Unlock()
+}
+
+// endMongoSpan closes the span of a finished command; failure is empty on success
+func endMongoSpan(requestID int64, failure string) {
+	if tracer == nil {
+		return
+	}
+	mongoSpans.Lock()
+	span, ok := mongoSpans.spans[requestID]
+	delete(mongoSpans.spans, requestID)
+	mongoSpans.Unlock()
+	if !ok {
+		return
+	}
+	if failure != "" {
+		span.SetStatus(codes.Error, failure)
+	}
+	span.End()
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/karthikeyan-meenachisundaram/goBack/models"
+	"github.com/karthikeyan-meenachisundaram/goBack/service"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// transferEmployee handles POST /api/employees/{id}/transfer
+func transferEmployee(w http.ResponseWriter, r *http.Request, empId int) {
+	if r.Method != http.MethodPost {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var input struct {
+		ToDepartment  string `json:"to_department"`
+		EffectiveDate string `json:"effective_date"` // YYYY-MM-DD, defaults to today
+		Reason        string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		httpError(w, "invalid input: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if input.ToDepartment == "" {
+		httpError(w, "to_department is required", http.StatusUnprocessableEntity)
+		return
+	}
+	effective, err := service.EffectiveDate(input.EffectiveDate)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	ctx := r.Context()
+
+	change := models.TransferChange{ToDepartment: input.ToDepartment, EffectiveDate: effective, Reason: input.Reason}
+	t, err := newTransfer(ctx, empId, change, actorFromRequest(r))
+	switch {
+	case errors.Is(err, models.ErrNotFound):
+		httpError(w, "employee not found", http.StatusNotFound)
+		return
+	case errors.Is(err, models.ErrTerminated):
+		httpError(w, "cannot transfer a terminated employee", http.StatusConflict)
+		return
+	case errors.Is(err, service.ErrSameDepartment):
+		httpError(w, "employee is already in department "+input.ToDepartment, http.StatusConflict)
+		return
+	case err != nil:
+		storeError(w, "find employee", err)
+		return
+	}
+
+	// in approval mode non-admin transfers wait for an approver
+	if approvalMode() && !isAdmin(r) {
+		submitChangeRequest(ctx, w, r, models.ChangeRequest{Kind: "transfer", EmpID: empId, Transfer: &change})
+		return
+	}
+
+	if err := transfers.Transfer(ctx, t); err != nil {
+		storeError(w, "transfer", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(bson.M{"message": "Employee transferred successfully", "transfer": t})
+}
+
+// newTransfer is the transfer c of employee empId recorded by actor: models.ErrNotFound,
+// models.ErrTerminated or service.ErrSameDepartment when the employee can't take it
+func newTransfer(ctx context.Context, empId int, c models.TransferChange, actor string) (models.Transfer, error) {
+	raw, err := employees.Get(ctx, empId, nil)
+	if err != nil {
+		return models.Transfer{}, err
+	}
+	var emp struct {
+		Status     string `bson:"status"`
+		Department string `bson:"department"`
+	}
+	if err := raw.Decode(&emp); err != nil {
+		return models.Transfer{}, err
+	}
+	if err := service.CheckTransfer(emp.Status, emp.Department, c.ToDepartment); err != nil {
+		return models.Transfer{}, err
+	}
+	return models.Transfer{
+		EmpID:          empId,
+		FromDepartment: emp.Department,
+		ToDepartment:   c.ToDepartment,
+		EffectiveDate:  c.EffectiveDate,
+		Reason:         c.Reason,
+		RecordedAt:     time.Now().UTC(),
+		RecordedBy:     actor,
+	}, nil
+}
+
+// transferHistory handles GET /api/employees/{id}/transfers, newest first
+func transferHistory(w http.ResponseWriter, r *http.Request, empId int) {
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	history, err := transfers.History(r.Context(), empId)
+	if err != nil {
+		storeError(w, "find transfers", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(history)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/karthikeyan-meenachisundaram/goBack/models"
+)
+
+func TestTransferEmployee(t *testing.T) {
+	s := useTestStores(t)
+	s.addEmployees(t, models.NewEmployee{EmpID: 1, EmpName: "Asha", Department: "Engg"})
+
+	w := asAdmin(t, empByIDHandler, http.MethodPost, empPath(1, "/transfer"), `{"to_department":"Ops","effective_date":"2026-04-01","reason":"rotation"}`)
+	expectStatus(t, w, http.StatusOK)
+	if len(s.transfers.recorded) != 1 {
+		t.Fatalf("recorded %d transfers, want 1", len(s.transfers.recorded))
+	}
+	tr := s.transfers.recorded[0]
+	if tr.FromDepartment != "Engg" || tr.ToDepartment != "Ops" || tr.RecordedBy != "admin" || tr.EffectiveDate.Format("2006-01-02") != "2026-04-01" {
+		t.Errorf("transfer = %+v", tr)
+	}
+
+	w = asAdmin(t, empByIDHandler, http.MethodGet, empPath(1, "/transfers"), "")
+	expectStatus(t, w, http.StatusOK)
+	if got := w.Body.String(); got == "[]\n" {
+		t.Errorf("history is empty")
+	}
+
+	// she is in Ops now
+	w = asAdmin(t, empByIDHandler, http.MethodPost, empPath(1, "/transfer"), `{"to_department":"Ops"}`)
+	expectStatus(t, w, http.StatusConflict)
+}
+
+func TestTransferEmployeeRejected(t *testing.T) {
+	s := useTestStores(t)
+	s.addEmployees(t, models.NewEmployee{EmpID: 1, EmpName: "Asha", Department: "Engg"})
+	if err := s.employees.Terminate(t.Context(), 1, models.Termination{Reason: "layoff"}, "admin"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		empID  int
+		body   string
+		status int
+	}{
+		{"terminated", 1, `{"to_department":"Ops"}`, http.StatusConflict},
+		{"unknown employee", 2, `{"to_department":"Ops"}`, http.StatusNotFound},
+		{"no department", 1, `{}`, http.StatusUnprocessableEntity},
+		{"bad date", 1, `{"to_department":"Ops","effective_date":"April 1"}`, http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := asAdmin(t, empByIDHandler, http.MethodPost, empPath(tt.empID, "/transfer"), tt.body)
+			expectStatus(t, w, tt.status)
+		})
+	}
+	if len(s.transfers.recorded) > 0 {
+		t.Errorf("recorded %+v", s.transfers.recorded)
+	}
+}
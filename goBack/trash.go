@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// TrashItem is one soft-deleted resource in the recycle bin
+type TrashItem struct {
+	Resource  string    `json:"resource"`
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	DeletedAt time.Time `json:"deleted_at"`
+	DeletedBy string    `json:"deleted_by"`
+}
+
+// trashHandler handles GET /api/trash (admin only)
+func trashHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	ctx := r.Context()
+
+	opts := options.Find().SetSort(bson.D{{Key: "deleted_at", Value: -1}})
+	cur, err := coll(ctx, "Employee").Find(ctx, bson.M{"deleted_at": bson.M{"$exists": true}}, opts)
+	if err != nil {
+		storeError(w, "find", err)
+		return
+	}
+	defer cur.Close(ctx)
+
+	var docs []struct {
+		EmpID     int       `bson:"emp_id"`
+		EmpName   string    `bson:"emp_name"`
+		DeletedAt time.Time `bson:"deleted_at"`
+		DeletedBy string    `bson:"deleted_by"`
+	}
+	if err := cur.All(ctx, &docs); err != nil {
+		storeError(w, "cursor all", err)
+		return
+	}
+	items := make([]TrashItem, 0, len(docs))
+	for _, d := range docs {
+		items = append(items, TrashItem{Resource: "employee", ID: d.EmpID, Name: d.EmpName, DeletedAt: d.DeletedAt, DeletedBy: d.DeletedBy})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(items)
+}
+
+// purgeDeleted permanently deletes the soft-deleted employees matching filter and returns
+// their emp_ids; on an error the ones purged so far are returned with it
+func purgeDeleted(ctx context.Context, filter bson.M, actor string) ([]int, error) {
+	// only soft-deleted employees can be purged
+	if _, ok := filter["deleted_at"]; !ok {
+		filter["deleted_at"] = bson.M{"$exists": true}
+	}
+	cur, err := coll(ctx, "Employee").Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	var docs []struct {
+		EmpID int `bson:"emp_id"`
+	}
+	if err := cur.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	purged := []int{}
+	for _, d := range docs {
+		if err := employees.Purge(ctx, d.EmpID); err != nil {
+			return purged, err
+		}
+		_ = recordAudit(ctx, "purge", d.EmpID, actor, nil)
+		purged = append(purged, d.EmpID)
+	}
+	return purged, nil
+}
+
+// trashPurgeHandler handles POST /api/trash/purge (admin only).
+// Permanently deletes the listed emp_ids and/or everything deleted more than older_than_days ago.
+func trashPurgeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	var input struct {
+		EmpIDs        []int `json:"emp_ids"`
+		OlderThanDays *int  `json:"older_than_days"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		httpError(w, "invalid input: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(input.EmpIDs) == 0 && input.OlderThanDays == nil {
+		httpError(w, "emp_ids or older_than_days is required", http.StatusUnprocessableEntity)
+		return
+	}
+	if input.OlderThanDays != nil && *input.OlderThanDays < 0 {
+		httpError(w, "older_than_days must not be negative", http.StatusUnprocessableEntity)
+		return
+	}
+
+	ctx := r.Context()
+
+	// only soft-deleted employees can be purged
+	deleted := bson.M{"$exists": true}
+	if input.OlderThanDays != nil {
+		deleted["$lte"] = time.Now().UTC().AddDate(0, 0, -*input.OlderThanDays)
+	}
+	filter := bson.M{"deleted_at": deleted}
+	if len(input.EmpIDs) > 0 {
+		filter["emp_id"] = bson.M{"$in": input.EmpIDs}
+	}
+
+	purged, err := purgeDeleted(ctx, filter, actorFromRequest(r))
+	if err != nil {
+		storeError(w, "purge", err)
+		return
+	}
+	_ = notify(ctx, actorFromRequest(r), "purge_finished", fmt.Sprintf("Trash purge finished: %d employee(s) permanently deleted", len(purged)), "/api/trash")
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(bson.M{"message": "Trash purged successfully", "purged_count": len(purged), "purged": purged})
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// usersHandler handles GET (list) and POST (create {username, password, role}) on /api/admin/users
+func usersHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	ctx := r.Context()
+
+	switch r.Method {
+	case http.MethodGet:
+		cur, err := coll(ctx, "Users").Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "username", Value: 1}}))
+		if err != nil {
+			storeError(w, "find users", err)
+			return
+		}
+		defer cur.Close(ctx)
+		list := []User{}
+		if err := cur.All(ctx, &list); err != nil {
+			storeError(w, "cursor all", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(list)
+	case http.MethodPost:
+		var input struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+			Role     string `json:"role"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			httpError(w, "invalid input: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if input.Role == "" {
+			input.Role = "viewer"
+		}
+		if input.Username == "" || strings.Contains(input.Username, "/") || len(input.Password) < 8 {
+			httpError(w, "username is required and password must be at least 8 characters", http.StatusUnprocessableEntity)
+			return
+		}
+		if _, ok := roleRank[input.Role]; !ok {
+			httpError(w, "role must be one of viewer, editor, admin", http.StatusUnprocessableEntity)
+			return
+		}
+		u, err := createUser(ctx, input.Username, input.Password, input.Role)
+		if err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				httpError(w, "user already exists: "+input.Username, http.StatusConflict)
+				return
+			}
+			storeError(w, "insert user", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(u)
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// userByNameHandler handles PUT ({role} and/or {password}) and DELETE on /api/admin/users/{username}.
+// Admins cannot demote or delete themselves, so there is always someone left to manage roles.
+func userByNameHandler(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/api/admin/users/")
+	if name == "" {
+		httpError(w, "username required in path", http.StatusBadRequest)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	self := name == actorFromRequest(r)
+	ctx := r.Context()
+
+	switch r.Method {
+	case http.MethodPut:
+		var input struct {
+			Role     string `json:"role"`
+			Password string `json:"password"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			httpError(w, "invalid input: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		set := bson.M{}
+		if input.Role != "" {
+			if _, ok := roleRank[input.Role]; !ok {
+				httpError(w, "role must be one of viewer, editor, admin", http.StatusUnprocessableEntity)
+				return
+			}
+			if self && input.Role != "admin" {
+				httpError(w, "cannot remove your own admin role", http.StatusConflict)
+				return
+			}
+			set["role"] = input.Role
+		}
+		if input.Password != "" {
+			if len(input.Password) < 8 {
+				httpError(w, "password must be at least 8 characters", http.StatusUnprocessableEntity)
+				return
+			}
+			hash, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
+			if err != nil {
+				httpError(w, "hash password: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			set["password_hash"] = string(hash)
+		}
+		if len(set) == 0 {
+			httpError(w, "nothing to update", http.StatusUnprocessableEntity)
+			return
+		}
+		var u User
+		err := coll(ctx, "Users").FindOneAndUpdate(ctx, bson.M{"username": name}, bson.M{"$set": set},
+			options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&u)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				httpError(w, "user not found", http.StatusNotFound)
+				return
+			}
+			storeError(w, "update user", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(u)
+	case http.MethodDelete:
+		if self {
+			httpError(w, "cannot delete your own account", http.StatusConflict)
+			return
+		}
+		res, err := coll(ctx, "Users").DeleteOne(ctx, bson.M{"username": name})
+		if err != nil {
+			storeError(w, "delete user", err)
+			return
+		}
+		if res.DeletedCount == 0 {
+			httpError(w, "user not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(bson.M{"message": "User deleted successfully"})
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/karthikeyan-meenachisundaram/goBack/models"
+)
+
+const (
+	maxNameLength       = 100
+	maxDepartmentLength = 64
+	maxLanguageLength   = 32
+	maxLanguages        = 20
+)
+
+var (
+	// departmentPattern allows names like "R&D", "Sales - EMEA" or "Ops/Infra"
+	departmentPattern = regexp.MustCompile(`^[\p{L}\p{N}][\p{L}\p{N} &.,'/()-]*$`)
+	// languagePattern allows names like "Go", "C++", "C#" or "Objective-C"
+	languagePattern = regexp.MustCompile(`^[\p{L}\p{N}][\p{L}\p{N} +#./-]*$`)
+)
+
+// checkValue validates one free-text value against a length and pattern; which values
+// are known is up to the reference lists (see checkReference)
+func checkValue(v string, max int, pattern *regexp.Regexp) string {
+	switch {
+	case utf8.RuneCountInString(v) > max:
+		return fmt.Sprintf("must be at most %d characters", max)
+	case !pattern.MatchString(v):
+		return "contains invalid characters"
+	}
+	return ""
+}
+
+// validatePayload trims the payload and returns per-field errors (nil when valid).
+// Department and languages must be on the ref lists (see checkReference).
+// On create emp_name and department are required; on update only given fields are checked.
+func validatePayload(p *models.EmployeePayload, ref referenceData, creating bool) map[string]string {
+	errs := map[string]string{}
+	switch {
+	case p.EmpId < 0:
+		errs["emp_id"] = "must be a positive number"
+	case p.EmpId > models.MaxEmpID:
+		errs["emp_id"] = fmt.Sprintf("must be at most %d", models.MaxEmpID)
+	}
+
+	if p.ManagerID != nil {
+		switch {
+		case *p.ManagerID < 0:
+			errs["manager_id"] = "must be a positive number"
+		case *p.ManagerID > models.MaxEmpID:
+			errs["manager_id"] = fmt.Sprintf("must be at most %d", models.MaxEmpID)
+		case *p.ManagerID != 0 && *p.ManagerID == p.EmpId:
+			errs["manager_id"] = "an employee cannot be their own manager"
+		}
+	}
+
+	if p.EmpName != nil {
+		name := strings.TrimSpace(*p.EmpName)
+		p.EmpName = &name
+	}
+	switch {
+	case p.EmpName == nil || *p.EmpName == "":
+		if creating || p.EmpName != nil {
+			errs["emp_name"] = "is required"
+		}
+	case utf8.RuneCountInString(*p.EmpName) > maxNameLength:
+		errs["emp_name"] = fmt.Sprintf("must be at most %d characters", maxNameLength)
+	}
+
+	if p.Department != nil {
+		d := strings.TrimSpace(*p.Department)
+		p.Department = &d
+	}
+	switch {
+	case p.Department == nil || *p.Department == "":
+		if creating || p.Department != nil {
+			errs["department"] = "is required"
+		}
+	default:
+		if msg := checkValue(*p.Department, maxDepartmentLength, departmentPattern); msg != "" {
+			errs["department"] = msg
+		} else if msg := checkReference(ref.departments, "department", *p.Department, creating); msg != "" {
+			errs["department"] = msg
+		}
+	}
+
+	if len(p.Languages) > maxLanguages {
+		errs["languages"] = fmt.Sprintf("at most %d languages", maxLanguages)
+	} else {
+		seen := map[string]bool{}
+		for i, l := range p.Languages {
+			l = strings.TrimSpace(l)
+			p.Languages[i] = l
+			field := fmt.Sprintf("languages[%d]", i)
+			if l == "" {
+				errs[field] = "must not be blank"
+				continue
+			}
+			if seen[strings.ToLower(l)] {
+				errs[field] = "is listed twice"
+				continue
+			}
+			seen[strings.ToLower(l)] = true
+			if msg := checkValue(l, maxLanguageLength, languagePattern); msg != "" {
+				errs[field] = msg
+			} else if msg := checkReference(ref.languages, "language", l, creating); msg != "" {
+				errs[field] = msg
+			}
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// mergeFieldErrors adds more to errs with its field names prefixed
+func mergeFieldErrors(errs map[string]string, prefix string, more map[string]string) map[string]string {
+	if len(more) == 0 {
+		return errs
+	}
+	if errs == nil {
+		errs = map[string]string{}
+	}
+	for f, msg := range more {
+		errs[prefix+f] = msg
+	}
+	return errs
+}
+
+// writeValidationErrors answers 422 with per-field messages in the error details
+func writeValidationErrors(w http.ResponseWriter, errs map[string]string) {
+	writeError(w, http.StatusUnprocessableEntity, "validation failed", errs)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// apiVersions mounts the API under /api/v1 and /api/v2. The prefix is stripped so every
+// middleware and the mux keep seeing /api/...; the version goes into the request
+// context. Unversioned /api/... is the deprecated alias of /api/v1 that the existing
+// client still uses: it keeps working and is marked with Deprecation and Link headers.
+// Both versions share the handlers; where v2 differs they branch on apiVersion.
+func apiVersions(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+		if !strings.HasPrefix(path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		rest := strings.TrimPrefix(path, "/api/")
+		version := 0
+		switch {
+		case strings.HasPrefix(rest, "v1/"):
+			version = 1
+		case strings.HasPrefix(rest, "v2/"):
+			version = 2
+		}
+		if version == 0 {
+			w.Header().Set("Deprecation", "true")
+			w.Header().Set("Link", `</api/v1/`+rest+`>; rel="successor-version"`)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		u := *r.URL
+		u.Path = "/api/" + rest[len("v1/"):]
+		u.RawPath = ""
+		r2 := r.WithContext(context.WithValue(r.Context(), apiVersionKey, version))
+		r2.URL = &u
+		next.ServeHTTP(w, r2)
+	})
+}
@@ -0,0 +1,463 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/karthikeyan-meenachisundaram/goBack/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// webhookEvents are the employee events a webhook can subscribe to
+var webhookEvents = []string{"created", "updated", "deleted"}
+
+// Webhook is a subscription: the chosen employee events are POSTed to URL, signed with
+// Secret. Format "slack" posts a Slack incoming-webhook message instead of the event.
+type Webhook struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	URL          string             `bson:"url" json:"url"`
+	Events       []string           `bson:"events" json:"events"`
+	Format       string             `bson:"format" json:"format"` // json, slack
+	Description  string             `bson:"description,omitempty" json:"description,omitempty"`
+	Active       bool               `bson:"active" json:"active"`
+	Secret       string             `bson:"secret" json:"secret,omitempty"` // only returned when set
+	CreatedBy    string             `bson:"created_by" json:"created_by"`
+	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
+	LastDelivery *WebhookDelivery   `bson:"last_delivery,omitempty" json:"last_delivery,omitempty"`
+}
+
+// WebhookDelivery is the outcome of the latest attempt to deliver an event to a webhook
+type WebhookDelivery struct {
+	EventID   string    `bson:"event_id" json:"event_id"`
+	At        time.Time `bson:"at" json:"at"`
+	Attempt   int       `bson:"attempt" json:"attempt"`
+	Status    int       `bson:"status,omitempty" json:"status,omitempty"` // HTTP status the endpoint answered
+	Error     string    `bson:"error,omitempty" json:"error,omitempty"`
+	Delivered bool      `bson:"delivered" json:"delivered"`
+	GaveUp    bool      `bson:"gave_up,omitempty" json:"gave_up,omitempty"` // no more retries
+}
+
+// WebhookEvent is the body of a json webhook. ID is the same on every retry, so
+// receivers can drop duplicates.
+type WebhookEvent struct {
+	ID       string          `json:"id"`
+	Type     string          `json:"type"` // employee.created, employee.updated, employee.deleted
+	At       time.Time       `json:"at"`
+	EmpID    int             `json:"emp_id"`
+	Employee json.RawMessage `json:"employee,omitempty"` // as GET /api/employees/{id} shows it to non-admins; absent once deleted
+}
+
+// WebhookInput is the body of POST and PUT on /api/admin/webhooks; nil fields keep
+// their value on PUT
+type WebhookInput struct {
+	URL          *string  `json:"url"`
+	Events       []string `json:"events"`
+	Format       *string  `json:"format"`
+	Description  *string  `json:"description"`
+	Active       *bool    `json:"active"`
+	Secret       *string  `json:"secret"`        // chosen by the caller; generated when empty on create
+	RotateSecret bool     `json:"rotate_secret"` // PUT: replace the secret with a generated one
+}
+
+// webhookClient sends the deliveries; redirects are not followed, a moved endpoint is
+// reported as its 3xx
+var webhookClient = &http.Client{
+	CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+}
+
+// newWebhookSecret returns 32 random bytes as hex
+func newWebhookSecret() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// signWebhook is the X-Webhook-Signature of body sent at timestamp: the hex HMAC-SHA256
+// of "timestamp.body" keyed with the webhook's secret
+func signWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// validate checks a webhook input into w; creating requires a URL
+func (in WebhookInput) validate(w *Webhook, creating bool) map[string]string {
+	errs := map[string]string{}
+	if in.URL != nil || creating {
+		raw := ""
+		if in.URL != nil {
+			raw = strings.TrimSpace(*in.URL)
+		}
+		u, err := url.Parse(raw)
+		if raw == "" || err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs["url"] = "must be an http or https URL"
+		} else {
+			w.URL = raw
+		}
+	}
+	if in.Events != nil || creating {
+		events := []string{}
+		for _, e := range in.Events {
+			e = strings.ToLower(strings.TrimSpace(e))
+			if !slices.Contains(webhookEvents, e) {
+				errs["events"] = "must be created, updated or deleted"
+				break
+			}
+			if !slices.Contains(events, e) {
+				events = append(events, e)
+			}
+		}
+		if len(events) == 0 {
+			events = webhookEvents // none listed means all of them
+		}
+		w.Events = events
+	}
+	if in.Format != nil || creating {
+		format := "json"
+		if in.Format != nil && *in.Format != "" {
+			format = *in.Format
+		}
+		if format != "json" && format != "slack" {
+			errs["format"] = "must be json or slack"
+		}
+		w.Format = format
+	}
+	if in.Description != nil {
+		w.Description = strings.TrimSpace(*in.Description)
+	}
+	if in.Active != nil {
+		w.Active = *in.Active
+	} else if creating {
+		w.Active = true
+	}
+	switch {
+	case in.Secret != nil && *in.Secret != "":
+		if len(*in.Secret) < 16 {
+			errs["secret"] = "must be at least 16 characters"
+		}
+		w.Secret = *in.Secret
+	case creating || in.RotateSecret:
+		w.Secret = newWebhookSecret()
+	}
+	return errs
+}
+
+// webhookPayload renders the body a webhook gets for e
+func webhookPayload(hook Webhook, e WebhookEvent, name string) ([]byte, error) {
+	if hook.Format != "slack" {
+		return json.Marshal(e)
+	}
+	who := fmt.Sprintf("Employee %d", e.EmpID)
+	if name != "" {
+		who += " (" + name + ")"
+	}
+	return json.Marshal(map[string]string{"text": who + " was " + e.Type[len("employee."):]})
+}
+
+// startWebhooks queues employee events for the subscribed webhooks until ctx ends; the
+// job workers deliver them. Events come from the change stream (see startEventWatcher),
+// so every instance sees every change. An event's id comes from the employee's version
+// (or deletion time), so instances queueing the same event create one job for it;
+// webhooks.deliver turns queueing off on an instance altogether.
+func startWebhooks(ctx context.Context) {
+	if !cfg.Webhooks.Deliver {
+		slog.Info("webhooks: delivery is off on this instance")
+		return
+	}
+	events, _ := subscribeEvents(0)
+	go func() {
+		defer unsubscribeEvents(events)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case e := <-events:
+				// looking up webhooks and the employee must not hold up the event stream
+				go dispatchWebhooks(ctx, e)
+			}
+		}
+	}()
+}
+
+// dispatchWebhooks queues a webhook job for e for every active webhook of its organization
+// subscribed to its type
+func dispatchWebhooks(ctx context.Context, e EmployeeEvent) {
+	ctx = withOrg(ctx, e.Org)
+	lookupCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	cur, err := coll(lookupCtx, "Webhooks").Find(lookupCtx, bson.M{"active": true, "events": e.Type})
+	if err != nil {
+		slog.Error("webhooks: find webhooks", "err", err)
+		return
+	}
+	var hooks []Webhook
+	if err := cur.All(lookupCtx, &hooks); err != nil {
+		slog.Error("webhooks: find webhooks", "err", err)
+		return
+	}
+	if len(hooks) == 0 {
+		return
+	}
+
+	event := WebhookEvent{Type: "employee." + e.Type, At: e.At, EmpID: e.EmpID}
+	var name string
+	if e.Type == "deleted" {
+		var emp struct {
+			DeletedAt time.Time `bson:"deleted_at"`
+		}
+		err := coll(lookupCtx, "Employee").FindOne(lookupCtx, bson.M{"emp_id": e.EmpID}).Decode(&emp)
+		if err != nil && err != mongo.ErrNoDocuments {
+			slog.Error("webhooks: find employee", "emp_id", e.EmpID, "err", err)
+			return
+		}
+		event.ID = fmt.Sprintf("emp-%d-deleted-%d", e.EmpID, emp.DeletedAt.UnixMilli())
+	} else {
+		defs, err := loadCustomFields(lookupCtx)
+		if err != nil {
+			slog.Error("webhooks: find custom fields", "err", err)
+			return
+		}
+		raw, err := employees.Get(lookupCtx, e.EmpID, hiddenCustomFields(defs, false))
+		if errors.Is(err, models.ErrNotFound) {
+			return // deleted again before the event went out; its own event follows
+		}
+		if err != nil {
+			slog.Error("webhooks: find employee", "emp_id", e.EmpID, "err", err)
+			return
+		}
+		var emp EmployeeDetails
+		if err := raw.Decode(&emp); err != nil {
+			slog.Error("webhooks: decode employee", "emp_id", e.EmpID, "err", err)
+			return
+		}
+		if event.Employee, err = json.Marshal(emp); err != nil {
+			slog.Error("webhooks: encode employee", "emp_id", e.EmpID, "err", err)
+			return
+		}
+		name, _ = emp.EmpName.(string)
+		event.ID = fmt.Sprintf("emp-%d-%s-v%d", e.EmpID, e.Type, raw.Version())
+	}
+
+	for _, hook := range hooks {
+		body, err := webhookPayload(hook, event, name)
+		if err != nil {
+			slog.Error("webhooks: encode event", "webhook", hook.ID.Hex(), "err", err)
+			continue
+		}
+		payload := bson.M{"webhook_id": hook.ID, "event_id": event.ID, "body": string(body)}
+		if err := enqueueJob(ctx, "webhook", "webhook:"+hook.ID.Hex()+":"+event.ID, payload, time.Now()); err != nil {
+			slog.Error("webhooks: queue delivery", "webhook", hook.ID.Hex(), "event_id", event.ID, "err", err)
+		}
+	}
+}
+
+// runWebhookJob makes one delivery attempt and records it on the webhook. Unreachable
+// endpoints and 408, 429 or 5xx answers are retried by the job queue; other answers
+// are final. Jobs of webhooks deleted or deactivated meanwhile are dropped.
+func runWebhookJob(ctx context.Context, j Job) (string, error) {
+	var p struct {
+		WebhookID primitive.ObjectID `bson:"webhook_id"`
+		EventID   string             `bson:"event_id"`
+		Body      string             `bson:"body"`
+	}
+	if b, err := bson.Marshal(j.Payload); err != nil || bson.Unmarshal(b, &p) != nil {
+		return "", permanent(fmt.Errorf("invalid webhook job payload"))
+	}
+	var hook Webhook
+	if err := coll(ctx, "Webhooks").FindOne(ctx, bson.M{"_id": p.WebhookID}).Decode(&hook); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return "webhook was deleted", nil
+		}
+		return "", err
+	}
+	if !hook.Active {
+		return "webhook is inactive", nil
+	}
+
+	status, err := postWebhook(ctx, hook, p.EventID, []byte(p.Body))
+	result := WebhookDelivery{EventID: p.EventID, At: time.Now().UTC(), Attempt: j.Attempts, Status: status}
+	switch {
+	case err != nil:
+	case status >= 200 && status < 300:
+		result.Delivered = true
+	default:
+		err = fmt.Errorf("endpoint answered %d %s", status, http.StatusText(status))
+		if status != http.StatusRequestTimeout && status != http.StatusTooManyRequests && status < 500 {
+			err = permanent(err)
+		}
+	}
+	if err != nil {
+		result.Error = err.Error()
+		result.GaveUp = errors.As(err, new(permanentError)) || j.Attempts >= j.MaxAttempts
+	}
+	if _, serr := coll(ctx, "Webhooks").UpdateOne(ctx, bson.M{"_id": hook.ID}, bson.M{"$set": bson.M{"last_delivery": result}}); serr != nil {
+		slog.Error("webhooks: record delivery", "webhook", hook.ID.Hex(), "err", serr)
+	}
+	if err != nil {
+		return "", err
+	}
+	return "delivered, status " + strconv.Itoa(status), nil
+}
+
+// postWebhook sends body to hook once and returns the status the endpoint answered
+func postWebhook(ctx context.Context, hook Webhook, eventID string, body []byte) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(cfg.Webhooks.Timeout))
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "goBack-webhooks/1")
+	req.Header.Set("X-Webhook-Id", eventID)
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", signWebhook(hook.Secret, timestamp, body))
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	return resp.StatusCode, nil
+}
+
+// ---------------- Handlers ----------------
+
+// webhooksHandler handles GET (list) and POST (subscribe) on /api/admin/webhooks (admin).
+// The secret is only in the response to the create.
+func webhooksHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	ctx := r.Context()
+
+	switch r.Method {
+	case http.MethodGet:
+		cur, err := coll(ctx, "Webhooks").Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+		if err != nil {
+			storeError(w, "find webhooks", err)
+			return
+		}
+		defer cur.Close(ctx)
+		list := []Webhook{}
+		if err := cur.All(ctx, &list); err != nil {
+			storeError(w, "cursor all", err)
+			return
+		}
+		for i := range list {
+			list[i].Secret = ""
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(list)
+	case http.MethodPost:
+		var input WebhookInput
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			httpError(w, "invalid input: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		hook := Webhook{CreatedBy: actorFromRequest(r), CreatedAt: time.Now().UTC()}
+		if errs := input.validate(&hook, true); len(errs) > 0 {
+			writeError(w, http.StatusUnprocessableEntity, "validation failed", errs)
+			return
+		}
+		res, err := coll(ctx, "Webhooks").InsertOne(ctx, hook)
+		if err != nil {
+			storeError(w, "insert webhook", err)
+			return
+		}
+		hook.ID, _ = res.InsertedID.(primitive.ObjectID)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(hook)
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// webhookByIDHandler handles GET, PUT and DELETE on /api/admin/webhooks/{id} (admin). A PUT
+// that sets or rotates the secret returns it.
+func webhookByIDHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	id, err := primitive.ObjectIDFromHex(strings.TrimPrefix(r.URL.Path, "/api/admin/webhooks/"))
+	if err != nil {
+		httpError(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	ctx := r.Context()
+
+	var hook Webhook
+	if err := coll(ctx, "Webhooks").FindOne(ctx, bson.M{"_id": id}).Decode(&hook); err != nil {
+		if err == mongo.ErrNoDocuments {
+			httpError(w, "webhook not found", http.StatusNotFound)
+			return
+		}
+		storeError(w, "find webhook", err)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		hook.Secret = ""
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(hook)
+	case http.MethodPut:
+		var input WebhookInput
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			httpError(w, "invalid input: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		secret := hook.Secret
+		if errs := input.validate(&hook, false); len(errs) > 0 {
+			writeError(w, http.StatusUnprocessableEntity, "validation failed", errs)
+			return
+		}
+		_, err := coll(ctx, "Webhooks").UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{
+			"url":         hook.URL,
+			"events":      hook.Events,
+			"format":      hook.Format,
+			"description": hook.Description,
+			"active":      hook.Active,
+			"secret":      hook.Secret,
+		}})
+		if err != nil {
+			storeError(w, "update webhook", err)
+			return
+		}
+		if hook.Secret == secret {
+			hook.Secret = ""
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(hook)
+	case http.MethodDelete:
+		if _, err := coll(ctx, "Webhooks").DeleteOne(ctx, bson.M{"_id": id}); err != nil {
+			storeError(w, "delete webhook", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(bson.M{"message": "Webhook deleted successfully"})
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
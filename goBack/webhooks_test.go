@@ -0,0 +1,55 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSignWebhook(t *testing.T) {
+	body := []byte(`{"event":"employee.created","emp_id":1}`)
+	// printf '%s' '1700000000.{"event":"employee.created","emp_id":1}' | openssl dgst -sha256 -hmac whsec_test
+	want := "sha256=57e6a1765b8b98ba0f81a8380d638cb1cdf9170a5dcf280bb23e6645dff571ee"
+	if got := signWebhook("whsec_test", "1700000000", body); got != want {
+		t.Errorf("signature = %s, want %s", got, want)
+	}
+	// the timestamp is signed too, so a captured delivery can't be replayed as a newer one
+	if got := signWebhook("whsec_test", "1700000001", body); got == want {
+		t.Error("signature ignores the timestamp")
+	}
+	if got := signWebhook("other", "1700000000", body); got == want {
+		t.Error("signature ignores the secret")
+	}
+}
+
+func TestPostWebhook(t *testing.T) {
+	useTestStores(t)
+	body := []byte(`{"event":"employee.created","emp_id":1}`)
+	var got *http.Request
+	var gotBody []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	status, err := postWebhook(t.Context(), Webhook{URL: srv.URL, Secret: "whsec_test"}, "evt-1", body)
+	if err != nil || status != http.StatusAccepted {
+		t.Fatalf("status = %d, err = %v", status, err)
+	}
+	ts := got.Header.Get("X-Webhook-Timestamp")
+	if sec, err := strconv.ParseInt(ts, 10, 64); err != nil || time.Since(time.Unix(sec, 0)) > time.Minute {
+		t.Errorf("X-Webhook-Timestamp = %q", ts)
+	}
+	// what a receiver checks: the signature of the timestamp and body it got
+	if sig := got.Header.Get("X-Webhook-Signature"); sig != signWebhook("whsec_test", ts, gotBody) || string(gotBody) != string(body) {
+		t.Errorf("X-Webhook-Signature = %s for %s", sig, gotBody)
+	}
+	if id := got.Header.Get("X-Webhook-Id"); id != "evt-1" {
+		t.Errorf("X-Webhook-Id = %q", id)
+	}
+}
@@ -0,0 +1,84 @@
+import axios from 'axios';
+import { ref } from 'vue';
+import router from './router';
+
+// Tokens from /api/auth/login, kept across reloads
+const ACCESS_KEY = 'access_token';
+const REFRESH_KEY = 'refresh_token';
+const USER_KEY = 'username';
+
+// The signed-in user's name, empty when signed out
+export const currentUser = ref(localStorage.getItem(USER_KEY) || '');
+
+function saveTokens(data, username) {
+  localStorage.setItem(ACCESS_KEY, data.access_token);
+  localStorage.setItem(REFRESH_KEY, data.refresh_token);
+  if (username) {
+    localStorage.setItem(USER_KEY, username);
+    currentUser.value = username;
+  }
+}
+
+function clearTokens() {
+  localStorage.removeItem(ACCESS_KEY);
+  localStorage.removeItem(REFRESH_KEY);
+  localStorage.removeItem(USER_KEY);
+  currentUser.value = '';
+}
+
+export async function login(username, password, org) {
+  const res = await axios.post('/api/auth/login', { username, password, org: org || undefined });
+  saveTokens(res.data, username);
+}
+
+export function logout() {
+  clearTokens();
+  router.push('/login');
+}
+
+// Every API call carries the access token
+axios.interceptors.request.use(config => {
+  const token = localStorage.getItem(ACCESS_KEY);
+  if (token && !config.url.startsWith('/api/auth/')) {
+    config.headers.Authorization = `Bearer ${token}`;
+  }
+  return config;
+});
+
+// Concurrent 401s share one refresh
+let refreshing = null;
+
+function refresh() {
+  if (!refreshing) {
+    const refreshToken = localStorage.getItem(REFRESH_KEY);
+    refreshing = (refreshToken
+      ? axios.post('/api/auth/refresh', { refresh_token: refreshToken }).then(res => saveTokens(res.data))
+      : Promise.reject(new Error('not signed in'))
+    ).finally(() => { refreshing = null; });
+  }
+  return refreshing;
+}
+
+// An expired access token is refreshed and the call retried once; without a valid
+// refresh token it's off to the login page, back here afterwards
+axios.interceptors.response.use(undefined, async err => {
+  const config = err.config;
+  if (err.response?.status !== 401 || !config || config.url.startsWith('/api/auth/')) {
+    throw err;
+  }
+  if (!config.retried) {
+    config.retried = true;
+    try {
+      await refresh();
+      return axios(config);
+    } catch {
+      // fall through to the login page
+    }
+  }
+  clearTokens();
+  const current = router.currentRoute.value;
+  if (current.path !== '/login') {
+    router.push({ path: '/login', query: { redirect: current.fullPath } });
+  }
+  throw err;
+});
@@ -2,6 +2,7 @@ import { createApp } from 'vue'
 import './style.css'
 import App from './App.vue'
 import router from './router';
+import './auth'; // attaches the access token to API calls
 import '@fortawesome/fontawesome-free/css/all.css';
 
 createApp(App).use(router).mount('#app');
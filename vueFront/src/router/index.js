@@ -3,12 +3,14 @@ import Home from '../Home.vue';
 import EmployeeDetails from '../EmployeeDetails.vue';
 import CreateEmployee from '../CreateEmployee.vue';
 import UpdateEmployee from '../UpdateEmployee.vue';
+import Login from '../Login.vue';
 
 const routes = [
   { path: '/', component: Home },
   { path: '/list', component: EmployeeDetails },
   { path: '/create', component: CreateEmployee },
   { path: '/update/:id', component: UpdateEmployee }, 
+  { path: '/login', component: Login },
 ];
 
 const router = createRouter({
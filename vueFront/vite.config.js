@@ -3,6 +3,11 @@ import vue from '@vitejs/plugin-vue'
 
 export default defineConfig({
   plugins: [vue()],
+  build: {
+    // embedded into the Go binary (goBack/spa.go)
+    outDir: '../goBack/frontend/dist',
+    emptyOutDir: true
+  },
   server: {
     proxy: {
       '/api': 'http://172.31.24.94:8080' // Forward /api calls to backend